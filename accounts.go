@@ -0,0 +1,109 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// MultiCredentials is an optional interface that a SecretSetter implements
+// when one secret has multiple database accounts, like app_rw, app_ro, and
+// migrator. Rotator uses AllCredentials instead of Credentials, and all accounts
+// are set, verified, and rolled back as one unit (see db.NewPassword.Accounts).
+//
+// AllCredentials must return the accounts in the same order for every secret
+// value because Rotator pairs the current and new accounts by index.
+// The Credentials method should return the first account.
+type MultiCredentials interface {
+	AllCredentials(secret map[string]string) []db.Credentials
+}
+
+// MultiRandomPassword is a SecretSetter for secrets with multiple database
+// accounts. Each account is a pair of fields: <prefix>username and <prefix>password
+// for each prefix in Accounts. For example, with Accounts = []string{"", "ro_"}
+// the secret value is:
+//
+//	{
+//	  "username": "app_rw",
+//	  "password": "...",
+//	  "ro_username": "app_ro",
+//	  "ro_password": "..."
+//	}
+//
+// Rotate sets a new random password for every account. Passwords are generated
+// by the embedded RandomPassword, so configure it the same way. Like RandomPassword,
+// it does not support Handler (user-invoked password rotation).
+type MultiRandomPassword struct {
+	RandomPassword
+
+	// Accounts are the field name prefixes of the accounts in the secret.
+	// The first account is the primary account returned by Credentials.
+	// If empty, there is one account with no prefix, like RandomPassword.
+	Accounts []string
+}
+
+var _ SecretSetter = MultiRandomPassword{}
+var _ MultiCredentials = MultiRandomPassword{}
+
+func (s MultiRandomPassword) Handler(context.Context, map[string]string) (map[string]string, error) {
+	return nil, errors.New("MultiRandomPassword does not support user-invoked password rotation")
+}
+
+func (s MultiRandomPassword) Rotate(secret map[string]string) error {
+	for _, prefix := range s.accounts() {
+		// RandomPassword rotates "username" and "password", so rotate a copy
+		// of one account, then copy the new password back
+		account := map[string]string{
+			"username": secret[prefix+"username"],
+			"password": secret[prefix+"password"],
+		}
+		if err := s.RandomPassword.Rotate(account); err != nil {
+			return err
+		}
+		secret[prefix+"password"] = account["password"]
+	}
+	return nil
+}
+
+func (s MultiRandomPassword) Credentials(secret map[string]string) (username, password string) {
+	prefix := s.accounts()[0]
+	return secret[prefix+"username"], secret[prefix+"password"]
+}
+
+func (s MultiRandomPassword) DBCredentials(secret map[string]string) db.Credentials {
+	return s.AllCredentials(secret)[0]
+}
+
+func (s MultiRandomPassword) AllCredentials(secret map[string]string) []db.Credentials {
+	accounts := s.accounts()
+	creds := []db.Credentials{}
+	for i, prefix := range accounts {
+		c := ParseCredentials(prefix, secret)
+		// Keys of other accounts match the prefix of this account if it's
+		// shorter (usually no prefix), so remove them from Extra
+		for k := range c.Extra {
+			for j, other := range accounts {
+				if j != i && len(other) > len(prefix) && strings.HasPrefix(prefix+k, other) {
+					delete(c.Extra, k)
+					break
+				}
+			}
+		}
+		if len(c.Extra) == 0 {
+			c.Extra = nil
+		}
+		creds = append(creds, c)
+	}
+	return creds
+}
+
+func (s MultiRandomPassword) accounts() []string {
+	if len(s.Accounts) == 0 {
+		return []string{""}
+	}
+	return s.Accounts
+}
@@ -0,0 +1,107 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestMultiRandomPassword(t *testing.T) {
+	ss := rotate.MultiRandomPassword{
+		Accounts: []string{"", "ro_"},
+	}
+	secret := map[string]string{
+		"username":    "app_rw",
+		"password":    "rw_old",
+		"ro_username": "app_ro",
+		"ro_password": "ro_old",
+		"host":        "db.local",
+	}
+	if err := ss.Rotate(secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret["password"] == "rw_old" || secret["ro_password"] == "ro_old" {
+		t.Errorf("not all passwords rotated: %v", secret)
+	}
+	if secret["username"] != "app_rw" || secret["ro_username"] != "app_ro" || secret["host"] != "db.local" {
+		t.Errorf("other fields changed: %v", secret)
+	}
+	if len(secret["password"]) != rotate.DEFAULT_PASSWORD_LENGTH || len(secret["ro_password"]) != rotate.DEFAULT_PASSWORD_LENGTH {
+		t.Errorf("expected passwords with %d characters: %v", rotate.DEFAULT_PASSWORD_LENGTH, secret)
+	}
+}
+
+func TestStepSetSecretMultiAccount(t *testing.T) {
+	// Test that the "setSecret" step passes all accounts to the PasswordSetter
+	// when the SecretSetter implements MultiCredentials
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: aws.String(`{"username":"app_rw","password":"rw1","ro_username":"app_ro","ro_password":"ro1"}`),
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: aws.String(`{"username":"app_rw","password":"rw2","ro_username":"app_ro","ro_password":"ro2"}`),
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+	}
+
+	var gotCreds db.NewPassword
+	ps := test.MockPasswordSetter{
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.New.Password == "rw2" {
+				return fmt.Errorf("not set yet")
+			}
+			return nil
+		},
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotCreds = creds
+			return nil
+		},
+	}
+
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter:   rotate.MultiRandomPassword{Accounts: []string{"", "ro_"}},
+		PasswordSetter: ps,
+	})
+
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Error(err)
+	}
+
+	expectCreds := db.NewPassword{
+		Current: db.Credentials{Username: "app_rw", Password: "rw1"},
+		New:     db.Credentials{Username: "app_rw", Password: "rw2"},
+		Accounts: []db.NewPassword{
+			{
+				Current: db.Credentials{Username: "app_ro", Password: "ro1"},
+				New:     db.Credentials{Username: "app_ro", Password: "ro2"},
+			},
+		},
+		Changed: []string{"password", "ro_password"},
+	}
+	if diff := deep.Equal(gotCreds, expectCreds); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"log"
+)
+
+// DEFAULT_ASYNC_QUEUE_SIZE is the AsyncReceiver queue size if none is given.
+// A rotation sends fewer than 20 events, so the queue should never be full.
+const DEFAULT_ASYNC_QUEUE_SIZE = 100
+
+// AsyncReceiver is an EventReceiver that queues events and delivers them to
+// another EventReceiver on a goroutine, so a slow receiver (like an HTTP webhook)
+// does not block the rotation and extend the password downtime. Events are
+// delivered in order. If the queue is full, Receive blocks until there is room.
+//
+// Rotator flushes the queue before Handler returns, waiting until Config.DeadlineReserve
+// before the Lambda deadline, so all events are delivered before Lambda freezes
+// the function. Create an AsyncReceiver by calling NewAsyncReceiver.
+//
+// If the other EventReceiver is a VetoEventReceiver, ReceiveVeto is not async:
+// it flushes the queue, then calls the other receiver and returns its error.
+type AsyncReceiver struct {
+	r     EventReceiver
+	queue chan asyncEvent
+}
+
+var _ VetoEventReceiver = &AsyncReceiver{}
+
+type asyncEvent struct {
+	e       Event
+	flushed chan struct{} // non-nil for a flush, not an event
+}
+
+// NewAsyncReceiver creates a new AsyncReceiver that delivers events to r. If
+// queueSize is zero, DEFAULT_ASYNC_QUEUE_SIZE is used.
+func NewAsyncReceiver(r EventReceiver, queueSize uint) *AsyncReceiver {
+	if queueSize == 0 {
+		queueSize = DEFAULT_ASYNC_QUEUE_SIZE
+	}
+	a := &AsyncReceiver{
+		r:     r,
+		queue: make(chan asyncEvent, queueSize),
+	}
+	go a.deliver()
+	return a
+}
+
+// Receive queues the event and returns immediately unless the queue is full.
+func (a *AsyncReceiver) Receive(e Event) {
+	a.queue <- asyncEvent{e: e}
+}
+
+// ReceiveVeto flushes the queue, then calls the other receiver synchronously.
+func (a *AsyncReceiver) ReceiveVeto(e Event) error {
+	v, ok := a.r.(VetoEventReceiver)
+	if !ok {
+		a.Receive(e)
+		return nil
+	}
+	a.Flush(context.Background())
+	return v.ReceiveVeto(e)
+}
+
+// Flush waits until all queued events are delivered or ctx is done. It returns
+// ctx.Err() if ctx is done first; the queued events are still delivered later.
+func (a *AsyncReceiver) Flush(ctx context.Context) error {
+	flushed := make(chan struct{})
+	select {
+	case a.queue <- asyncEvent{flushed: flushed}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *AsyncReceiver) deliver() {
+	for ae := range a.queue {
+		if ae.flushed != nil {
+			close(ae.flushed)
+			continue
+		}
+		a.r.Receive(ae.e)
+	}
+}
+
+// --------------------------------------------------------------------------
+
+// flushEvents flushes the EventReceiver if it's an AsyncReceiver or another
+// receiver with a Flush method. It's called before Handler returns.
+func (r *Rotator) flushEvents(ctx context.Context) {
+	f, ok := r.event.r.(interface{ Flush(context.Context) error })
+	if !ok {
+		return
+	}
+	bctx, cancel := r.budget(ctx)
+	defer cancel()
+	if err := f.Flush(bctx); err != nil {
+		log.Printf("ERROR: events not flushed: %s", err)
+	}
+}
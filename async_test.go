@@ -0,0 +1,98 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestAsyncReceiver(t *testing.T) {
+	// Test that a slow receiver does not block the step, and Handler flushes
+	// all events before returning
+	var mux sync.Mutex
+	var got []string
+	slow := eventRecorder(func(e rotate.Event) {
+		time.Sleep(20 * time.Millisecond)
+		mux.Lock()
+		got = append(got, e.Name)
+		mux.Unlock()
+	})
+	async := rotate.NewAsyncReceiver(slow, 0)
+
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+	}
+	var stepTime time.Duration
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{},
+		EventReceiver:  async,
+	})
+	r.Use(func(next rotate.StepFunc) rotate.StepFunc {
+		return func(ctx context.Context, step string, event map[string]string) error {
+			t0 := time.Now()
+			err := next(ctx, step, event)
+			stepTime = time.Since(t0)
+			return err
+		}
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "testSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if stepTime >= 40*time.Millisecond {
+		t.Errorf("step took %s, expected less than 40ms (2 slow events)", stepTime)
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	expect := []string{
+		rotate.EVENT_BEGIN_PASSWORD_VERIFICATION,
+		rotate.EVENT_END_PASSWORD_VERIFICATION,
+		rotate.EVENT_END_STEP,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestAsyncReceiverFlushTimeout(t *testing.T) {
+	block := make(chan bool)
+	async := rotate.NewAsyncReceiver(eventRecorder(func(e rotate.Event) { <-block }), 1)
+	async.Receive(rotate.Event{Name: rotate.EVENT_BEGIN_ROTATION})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := async.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, expected context.DeadlineExceeded", err)
+	}
+	close(block)
+	if err := async.Flush(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// Audit outcomes of a rotation.
+const (
+	AUDIT_OUTCOME_SUCCESS = "success"
+	AUDIT_OUTCOME_FAILURE = "failure" // a step failed; it changes to success if a retry succeeds
+)
+
+// AuditStep is the result of one step in an audit record.
+type AuditStep struct {
+	Step     string    `dynamodbav:"Step" json:"step"`
+	Time     time.Time `dynamodbav:"Time" json:"time"`
+	Duration int64     `dynamodbav:"DurationMs" json:"durationMs"`
+	Error    string    `dynamodbav:"Error,omitempty" json:"error,omitempty"`
+}
+
+// auditRecord is the audit record of one step invocation. Start is set only
+// for createSecret, and End, Duration, and Downtime only for finishSecret.
+type auditRecord struct {
+	step     AuditStep
+	start    time.Time
+	end      time.Time
+	duration time.Duration
+	downtime time.Duration
+}
+
+// auditor accumulates the audit record of one Lambda invocation (one step).
+type auditor struct {
+	initiator string
+	// --
+	mux *sync.Mutex
+	rec auditRecord
+}
+
+func newAuditor(initiator string) auditor {
+	if initiator == "" {
+		initiator = defaultInitiator()
+	}
+	return auditor{
+		initiator: initiator,
+		mux:       &sync.Mutex{},
+	}
+}
+
+// record records the event. On EVENT_END_STEP, it returns the audit record
+// and true, and resets the record. Then the caller writes the audit record.
+func (a *auditor) record(e Event) (auditRecord, bool) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	switch e.Name {
+	case EVENT_BEGIN_ROTATION:
+		a.rec.start = e.Time
+	case EVENT_NEW_PASSWORD_IS_CURRENT:
+		a.rec.downtime = e.Duration
+	case EVENT_END_ROTATION:
+		a.rec.end = e.Time
+		a.rec.duration = e.Duration
+	case EVENT_END_STEP:
+		rec := a.rec
+		rec.step = AuditStep{
+			Step:     e.Step,
+			Time:     e.Time,
+			Duration: e.Duration.Milliseconds(),
+		}
+		if e.Error != nil {
+			rec.step.Error = e.Error.Error()
+		}
+		a.rec = auditRecord{}
+		return rec, true
+	}
+	return auditRecord{}, false
+}
+
+// defaultInitiator returns "lambda:<function name>" in Lambda, else the hostname.
+func defaultInitiator() string {
+	if fn := os.Getenv("AWS_LAMBDA_FUNCTION_NAME"); fn != "" {
+		return "lambda:" + fn
+	}
+	hostname, _ := os.Hostname()
+	return hostname
+}
+
+// --------------------------------------------------------------------------
+
+// DynamoDBAuditReceiver is an EventReceiver that keeps an audit record of every
+// rotation in a DynamoDB table, independent of CloudTrail log retention. The
+// table must have partition key "SecretId" (string) and sort key "VersionId"
+// (string), so there is one item per rotation. The item attributes are:
+//
+//	SecretId, VersionId  secret ID and new secret version ID (ClientRequestToken)
+//	Initiator            who ran the rotation (see NewDynamoDBAuditReceiver)
+//	StartTime            when createSecret began the rotation
+//	Steps                list of AuditStep, one per step invocation, including retries
+//	Outcome              AUDIT_OUTCOME_SUCCESS or AUDIT_OUTCOME_FAILURE
+//	EndTime, DurationMs  when the rotation completed, and total rotation time
+//	DowntimeMs           password downtime (see EVENT_NEW_PASSWORD_IS_CURRENT)
+//
+// Each step is appended to Steps at the end of the step (EVENT_END_STEP), and
+// items are never deleted, so the table is an append-only history. Restrict
+// the Lambda function to dynamodb:UpdateItem so it cannot delete items.
+// Errors are logged and ignored.
+//
+// Create a DynamoDBAuditReceiver by calling NewDynamoDBAuditReceiver. It is safe
+// for concurrent use by multiple goroutines.
+type DynamoDBAuditReceiver struct {
+	ddb   dynamodbiface.DynamoDBAPI
+	table string
+	// --
+	a auditor
+}
+
+var _ EventReceiver = &DynamoDBAuditReceiver{}
+
+// NewDynamoDBAuditReceiver creates a new DynamoDBAuditReceiver that writes to
+// the table. initiator identifies who ran the rotation. If empty, it is
+// "lambda:<function name>" in Lambda, else the hostname.
+func NewDynamoDBAuditReceiver(ddb dynamodbiface.DynamoDBAPI, table, initiator string) *DynamoDBAuditReceiver {
+	return &DynamoDBAuditReceiver{
+		ddb:   ddb,
+		table: table,
+		a:     newAuditor(initiator),
+	}
+}
+
+func (r *DynamoDBAuditReceiver) Receive(e Event) {
+	rec, ok := r.a.record(e)
+	if !ok {
+		return
+	}
+	if err := r.update(e, rec); err != nil {
+		log.Printf("ERROR: audit %s %s: %s", e.SecretId, e.VersionId, err)
+	}
+}
+
+func (r *DynamoDBAuditReceiver) update(e Event, rec auditRecord) error {
+	stepVal, err := dynamodbattribute.Marshal(rec.step)
+	if err != nil {
+		return err
+	}
+	set := "Steps = list_append(if_not_exists(Steps, :empty), :step), Initiator = if_not_exists(Initiator, :initiator)"
+	values := map[string]*dynamodb.AttributeValue{
+		":empty":     {L: []*dynamodb.AttributeValue{}},
+		":step":      {L: []*dynamodb.AttributeValue{stepVal}},
+		":initiator": {S: aws.String(r.a.initiator)},
+	}
+	if !rec.start.IsZero() {
+		set += ", StartTime = :start"
+		values[":start"] = &dynamodb.AttributeValue{S: aws.String(rec.start.UTC().Format(time.RFC3339Nano))}
+	}
+	if rec.downtime > 0 {
+		set += ", DowntimeMs = :downtime"
+		values[":downtime"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(rec.downtime.Milliseconds(), 10))}
+	}
+	switch {
+	case !rec.end.IsZero():
+		set += ", Outcome = :outcome, EndTime = :end, DurationMs = :duration"
+		values[":outcome"] = &dynamodb.AttributeValue{S: aws.String(AUDIT_OUTCOME_SUCCESS)}
+		values[":end"] = &dynamodb.AttributeValue{S: aws.String(rec.end.UTC().Format(time.RFC3339Nano))}
+		values[":duration"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(rec.duration.Milliseconds(), 10))}
+	case rec.step.Error != "":
+		set += ", Outcome = :outcome"
+		values[":outcome"] = &dynamodb.AttributeValue{S: aws.String(AUDIT_OUTCOME_FAILURE)}
+	}
+
+	_, err = r.ddb.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(r.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"SecretId":  {S: aws.String(e.SecretId)},
+			"VersionId": {S: aws.String(e.VersionId)},
+		},
+		UpdateExpression:          aws.String("SET " + set),
+		ExpressionAttributeValues: values,
+	})
+	return err
+}
+
+// --------------------------------------------------------------------------
+
+// S3_AUDIT_RECORD_VERSION is S3AuditRecord.Version. It changes only if the
+// record schema changes incompatibly.
+const S3_AUDIT_RECORD_VERSION = "1"
+
+// S3_AUDIT_MAC_ALGORITHM is the KMS MAC algorithm that signs S3 audit records.
+const S3_AUDIT_MAC_ALGORITHM = kms.MacAlgorithmSpecHmacSha256
+
+// S3AuditRecord is the audit record of one step invocation written by
+// S3AuditReceiver. Fields are only added, never changed or removed, unless
+// Version changes.
+type S3AuditRecord struct {
+	Version    string     `json:"version"` // S3_AUDIT_RECORD_VERSION
+	SecretId   string     `json:"secretId"`
+	SecretName string     `json:"secretName"`
+	VersionId  string     `json:"versionId"`
+	Initiator  string     `json:"initiator"`
+	Step       AuditStep  `json:"step"`
+	Outcome    string     `json:"outcome,omitempty"`   // set by finishSecret or a failed step
+	StartTime  *time.Time `json:"startTime,omitempty"` // createSecret only
+	EndTime    *time.Time `json:"endTime,omitempty"`   // finishSecret only
+	DurationMs int64      `json:"durationMs,omitempty"`
+	DowntimeMs int64      `json:"downtimeMs,omitempty"`
+}
+
+// S3AuditObject is the JSON object written to S3. Record is the exact bytes
+// that were signed, so verify an object by calling kms:VerifyMac with Record
+// as the message, Mac (base64-decoded), KeyId, and MacAlgorithm, then decode
+// Record into an S3AuditRecord.
+type S3AuditObject struct {
+	Record       json.RawMessage `json:"record"`
+	Mac          string          `json:"mac"` // base64
+	KeyId        string          `json:"keyId"`
+	MacAlgorithm string          `json:"macAlgorithm"` // S3_AUDIT_MAC_ALGORITHM
+}
+
+// S3AuditConfig configures an S3AuditReceiver.
+type S3AuditConfig struct {
+	S3     s3iface.S3API
+	Bucket string
+	Prefix string // key prefix, like "rotation-audit/"; optional
+
+	// KMS and KeyId sign records with kms:GenerateMac. KeyId is an HMAC_256
+	// KMS key ID, ARN, or alias. Both are required.
+	KMS   kmsiface.KMSAPI
+	KeyId string
+
+	// Initiator identifies who ran the rotation. If empty, it is
+	// "lambda:<function name>" in Lambda, else the hostname.
+	Initiator string
+}
+
+// S3AuditReceiver is an EventReceiver that writes a signed JSON audit record
+// of every rotation to an S3 bucket for long-term, immutable evidence of
+// rotation activity. At the end of each step (EVENT_END_STEP), it writes one
+// S3AuditObject to key:
+//
+//	<prefix><secret name>/<version ID>/<step end time>-<step>.json
+//
+// So the audit record of a rotation is all the objects under
+// "<prefix><secret name>/<version ID>/", one per step invocation, including
+// retries, and listing them returns them in order. Every key is unique, so
+// objects are written once and never overwritten, which is compatible with
+// S3 Object Lock: enable it with a default retention on the bucket. Objects
+// are written with Content-MD5, which Object Lock requires.
+//
+// The Lambda function needs s3:PutObject on the bucket and kms:GenerateMac on
+// the key; it should not have s3:DeleteObject. Errors are logged and ignored.
+//
+// Create an S3AuditReceiver by calling NewS3AuditReceiver. It is safe for
+// concurrent use by multiple goroutines.
+type S3AuditReceiver struct {
+	cfg S3AuditConfig
+	// --
+	a auditor
+}
+
+var _ EventReceiver = &S3AuditReceiver{}
+
+// NewS3AuditReceiver creates a new S3AuditReceiver.
+func NewS3AuditReceiver(cfg S3AuditConfig) *S3AuditReceiver {
+	return &S3AuditReceiver{
+		cfg: cfg,
+		a:   newAuditor(cfg.Initiator),
+	}
+}
+
+func (r *S3AuditReceiver) Receive(e Event) {
+	rec, ok := r.a.record(e)
+	if !ok {
+		return
+	}
+	if err := r.put(e, rec); err != nil {
+		log.Printf("ERROR: audit %s %s: s3://%s: %s", e.SecretId, e.VersionId, r.cfg.Bucket, err)
+	}
+}
+
+func (r *S3AuditReceiver) put(e Event, rec auditRecord) error {
+	record := S3AuditRecord{
+		Version:    S3_AUDIT_RECORD_VERSION,
+		SecretId:   e.SecretId,
+		SecretName: secretName(e.SecretId),
+		VersionId:  e.VersionId,
+		Initiator:  r.a.initiator,
+		Step:       rec.step,
+		DowntimeMs: rec.downtime.Milliseconds(),
+	}
+	if !rec.start.IsZero() {
+		start := rec.start.UTC()
+		record.StartTime = &start
+	}
+	switch {
+	case !rec.end.IsZero():
+		end := rec.end.UTC()
+		record.Outcome = AUDIT_OUTCOME_SUCCESS
+		record.EndTime = &end
+		record.DurationMs = rec.duration.Milliseconds()
+	case rec.step.Error != "":
+		record.Outcome = AUDIT_OUTCOME_FAILURE
+	}
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	out, err := r.cfg.KMS.GenerateMac(&kms.GenerateMacInput{
+		KeyId:        aws.String(r.cfg.KeyId),
+		MacAlgorithm: aws.String(S3_AUDIT_MAC_ALGORITHM),
+		Message:      recordBytes,
+	})
+	if err != nil {
+		return fmt.Errorf("kms:GenerateMac: %s", err)
+	}
+	obj, err := json.Marshal(S3AuditObject{
+		Record:       recordBytes,
+		Mac:          base64.StdEncoding.EncodeToString(out.Mac),
+		KeyId:        aws.StringValue(out.KeyId),
+		MacAlgorithm: S3_AUDIT_MAC_ALGORITHM,
+	})
+	if err != nil {
+		return err
+	}
+
+	sum := md5.Sum(obj)
+	_, err = r.cfg.S3.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(r.cfg.Bucket),
+		Key:         aws.String(r.key(record)),
+		Body:        bytes.NewReader(obj),
+		ContentType: aws.String("application/json"),
+		ContentMD5:  aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	})
+	return err
+}
+
+// key returns the object key of the record. The step end time is formatted
+// with fixed width so keys sort in time order.
+func (r *S3AuditReceiver) key(record S3AuditRecord) string {
+	t := record.Step.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return fmt.Sprintf("%s%s/%s/%s-%s.json", r.cfg.Prefix, record.SecretName, record.VersionId,
+		t.UTC().Format("20060102T150405.000000000Z"), record.Step.Step)
+}
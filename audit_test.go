@@ -0,0 +1,177 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+type auditDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	updates []*dynamodb.UpdateItemInput
+}
+
+func (m *auditDynamoDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	m.updates = append(m.updates, input)
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+func TestDynamoDBAuditReceiver(t *testing.T) {
+	ddb := &auditDynamoDB{}
+	r := rotate.NewDynamoDBAuditReceiver(ddb, "audit", "ops")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	ev := func(name, step string, d time.Duration, err error) rotate.Event {
+		return rotate.Event{Name: name, SecretId: "db", VersionId: "v2", Step: step, Time: now, Duration: d, Error: err}
+	}
+
+	// createSecret
+	r.Receive(ev(rotate.EVENT_BEGIN_ROTATION, "createSecret", 0, nil))
+	r.Receive(ev(rotate.EVENT_END_STEP, "createSecret", time.Second, nil))
+	// setSecret fails
+	r.Receive(ev(rotate.EVENT_END_STEP, "setSecret", time.Second, errors.New("fail")))
+	// finishSecret
+	r.Receive(ev(rotate.EVENT_NEW_PASSWORD_IS_CURRENT, "finishSecret", 3*time.Second, nil))
+	r.Receive(ev(rotate.EVENT_END_ROTATION, "finishSecret", time.Minute, nil))
+	r.Receive(ev(rotate.EVENT_END_STEP, "finishSecret", time.Second, nil))
+
+	if len(ddb.updates) != 3 {
+		t.Fatalf("got %d updates, expected 3", len(ddb.updates))
+	}
+	for _, u := range ddb.updates {
+		if *u.TableName != "audit" || *u.Key["SecretId"].S != "db" || *u.Key["VersionId"].S != "v2" {
+			t.Errorf("wrong table or key: %s %v", *u.TableName, u.Key)
+		}
+		if *u.ExpressionAttributeValues[":initiator"].S != "ops" {
+			t.Errorf("got initiator %s, expected ops", *u.ExpressionAttributeValues[":initiator"].S)
+		}
+	}
+
+	expect := []string{
+		"SET Steps = list_append(if_not_exists(Steps, :empty), :step), Initiator = if_not_exists(Initiator, :initiator), StartTime = :start",
+		"SET Steps = list_append(if_not_exists(Steps, :empty), :step), Initiator = if_not_exists(Initiator, :initiator), Outcome = :outcome",
+		"SET Steps = list_append(if_not_exists(Steps, :empty), :step), Initiator = if_not_exists(Initiator, :initiator), DowntimeMs = :downtime, Outcome = :outcome, EndTime = :end, DurationMs = :duration",
+	}
+	got := []string{}
+	for _, u := range ddb.updates {
+		got = append(got, aws.StringValue(u.UpdateExpression))
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	var step rotate.AuditStep
+	if err := dynamodbattribute.Unmarshal(ddb.updates[1].ExpressionAttributeValues[":step"].L[0], &step); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(step, rotate.AuditStep{Step: "setSecret", Time: now, Duration: 1000, Error: "fail"}); diff != nil {
+		t.Error(diff)
+	}
+	outcomes := []string{
+		*ddb.updates[1].ExpressionAttributeValues[":outcome"].S,
+		*ddb.updates[2].ExpressionAttributeValues[":outcome"].S,
+	}
+	if diff := deep.Equal(outcomes, []string{rotate.AUDIT_OUTCOME_FAILURE, rotate.AUDIT_OUTCOME_SUCCESS}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+type auditS3 struct {
+	s3iface.S3API
+	puts []*s3.PutObjectInput
+	body [][]byte
+}
+
+func (m *auditS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	body, _ := io.ReadAll(input.Body)
+	m.puts = append(m.puts, input)
+	m.body = append(m.body, body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+type auditKMS struct {
+	kmsiface.KMSAPI
+}
+
+func (m auditKMS) GenerateMac(input *kms.GenerateMacInput) (*kms.GenerateMacOutput, error) {
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write(input.Message)
+	return &kms.GenerateMacOutput{Mac: mac.Sum(nil), KeyId: input.KeyId, MacAlgorithm: input.MacAlgorithm}, nil
+}
+
+func TestS3AuditReceiver(t *testing.T) {
+	s3mock := &auditS3{}
+	r := rotate.NewS3AuditReceiver(rotate.S3AuditConfig{
+		S3:        s3mock,
+		Bucket:    "bucket",
+		Prefix:    "audit/",
+		KMS:       auditKMS{},
+		KeyId:     "alias/audit",
+		Initiator: "ops",
+	})
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	secretId := "arn:aws:secretsmanager:us-east-1:111:secret:db-AbCdEf"
+	r.Receive(rotate.Event{Name: rotate.EVENT_NEW_PASSWORD_IS_CURRENT, SecretId: secretId, VersionId: "v2", Step: "finishSecret", Duration: 3 * time.Second})
+	r.Receive(rotate.Event{Name: rotate.EVENT_END_ROTATION, SecretId: secretId, VersionId: "v2", Step: "finishSecret", Time: now, Duration: time.Minute})
+	r.Receive(rotate.Event{Name: rotate.EVENT_END_STEP, SecretId: secretId, VersionId: "v2", Step: "finishSecret", Time: now, Duration: time.Second})
+
+	if len(s3mock.puts) != 1 {
+		t.Fatalf("got %d puts, expected 1", len(s3mock.puts))
+	}
+	put := s3mock.puts[0]
+	if *put.Bucket != "bucket" || *put.Key != "audit/db/v2/20260102T030405.000000000Z-finishSecret.json" {
+		t.Errorf("got s3://%s/%s", *put.Bucket, *put.Key)
+	}
+	sum := md5.Sum(s3mock.body[0])
+	if *put.ContentMD5 != base64.StdEncoding.EncodeToString(sum[:]) {
+		t.Errorf("wrong Content-MD5 %s", *put.ContentMD5)
+	}
+
+	var obj rotate.S3AuditObject
+	if err := json.Unmarshal(s3mock.body[0], &obj); err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write(obj.Record)
+	if obj.Mac != base64.StdEncoding.EncodeToString(mac.Sum(nil)) || obj.KeyId != "alias/audit" || obj.MacAlgorithm != rotate.S3_AUDIT_MAC_ALGORITHM {
+		t.Errorf("record not signed: %+v", obj)
+	}
+	var got rotate.S3AuditRecord
+	if err := json.Unmarshal(obj.Record, &got); err != nil {
+		t.Fatal(err)
+	}
+	expect := rotate.S3AuditRecord{
+		Version:    rotate.S3_AUDIT_RECORD_VERSION,
+		SecretId:   secretId,
+		SecretName: "db",
+		VersionId:  "v2",
+		Initiator:  "ops",
+		Step:       rotate.AuditStep{Step: "finishSecret", Time: now, Duration: 1000},
+		Outcome:    rotate.AUDIT_OUTCOME_SUCCESS,
+		EndTime:    &now,
+		DurationMs: 60000,
+		DowntimeMs: 3000,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
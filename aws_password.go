@@ -0,0 +1,89 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// AWSRandomPassword is a SecretSetter that generates passwords by calling
+// Secrets Manager GetRandomPassword. The password policy is the AWS-native
+// GetRandomPassword parameters, so passwords match what AWS-provided rotation
+// functions generate. Like RandomPassword, it requires the secret value to have
+// two JSON fields: username and password. Other fields are ignored.
+//
+// AWSRandomPassword does not support Handler (user-invoked password rotation),
+// it only supports rotation by Secrets Manager.
+type AWSRandomPassword struct {
+	// SecretsManager is an AWS Secrets Manager client, usually the same one
+	// as Config.SecretsManager. It is required.
+	SecretsManager SecretsManager
+
+	// PasswordLength defines the length of the password. If not provided,
+	// DEFAULT_PASSWORD_LENGTH is used (not the GetRandomPassword default).
+	PasswordLength int
+
+	// ExcludeCharacters are characters never used. For example, `'"\@/` to
+	// avoid characters that break DSNs or SQL.
+	ExcludeCharacters string
+
+	// ExcludeNumbers, ExcludeLowercase, ExcludeUppercase, and ExcludePunctuation
+	// exclude all characters of the class.
+	ExcludeNumbers     bool
+	ExcludeLowercase   bool
+	ExcludeUppercase   bool
+	ExcludePunctuation bool
+
+	// IncludeSpace includes the space character.
+	IncludeSpace bool
+
+	// RequireEachIncludedType requires at least one character of every class
+	// that is not excluded.
+	RequireEachIncludedType bool
+}
+
+var _ SecretSetter = AWSRandomPassword{}
+
+func (s AWSRandomPassword) Init(context.Context, map[string]string) error {
+	return nil // nothing we need to do
+}
+
+func (s AWSRandomPassword) Handler(context.Context, map[string]string) (map[string]string, error) {
+	return nil, errors.New("AWSRandomPassword does not support user-invoked password rotation")
+}
+
+func (s AWSRandomPassword) Rotate(secret map[string]string) error {
+	passwordLength := s.PasswordLength
+	if passwordLength == 0 {
+		passwordLength = DEFAULT_PASSWORD_LENGTH
+	}
+	input := &secretsmanager.GetRandomPasswordInput{
+		PasswordLength:          aws.Int64(int64(passwordLength)),
+		ExcludeNumbers:          aws.Bool(s.ExcludeNumbers),
+		ExcludeLowercase:        aws.Bool(s.ExcludeLowercase),
+		ExcludeUppercase:        aws.Bool(s.ExcludeUppercase),
+		ExcludePunctuation:      aws.Bool(s.ExcludePunctuation),
+		IncludeSpace:            aws.Bool(s.IncludeSpace),
+		RequireEachIncludedType: aws.Bool(s.RequireEachIncludedType),
+	}
+	if s.ExcludeCharacters != "" {
+		input.ExcludeCharacters = aws.String(s.ExcludeCharacters)
+	}
+	output, err := s.SecretsManager.GetRandomPassword(input)
+	if err != nil {
+		return err
+	}
+	if output == nil || output.RandomPassword == nil || *output.RandomPassword == "" {
+		return errors.New("GetRandomPassword returned no password")
+	}
+	secret["password"] = *output.RandomPassword
+	return nil
+}
+
+func (s AWSRandomPassword) Credentials(secret map[string]string) (username, password string) {
+	return secret["username"], secret["password"]
+}
@@ -0,0 +1,147 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+// DEFAULT_SECRET_CACHE_TTL is how long a SecretCache entry is used if the TTL
+// passed to NewSecretCache is zero.
+const DEFAULT_SECRET_CACHE_TTL = 5 * time.Minute
+
+// SecretCache caches GetSecretValue responses across the invocations of a warm
+// Lambda container, so the four steps of a rotation (and their retries) get the
+// AWSCURRENT and AWSPREVIOUS secrets, and the admin secret, about once instead
+// of on every step. Create one outside the handler, so it outlives invocations,
+// and set it as Config.SecretCache. It is safe for concurrent use by multiple
+// goroutines.
+//
+// Entries are keyed on secret ID, staging label, and the ClientRequestToken of
+// the rotation, so a rotation never uses the entries of another. The entries
+// of a secret are invalidated when the Rotator puts a value or moves a staging
+// label of the secret, and expire after the TTL. AWSPENDING is never cached
+// because a fresh read is how the Rotator detects a concurrent rotation of the
+// secret (see ErrPendingConflict).
+//
+// Changes made by others, like a manual change in the AWS console, are not seen
+// until the entries expire. Call Invalidate to drop them sooner.
+type SecretCache struct {
+	ttl time.Duration
+	// --
+	mux     *sync.Mutex
+	entries map[secretCacheKey]secretCacheEntry
+	hits    uint
+	misses  uint
+}
+
+type secretCacheKey struct {
+	secretId string
+	stage    string
+	token    string
+}
+
+type secretCacheEntry struct {
+	output  *secretsmanager.GetSecretValueOutput
+	expires time.Time
+}
+
+// NewSecretCache returns an empty SecretCache with entries that expire after
+// ttl. If ttl is zero, DEFAULT_SECRET_CACHE_TTL is used.
+func NewSecretCache(ttl time.Duration) *SecretCache {
+	if ttl == 0 {
+		ttl = DEFAULT_SECRET_CACHE_TTL
+	}
+	return &SecretCache{
+		ttl:     ttl,
+		mux:     &sync.Mutex{},
+		entries: map[secretCacheKey]secretCacheEntry{},
+	}
+}
+
+// Invalidate removes all entries of the secret, which must be the same secret
+// ID as in the Secrets Manager event (or Config.AdminSecretId).
+func (c *SecretCache) Invalidate(secretId string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for k := range c.entries {
+		if k.secretId == secretId {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Stats returns the number of cache hits and misses since the SecretCache
+// was created.
+func (c *SecretCache) Stats() (hits, misses uint) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.hits, c.misses
+}
+
+func (c *SecretCache) get(k secretCacheKey) *secretsmanager.GetSecretValueOutput {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	e, ok := c.entries[k]
+	if ok && time.Now().After(e.expires) {
+		delete(c.entries, k)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil
+	}
+	c.hits++
+	return e.output
+}
+
+func (c *SecretCache) put(k secretCacheKey, output *secretsmanager.GetSecretValueOutput) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.entries[k] = secretCacheEntry{output: output, expires: time.Now().Add(c.ttl)}
+}
+
+// --------------------------------------------------------------------------
+
+// cachedSecretsManager is the SecretsManager of a Rotator with a SecretCache.
+// It gets secrets by staging label from the cache, keyed on the token of the
+// current rotation, and invalidates the cache on changes.
+type cachedSecretsManager struct {
+	SecretsManager
+	cache *SecretCache
+	token func() string // Rotator.clientRequestToken
+}
+
+var _ SecretsManager = cachedSecretsManager{}
+
+func (m cachedSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	stage := aws.StringValue(input.VersionStage)
+	if input.VersionId != nil || stage == "" || stage == AWSPENDING {
+		return m.SecretsManager.GetSecretValue(input)
+	}
+	k := secretCacheKey{secretId: aws.StringValue(input.SecretId), stage: stage, token: m.token()}
+	if output := m.cache.get(k); output != nil {
+		debug("secret cache hit: %s stage %s", k.secretId, stage)
+		return output, nil
+	}
+	output, err := m.SecretsManager.GetSecretValue(input)
+	if err != nil {
+		return nil, err
+	}
+	m.cache.put(k, output)
+	return output, nil
+}
+
+func (m cachedSecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	defer m.cache.Invalidate(aws.StringValue(input.SecretId))
+	return m.SecretsManager.PutSecretValue(input)
+}
+
+func (m cachedSecretsManager) UpdateSecretVersionStage(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	defer m.cache.Invalidate(aws.StringValue(input.SecretId))
+	return m.SecretsManager.UpdateSecretVersionStage(input)
+}
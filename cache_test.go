@@ -0,0 +1,128 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+// countingSecretsManager returns the fake as a MockSecretsManager that counts
+// GetSecretValue calls by staging label.
+func countingSecretsManager(fake *test.FakeSecretsManager, calls map[string]int) test.MockSecretsManager {
+	return test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			calls[aws.StringValue(input.VersionStage)]++
+			return fake.GetSecretValue(input)
+		},
+		PutSecretValueFunc:           fake.PutSecretValue,
+		UpdateSecretVersionStageFunc: fake.UpdateSecretVersionStage,
+		DescribeSecretFunc:           fake.DescribeSecret,
+		GetRandomPasswordFunc:        fake.GetRandomPassword,
+	}
+}
+
+func TestSecretCache(t *testing.T) {
+	// Test that a rotation with a SecretCache gets AWSCURRENT from Secrets
+	// Manager only after a change, and AWSPENDING on every step, and that the
+	// next rotation (new token) does not use the entries of the last
+	fake := test.NewFakeSecretsManager()
+	fake.AddSecret("def", map[string]string{"username": "foo", "password": "p1"})
+	calls := map[string]int{}
+	cache := rotate.NewSecretCache(0)
+	var dbPassword = "p1"
+	ps := test.MockPasswordSetter{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			dbPassword = creds.New.Password
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.New.Password != dbPassword {
+				return errors.New("access denied")
+			}
+			return nil
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: countingSecretsManager(fake, calls),
+		PasswordSetter: ps,
+		SecretCache:    cache,
+	})
+	rotateAll := func(token string) {
+		t.Helper()
+		for _, step := range []string{"createSecret", "setSecret", "testSecret", "finishSecret"} {
+			event := map[string]string{"ClientRequestToken": token, "SecretId": "def", "Step": step}
+			if _, err := r.Handler(context.TODO(), event); err != nil {
+				t.Fatalf("%s %s: %s", token, step, err)
+			}
+		}
+	}
+
+	rotateAll("v2")
+	if fake.Stages("def")[rotate.AWSCURRENT] != "v2" {
+		t.Fatalf("AWSCURRENT is not v2: %v", fake.Stages("def"))
+	}
+	// createSecret puts the pending secret, which invalidates AWSCURRENT, so
+	// it is got again once for the other steps
+	if calls[rotate.AWSCURRENT] != 2 {
+		t.Errorf("got AWSCURRENT %d times, expected 2", calls[rotate.AWSCURRENT])
+	}
+	if calls[rotate.AWSPENDING] < 3 {
+		t.Errorf("got AWSPENDING %d times, expected at least 3 (not cached)", calls[rotate.AWSPENDING])
+	}
+	if hits, _ := cache.Stats(); hits == 0 {
+		t.Error("no cache hits")
+	}
+
+	// The next rotation gets the new AWSCURRENT (v2), not the cached v1
+	rotateAll("v3")
+	if fake.Stages("def")[rotate.AWSCURRENT] != "v3" {
+		t.Fatalf("AWSCURRENT is not v3: %v", fake.Stages("def"))
+	}
+	if got := fake.Values("def", rotate.AWSPREVIOUS)["password"]; got == "p1" {
+		t.Errorf("AWSPREVIOUS password is p1, expected the v2 password")
+	}
+}
+
+func TestSecretCacheInvalidate(t *testing.T) {
+	// Test that Invalidate drops the entries of a secret changed by others,
+	// like a manual change, which the cache does not see
+	fake := test.NewFakeSecretsManager()
+	fake.AddSecret("def", map[string]string{"username": "foo", "password": "p1"})
+	calls := map[string]int{}
+	cache := rotate.NewSecretCache(0)
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: countingSecretsManager(fake, calls),
+		PasswordSetter: test.MockPasswordSetter{},
+		SecretCache:    cache,
+		SkipDatabase:   true,
+	})
+	event := map[string]string{"ClientRequestToken": "v2", "SecretId": "def", "Step": "createSecret"}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Handler(context.TODO(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := calls[rotate.AWSCURRENT]
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if calls[rotate.AWSCURRENT] != n {
+		t.Errorf("got AWSCURRENT %d times, expected %d (cached)", calls[rotate.AWSCURRENT], n)
+	}
+	cache.Invalidate("def")
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if calls[rotate.AWSCURRENT] != n+1 {
+		t.Errorf("got AWSCURRENT %d times after Invalidate, expected %d", calls[rotate.AWSCURRENT], n+1)
+	}
+}
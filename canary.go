@@ -0,0 +1,107 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// Canary is an application-level check of the new credentials in testSecret,
+// after PasswordSetter.VerifyPassword, like a health check of an application
+// that uses the pending secret. If Check returns an error, testSecret rolls
+// back and returns a RotationError with Kind ErrCanaryFailed. Set Config.Canary
+// to enable.
+//
+// event is the Secrets Manager event with the secret ID and ClientRequestToken,
+// which is the version ID of the pending secret. creds are the same credentials
+// passed to PasswordSetter.VerifyPassword.
+//
+// CanaryFunc, HTTPCanary, and LambdaCanary implement this interface. To check
+// the new credentials with a query, see mysql.QueryCanary.
+type Canary interface {
+	Check(ctx context.Context, event map[string]string, creds db.NewPassword) error
+}
+
+// CanaryFunc is a function that implements Canary.
+type CanaryFunc func(ctx context.Context, event map[string]string, creds db.NewPassword) error
+
+var _ Canary = CanaryFunc(nil)
+
+func (f CanaryFunc) Check(ctx context.Context, event map[string]string, creds db.NewPassword) error {
+	return f(ctx, event, creds)
+}
+
+// HTTPCanary is a Canary that sends an HTTP GET request to URL and fails
+// unless the response status code is 2xx. The credentials are not sent, so
+// the endpoint must get the pending secret itself.
+type HTTPCanary struct {
+	URL    string
+	Client *http.Client // http.DefaultClient if nil
+}
+
+var _ Canary = HTTPCanary{}
+
+func (c HTTPCanary) Check(ctx context.Context, event map[string]string, creds db.NewPassword) error {
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("canary %s returned %s: %s", c.URL, resp.Status, body)
+	}
+	return nil
+}
+
+// LambdaCanary is a Canary that invokes a Lambda function synchronously and
+// fails if the function returns an error. The payload is a JSON object with the
+// secret ID, ClientRequestToken (the pending secret version ID), and VersionStage
+// AWSPENDING, so the function can get and test the pending secret. The
+// credentials are not sent.
+type LambdaCanary struct {
+	Lambda       lambdaiface.LambdaAPI
+	FunctionName string
+}
+
+var _ Canary = LambdaCanary{}
+
+func (c LambdaCanary) Check(ctx context.Context, event map[string]string, creds db.NewPassword) error {
+	payload, err := json.Marshal(map[string]string{
+		"SecretId":           event["SecretId"],
+		"ClientRequestToken": event["ClientRequestToken"],
+		"VersionStage":       AWSPENDING,
+	})
+	if err != nil {
+		return err
+	}
+	out, err := c.Lambda.InvokeWithContext(ctx, &lambda.InvokeInput{
+		FunctionName: aws.String(c.FunctionName),
+		Payload:      payload,
+	})
+	if err != nil {
+		return err
+	}
+	if out.FunctionError != nil {
+		return fmt.Errorf("canary function %s error: %s: %s", c.FunctionName, *out.FunctionError, out.Payload)
+	}
+	return nil
+}
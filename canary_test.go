@@ -0,0 +1,111 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestCanary(t *testing.T) {
+	// Test that a failed canary rolls back the new password
+	var gotUpdates []string
+	var rolledBack bool
+	var gotCreds db.NewPassword
+	canaryErr := fmt.Errorf("app cannot connect")
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: commandSecretsManager(&gotUpdates),
+		PasswordSetter: test.MockPasswordSetter{
+			RollbackFunc: func(ctx context.Context, creds db.NewPassword) error {
+				rolledBack = true
+				return nil
+			},
+		},
+		Canary: rotate.CanaryFunc(func(ctx context.Context, event map[string]string, creds db.NewPassword) error {
+			gotCreds = creds
+			return canaryErr
+		}),
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "testSecret",
+	}
+	_, err := r.Handler(context.TODO(), event)
+	if !errors.Is(err, rotate.ErrCanaryFailed) || !errors.Is(err, canaryErr) {
+		t.Errorf("got error %v, expected ErrCanaryFailed", err)
+	}
+	if gotCreds.New.Password != "p2" {
+		t.Errorf("canary got new password %s, expected p2", gotCreds.New.Password)
+	}
+	if !rolledBack {
+		t.Error("new password not rolled back")
+	}
+
+	// Canary ok
+	canaryErr = nil
+	rolledBack = false
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Error(err)
+	}
+	if rolledBack {
+		t.Error("new password rolled back")
+	}
+}
+
+func TestHTTPCanary(t *testing.T) {
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+	c := rotate.HTTPCanary{URL: ts.URL}
+	if err := c.Check(context.TODO(), nil, db.NewPassword{}); err != nil {
+		t.Error(err)
+	}
+	status = http.StatusServiceUnavailable
+	if err := c.Check(context.TODO(), nil, db.NewPassword{}); err == nil {
+		t.Error("no error for 503 response")
+	}
+}
+
+type mockLambda struct {
+	lambdaiface.LambdaAPI
+	input *lambda.InvokeInput
+	out   *lambda.InvokeOutput
+}
+
+func (m *mockLambda) InvokeWithContext(ctx aws.Context, input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	m.input = input
+	return m.out, nil
+}
+
+func TestLambdaCanary(t *testing.T) {
+	m := &mockLambda{out: &lambda.InvokeOutput{}}
+	c := rotate.LambdaCanary{Lambda: m, FunctionName: "app-health"}
+	event := map[string]string{"SecretId": "def", "ClientRequestToken": "v2", "Step": "testSecret"}
+	if err := c.Check(context.TODO(), event, db.NewPassword{}); err != nil {
+		t.Error(err)
+	}
+	expect := `{"ClientRequestToken":"v2","SecretId":"def","VersionStage":"AWSPENDING"}`
+	if string(m.input.Payload) != expect {
+		t.Errorf("got payload %s, expected %s", m.input.Payload, expect)
+	}
+	m.out = &lambda.InvokeOutput{FunctionError: aws.String("Unhandled"), Payload: []byte(`{"errorMessage":"nope"}`)}
+	if err := c.Check(context.TODO(), event, db.NewPassword{}); err == nil {
+		t.Error("no error for function error")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// DEFAULT_CLOUDWATCH_NAMESPACE is the CloudWatchMetrics namespace if none is set.
+const DEFAULT_CLOUDWATCH_NAMESPACE = "PasswordRotation"
+
+// CloudWatch metric names published by CloudWatchMetrics.
+const (
+	CLOUDWATCH_ROTATION_SUCCESS = "RotationSuccess"    // 1 when a rotation completes
+	CLOUDWATCH_ROTATION_FAILURE = "RotationFailure"    // 1 when a step fails
+	CLOUDWATCH_DOWNTIME         = "PasswordDowntimeMs" // see EVENT_NEW_PASSWORD_IS_CURRENT
+	CLOUDWATCH_HOSTS_FAILED     = "HostsFailed"        // database hosts that failed in a step
+)
+
+// CloudWatchMetrics is an EventReceiver that publishes custom metrics with the
+// CloudWatch PutMetricData API, for when CloudWatch embedded metric format (EMF)
+// is not used. Every metric has dimension "SecretName", which is the secret
+// name from Event.SecretId. Set it as Config.EventReceiver. To publish
+// HostsFailed, also set it as the PasswordSetter observer, like
+// mysql.Config.Observer, because it is a db.HostObserver.
+//
+// Metrics are published synchronously when the event is received, so the
+// Lambda function must allow cloudwatch:PutMetricData. Wrap it in an
+// AsyncReceiver to publish without blocking the rotation. Publish errors are
+// logged and ignored.
+//
+// Create a CloudWatchMetrics by calling NewCloudWatchMetrics. It is safe for
+// concurrent use by multiple goroutines.
+type CloudWatchMetrics struct {
+	cw        cloudwatchiface.CloudWatchAPI
+	namespace string
+	// --
+	mux   *sync.Mutex
+	hosts map[string]bool // hostname => failed, since the last EVENT_END_STEP
+}
+
+var _ EventReceiver = &CloudWatchMetrics{}
+var _ db.HostObserver = &CloudWatchMetrics{}
+
+// NewCloudWatchMetrics creates a new CloudWatchMetrics that publishes metrics
+// in the namespace. If namespace is empty, DEFAULT_CLOUDWATCH_NAMESPACE is used.
+func NewCloudWatchMetrics(cw cloudwatchiface.CloudWatchAPI, namespace string) *CloudWatchMetrics {
+	if namespace == "" {
+		namespace = DEFAULT_CLOUDWATCH_NAMESPACE
+	}
+	return &CloudWatchMetrics{
+		cw:        cw,
+		namespace: namespace,
+		mux:       &sync.Mutex{},
+		hosts:     map[string]bool{},
+	}
+}
+
+// Receive publishes RotationSuccess on EVENT_END_ROTATION, RotationFailure on
+// EVENT_ERROR, PasswordDowntimeMs on EVENT_NEW_PASSWORD_IS_CURRENT (if known),
+// and HostsFailed on EVENT_END_STEP if the step observed any hosts.
+func (m *CloudWatchMetrics) Receive(e Event) {
+	var datum *cloudwatch.MetricDatum
+	switch e.Name {
+	case EVENT_END_ROTATION:
+		datum = m.datum(CLOUDWATCH_ROTATION_SUCCESS, 1, cloudwatch.StandardUnitCount, e)
+	case EVENT_ERROR:
+		datum = m.datum(CLOUDWATCH_ROTATION_FAILURE, 1, cloudwatch.StandardUnitCount, e)
+	case EVENT_NEW_PASSWORD_IS_CURRENT:
+		if e.Duration > 0 {
+			datum = m.datum(CLOUDWATCH_DOWNTIME, float64(e.Duration.Milliseconds()), cloudwatch.StandardUnitMilliseconds, e)
+		}
+	case EVENT_END_STEP:
+		m.mux.Lock()
+		n, failed := len(m.hosts), 0
+		for _, f := range m.hosts {
+			if f {
+				failed++
+			}
+		}
+		m.hosts = map[string]bool{}
+		m.mux.Unlock()
+		if n > 0 {
+			datum = m.datum(CLOUDWATCH_HOSTS_FAILED, float64(failed), cloudwatch.StandardUnitCount, e)
+		}
+	}
+	if datum == nil {
+		return
+	}
+	_, err := m.cw.PutMetricData(&cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(m.namespace),
+		MetricData: []*cloudwatch.MetricDatum{datum},
+	})
+	if err != nil {
+		log.Printf("ERROR: CloudWatch PutMetricData %s: %s", *datum.MetricName, err)
+	}
+}
+
+// ObserveHost records if the host failed. A host that fails any action in
+// the step counts as failed.
+func (m *CloudWatchMetrics) ObserveHost(hostname, action string, d time.Duration, err error) {
+	m.mux.Lock()
+	m.hosts[hostname] = m.hosts[hostname] || err != nil
+	m.mux.Unlock()
+}
+
+func (m *CloudWatchMetrics) datum(name string, value float64, unit string, e Event) *cloudwatch.MetricDatum {
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return &cloudwatch.MetricDatum{
+		MetricName: aws.String(name),
+		Value:      aws.Float64(value),
+		Unit:       aws.String(unit),
+		Timestamp:  aws.Time(t),
+		Dimensions: []*cloudwatch.Dimension{
+			{Name: aws.String("SecretName"), Value: aws.String(secretName(e.SecretId))},
+		},
+	}
+}
+
+// secretARNSuffix is the random suffix that Secrets Manager appends to the
+// secret name in the ARN, like "-a1b2c3".
+var secretARNSuffix = regexp.MustCompile(`-[a-zA-Z0-9]{6}$`)
+
+// secretName returns the secret name from the secret ID, which is the name
+// or ARN, like "arn:aws:secretsmanager:us-east-1:123456789012:secret:db-a1b2c3".
+func secretName(secretId string) string {
+	if !strings.HasPrefix(secretId, "arn:") {
+		return secretId
+	}
+	f := strings.SplitN(secretId, ":", 7)
+	if len(f) != 7 {
+		return secretId
+	}
+	return secretARNSuffix.ReplaceAllString(f[6], "")
+}
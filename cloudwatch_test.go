@@ -0,0 +1,58 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+type mockCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	got []string // "namespace name dimension=value value unit"
+}
+
+func (m *mockCloudWatch) PutMetricData(input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	for _, d := range input.MetricData {
+		dim := *d.Dimensions[0].Name + "=" + *d.Dimensions[0].Value
+		m.got = append(m.got, *input.Namespace+" "+*d.MetricName+" "+dim+" "+formatValue(*d.Value)+" "+*d.Unit)
+	}
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func TestCloudWatchMetrics(t *testing.T) {
+	cw := &mockCloudWatch{}
+	m := rotate.NewCloudWatchMetrics(cw, "")
+	arn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/db-a1b2c3"
+
+	m.ObserveHost("db1", "setting", time.Millisecond, nil)
+	m.ObserveHost("db2", "setting", time.Millisecond, errors.New("fail"))
+	m.Receive(rotate.Event{Name: rotate.EVENT_ERROR, SecretId: arn, Step: "setSecret"})
+	m.Receive(rotate.Event{Name: rotate.EVENT_END_STEP, SecretId: arn, Step: "setSecret"})
+	m.Receive(rotate.Event{Name: rotate.EVENT_END_STEP, SecretId: arn, Step: "createSecret"}) // no hosts
+	m.Receive(rotate.Event{Name: rotate.EVENT_NEW_PASSWORD_IS_CURRENT, SecretId: "db", Duration: 2 * time.Second})
+	m.Receive(rotate.Event{Name: rotate.EVENT_END_ROTATION, SecretId: "db"})
+	m.Receive(rotate.Event{Name: rotate.EVENT_BEGIN_ROTATION, SecretId: "db"}) // not published
+
+	expect := []string{
+		"PasswordRotation RotationFailure SecretName=prod/db 1 Count",
+		"PasswordRotation HostsFailed SecretName=prod/db 1 Count",
+		"PasswordRotation PasswordDowntimeMs SecretName=db 2000 Milliseconds",
+		"PasswordRotation RotationSuccess SecretName=db 1 Count",
+	}
+	if diff := deep.Equal(cw.got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2026, Square, Inc.
+
+// rotatectl runs a rotation or rotation command locally, without Lambda. It
+// wires the Rotator like examples/rds (Secrets Manager, RDS, and MySQL), so
+// it's useful for break-glass rotations when Lambda is impaired. AWS credentials
+// and region are read from the environment or shared config, like the AWS CLI.
+//
+//	rotatectl rotate -secret-id X        rotate the secret (all four steps)
+//	rotatectl status -secret-id X        print secret stages and rotation state
+//	rotatectl rollback -secret-id X      set current password, remove pending secret
+//	rotatectl abort -secret-id X         remove pending secret if not in use
+//	rotatectl force-finish -secret-id X  make verified pending secret current
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+)
+
+var commands = map[string]string{
+	"rotate":                    "rotate the secret (all four steps)",
+	rotate.COMMAND_STATUS:       "print secret stages and rotation state",
+	rotate.COMMAND_ROLLBACK:     "set current password on databases, remove pending secret",
+	rotate.COMMAND_ABORT:        "remove pending secret if databases do not use it",
+	rotate.COMMAND_FORCE_FINISH: "verify pending secret and make it current",
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: rotatectl COMMAND -secret-id ID [options]\n\nCommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name])
+	}
+	fmt.Fprintf(os.Stderr, "\nRun 'rotatectl COMMAND -h' for options.\n")
+}
+
+// options are the command-line options common to all commands.
+type options struct {
+	secretId        string
+	adminSecretId   string
+	dryRun          bool
+	skipDatabase    bool
+	parallel        uint
+	force           bool
+	secretTagPrefix string
+}
+
+func main() {
+	if len(os.Args) < 2 || commands[os.Args[1]] == "" {
+		usage()
+		os.Exit(2)
+	}
+	command := os.Args[1]
+
+	opts := options{}
+	fs := flag.NewFlagSet(command, flag.ExitOnError)
+	fs.StringVar(&opts.secretId, "secret-id", "", "Secrets Manager secret ID or ARN (required)")
+	fs.StringVar(&opts.adminSecretId, "admin-secret-id", "", "Secrets Manager secret ID of admin credentials (optional)")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "do not change passwords on databases; print the statements not executed")
+	fs.BoolVar(&opts.skipDatabase, "skip-database", false, "do not set, verify, or roll back passwords on databases")
+	noTLS := fs.Bool("no-tls", false, "do not use TLS to connect to databases")
+	fs.UintVar(&opts.parallel, "parallel", 1, "number of databases to change in parallel")
+	fs.BoolVar(&opts.force, "force", false, "abort even if databases use the pending password")
+	timeout := fs.Duration("timeout", 15*time.Minute, "maximum run time, like the Lambda timeout")
+	fs.StringVar(&opts.secretTagPrefix, "secret-tag-prefix", "", "read per-secret settings from secret tags with this prefix")
+	debug := fs.Bool("debug", false, "print debug output")
+	fs.Parse(os.Args[2:])
+	if opts.secretId == "" {
+		fmt.Fprintln(os.Stderr, "-secret-id is required")
+		fs.Usage()
+		os.Exit(2)
+	}
+	rotate.Debug = *debug
+	log.SetOutput(rotate.RedactWriter{W: os.Stderr}) // redact passwords from log output
+
+	// Start AWS session using env vars and shared config (~/.aws)
+	sess, err := session.NewSessionWithOptions(session.Options{
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		fatal("error making AWS session: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	err = run(ctx, command, opts, secretsmanager.New(sess), rds.New(sess), mysql.NewRDSClient(!*noTLS, opts.dryRun), os.Stdout)
+	if err != nil {
+		fatal("%s", err)
+	}
+}
+
+// run runs the command with the AWS and database clients, and prints the result
+// to out. It's separate from main so the wiring can be tested with mock clients.
+func run(ctx context.Context, command string, opts options, sm rotate.SecretsManager, rdsClient rdsiface.RDSAPI, dbClient mysql.PasswordClient, out io.Writer) error {
+	// Same wiring as the Lambda function (examples/rds)
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  dbClient,
+		Parallel:  opts.parallel,
+	})
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager:  sm,
+		PasswordSetter:  ps,
+		SkipDatabase:    opts.skipDatabase,
+		SecretTagPrefix: opts.secretTagPrefix,
+		AdminSecretId:   opts.adminSecretId,
+		UserCommands:    true,
+	})
+
+	if command == "rotate" {
+		version, err := r.Rotate(ctx, opts.secretId)
+		if opts.dryRun {
+			printDryRunReport(out, ps)
+		}
+		if err != nil {
+			return fmt.Errorf("rotation failed (version ID %s): %s", version, err)
+		}
+		fmt.Fprintf(out, "rotated secret %s: new version ID %s\n", opts.secretId, version)
+		return nil
+	}
+
+	event := map[string]string{
+		"command":  command,
+		"SecretId": opts.secretId,
+	}
+	if opts.force {
+		event["force"] = "true"
+	}
+	res, err := r.Handler(ctx, event)
+	if opts.dryRun {
+		printDryRunReport(out, ps)
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %s", command, err)
+	}
+	if res != nil {
+		bytes, _ := json.MarshalIndent(res, "", "  ")
+		fmt.Fprintln(out, string(bytes))
+		return nil
+	}
+	fmt.Fprintf(out, "%s ok\n", command)
+	return nil
+}
+
+// printDryRunReport prints the statements not executed on databases because of
+// -dry-run, so they can be reviewed before rotating for real.
+func printDryRunReport(out io.Writer, ps *mysql.PasswordSetter) {
+	report := ps.DryRunReport()
+	if len(report) == 0 {
+		fmt.Fprintln(out, "dry run: no database statements")
+		return
+	}
+	bytes, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Fprintf(out, "dry run: %d database statements not executed:\n%s\n", len(report), bytes)
+}
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "rotatectl: "+format+"\n", args...)
+	os.Exit(1)
+}
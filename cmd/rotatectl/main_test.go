@@ -0,0 +1,76 @@
+// Copyright 2026, Square, Inc.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestRunRollback(t *testing.T) {
+	// Test that rollback sets the current password on the databases that use
+	// the pending password, then removes the pending secret
+	sm := test.NewFakeSecretsManager()
+	sm.AddSecret("def", map[string]string{"username": "foo", "password": "p1"})
+	_, err := sm.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:           aws.String("def"),
+		ClientRequestToken: aws.String("v2"),
+		SecretString:       aws.String(`{"username":"foo","password":"p2"}`),
+		VersionStages:      []*string{aws.String(rotate.AWSPENDING)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("db1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("db2")}},
+				},
+			}, nil
+		},
+	}
+	mux := &sync.Mutex{}
+	passwords := map[string]string{"db1": "p2", "db2": "p2"} // pending password set
+	dbClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			defer mux.Unlock()
+			if passwords[creds.Current.Hostname] != creds.Current.Password {
+				return fmt.Errorf("access denied")
+			}
+			passwords[creds.Current.Hostname] = creds.New.Password
+			return nil
+		},
+	}
+
+	out := &bytes.Buffer{}
+	opts := options{secretId: "def", parallel: 1}
+	if err := run(context.TODO(), rotate.COMMAND_ROLLBACK, opts, sm, rdsClient, dbClient, out); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{"db1": "p1", "db2": "p1"}
+	if diff := deep.Equal(passwords, expect); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(sm.Stages("def"), map[string]string{rotate.AWSCURRENT: "v1"}); diff != nil {
+		t.Error(diff)
+	}
+	if out.String() != "rollback ok\n" {
+		t.Errorf("got output %q, expected \"rollback ok\\n\"", out.String())
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// User event commands. Send a user event like {"command":"status","SecretId":"my-secret"}
+// to run a command. Config.UserCommands must be true.
+const (
+	// COMMAND_STATUS returns the version IDs of each stage, rotation dates,
+	// and the rotation state if Config.StateStore is set. It changes nothing.
+	COMMAND_STATUS = "status"
+
+	// COMMAND_FORCE_FINISH verifies the pending password on the databases, then
+	// makes the pending secret current like FinishSecret. Use it when a rotation
+	// set the new password but failed to finish. If Config.SkipDatabase is true,
+	// the pending password is not verified.
+	COMMAND_FORCE_FINISH = "force-finish"
+
+	// COMMAND_ABORT removes AWSPENDING from the pending secret so the next rotation
+	// can start. It returns an error if the databases use the pending password
+	// because then the pending secret is the only copy of the password; set
+	// "force":"true" in the event to abort anyway.
+	COMMAND_ABORT = "abort"
+
+	// COMMAND_ROLLBACK sets the current password on the databases, then removes
+	// AWSPENDING from the pending secret like COMMAND_ABORT. Use it to undo a
+	// rotation that set the new password but failed to finish. The pending secret
+	// is not removed if setting the current password fails on any database.
+	COMMAND_ROLLBACK = "rollback"
+
+	// COMMAND_DISCARD_OLD_PASSWORD discards the old password on the databases
+	// (see Config.DiscardOldPassword) if Config.DiscardGracePeriod has passed
+	// since the last rotation. It does nothing if the grace period has not
+	// passed or a rotation is in progress. Run it on a schedule.
+	COMMAND_DISCARD_OLD_PASSWORD = "discard-old-password"
+)
+
+// commands returns the sorted list of command names for error messages.
+func commands() string {
+	c := []string{COMMAND_STATUS, COMMAND_FORCE_FINISH, COMMAND_ABORT, COMMAND_ROLLBACK, COMMAND_DISCARD_OLD_PASSWORD}
+	sort.Strings(c)
+	return strings.Join(c, ", ")
+}
+
+// command runs a user event command. It is called by Handler if Config.UserCommands
+// is true and the user event has a "command" value.
+func (r *Rotator) command(ctx context.Context, event map[string]string) (map[string]string, error) {
+	command := event["command"]
+	secretId := event["SecretId"]
+	if secretId == "" {
+		return nil, fmt.Errorf("command %s: SecretId is required in the event", command)
+	}
+	log.Printf("command %s for secret %s", command, secretId)
+	r.secretId = secretId
+	r.event.secretId = secretId
+
+	switch command {
+	case COMMAND_STATUS:
+		return r.status(ctx)
+	case COMMAND_FORCE_FINISH:
+		return nil, r.forceFinish(ctx)
+	case COMMAND_ABORT:
+		return nil, r.abort(ctx, event["force"] == "true")
+	case COMMAND_ROLLBACK:
+		return nil, r.rollbackPending(ctx)
+	case COMMAND_DISCARD_OLD_PASSWORD:
+		return r.discardCommand(ctx)
+	}
+	return nil, fmt.Errorf("invalid command: %s: valid commands are: %s", command, commands())
+}
+
+func (r *Rotator) status(ctx context.Context) (map[string]string, error) {
+	out, err := r.sm.DescribeSecret(&secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(r.secretId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	status := map[string]string{
+		"SecretId":        r.secretId,
+		"RotationEnabled": strconv.FormatBool(aws.BoolValue(out.RotationEnabled)),
+	}
+	for versionId, stages := range out.VersionIdsToStages {
+		for _, stage := range stages {
+			status[aws.StringValue(stage)] = versionId
+		}
+	}
+	if out.LastRotatedDate != nil {
+		status["LastRotatedDate"] = out.LastRotatedDate.UTC().Format(time.RFC3339)
+	}
+	if out.NextRotationDate != nil {
+		status["NextRotationDate"] = out.NextRotationDate.UTC().Format(time.RFC3339)
+	}
+
+	if pending, ok := status[AWSPENDING]; ok && r.stateStore != nil {
+		state, err := r.stateStore.Load(ctx, r.secretId, pending)
+		if err != nil {
+			return nil, fmt.Errorf("cannot load rotation state: %s", err)
+		}
+		if state.Step != "" {
+			status["Step"] = state.Step
+			status["StepTime"] = state.StepTime.UTC().Format(time.RFC3339)
+		}
+	}
+	return status, nil
+}
+
+// pending returns the version ID and values of the pending secret, and the
+// current secret values. It returns an error if there is no pending secret
+// or it's also current.
+func (r *Rotator) pending(ctx context.Context) (string, map[string]string, map[string]string, error) {
+	curSec, curVals, err := r.getSecret(AWSCURRENT)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	penSec, newVals, err := r.getSecret(AWSPENDING)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("cannot get pending secret: %s", err)
+	}
+	if *penSec.VersionId == *curSec.VersionId {
+		return "", nil, nil, fmt.Errorf("pending secret is also current (version ID %s)", *penSec.VersionId)
+	}
+	if err := r.ss.Init(ctx, map[string]string{"SecretId": r.secretId}); err != nil {
+		return "", nil, nil, err
+	}
+	if err := r.db.Init(ctx, map[string]string{"SecretId": r.secretId}); err != nil {
+		return "", nil, nil, err
+	}
+	r.admin = nil
+	if err := r.loadAdmin(); err != nil {
+		return "", nil, nil, err
+	}
+	return *penSec.VersionId, curVals, newVals, nil
+}
+
+func (r *Rotator) forceFinish(ctx context.Context) error {
+	versionId, curVals, newVals, err := r.pending(ctx)
+	if err != nil {
+		return err
+	}
+	r.clientRequestToken = versionId
+	r.event.versionId = versionId
+	if r.skipDatabase() {
+		log.Println("SkipDatabase is enabled, not verifying pending password on database")
+	} else if err := r.db.VerifyPassword(ctx, r.dbCreds(curVals, newVals)); err != nil {
+		return fmt.Errorf("pending password does not work on databases, not finishing: %s", err)
+	}
+	return r.FinishSecret(ctx, map[string]string{"SecretId": r.secretId})
+}
+
+func (r *Rotator) abort(ctx context.Context, force bool) error {
+	versionId, curVals, newVals, err := r.pending(ctx)
+	if err != nil {
+		return err
+	}
+	r.clientRequestToken = versionId
+	r.event.versionId = versionId
+	if !force && !r.skipDatabase() {
+		// Probe because the pending password should not work
+		if err := r.db.VerifyPassword(db.WithProbe(ctx), r.dbCreds(curVals, newVals)); err == nil {
+			return fmt.Errorf("databases use the pending password (version ID %s), not aborting: "+
+				"run %s, or set \"force\":\"true\" to abort anyway", versionId, COMMAND_FORCE_FINISH)
+		}
+	}
+	return r.removePending(ctx, versionId)
+}
+
+func (r *Rotator) rollbackPending(ctx context.Context) error {
+	versionId, curVals, newVals, err := r.pending(ctx)
+	if err != nil {
+		return err
+	}
+	r.clientRequestToken = versionId
+	r.event.versionId = versionId
+	// Set the current password like SetPassword, not Rollback, because Rollback
+	// reverses only what SetPassword changed in this invocation, which is nothing.
+	// The pending secret is removed only if the databases use the current password.
+	if r.skipDatabase() {
+		log.Println("SkipDatabase is enabled, not rolling back password on database")
+	} else if err := r.db.SetPassword(ctx, r.dbCreds(curVals, newVals).Swap()); err != nil {
+		return fmt.Errorf("rollback failed, pending secret not removed: %s", err)
+	}
+	return r.removePending(ctx, versionId)
+}
+
+// cleanupPending removes the pending secret if the databases use the current
+// password. It's called on step failure if Config.CleanupOnFailure is true.
+func (r *Rotator) cleanupPending(ctx context.Context) error {
+	versionId, curVals, _, err := r.pending(ctx)
+	if err != nil {
+		return err
+	}
+	if versionId != r.clientRequestToken {
+		return fmt.Errorf("pending secret is not from this rotation: version ID %s", versionId)
+	}
+	if !r.skipDatabase() {
+		if err := r.db.VerifyPassword(ctx, r.dbCreds(curVals, curVals)); err != nil {
+			return fmt.Errorf("databases might use the pending password, current password does not work: %s", err)
+		}
+	}
+	return r.removePending(ctx, versionId)
+}
+
+// removePending removes AWSPENDING from the secret version and unlocks the secret.
+func (r *Rotator) removePending(ctx context.Context, versionId string) error {
+	log.Printf("removing AWSPENDING from version id = %s", versionId)
+	_, err := r.sm.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
+		SecretId:            aws.String(r.secretId),
+		RemoveFromVersionId: aws.String(versionId),
+		VersionStage:        aws.String(AWSPENDING),
+	})
+	if err != nil {
+		return err
+	}
+	r.unlock(ctx)
+	return nil
+}
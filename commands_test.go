@@ -0,0 +1,330 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func commandSecretsManager(gotUpdates *[]string) test.MockSecretsManager {
+	return test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			*gotUpdates = append(*gotUpdates, fmt.Sprintf("%s move %s remove %s",
+				aws.StringValue(input.VersionStage), aws.StringValue(input.MoveToVersionId), aws.StringValue(input.RemoveFromVersionId)))
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{
+				RotationEnabled: aws.Bool(true),
+				LastRotatedDate: aws.Time(now),
+				VersionIdsToStages: map[string][]*string{
+					"v0": {aws.String(rotate.AWSPREVIOUS)},
+					"v1": {aws.String(rotate.AWSCURRENT)},
+					"v2": {aws.String(rotate.AWSPENDING)},
+				},
+			}, nil
+		},
+	}
+}
+
+func TestCommandStatus(t *testing.T) {
+	var gotUpdates []string
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: commandSecretsManager(&gotUpdates),
+		PasswordSetter: test.MockPasswordSetter{},
+		UserCommands:   true,
+	})
+	got, err := r.Handler(context.TODO(), map[string]string{"command": "status", "SecretId": "def"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"SecretId":         "def",
+		"RotationEnabled":  "true",
+		"LastRotatedDate":  now.UTC().Format(time.RFC3339),
+		rotate.AWSPREVIOUS: "v0",
+		rotate.AWSCURRENT:  "v1",
+		rotate.AWSPENDING:  "v2",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+	if gotUpdates != nil {
+		t.Errorf("status changed secret stages: %v", gotUpdates)
+	}
+
+	// Invalid command and missing SecretId are errors
+	if _, err := r.Handler(context.TODO(), map[string]string{"command": "nope", "SecretId": "def"}); err == nil {
+		t.Error("no error for invalid command")
+	}
+	if _, err := r.Handler(context.TODO(), map[string]string{"command": "status"}); err == nil {
+		t.Error("no error without SecretId")
+	}
+}
+
+func TestCommandDisabled(t *testing.T) {
+	// Without Config.UserCommands, user events with "command" go to SecretSetter.Handler
+	var gotEvent map[string]string
+	var gotUpdates []string
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: commandSecretsManager(&gotUpdates),
+		PasswordSetter: test.MockPasswordSetter{},
+		SecretSetter: test.MockSecretSetter{
+			HandlerFunc: func(ctx context.Context, event map[string]string) (map[string]string, error) {
+				gotEvent = event
+				return nil, nil
+			},
+		},
+	})
+	event := map[string]string{"command": "abort", "SecretId": "def"}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotEvent, event); diff != nil {
+		t.Error(diff)
+	}
+	if gotUpdates != nil {
+		t.Errorf("secret stages changed: %v", gotUpdates)
+	}
+}
+
+func TestCommandForceFinish(t *testing.T) {
+	var gotUpdates []string
+	verifyErr := fmt.Errorf("access denied")
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: commandSecretsManager(&gotUpdates),
+		PasswordSetter: test.MockPasswordSetter{
+			VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				return verifyErr
+			},
+		},
+		UserCommands: true,
+	})
+	event := map[string]string{"command": "force-finish", "SecretId": "def"}
+
+	// Pending password does not work, so it must not be made current
+	if _, err := r.Handler(context.TODO(), event); err == nil {
+		t.Error("no error when pending password does not verify")
+	}
+	if gotUpdates != nil {
+		t.Errorf("secret stages changed: %v", gotUpdates)
+	}
+
+	verifyErr = nil
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"AWSCURRENT move v2 remove v1",
+		"AWSPENDING move  remove v2",
+	}
+	if diff := deep.Equal(gotUpdates, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestCommandAbort(t *testing.T) {
+	var gotUpdates []string
+	var verifyErr error // nil = databases use pending password
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: commandSecretsManager(&gotUpdates),
+		PasswordSetter: test.MockPasswordSetter{
+			VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				return verifyErr
+			},
+		},
+		UserCommands: true,
+	})
+	event := map[string]string{"command": "abort", "SecretId": "def"}
+
+	// Databases use the pending password, so it must not be removed
+	if _, err := r.Handler(context.TODO(), event); err == nil {
+		t.Error("no error when databases use the pending password")
+	}
+	if gotUpdates != nil {
+		t.Errorf("secret stages changed: %v", gotUpdates)
+	}
+
+	// Unless forced
+	event["force"] = "true"
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{"AWSPENDING move  remove v2"}
+	if diff := deep.Equal(gotUpdates, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Or the databases do not use the pending password
+	gotUpdates = nil
+	verifyErr = fmt.Errorf("access denied")
+	delete(event, "force")
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotUpdates, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestCommandRollback(t *testing.T) {
+	var gotUpdates []string
+	var gotRollback db.NewPassword
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: commandSecretsManager(&gotUpdates),
+		PasswordSetter: test.MockPasswordSetter{
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				gotRollback = creds
+				return nil
+			},
+		},
+		UserCommands: true,
+	})
+	event := map[string]string{"command": "rollback", "SecretId": "def"}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	// Current password is set on the databases that use the pending password
+	if gotRollback.Current.Password != "p2" || gotRollback.New.Password != "p1" {
+		t.Errorf("got rollback creds %+v, expected current p2 and new p1", gotRollback)
+	}
+	expect := []string{"AWSPENDING move  remove v2"}
+	if diff := deep.Equal(gotUpdates, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestCommandRollbackMySQL(t *testing.T) {
+	// Test that rollback sets the current password on every host with the real
+	// mysql.PasswordSetter, which has set nothing in this invocation, and that
+	// the pending secret is kept if a host fails
+	var gotUpdates []string
+	var gotSet []string
+	var setErr error
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("db1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("db2")}},
+				},
+			}, nil
+		},
+	}
+	dbClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotSet = append(gotSet, fmt.Sprintf("%s@%s %s->%s", creds.Current.Username, creds.Current.Hostname,
+				creds.Current.Password, creds.New.Password))
+			return setErr
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: commandSecretsManager(&gotUpdates),
+		PasswordSetter: mysql.NewPasswordSetter(mysql.Config{
+			RDSClient: rdsClient,
+			DbClient:  dbClient,
+		}),
+		UserCommands: true,
+	})
+	event := map[string]string{"command": "rollback", "SecretId": "def"}
+
+	setErr = fmt.Errorf("access denied")
+	if _, err := r.Handler(context.TODO(), event); err == nil {
+		t.Error("no error when current password was not set")
+	}
+	if gotUpdates != nil {
+		t.Errorf("secret stages changed: %v", gotUpdates)
+	}
+
+	setErr = nil
+	gotSet = nil
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(gotSet)
+	expectSet := []string{"foo@db1 p2->p1", "foo@db2 p2->p1"}
+	if diff := deep.Equal(gotSet, expectSet); diff != nil {
+		t.Error(diff)
+	}
+	expect := []string{"AWSPENDING move  remove v2"}
+	if diff := deep.Equal(gotUpdates, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestCleanupOnFailure(t *testing.T) {
+	// Test that the pending secret is removed when setSecret fails without
+	// rolling back, but only if the databases use the current password
+	var gotUpdates []string
+	verifyErr := fmt.Errorf("access denied")
+	newRotator := func(cleanup bool) *rotate.Rotator {
+		return rotate.NewRotator(rotate.Config{
+			SecretsManager: commandSecretsManager(&gotUpdates),
+			PasswordSetter: test.MockPasswordSetter{
+				VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+					return verifyErr
+				},
+			},
+			StepHooks:        &mockStepHooks{before: fmt.Errorf("db unreachable")},
+			CleanupOnFailure: cleanup,
+		})
+	}
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+
+	// Disabled
+	if _, err := newRotator(false).Handler(context.TODO(), event); err == nil {
+		t.Fatal("no error, expected BeforeStep error")
+	}
+	if gotUpdates != nil {
+		t.Errorf("secret stages changed: %v", gotUpdates)
+	}
+
+	// Current password does not work, so databases might use the pending password
+	if _, err := newRotator(true).Handler(context.TODO(), event); err == nil {
+		t.Fatal("no error, expected BeforeStep error")
+	}
+	if gotUpdates != nil {
+		t.Errorf("secret stages changed: %v", gotUpdates)
+	}
+
+	verifyErr = nil
+	if _, err := newRotator(true).Handler(context.TODO(), event); err == nil {
+		t.Fatal("no error, expected BeforeStep error")
+	}
+	expect := []string{"AWSPENDING move  remove v2"}
+	if diff := deep.Equal(gotUpdates, expect); diff != nil {
+		t.Error(diff)
+	}
+}
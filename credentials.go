@@ -0,0 +1,94 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// FullCredentials is an optional interface that a SecretSetter implements to
+// return all database credentials from the secret, not just the username and
+// password: port, database, TLS, and extra values. Rotator uses DBCredentials
+// instead of Credentials, and the values are plumbed through to the PasswordSetter,
+// so the PasswordSetter does not have to parse the secret.
+//
+// RandomPassword, AWSRandomPassword, and MultiRandomPassword implement this
+// interface by calling ParseCredentials.
+type FullCredentials interface {
+	DBCredentials(secret map[string]string) db.Credentials
+}
+
+// Standard secret keys used by ParseCredentials. These are the same keys used
+// by RDS and AWS-provided rotation functions, except tls.
+const (
+	SECRET_KEY_USERNAME = "username"
+	SECRET_KEY_PASSWORD = "password"
+	SECRET_KEY_PORT     = "port"
+	SECRET_KEY_DATABASE = "dbname"
+	SECRET_KEY_TLS      = "tls"
+)
+
+// ParseCredentials returns the database credentials from secret keys with the
+// given prefix (usually no prefix): username, password, port, dbname, and tls.
+// All other keys with the prefix are returned in Extra, without the prefix,
+// except keys ending in password.
+// Hostname is not set because the PasswordSetter sets it for each database.
+// An invalid port is logged and ignored.
+func ParseCredentials(prefix string, secret map[string]string) db.Credentials {
+	creds := db.Credentials{
+		Username: secret[prefix+SECRET_KEY_USERNAME],
+		Password: secret[prefix+SECRET_KEY_PASSWORD],
+		Database: secret[prefix+SECRET_KEY_DATABASE],
+		TLS:      secret[prefix+SECRET_KEY_TLS],
+	}
+	if v := secret[prefix+SECRET_KEY_PORT]; v != "" {
+		port, err := strconv.Atoi(v)
+		if err != nil || port <= 0 || port > 65535 {
+			log.Printf("invalid port in secret: %s: ignoring", v)
+		} else {
+			creds.Port = port
+		}
+	}
+	for k, v := range secret {
+		if !strings.HasPrefix(k, prefix) || strings.HasSuffix(k, SECRET_KEY_PASSWORD) {
+			continue // not our key, or a password (of any account)
+		}
+		switch k = strings.TrimPrefix(k, prefix); k {
+		case SECRET_KEY_USERNAME, SECRET_KEY_PORT, SECRET_KEY_DATABASE, SECRET_KEY_TLS:
+			continue
+		}
+		if creds.Extra == nil {
+			creds.Extra = map[string]string{}
+		}
+		creds.Extra[k] = v
+	}
+	return creds
+}
+
+var _ FullCredentials = RandomPassword{}
+var _ FullCredentials = AWSRandomPassword{}
+
+func (s RandomPassword) DBCredentials(secret map[string]string) db.Credentials {
+	return ParseCredentials("", secret)
+}
+
+func (s AWSRandomPassword) DBCredentials(secret map[string]string) db.Credentials {
+	return ParseCredentials("", secret)
+}
+
+// secretCredentials returns the database credentials from the secret using
+// FullCredentials if the SecretSetter implements it, else Credentials.
+func secretCredentials(ss SecretSetter, secret map[string]string) db.Credentials {
+	if fc, ok := ss.(FullCredentials); ok {
+		return fc.DBCredentials(secret)
+	}
+	username, password := ss.Credentials(secret)
+	return db.Credentials{
+		Username: username,
+		Password: password,
+	}
+}
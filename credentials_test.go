@@ -0,0 +1,68 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+func TestParseCredentials(t *testing.T) {
+	secret := map[string]string{
+		"username":    "app",
+		"password":    "p1",
+		"port":        "3307",
+		"dbname":      "orders",
+		"tls":         "skip-verify",
+		"engine":      "mysql",
+		"ro_username": "app_ro",
+		"ro_password": "p2",
+		"ro_port":     "bad",
+	}
+
+	got := rotate.ParseCredentials("", secret)
+	expect := db.Credentials{
+		Username: "app",
+		Password: "p1",
+		Port:     3307,
+		Database: "orders",
+		TLS:      "skip-verify",
+		Extra: map[string]string{
+			"engine":      "mysql",
+			"ro_username": "app_ro",
+			"ro_port":     "bad",
+		},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Invalid port is ignored
+	got = rotate.ParseCredentials("ro_", secret)
+	expect = db.Credentials{
+		Username: "app_ro",
+		Password: "p2",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// MultiRandomPassword removes keys of other accounts from Extra
+	ss := rotate.MultiRandomPassword{Accounts: []string{"", "ro_"}}
+	got = ss.DBCredentials(secret)
+	expect = db.Credentials{
+		Username: "app",
+		Password: "p1",
+		Port:     3307,
+		Database: "orders",
+		TLS:      "skip-verify",
+		Extra:    map[string]string{"engine": "mysql"},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
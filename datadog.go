@@ -0,0 +1,163 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// DEFAULT_DOGSTATSD_ADDR is the DatadogReceiver address if none is set. It is
+// the default address of the Datadog Agent and the Datadog Lambda extension.
+const DEFAULT_DOGSTATSD_ADDR = "127.0.0.1:8125"
+
+// DatadogReceiver is an EventReceiver that sends rotation events and metrics
+// to the Datadog Agent (or Datadog Lambda extension) using DogStatsD over UDP.
+// Metrics are prefixed "password_rotation." and are:
+//
+//	step.duration      distribution  ms, EVENT_END_STEP, tags step and status (ok or error)
+//	step.failures      count         EVENT_ERROR, tag step
+//	rollbacks          count         EVENT_BEGIN_PASSWORD_ROLLBACK
+//	rotations          count         EVENT_END_ROTATION
+//	rotation.duration  distribution  ms, EVENT_END_ROTATION
+//	password.downtime  distribution  ms, EVENT_NEW_PASSWORD_IS_CURRENT
+//	host.failures      count         db.HostObserver, tags hostname and action
+//
+// Datadog events are sent for EVENT_BEGIN_ROTATION, EVENT_END_ROTATION,
+// EVENT_BEGIN_PASSWORD_ROLLBACK, EVENT_PASSWORD_ROTATION_INTERRUPTED, and
+// EVENT_ERROR. All metrics and events are tagged "secret:<name>" (see
+// Event.SecretId), "engine:<engine>" if set, and the extra tags.
+//
+// Create a DatadogReceiver by calling NewDatadogReceiver. It is safe for
+// concurrent use by multiple goroutines. Send errors are logged and ignored.
+type DatadogReceiver struct {
+	addr   string
+	engine string
+	tags   []string
+	// --
+	mux      *sync.Mutex
+	conn     net.Conn
+	secretId string // from last event, for ObserveHost
+}
+
+var _ EventReceiver = &DatadogReceiver{}
+var _ db.HostObserver = &DatadogReceiver{}
+
+// NewDatadogReceiver creates a new DatadogReceiver that sends to the DogStatsD
+// address, or DEFAULT_DOGSTATSD_ADDR if empty. engine is the database engine,
+// like "mysql", for the engine tag; it is optional. tags are extra tags, like
+// "env:prod".
+func NewDatadogReceiver(addr, engine string, tags ...string) *DatadogReceiver {
+	if addr == "" {
+		addr = DEFAULT_DOGSTATSD_ADDR
+	}
+	return &DatadogReceiver{
+		addr:   addr,
+		engine: engine,
+		tags:   tags,
+		mux:    &sync.Mutex{},
+	}
+}
+
+// Receive sends the metrics and events for the event.
+func (d *DatadogReceiver) Receive(e Event) {
+	d.mux.Lock()
+	d.secretId = e.SecretId
+	d.mux.Unlock()
+
+	tags := d.eventTags(e.SecretId)
+	switch e.Name {
+	case EVENT_END_STEP:
+		status := "ok"
+		if e.Error != nil {
+			status = "error"
+		}
+		d.send(dogMetric("step.duration", e.Duration.Milliseconds(), "d", tags, "step:"+e.Step, "status:"+status))
+	case EVENT_ERROR:
+		d.send(dogMetric("step.failures", 1, "c", tags, "step:"+e.Step))
+		d.send(dogEvent("Password rotation failed", fmt.Sprintf("%s failed: %v", e.Step, e.Error), "error", tags))
+	case EVENT_BEGIN_PASSWORD_ROLLBACK:
+		d.send(dogMetric("rollbacks", 1, "c", tags))
+		d.send(dogEvent("Password rollback", fmt.Sprintf("%s rolling back password", e.Step), "warning", tags))
+	case EVENT_PASSWORD_ROTATION_INTERRUPTED:
+		d.send(dogEvent("Password rotation interrupted", fmt.Sprintf("%s interrupted: %v", e.Step, e.Error), "error", tags))
+	case EVENT_BEGIN_ROTATION:
+		d.send(dogEvent("Password rotation started", "createSecret made new secret", "info", tags))
+	case EVENT_END_ROTATION:
+		d.send(dogMetric("rotations", 1, "c", tags))
+		if e.Duration > 0 {
+			d.send(dogMetric("rotation.duration", e.Duration.Milliseconds(), "d", tags))
+		}
+		d.send(dogEvent("Password rotation completed", "new secret is current", "success", tags))
+	case EVENT_NEW_PASSWORD_IS_CURRENT:
+		if e.Duration > 0 {
+			d.send(dogMetric("password.downtime", e.Duration.Milliseconds(), "d", tags))
+		}
+	}
+}
+
+// ObserveHost counts failed password actions on one database host.
+func (d *DatadogReceiver) ObserveHost(hostname, action string, dur time.Duration, err error) {
+	if err == nil {
+		return
+	}
+	d.mux.Lock()
+	secretId := d.secretId
+	d.mux.Unlock()
+	d.send(dogMetric("host.failures", 1, "c", d.eventTags(secretId), "hostname:"+hostname, "action:"+action))
+}
+
+func (d *DatadogReceiver) eventTags(secretId string) []string {
+	tags := make([]string, 0, len(d.tags)+2)
+	tags = append(tags, "secret:"+secretName(secretId))
+	if d.engine != "" {
+		tags = append(tags, "engine:"+d.engine)
+	}
+	return append(tags, d.tags...)
+}
+
+// dogEvent returns a DogStatsD event datagram.
+func dogEvent(title, text, alertType string, tags []string) string {
+	text = strings.ReplaceAll(text, "\n", "\\n")
+	return fmt.Sprintf("_e{%d,%d}:%s|%s|t:%s|s:password_rotation|#%s", len(title), len(text), title, text, alertType, joinTags(tags))
+}
+
+// send sends one DogStatsD datagram, connecting on first use.
+func (d *DatadogReceiver) send(datagram string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	if d.conn == nil {
+		conn, err := net.Dial("udp", d.addr)
+		if err != nil {
+			log.Printf("ERROR: DogStatsD %s: %s", d.addr, err)
+			return
+		}
+		d.conn = conn
+	}
+	if _, err := d.conn.Write([]byte(datagram)); err != nil {
+		log.Printf("ERROR: DogStatsD %s: %s", d.addr, err)
+	}
+}
+
+// dogMetric returns a DogStatsD metric datagram.
+func dogMetric(name string, value int64, typ string, tags []string, more ...string) string {
+	return fmt.Sprintf("password_rotation.%s:%d|%s|#%s", name, value, typ, joinTags(append(append([]string{}, tags...), more...)))
+}
+
+// dogTagReplacer replaces characters that DogStatsD does not allow in tags.
+var dogTagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_")
+
+// joinTags joins the tags for a DogStatsD datagram.
+func joinTags(tags []string) string {
+	safe := make([]string, len(tags))
+	for i := range tags {
+		safe[i] = dogTagReplacer.Replace(tags[i])
+	}
+	return strings.Join(safe, ",")
+}
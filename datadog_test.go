@@ -0,0 +1,48 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+func TestDatadogReceiver(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	d := rotate.NewDatadogReceiver(pc.LocalAddr().String(), "mysql", "env:test")
+	d.Receive(rotate.Event{Name: rotate.EVENT_END_STEP, SecretId: "db", Step: "setSecret", Duration: 1500 * time.Millisecond})
+	d.Receive(rotate.Event{Name: rotate.EVENT_ERROR, SecretId: "db", Step: "testSecret", Error: errors.New("bad")})
+	d.ObserveHost("db1", "verify", time.Millisecond, errors.New("bad"))
+	d.ObserveHost("db2", "verify", time.Millisecond, nil) // not sent
+
+	expect := []string{
+		"password_rotation.step.duration:1500|d|#secret:db,engine:mysql,env:test,step:setSecret,status:ok",
+		"password_rotation.step.failures:1|c|#secret:db,engine:mysql,env:test,step:testSecret",
+		"_e{24,22}:Password rotation failed|testSecret failed: bad|t:error|s:password_rotation|#secret:db,engine:mysql,env:test",
+		"password_rotation.host.failures:1|c|#secret:db,engine:mysql,env:test,hostname:db1,action:verify",
+	}
+	got := []string{}
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	for range expect {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, string(buf[:n]))
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -4,6 +4,7 @@ package db
 
 import (
 	"context"
+	"time"
 )
 
 // Credentials represents one set of database credentials.
@@ -11,6 +12,25 @@ type Credentials struct {
 	Username string
 	Hostname string
 	Password string
+
+	// Port is the database port. If zero, the database default is used (3306
+	// for MySQL). A PasswordSetter that discovers hostnames, like mysql.PasswordSetter,
+	// sets Hostname and, if known, Port of each host, which overrides Port from
+	// the secret.
+	Port int
+
+	// Database is the default database (schema) to connect to. Usually it's
+	// empty because a database is not needed to set or verify passwords.
+	Database string
+
+	// TLS overrides the PasswordSetter TLS option: "true", "false", "skip-verify",
+	// "preferred", or a TLS config name registered with the database driver.
+	// If empty, the PasswordSetter TLS option is used.
+	TLS string
+
+	// Extra are other values from the secret that a PasswordSetter can use,
+	// like the engine. It never contains the password.
+	Extra map[string]string
 }
 
 // NewPassword represents current and new credentials. This is the primary
@@ -19,6 +39,71 @@ type Credentials struct {
 type NewPassword struct {
 	Current Credentials
 	New     Credentials
+
+	// Accounts are additional current and new credentials when one secret has
+	// multiple database accounts. A PasswordSetter must set, verify, and roll
+	// back all accounts (see All) as one unit: if any account fails, the whole
+	// set is rolled back. Accounts of Accounts are ignored.
+	Accounts []NewPassword
+
+	// Admin are optional privileged credentials, like the RDS master user.
+	// If set, a PasswordSetter should connect as the admin to change the password
+	// of the Current user, instead of connecting as the Current user. This is
+	// required for locked-down users that cannot change their own password.
+	// Admin.Hostname is not used; the admin connects to the Current hostname.
+	Admin *Credentials
+
+	// Changed are the secret keys (JSON fields) that differ between the current
+	// and new secret, in sorted order, like "password" and "hmac_key" if the
+	// SecretSetter rotates both. It is nil if no key changed, like when the
+	// current credentials are verified, in which case all fields are affected.
+	// See Affects and FieldSetter.
+	Changed []string
+}
+
+// All returns the credentials followed by all additional Accounts. Accounts
+// of the returned credentials are nil, and Admin and Changed are the same for all.
+func (np NewPassword) All() []NewPassword {
+	all := make([]NewPassword, 0, 1+len(np.Accounts))
+	all = append(all, NewPassword{Current: np.Current, New: np.New, Admin: np.Admin, Changed: np.Changed})
+	for _, a := range np.Accounts {
+		all = append(all, NewPassword{Current: a.Current, New: a.New, Admin: np.Admin, Changed: np.Changed})
+	}
+	return all
+}
+
+// Swap returns the credentials with Current and New swapped, including all
+// Accounts. It is used to roll back from the new to the current credentials.
+func (np NewPassword) Swap() NewPassword {
+	swap := NewPassword{Current: np.New, New: np.Current, Admin: np.Admin, Changed: np.Changed}
+	for _, a := range np.Accounts {
+		swap.Accounts = append(swap.Accounts, NewPassword{Current: a.New, New: a.Current})
+	}
+	return swap
+}
+
+// Affects returns true if any of the secret keys changed, or if Changed is nil.
+func (np NewPassword) Affects(keys []string) bool {
+	if np.Changed == nil {
+		return true
+	}
+	for _, k := range keys {
+		for _, c := range np.Changed {
+			if k == c {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FieldSetter is an optional interface that a PasswordSetter implements to
+// declare the secret keys (JSON fields) that it acts on, like "password" for
+// a database or "hmac_key" for a signing key backend. When a secret has fields
+// for several backends, rotate.MultiPasswordSetter calls a FieldSetter only if
+// one of its fields changed (see NewPassword.Affects).
+type FieldSetter interface {
+	Fields() []string
 }
 
 // PasswordSetter changes and verifies database passwords. A database-specific
@@ -47,3 +132,85 @@ type PasswordSetter interface {
 	// by SetPassword.
 	Rollback(ctx context.Context, creds NewPassword) error
 }
+
+// HostObserver observes the result of every password action on one database
+// host. A PasswordSetter calls it, if configured, after each set, verify, or
+// rollback on a host. rotate.Metrics implements this interface.
+//
+// HostObserver implementations must be safe for concurrent use by multiple goroutines.
+type HostObserver interface {
+	ObserveHost(hostname, action string, d time.Duration, err error)
+}
+
+// HostObservable is an optional interface that a PasswordSetter can implement
+// to accept a HostObserver from rotate.Rotator, which records per-host results
+// in its rotate.StateStore. The observer is in addition to any observer
+// configured by the user, and it replaces the previous one set by this method.
+type HostObservable interface {
+	SetHostObserver(HostObserver)
+}
+
+// Resumable is an optional interface that a PasswordSetter can implement to
+// resume SetPassword after it was interrupted, like a Lambda timeout. On retry,
+// rotate.Rotator calls Resume before SetPassword with the hostnames on which
+// the new password was set, which it records in its rotate.StateStore using
+// HostObservable. The next SetPassword must not set the password on those
+// hosts again, but Rollback must roll them back.
+type Resumable interface {
+	Resume(hostnames []string)
+}
+
+// Tunable is an optional interface that a PasswordSetter can implement to receive
+// per-secret settings. rotate.Rotator reads the settings from the secret tags
+// (see rotate.Config.SecretTagPrefix) and calls Tune before Init on every
+// invocation. Which settings are used, if any, is implementation-specific;
+// unknown settings should be ignored.
+type Tunable interface {
+	Tune(settings map[string]string) error
+}
+
+// OldPasswordDiscarder is an optional interface that a PasswordSetter can implement
+// if it can retain the old password when it sets the new password, like MySQL 8
+// dual passwords (see mysql.RDSClientOptions.RetainCurrentPassword).
+// DiscardOldPassword discards the old (secondary) password of the creds.New
+// users, so only the new password works. It is called by rotate.Rotator after
+// the new secret is current (see rotate.Config.DiscardOldPassword).
+type OldPasswordDiscarder interface {
+	DiscardOldPassword(ctx context.Context, creds NewPassword) error
+}
+
+// SessionKiller is an optional interface that a PasswordSetter can implement
+// to kill the database sessions of the creds.New users, so clients reconnect
+// with the new secret instead of keeping connections made with the old password.
+// It is called by rotate.Rotator after the new secret is current (see
+// rotate.Config.KillSessions).
+type SessionKiller interface {
+	KillSessions(ctx context.Context, creds NewPassword) error
+}
+
+// SessionCounter is an optional interface that a PasswordSetter can implement
+// to count the database sessions of the creds.New users that were connected
+// before the call, per database hostname. It is called by rotate.Rotator right
+// after the new secret is current, so the sessions were authenticated with the
+// old password, which shows the applications that have not reconnected with the
+// new secret (see rotate.Config.ReportLingeringSessions).
+type SessionCounter interface {
+	CountSessions(ctx context.Context, creds NewPassword) (map[string]int, error)
+}
+
+type probeKey struct{}
+
+// WithProbe returns a copy of ctx that marks VerifyPassword as a probe: a check
+// that is expected to fail, like rotate.Rotator checking whether the new password
+// is already set before setting it. A PasswordSetter should try a probe once
+// and not wait for the password to work, like waiting for replication, because
+// a failure answers the question. See IsProbe.
+func WithProbe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, probeKey{}, true)
+}
+
+// IsProbe returns true if ctx was returned by WithProbe.
+func IsProbe(ctx context.Context) bool {
+	probe, _ := ctx.Value(probeKey{}).(bool)
+	return probe
+}
@@ -0,0 +1,141 @@
+// Copyright 2026, Square, Inc.
+
+package mysql_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+// Benchmarks of large fleets with mock clients, so they measure PasswordSetter,
+// not MySQL. Run them with:
+//
+//	go test -run NONE -bench . -benchmem ./db/mysql/
+//
+// The ns/host metric is the time per db instance of one SetPassword call.
+
+// fleet returns an RDS client with n db instances in clusters of 5.
+func fleet(n int) test.MockRDSClient {
+	dbs := make([]*rds.DBInstance, n)
+	for i := range dbs {
+		dbs[i] = &rds.DBInstance{
+			DBInstanceIdentifier: aws.String(fmt.Sprintf("db-%d", i)),
+			DBClusterIdentifier:  aws.String(fmt.Sprintf("cluster-%d", i/5)),
+			Endpoint:             &rds.Endpoint{Address: aws.String(fmt.Sprintf("db-%d.rds", i)), Port: aws.Int64(3306)},
+		}
+	}
+	return test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{DBInstances: dbs}, nil
+		},
+	}
+}
+
+// latencyClient is a PasswordClient that takes d to set or verify a password,
+// like a database round trip.
+func latencyClient(d time.Duration) test.MockMySQLPasswordClient {
+	wait := func(ctx context.Context, creds db.NewPassword) error {
+		if d > 0 {
+			time.Sleep(d)
+		}
+		return nil
+	}
+	return test.MockMySQLPasswordClient{SetPasswordFunc: wait, VerifyPasswordFunc: wait}
+}
+
+// quiet discards log output, which is a line per db instance, for the benchmark.
+func quiet(b *testing.B) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func benchSetPassword(b *testing.B, cfg mysql.Config, n int) {
+	quiet(b)
+	ps := mysql.NewPasswordSetter(cfg)
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		b.Fatal(err)
+	}
+	creds := db.NewPassword{
+		Current: db.Credentials{Username: "user", Password: "old_pass"},
+		New:     db.Credentials{Username: "user", Password: "new_pass"},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := ps.SetPassword(context.TODO(), creds); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/host")
+}
+
+func BenchmarkPasswordSetterInit(b *testing.B) {
+	for _, n := range []int{500, 1000, 5000} {
+		b.Run(fmt.Sprintf("dbs=%d", n), func(b *testing.B) {
+			quiet(b)
+			rdsClient := fleet(n)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				ps := mysql.NewPasswordSetter(mysql.Config{RDSClient: rdsClient, DbClient: latencyClient(0)})
+				if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkPasswordSetterScheduling(b *testing.B) {
+	// No database latency, so this is the scheduling overhead of setAll
+	for _, n := range []int{500, 1000, 5000} {
+		for _, parallel := range []uint{1, 10, 100} {
+			b.Run(fmt.Sprintf("dbs=%d/parallel=%d", n, parallel), func(b *testing.B) {
+				benchSetPassword(b, mysql.Config{
+					RDSClient: fleet(n),
+					DbClient:  latencyClient(0),
+					Parallel:  parallel,
+				}, n)
+			})
+		}
+	}
+}
+
+func BenchmarkPasswordSetterParallel(b *testing.B) {
+	// 1ms per database call, so this shows how Parallel scales a fleet
+	for _, parallel := range []uint{1, 10, 50, 100} {
+		b.Run(fmt.Sprintf("dbs=500/parallel=%d", parallel), func(b *testing.B) {
+			benchSetPassword(b, mysql.Config{
+				RDSClient: fleet(500),
+				DbClient:  latencyClient(time.Millisecond),
+				Parallel:  parallel,
+			}, 500)
+		})
+	}
+}
+
+func BenchmarkPasswordSetterParallelPerGroup(b *testing.B) {
+	// Like BenchmarkPasswordSetterParallel with at most 1 db instance of each
+	// cluster of 5 at a time
+	for _, parallel := range []uint{10, 100} {
+		b.Run(fmt.Sprintf("dbs=500/parallel=%d", parallel), func(b *testing.B) {
+			benchSetPassword(b, mysql.Config{
+				RDSClient:        fleet(500),
+				DbClient:         latencyClient(time.Millisecond),
+				Parallel:         parallel,
+				ParallelPerGroup: 1,
+			}, 500)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// DEFAULT_CANARY_QUERY is the QueryCanary query if none is set.
+const DEFAULT_CANARY_QUERY = "SELECT 1"
+
+// QueryCanary is a rotate.Canary that connects with the new credentials and
+// runs a query, like a query that the application runs, to check that the new
+// user has the privileges the application needs. Set it as rotate.Config.Canary.
+//
+// It connects to Hostname, if set, else the new credentials hostname, which
+// is the "host" value of the secret. The query result is ignored; the check
+// fails only if the query returns an error.
+type QueryCanary struct {
+	Client   *RDSClient // for TLS and other connection options; required
+	Hostname string
+	Query    string // DEFAULT_CANARY_QUERY if empty
+}
+
+func (c QueryCanary) Check(ctx context.Context, event map[string]string, creds db.NewPassword) error {
+	target := creds.New
+	if c.Hostname != "" {
+		target.Hostname = c.Hostname
+	}
+	if target.Hostname == "" {
+		return fmt.Errorf("QueryCanary: no hostname: QueryCanary.Hostname and the secret host are not set")
+	}
+	query := c.Query
+	if query == "" {
+		query = DEFAULT_CANARY_QUERY
+	}
+	conn, err := c.Client.connect(ctx, target.Username, target.Password, target)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	ctx, cancel := c.Client.statementContext(ctx)
+	defer cancel()
+	t0 := time.Now()
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() { // read all rows so errors are returned by rows.Err
+	}
+	log.Printf("%s: canary query response time: %dms", target.Hostname, time.Now().Sub(t0).Milliseconds())
+	return rows.Err()
+}
@@ -9,7 +9,10 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"net"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -36,87 +39,728 @@ type PasswordClient interface {
 //   - rds-ca-rsa2048-g1
 //
 // The RDS CA is built-in;
-// it does not need to be provided.
+// it does not need to be provided. Other CAs can be added, or a custom TLS config
+// used, with NewRDSClientWithOptions.
 type RDSClient struct {
-	tls    bool
-	dryrun bool
+	tls     bool
+	tlsName string // registered TLS config name, if tls
+	network string // "tcp" or registered SSH network name
+	dryrun  bool
+	opts    RDSClientOptions
+	// --
+	mux    *sync.Mutex
+	conns  map[string]*sql.DB // keyed on DSN, if ReuseConnections
+	report []DryRunStatement  // if dryrun; see DryRunReport
 }
 
 var _ PasswordClient = &RDSClient{}
 
+// EXTRA_SQL_LOG_BIN is the db.Credentials.Extra key that disables binary logging
+// for the session that sets the password if its value is "0". PasswordSetter sets
+// it for the hosts in Config.NoBinlog. It can also be set in the secret to
+// disable binary logging on all hosts.
+const EXTRA_SQL_LOG_BIN = "sql_log_bin"
+
+// EXTRA_AUTH_PLUGIN is the db.Credentials.Extra key of the MySQL authentication
+// plugin, like CACHING_SHA2_PASSWORD, to set when setting the password and to
+// verify the new password with. PasswordSetter sets it from Config.AuthPlugin.
+// It can also be set in the secret.
+const EXTRA_AUTH_PLUGIN = "auth_plugin"
+
+// EXTRA_USER_HOST is the db.Credentials.Extra key of the host part of the MySQL
+// account, like "10.%" in 'app'@'10.%', whose password is set when connecting
+// as the admin (see db.NewPassword.Admin). Set it in the secret to rotate an
+// account other than 'username'@'%'. It overrides RDSClientOptions.UserHost.
+const EXTRA_USER_HOST = "user_host"
+
+// RDSClientOptions are the options of an RDSClient created by NewRDSClientWithOptions.
+// The zero value is the same as NewRDSClient(false, false).
+type RDSClientOptions struct {
+	TLS    bool // use TLS with the built-in RDS CA
+	DryRun bool // connect but do not set passwords
+
+	// TLSConfig is a custom TLS config, like for non-RDS MySQL. If set, TLS is
+	// used and TLSConfig is used instead of the built-in RDS CA, unless CACerts
+	// is also set, which sets TLSConfig.RootCAs (on a copy).
+	TLSConfig *tls.Config
+
+	// CACerts are PEM-encoded CA certificates to trust in addition to the built-in
+	// RDS CA bundle, like a newer RDS bundle, a regional bundle, or a private CA.
+	// If set, TLS is used. See also RDSCertPool.
+	CACerts []byte
+
+	// TLSConfigName is the name under which the TLS config is registered with
+	// the MySQL driver. The registry is global, so give each RDSClient with a
+	// different TLS config a different name. If empty, DEFAULT_TLS_CONFIG_NAME
+	// is used.
+	TLSConfigName string
+
+	// SSHTunnel dials database connections through an SSH jump host. It is
+	// registered with the MySQL driver as network SSHNetwork. If nil, database
+	// connections are dialed directly.
+	SSHTunnel *SSHTunnel
+
+	// SSHNetwork is the network name under which SSHTunnel is registered with
+	// the MySQL driver. The registry is global, so give each RDSClient with a
+	// different SSHTunnel a different name. If empty, DEFAULT_SSH_NETWORK is used.
+	SSHNetwork string
+
+	// ReuseConnections reuses database connections to the same host as the same
+	// user, like the admin, within one PasswordSetter call, instead of connecting
+	// on every SetPassword and DiscardOldPassword call. PasswordSetter closes the
+	// connections before it returns (see ConnectionCloser). VerifyPassword always
+	// makes a new connection because it must authenticate, but with TLS, it
+	// resumes the TLS session of the previous connection to the host, which
+	// avoids a full TLS handshake.
+	ReuseConnections bool
+
+	// PersistentConnections is like ReuseConnections, but the connections are
+	// kept in a package-level pool, keyed on host and user, that CloseConnections
+	// does not close, so they are reused across password changes and warm Lambda
+	// invocations, even by a new RDSClient. This saves the connection setup on
+	// every invocation for secrets rotated frequently across large fleets.
+	// A connection pool is closed and replaced if the password (or any other
+	// DSN setting) of its user changes, like after the password is rotated.
+	// Call ClosePersistentConnections to close all of them.
+	PersistentConnections bool
+
+	// ConnMaxLifetime is the maximum time a reused connection is reused. If zero,
+	// DEFAULT_CONN_MAX_LIFETIME is used, or DEFAULT_PERSISTENT_CONN_MAX_LIFETIME
+	// if PersistentConnections is true.
+	ConnMaxLifetime time.Duration
+
+	// DialTimeout is the maximum time to connect to a database instance,
+	// including the TLS handshake and authentication. If zero, it is
+	// DEFAULT_DIAL_TIMEOUT or 1/TIMEOUT_DEADLINE_FRACTION of the time until the
+	// context deadline (the Lambda deadline), whichever is less, so one
+	// unresponsive instance times out instead of using the rest of the invocation.
+	DialTimeout time.Duration
+
+	// ReadTimeout is the I/O read timeout of database connections. It overrides
+	// DriverConfig.ReadTimeout. If zero, the DriverConfig value is used, which
+	// is no timeout by default; StatementTimeout bounds statements regardless.
+	ReadTimeout time.Duration
+
+	// StatementTimeout is the maximum time of each SQL statement, like
+	// ALTER USER. If zero, it is DEFAULT_STATEMENT_TIMEOUT or
+	// 1/TIMEOUT_DEADLINE_FRACTION of the time until the context deadline,
+	// whichever is less.
+	StatementTimeout time.Duration
+
+	// DriverConfig sets go-sql-driver/mysql options, like Timeout, ReadTimeout,
+	// WriteTimeout, Collation, AllowCleartextPasswords, and InterpolateParams.
+	// Create it with the driver NewConfig func to start from the driver defaults.
+	// User, Passwd, Net, Addr, and DBName are set for each connection,
+	// InterpolateParams is always true (so Collation must not be a multibyte
+	// collation unsafe for interpolation, like big5 or sjis), and
+	// TLSConfig is set if TLS is used or db.Credentials.TLS is set. If nil,
+	// the driver defaults are used.
+	DriverConfig *mysql.Config
+
+	// HashPlugin is the MySQL authentication plugin, NATIVE_PASSWORD or
+	// CACHING_SHA2_PASSWORD, to set the password by hash: "ALTER USER ...
+	// IDENTIFIED WITH plugin AS 'hash'", where the hash is computed by HashPassword.
+	// Then the plaintext password is never sent to MySQL, so it does not appear
+	// in the binary log, slow or general log, or audit plugins. The plugin must
+	// be the plugin of the user, else the user is switched to it. Setting the
+	// password by hash requires the CREATE USER privilege, so it usually requires
+	// admin credentials (see db.NewPassword.Admin).
+	//
+	// If empty (the default), the password is set in plaintext: "ALTER USER ...
+	// IDENTIFIED BY 'password'".
+	HashPlugin string
+
+	// UserHost is the host part of the MySQL account whose password is set when
+	// connecting as the admin: 'username'@'UserHost'. It can be overridden per
+	// secret by EXTRA_USER_HOST. If empty, "%" is used.
+	UserHost string
+
+	// RetainCurrentPassword sets the new password with "RETAIN CURRENT PASSWORD"
+	// (MySQL 8.0.14 and newer), so the current password remains valid as the
+	// secondary password. Then both the current and new passwords work during and
+	// after rotation, which eliminates the password downtime for applications that
+	// have not yet refreshed the secret. The next password change replaces the
+	// secondary password. Rollback retains the new password, too, until the next
+	// change. Retaining the password of the connecting user requires the
+	// APPLICATION_PASSWORD_ADMIN privilege; of another user (see db.NewPassword.Admin),
+	// CREATE USER.
+	RetainCurrentPassword bool
+
+	// AccountPolicy are account options, like PASSWORD EXPIRE INTERVAL and
+	// FAILED_LOGIN_ATTEMPTS, appended to "ALTER USER" when setting the password
+	// (and when rolling it back). The zero value (the default) appends none.
+	AccountPolicy AccountPolicy
+
+	// CreateUser creates the user if it does not exist on a db instance, like a
+	// new replica or an instance restored from an old snapshot, instead of
+	// failing to set its password: "CREATE USER 'username'@'host' IDENTIFIED
+	// BY password" (with HashPlugin, EXTRA_AUTH_PLUGIN, and AccountPolicy like
+	// ALTER USER), then "GRANT grant TO 'username'@'host'" for each of Grants.
+	// It requires admin credentials (see db.NewPassword.Admin) with the CREATE
+	// USER privilege, SELECT on mysql.user, and the privileges granted; without
+	// admin credentials, it is ignored.
+	CreateUser bool
+
+	// Grants are the privileges or roles granted to a user created by CreateUser
+	// or by SyncGrants, like "SELECT, INSERT, UPDATE, DELETE ON app.*" or
+	// "app_role". They are SQL, not query parameters, so they must come from
+	// trusted configuration.
+	Grants []string
+
+	// SyncGrants grants Grants to the user after setting its password, in the
+	// same session, so rotation also corrects privileges revoked since the
+	// last rotation. After granting, the grants of the user (SHOW GRANTS) are
+	// logged for auditing, which shows privileges granted outside Grants; they
+	// are not revoked. A failed grant fails SetPassword, after the password
+	// was set, so PasswordSetter retries or rolls back like any other failure.
+	// Like CreateUser, it requires admin credentials; without admin
+	// credentials, it is ignored.
+	SyncGrants bool
+}
+
 // NewRDSClient creates a new RDSClient.
 func NewRDSClient(useTLS, dryrun bool) *RDSClient {
+	return NewRDSClientWithOptions(RDSClientOptions{TLS: useTLS, DryRun: dryrun})
+}
+
+// NewRDSClientWithOptions creates a new RDSClient with the options.
+func NewRDSClientWithOptions(opts RDSClientOptions) *RDSClient {
+	useTLS := opts.TLS || opts.TLSConfig != nil || len(opts.CACerts) > 0
+	tlsName := opts.TLSConfigName
+	if tlsName == "" {
+		tlsName = DEFAULT_TLS_CONFIG_NAME
+	}
 	if useTLS {
-		caCertPool := x509.NewCertPool()
-		caCertPool.AppendCertsFromPEM(globalBundle)
-		tlsConfig := &tls.Config{RootCAs: caCertPool}
-		mysql.RegisterTLSConfig("rds", tlsConfig)
-		log.Println("TLS enabled")
+		var tlsConfig *tls.Config
+		if opts.TLSConfig != nil {
+			tlsConfig = opts.TLSConfig.Clone()
+		} else {
+			tlsConfig = &tls.Config{}
+		}
+		if (opts.ReuseConnections || opts.PersistentConnections) && tlsConfig.ClientSessionCache == nil {
+			tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+		}
+		if opts.TLSConfig == nil || len(opts.CACerts) > 0 {
+			pool, err := RDSCertPool(opts.CACerts)
+			if err != nil {
+				log.Printf("ERROR: CACerts: %s (ignored, using only the built-in RDS CA)", err)
+				pool, _ = RDSCertPool()
+			}
+			tlsConfig.RootCAs = pool
+		}
+		if err := mysql.RegisterTLSConfig(tlsName, tlsConfig); err != nil {
+			log.Printf("ERROR: cannot register TLS config %s: %s", tlsName, err)
+		}
+		log.Printf("TLS enabled (%s)", tlsName)
+	}
+
+	if opts.ConnMaxLifetime == 0 {
+		opts.ConnMaxLifetime = DEFAULT_CONN_MAX_LIFETIME
+		if opts.PersistentConnections {
+			opts.ConnMaxLifetime = DEFAULT_PERSISTENT_CONN_MAX_LIFETIME
+		}
+	}
+
+	network := "tcp"
+	if opts.SSHTunnel != nil {
+		network = opts.SSHNetwork
+		if network == "" {
+			network = DEFAULT_SSH_NETWORK
+		}
+		mysql.RegisterDialContext(network, opts.SSHTunnel.DialContext)
+		log.Printf("SSH tunnel enabled (%s)", network)
 	}
 
 	return &RDSClient{
-		tls:    useTLS,
-		dryrun: dryrun,
+		tls:     useTLS,
+		tlsName: tlsName,
+		network: network,
+		dryrun:  opts.DryRun,
+		opts:    opts,
+		mux:     &sync.Mutex{},
+		conns:   map[string]*sql.DB{},
 	}
 }
 
+// DEFAULT_DIAL_TIMEOUT is the maximum time to connect to a database instance
+// if RDSClientOptions.DialTimeout is zero.
+const DEFAULT_DIAL_TIMEOUT = 10 * time.Second
+
+// DEFAULT_STATEMENT_TIMEOUT is the maximum time of each SQL statement if
+// RDSClientOptions.StatementTimeout is zero.
+const DEFAULT_STATEMENT_TIMEOUT = 30 * time.Second
+
+// TIMEOUT_DEADLINE_FRACTION limits the default dial and statement timeouts to
+// a fraction of the time until the context deadline, so there is time left to
+// retry or roll back after a timeout.
+const TIMEOUT_DEADLINE_FRACTION = 4
+
+// DEFAULT_CONN_MAX_LIFETIME is the maximum time a connection is reused if
+// RDSClientOptions.ReuseConnections is true and ConnMaxLifetime is zero.
+const DEFAULT_CONN_MAX_LIFETIME = time.Minute
+
+// ConnectionCloser is an optional interface that a PasswordClient implements
+// if it reuses connections. PasswordSetter calls CloseConnections before
+// SetPassword, VerifyPassword, Rollback, and DiscardOldPassword return, so
+// connections are not reused across password changes.
+type ConnectionCloser interface {
+	CloseConnections()
+}
+
+var _ ConnectionCloser = &RDSClient{}
+
+// CloseConnections closes all reused connections. It is safe to call if
+// RDSClientOptions.ReuseConnections is false. It does not close persistent
+// connections (see ClosePersistentConnections).
+func (c *RDSClient) CloseConnections() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	for dsn, db := range c.conns {
+		db.Close()
+		delete(c.conns, dsn)
+	}
+}
+
+// DEFAULT_TLS_CONFIG_NAME is the TLS config name registered by RDSClient if
+// RDSClientOptions.TLSConfigName is empty.
+const DEFAULT_TLS_CONFIG_NAME = "rds"
+
+// RDSCertPool returns a cert pool with the built-in RDS CA bundle (global-bundle.pem)
+// and the PEM-encoded CA certificates, if any. It returns an error if a PEM
+// has no certificates.
+func RDSCertPool(pems ...[]byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(globalBundle)
+	for i, pem := range pems {
+		if len(pem) == 0 {
+			continue
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("PEM %d has no valid certificates", i+1)
+		}
+	}
+	return pool, nil
+}
+
 // SetPassword connects as username on hostname and sets the password.
 // Only the password for the given username is changed because the SQL query
 // is "ALTER USER CURRENT_USER IDENTIFIED BY password".
 //
-// A new database connection is made on each call. If configured for a dry run,
-// the connection is made but the SQL query is not executed.
+// If creds.Admin is set, it connects as the admin instead and the SQL query is
+// "ALTER USER 'username'@'host' IDENTIFIED BY password", where host is from
+// creds.Current.Extra[EXTRA_USER_HOST], RDSClientOptions.UserHost, or "%". This is
+// required for users that do not have privileges to change their own password.
+//
+// If RDSClientOptions.HashPlugin is set, the SQL query is "ALTER USER user
+// IDENTIFIED WITH plugin AS 'hash'" instead. If RDSClientOptions.RetainCurrentPassword
+// is set, "RETAIN CURRENT PASSWORD" is appended. The RDSClientOptions.AccountPolicy
+// clauses, if any, are appended last. If creds.Current.Extra[EXTRA_SQL_LOG_BIN]
+// is "0", binary logging is disabled for the session before the SQL query.
+//
+// If creds.Current.Extra[EXTRA_AUTH_PLUGIN] is set, the user is switched to
+// (or pinned to) the plugin: "ALTER USER user IDENTIFIED WITH plugin BY password".
+// It overrides HashPlugin. MySQL does not allow RETAIN CURRENT PASSWORD when
+// the plugin changes, so do not use both to migrate users to a new plugin.
+//
+// If RDSClientOptions.CreateUser is set and creds.Admin is set, the user is
+// created with the new password and RDSClientOptions.Grants if it does not
+// exist. RETAIN CURRENT PASSWORD is not used because there is no current
+// password. If RDSClientOptions.SyncGrants is set and creds.Admin is set,
+// RDSClientOptions.Grants are granted after the password is set.
+//
+// The password, hash, username, and host are sent as query parameters that the
+// driver quotes for the session sql_mode, including NO_BACKSLASH_ESCAPES, so
+// any password is set as-is. The plugin must be an identifier (letters, digits,
+// and underscores), else an error is returned before connecting.
+//
+// A new database connection is made on each call, unless ReuseConnections or
+// PersistentConnections is set. If configured for a dry run, the connection is
+// made but the SQL query is not executed; it is recorded for DryRunReport.
 func (c *RDSClient) SetPassword(ctx context.Context, creds db.NewPassword) error {
-	// Connect with CURRENT credentials
-	db, err := c.connect(ctx, creds.Current.Username, creds.Current.Password, creds.Current.Hostname)
+	plugin := creds.Current.Extra[EXTRA_AUTH_PLUGIN]
+	if plugin == "" {
+		plugin = c.opts.HashPlugin
+	}
+	if plugin != "" && !isIdentifier(plugin) {
+		return fmt.Errorf("invalid auth plugin %q: not an identifier", plugin)
+	}
+	policy, err := c.opts.AccountPolicy.Clauses()
 	if err != nil {
 		return err
 	}
-	defer db.Close()
+	if err := validGrants(c.opts.Grants); err != nil {
+		return err
+	}
+
+	// Connect with CURRENT or ADMIN credentials
+	var db *sql.DB
+	user := "CURRENT_USER"
+	connectAs := creds.Current.Username
+	var args []interface{}
+	if creds.Admin != nil {
+		db, err = c.reuse(ctx, creds.Admin.Username, creds.Admin.Password, creds.Current)
+		user, args = "?@?", c.account(creds.Current)
+		connectAs = creds.Admin.Username
+	} else {
+		db, err = c.reuse(ctx, creds.Current.Username, creds.Current.Password, creds.Current)
+	}
+	if err != nil {
+		return err
+	}
+	defer c.release(db)
+
+	// Set NEW password, by hash if enabled, with the auth plugin if set
+	identified := " IDENTIFIED BY ?"
+	if creds.Current.Extra[EXTRA_AUTH_PLUGIN] != "" {
+		identified = " IDENTIFIED WITH " + plugin + " BY ?"
+	}
+	secret := creds.New.Password
+	if c.opts.HashPlugin != "" {
+		hash, err := HashPassword(plugin, creds.New.Password)
+		if err != nil {
+			return err
+		}
+		identified = " IDENTIFIED WITH " + plugin + " AS ?"
+		secret = hash
+	}
+	alter := "ALTER USER " + user + identified
+	if c.opts.RetainCurrentPassword {
+		alter += " RETAIN CURRENT PASSWORD"
+	}
+	alter += policy
+
+	// Or create the user with the NEW password, if missing and enabled
+	sync := c.opts.SyncGrants && creds.Admin != nil
+	create := ""
+	if c.opts.CreateUser && creds.Admin != nil {
+		exists, err := c.userExists(ctx, db, args)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			create = "CREATE USER " + user + identified + policy
+			log.Printf("%s: user %s does not exist, creating it", creds.Current.Hostname, c.displayAccount(args))
+		}
+	}
 
 	if c.dryrun {
+		s := DryRunStatement{
+			Hostname:  creds.Current.Hostname,
+			Action:    "set",
+			ConnectAs: connectAs,
+			User:      c.displayAccount(args),
+			Statement: alter,
+			TLS:       c.tlsMode(creds.Current),
+			Hashed:    c.opts.HashPlugin != "",
+			NoBinlog:  creds.Current.Extra[EXTRA_SQL_LOG_BIN] == "0",
+		}
+		if strings.Contains(identified, " IDENTIFIED WITH ") {
+			s.AuthPlugin = plugin
+		}
+		if create != "" {
+			s.Action, s.Statement = "create", create
+		}
+		c.dryRun(s)
+		if create != "" || sync {
+			for _, g := range c.opts.Grants {
+				s.Action, s.Statement, s.AuthPlugin, s.Hashed = "grant", "GRANT "+g+" TO ?@?", "", false
+				c.dryRun(s)
+			}
+		}
 		return nil
 	}
 
-	// Set NEW password
-	escapedPassword := strings.ReplaceAll(creds.New.Password, "'", "\\'")
-	alter := "ALTER USER CURRENT_USER IDENTIFIED BY '" + escapedPassword + "'"
+	// Disable binary logging for this session, if enabled for the host. The
+	// session is a single connection because SET SESSION applies only to it.
+	var exec execFunc = db.ExecContext
+	if creds.Current.Extra[EXTRA_SQL_LOG_BIN] == "0" {
+		sctx, cancel := c.statementContext(ctx)
+		defer cancel()
+		conn, err := db.Conn(sctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if _, err := conn.ExecContext(sctx, "SET SESSION sql_log_bin=0"); err != nil {
+			return fmt.Errorf("cannot disable binary logging: %s", err)
+		}
+		if c.opts.ReuseConnections || c.opts.PersistentConnections {
+			// Restore binary logging before the connection returns to the pool
+			defer conn.ExecContext(context.Background(), "SET SESSION sql_log_bin=1")
+		}
+		exec = conn.ExecContext
+	}
 
 	t0 := time.Now()
-	_, err = db.ExecContext(ctx, alter)
+	if create != "" {
+		err = c.createUser(ctx, exec, creds.Current, create, args, secret)
+	} else {
+		sctx, cancel := c.statementContext(ctx)
+		_, err = exec(sctx, alter, append(args, secret)...)
+		cancel()
+		if err == nil && sync {
+			err = c.grant(ctx, exec, args)
+		}
+	}
 	log.Printf("%s: exec response time: %dms", creds.Current.Hostname, time.Now().Sub(t0).Milliseconds())
+	if err == nil && (create != "" || sync) {
+		c.logGrants(ctx, db, creds.Current, args)
+	}
 	return err
 }
 
+// OldPasswordClient is an optional interface that a PasswordClient implements
+// to discard old (secondary) passwords. PasswordSetter requires it for
+// DiscardOldPassword.
+type OldPasswordClient interface {
+	DiscardOldPassword(ctx context.Context, creds db.NewPassword) error
+}
+
+var _ OldPasswordClient = &RDSClient{}
+
+// DiscardOldPassword connects as the creds.New user (or creds.Admin, if set)
+// and discards the secondary password retained by RetainCurrentPassword:
+// "ALTER USER CURRENT_USER DISCARD OLD PASSWORD" (or 'username'@'host' with admin,
+// like SetPassword).
+// It is not an error if there is no secondary password.
+//
+// A new database connection is made on each call, unless ReuseConnections or
+// PersistentConnections is set. If configured for a dry run, the connection is
+// made but the SQL query is not executed; it is recorded for DryRunReport.
+func (c *RDSClient) DiscardOldPassword(ctx context.Context, creds db.NewPassword) error {
+	var db *sql.DB
+	var err error
+	user := "CURRENT_USER"
+	connectAs := creds.New.Username
+	var args []interface{}
+	if creds.Admin != nil {
+		db, err = c.reuse(ctx, creds.Admin.Username, creds.Admin.Password, creds.New)
+		user, args = "?@?", c.account(creds.New)
+		connectAs = creds.Admin.Username
+	} else {
+		db, err = c.reuse(ctx, creds.New.Username, creds.New.Password, creds.New)
+	}
+	if err != nil {
+		return err
+	}
+	defer c.release(db)
+
+	discard := "ALTER USER " + user + " DISCARD OLD PASSWORD"
+	if c.dryrun {
+		c.dryRun(DryRunStatement{
+			Hostname:  creds.New.Hostname,
+			Action:    "discard old",
+			ConnectAs: connectAs,
+			User:      c.displayAccount(args),
+			Statement: discard,
+			TLS:       c.tlsMode(creds.New),
+		})
+		return nil
+	}
+	sctx, cancel := c.statementContext(ctx)
+	defer cancel()
+	_, err = db.ExecContext(sctx, discard, args...)
+	return err
+}
+
+// ReplicationLagClient is an optional interface that a PasswordClient implements
+// to return the replication lag of a replica. PasswordSetter requires it for
+// Config.ReplicationLagCheck.
+type ReplicationLagClient interface {
+	ReplicationLag(ctx context.Context, creds db.NewPassword) (time.Duration, error)
+}
+
+var _ ReplicationLagClient = &RDSClient{}
+
+// ReplicationLag connects as the creds.New user (or creds.Admin, if set) and
+// returns the replication lag (Seconds_Behind_Master) from SHOW SLAVE STATUS.
+// If the instance is not a replica, like an Aurora reader (which shares storage
+// with the writer), the lag is zero. It returns an error if replication is not
+// running, because then the lag is unknown.
+//
+// A new database connection is made on each call, unless ReuseConnections or
+// PersistentConnections is set. Dry run does not affect this function.
+func (c *RDSClient) ReplicationLag(ctx context.Context, creds db.NewPassword) (time.Duration, error) {
+	var db *sql.DB
+	var err error
+	if creds.Admin != nil {
+		db, err = c.reuse(ctx, creds.Admin.Username, creds.Admin.Password, creds.New)
+	} else {
+		db, err = c.reuse(ctx, creds.New.Username, creds.New.Password, creds.New)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer c.release(db)
+
+	sctx, cancel := c.statementContext(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(sctx, "SHOW SLAVE STATUS")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err() // not a replica
+	}
+	vals := make([]sql.NullString, len(cols))
+	ptrs := make([]interface{}, len(cols))
+	for i := range vals {
+		ptrs[i] = &vals[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return 0, err
+	}
+	for i, col := range cols {
+		if col != "Seconds_Behind_Master" && col != "Seconds_Behind_Source" {
+			continue
+		}
+		if !vals[i].Valid {
+			return 0, fmt.Errorf("replication is not running")
+		}
+		s, err := strconv.Atoi(vals[i].String)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %s: %s", col, vals[i].String)
+		}
+		return time.Duration(s) * time.Second, nil
+	}
+	return 0, fmt.Errorf("SHOW SLAVE STATUS has no Seconds_Behind_Master column")
+}
+
 // VerifyPassword connects as username on hostname with password. If the password
 // is valid, the connection will be successful; else, an error is returned.
 //
+// If creds.New.Extra[EXTRA_AUTH_PLUGIN] is CACHING_SHA2_PASSWORD, the connection
+// does not allow mysql_native_password, so it fails if the user was not switched
+// to caching_sha2_password. (The connecting user cannot check its own plugin
+// without privileges on the mysql schema, so other plugins are not verified.)
+//
 // A new database connection is made on each call. Dry run does not affect this function.
 func (c *RDSClient) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
 	// Connect with NEW credentials
-	db, err := c.connect(ctx, creds.New.Username, creds.New.Password, creds.New.Hostname)
+	cfg := c.driverConfig(creds.New.Username, creds.New.Password, creds.New)
+	if creds.New.Extra[EXTRA_AUTH_PLUGIN] == CACHING_SHA2_PASSWORD {
+		cfg.AllowNativePasswords = false
+	}
+	db, err := c.open(ctx, cfg.FormatDSN(), creds.New.Hostname)
 	if db != nil {
 		db.Close()
 	}
 	return err
 }
 
-// connect makes a DSN and connects to MySQL (RDS). This func is called by
-// SetPassword and VerifyPassword.
-func (c *RDSClient) connect(ctx context.Context, username, password, hostname string) (*sql.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/", username, password, hostname)
+// connect makes a DSN and connects to MySQL (RDS) as username with password.
+// The hostname, port, and database are from target. If target.TLS is set, it is
+// the DSN tls param (true, false, skip-verify, preferred, or a registered config);
+// else, the RDS TLS config is used if enabled. This func is called by reuse
+// and QueryCanary.
+func (c *RDSClient) connect(ctx context.Context, username, password string, target db.Credentials) (*sql.DB, error) {
+	return c.open(ctx, c.dsn(username, password, target), target.Hostname)
+}
+
+// reuse returns a reused connection if RDSClientOptions.ReuseConnections or
+// PersistentConnections is true, else it's the same as connect. Call release
+// when done with the connection. This func is called by SetPassword and
+// DiscardOldPassword.
+func (c *RDSClient) reuse(ctx context.Context, username, password string, target db.Credentials) (*sql.DB, error) {
+	if c.opts.PersistentConnections {
+		return c.persistent(ctx, username, password, target)
+	}
+	if !c.opts.ReuseConnections {
+		return c.connect(ctx, username, password, target)
+	}
+	dsn := c.dsn(username, password, target)
+	c.mux.Lock()
+	db, ok := c.conns[dsn]
+	c.mux.Unlock()
+	if ok {
+		return db, nil
+	}
+	db, err := c.open(ctx, dsn, target.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxLifetime(c.opts.ConnMaxLifetime)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if cached, ok := c.conns[dsn]; ok { // another goroutine connected first
+		db.Close()
+		return cached, nil
+	}
+	c.conns[dsn] = db
+	return db, nil
+}
+
+// release closes the connection unless it's reused.
+func (c *RDSClient) release(db *sql.DB) {
+	if !c.opts.ReuseConnections && !c.opts.PersistentConnections {
+		db.Close()
+	}
+}
+
+// dsn returns the DSN to connect to target as username with password.
+func (c *RDSClient) dsn(username, password string, target db.Credentials) string {
+	return c.driverConfig(username, password, target).FormatDSN()
+}
+
+// driverConfig returns a copy of RDSClientOptions.DriverConfig (or the driver
+// defaults) to connect to target as username with password.
+func (c *RDSClient) driverConfig(username, password string, target db.Credentials) *mysql.Config {
+	cfg := mysql.NewConfig()
+	if c.opts.DriverConfig != nil {
+		*cfg = *c.opts.DriverConfig
+	}
+	cfg.User = username
+	cfg.Passwd = password
+	cfg.Net = c.network
+	cfg.Addr = target.Hostname
+	if target.Port != 0 {
+		cfg.Addr = net.JoinHostPort(target.Hostname, strconv.Itoa(target.Port))
+	}
+	cfg.DBName = target.Database
+	cfg.InterpolateParams = true // quote query parameters for the session sql_mode; see SetPassword
+	if c.opts.ReadTimeout != 0 {
+		cfg.ReadTimeout = c.opts.ReadTimeout
+	}
+	if target.TLS != "" {
+		cfg.TLSConfig = target.TLS
+	} else if c.tls {
+		cfg.TLSConfig = c.tlsName
+	}
+	return cfg
+}
+
+// tlsMode returns the DSN tls param of connections to target, or "false" if
+// TLS is not used.
+func (c *RDSClient) tlsMode(target db.Credentials) string {
+	if target.TLS != "" {
+		return target.TLS
+	}
 	if c.tls {
-		dsn += "?tls=rds"
+		return c.tlsName
+	}
+	if c.opts.DriverConfig != nil && c.opts.DriverConfig.TLSConfig != "" {
+		return c.opts.DriverConfig.TLSConfig
 	}
+	return "false"
+}
 
+// open opens the DSN and connects.
+func (c *RDSClient) open(ctx context.Context, dsn, hostname string) (*sql.DB, error) {
 	// sql.Open() just creates a *sql.DB, it doesn't actually connect,
-	// so we have to sql.Ping() to make a connectiion
+	// so we have to sql.PingContext() to make a connectiion
 	t0 := time.Now()
 	db, err := sql.Open("mysql", dsn)
 	if err != nil {
 		return nil, err
 	}
-	if err := db.Ping(); err != nil {
+	ctx, cancel := context.WithTimeout(ctx, timeout(ctx, c.opts.DialTimeout, DEFAULT_DIAL_TIMEOUT))
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
 		db.Close()
 		return nil, err
 	}
@@ -124,3 +768,61 @@ func (c *RDSClient) connect(ctx context.Context, username, password, hostname st
 
 	return db, nil
 }
+
+// statementContext returns a context for one SQL statement that is cancelled
+// after the statement timeout. See RDSClientOptions.StatementTimeout.
+func (c *RDSClient) statementContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, timeout(ctx, c.opts.StatementTimeout, DEFAULT_STATEMENT_TIMEOUT))
+}
+
+// timeout returns d if not zero, else def or 1/TIMEOUT_DEADLINE_FRACTION of the
+// time until the ctx deadline, whichever is less.
+func timeout(ctx context.Context, d, def time.Duration) time.Duration {
+	if d != 0 {
+		return d
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if t := time.Until(deadline) / TIMEOUT_DEADLINE_FRACTION; t < def {
+			return t
+		}
+	}
+	return def
+}
+
+// account returns the username and host of the MySQL account 'username'@'host'
+// of the creds, which is set when connecting as the admin. They are the query
+// parameters of "?@?".
+func (c *RDSClient) account(creds db.Credentials) []interface{} {
+	host := creds.Extra[EXTRA_USER_HOST]
+	if host == "" {
+		host = c.opts.UserHost
+	}
+	if host == "" {
+		host = "%"
+	}
+	return []interface{}{creds.Username, host}
+}
+
+// displayAccount returns the account of the account args, quoted for display,
+// or CURRENT_USER if none. See DryRunStatement.User.
+func (c *RDSClient) displayAccount(args []interface{}) string {
+	if len(args) == 0 {
+		return "CURRENT_USER"
+	}
+	return fmt.Sprintf("'%s'@'%s'", args...)
+}
+
+// isIdentifier returns true if s is an unquoted SQL identifier: only letters,
+// digits, and underscores. It is used for auth plugin names, which cannot be
+// query parameters.
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
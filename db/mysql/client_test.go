@@ -4,10 +4,24 @@ package mysql_test
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"database/sql"
+	"encoding/pem"
 	"fmt"
-	"os"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/go-test/deep"
 
 	rdb "github.com/square/password-rotation-lambda/v2/db"
 	"github.com/square/password-rotation-lambda/v2/db/mysql"
@@ -16,14 +30,15 @@ import (
 var (
 	default_dsn = "root@tcp(127.0.0.1:3306)/"
 	user        = "square_test"
-	host        = "127.0.0.1"
+	host        = "127.0.0.1" // host part of the test user: the client host seen by the server
+	server      = "127.0.0.1" // hostname to connect to the server
 	pass        = "password"
 )
 
-func setup(t *testing.T) (*sql.DB, error) {
-	dsn := os.Getenv("MYSQL_DSN")
-	if dsn == "" {
-		dsn = default_dsn
+func setup(t testing.TB) (*sql.DB, error) {
+	dsn, err := testDSN() // see container_test.go
+	if err != nil {
+		return nil, err
 	}
 
 	db, err := sql.Open("mysql", dsn)
@@ -62,12 +77,12 @@ func TestClient(t *testing.T) {
 		Current: rdb.Credentials{
 			Username: user,
 			Password: pass,
-			Hostname: host,
+			Hostname: server,
 		},
 		New: rdb.Credentials{
 			Username: user,
 			Password: "newpass",
-			Hostname: host,
+			Hostname: server,
 		},
 	}
 
@@ -81,3 +96,800 @@ func TestClient(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestQueryCanary(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	c := mysql.QueryCanary{Client: mysql.NewRDSClient(false, false)}
+	creds := rdb.NewPassword{
+		New: rdb.Credentials{
+			Username: user,
+			Password: pass,
+			Hostname: server,
+		},
+	}
+	if err := c.Check(context.TODO(), nil, creds); err != nil {
+		t.Error(err)
+	}
+	c.Query = "SELECT * FROM mysql.user"
+	if err := c.Check(context.TODO(), nil, creds); err == nil {
+		t.Error("no error, expected privilege error for mysql.user")
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	got, err := mysql.HashPassword(mysql.NATIVE_PASSWORD, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := "*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19"; got != expect {
+		t.Errorf("got %s, expected %s", got, expect)
+	}
+
+	// $A$005$ + 20-byte salt + 43-byte digest, with a random salt
+	got, err = mysql.HashPassword(mysql.CACHING_SHA2_PASSWORD, "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 70 || got[:7] != "$A$005$" {
+		t.Errorf("got hash %q, expected $A$005$ and 70 characters", got)
+	}
+	got2, _ := mysql.HashPassword(mysql.CACHING_SHA2_PASSWORD, "password")
+	if got2 == got {
+		t.Errorf("got same hash twice, expected random salt")
+	}
+
+	if _, err := mysql.HashPassword("sha256_password", "password"); err == nil {
+		t.Error("no error, expected error for unsupported plugin")
+	}
+}
+
+func TestSHA256Crypt(t *testing.T) {
+	// Known answers from the SHA-crypt spec (https://www.akkadia.org/drepper/SHA-crypt.txt)
+	// in crypt(3) format: $5$[rounds=N$]salt$digest, 5000 rounds if not given.
+	// crypt(3) truncates salts to 16 bytes but sha256Crypt does not, so the salts
+	// here are truncated. A wrong digest locks users out of every host, so the
+	// format and length checks in TestHashPassword are not enough.
+	tests := []struct {
+		password string
+		crypt    string
+	}{
+		{"Hello world!", "$5$saltstring$5B8vYYiY.CVt1RlTTf8KbXBH3hsxY/GNooZaBBGWEc5"},
+		{"Hello world!", "$5$rounds=10000$saltstringsaltst$3xv.VbSHBb41AL9AvLeujZkZRBAwqFMz2.opqey6IcA"},
+		{"This is just a test", "$5$rounds=5000$toolongsaltstrin$Un/5jzAHMgOGZ5.mWJpuVolil07guHPvOW8mGRcvxa5"},
+		{"a very much longer text to encrypt.  This one even stretches over morethan one line.",
+			"$5$rounds=1400$anotherlongsalts$Rx.j8H.h8HjEDGomFU8bDkXm3XIUnzyxf12oP84Bnq1"},
+		{"we have a short salt string but not a short password", "$5$rounds=77777$short$JiO1O3ZpDAxGJeaDIuqCoEFysAe1mZNJRs3pw0KQRd/"},
+		{"a short string", "$5$rounds=123456$asaltof16chars..$gP3VQ/6X7UUEW3HkBn2w1/Ptq2jxPyzV/cZKmF/wJvD"},
+	}
+	for _, tt := range tests {
+		f := strings.Split(tt.crypt, "$") // "", "5", ["rounds=N",] salt, digest
+		rounds := 5000
+		if len(f) == 5 {
+			rounds, _ = strconv.Atoi(strings.TrimPrefix(f[2], "rounds="))
+		}
+		salt, expect := f[len(f)-2], f[len(f)-1]
+		got := mysql.SHA256Crypt([]byte(tt.password), []byte(salt), rounds)
+		if got != expect {
+			t.Errorf("%q salt %q rounds %d: got %s, expected %s", tt.password, salt, rounds, got, expect)
+		}
+	}
+}
+
+func TestClientPasswordHash(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	// Setting a password by hash requires CREATE USER
+	if _, err := db.Exec(fmt.Sprintf("GRANT CREATE USER ON *.* TO '%s'@'%s'", user, host)); err != nil {
+		t.Fatal(err)
+	}
+	for _, plugin := range []string{mysql.CACHING_SHA2_PASSWORD, mysql.NATIVE_PASSWORD} {
+		client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{HashPlugin: plugin})
+		creds := rdb.NewPassword{
+			Current: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+			New:     rdb.Credentials{Username: user, Password: "newpass", Hostname: server},
+		}
+		if err := client.SetPassword(context.TODO(), creds); err != nil {
+			t.Fatalf("%s: %s", plugin, err)
+		}
+		if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+			t.Errorf("%s: %s", plugin, err)
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER USER '%s'@'%s' IDENTIFIED WITH %s BY '%s'", user, host, plugin, pass)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestClientRetainCurrentPassword(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(fmt.Sprintf("GRANT APPLICATION_PASSWORD_ADMIN ON *.* TO '%s'@'%s'", user, host)); err != nil {
+		t.Skip(err) // MySQL 5.7 or 8.0 < 8.0.14
+	}
+	client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{RetainCurrentPassword: true})
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+		New:     rdb.Credentials{Username: user, Password: "newpass", Hostname: server},
+	}
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	// Both passwords work
+	if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+	if err := client.VerifyPassword(context.TODO(), creds.Swap()); err != nil {
+		t.Errorf("current password does not work, expected it to be retained: %s", err)
+	}
+}
+
+func TestClientAuthPlugin(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	// Switch from mysql_native_password to caching_sha2_password
+	if _, err := db.Exec(fmt.Sprintf("ALTER USER '%s'@'%s' IDENTIFIED WITH mysql_native_password BY '%s'", user, host, pass)); err != nil {
+		t.Skip(err) // MySQL 8.4 without mysql_native_password
+	}
+	if _, err := db.Exec(fmt.Sprintf("GRANT CREATE USER ON *.* TO '%s'@'%s'", user, host)); err != nil {
+		t.Fatal(err)
+	}
+	extra := map[string]string{mysql.EXTRA_AUTH_PLUGIN: mysql.CACHING_SHA2_PASSWORD}
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: user, Password: pass, Hostname: server, Extra: extra},
+		New:     rdb.Credentials{Username: user, Password: "newpass", Hostname: server, Extra: extra},
+	}
+	client := mysql.NewRDSClient(false, false)
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+	var plugin string
+	if err := db.QueryRow("SELECT plugin FROM mysql.user WHERE user=? AND host=?", user, host).Scan(&plugin); err != nil {
+		t.Fatal(err)
+	}
+	if plugin != mysql.CACHING_SHA2_PASSWORD {
+		t.Errorf("got plugin %s, expected %s", plugin, mysql.CACHING_SHA2_PASSWORD)
+	}
+}
+
+func TestRDSCertPool(t *testing.T) {
+	// A private CA
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "private CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	builtIn, err := mysql.RDSCertPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool, err := mysql.RDSCertPool(ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if builtIn.Equal(pool) {
+		t.Error("pool with private CA equals built-in pool, expected private CA added")
+	}
+	if _, err := mysql.RDSCertPool([]byte("not a cert")); err == nil {
+		t.Error("no error, expected error for PEM without certificates")
+	}
+}
+
+func TestClientDriverConfig(t *testing.T) {
+	// A server that accepts connections but never sends the handshake, so
+	// only the driver ReadTimeout makes VerifyPassword return
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	h, p, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(p)
+
+	cfg := driver.NewConfig()
+	cfg.ReadTimeout = 100 * time.Millisecond
+	client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{DriverConfig: cfg})
+	creds := rdb.NewPassword{
+		New: rdb.Credentials{
+			Username: user,
+			Password: pass,
+			Hostname: h,
+			Port:     port,
+		},
+	}
+	errc := make(chan error, 1)
+	go func() {
+		errc <- client.VerifyPassword(context.TODO(), creds)
+	}()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("no error, expected read timeout")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("VerifyPassword did not time out: DriverConfig.ReadTimeout not used")
+	}
+	if cfg.User != "" || cfg.Addr != "" {
+		t.Errorf("DriverConfig modified: User=%q Addr=%q, expected it to be copied", cfg.User, cfg.Addr)
+	}
+}
+
+func TestClientDialTimeout(t *testing.T) {
+	// A server that accepts connections but never sends the handshake, so
+	// VerifyPassword returns only when the dial timeout is reached
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	h, p, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(p)
+	creds := rdb.NewPassword{
+		New: rdb.Credentials{
+			Username: user,
+			Password: pass,
+			Hostname: h,
+			Port:     port,
+		},
+	}
+
+	// Explicit DialTimeout
+	client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{DialTimeout: 100 * time.Millisecond})
+	t0 := time.Now()
+	err = client.VerifyPassword(context.TODO(), creds)
+	if err == nil {
+		t.Error("no error, expected dial timeout")
+	}
+	if d := time.Since(t0); d > 2*time.Second {
+		t.Errorf("VerifyPassword returned after %s, expected DialTimeout 100ms", d)
+	}
+
+	// Default derived from the context deadline: 1/4 of 2s, not the full 2s
+	client = mysql.NewRDSClient(false, false)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	t0 = time.Now()
+	err = client.VerifyPassword(ctx, creds)
+	if err == nil {
+		t.Error("no error, expected dial timeout")
+	}
+	if d := time.Since(t0); d > 1500*time.Millisecond {
+		t.Errorf("VerifyPassword returned after %s, expected 1/%d of the context deadline", d, mysql.TIMEOUT_DEADLINE_FRACTION)
+	}
+	if ctx.Err() != nil {
+		t.Error("context deadline reached, expected dial timeout first")
+	}
+}
+
+func TestClientAdminUserHost(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	// Connect as the admin (MYSQL_DSN user, usually root) to set the password
+	// of 'square_test'@'127.0.0.1', not 'square_test'@'%' which does not exist
+	dsn, _ := testDSN()
+	cfg, err := driver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &rdb.Credentials{Username: cfg.User, Password: cfg.Passwd}
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+		New:     rdb.Credentials{Username: user, Password: "newpass", Hostname: server},
+		Admin:   admin,
+	}
+	client := mysql.NewRDSClient(false, false)
+	if err := client.SetPassword(context.TODO(), creds); err == nil {
+		t.Error("no error setting password of 'square_test'@'%', expected an error because the account does not exist")
+	}
+
+	client = mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{UserHost: host})
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+
+	// Extra overrides UserHost
+	creds = creds.Swap()
+	creds.Current.Extra = map[string]string{mysql.EXTRA_USER_HOST: host}
+	client = mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{UserHost: "10.%"})
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientCreateUser(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	// The user is missing, like on a new replica
+	if _, err := db.Exec(fmt.Sprintf("DROP USER '%s'@'%s'", user, host)); err != nil {
+		t.Fatal(err)
+	}
+
+	dsn, _ := testDSN()
+	cfg, err := driver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &rdb.Credentials{Username: cfg.User, Password: cfg.Passwd}
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+		New:     rdb.Credentials{Username: user, Password: "newpass", Hostname: server},
+		Admin:   admin,
+	}
+	client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{UserHost: host})
+	if err := client.SetPassword(context.TODO(), creds); err == nil {
+		t.Fatal("no error setting password of missing user without CreateUser")
+	}
+
+	client = mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{
+		UserHost:   host,
+		CreateUser: true,
+		Grants:     []string{"PROCESS ON *.*"},
+	})
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+	var grants string
+	err = db.QueryRow(fmt.Sprintf("SHOW GRANTS FOR '%s'@'%s'", user, host)).Scan(&grants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(grants, "PROCESS") {
+		t.Errorf("grants %q do not include PROCESS", grants)
+	}
+
+	// User exists now, so the password is set (rollback)
+	creds = creds.Swap()
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientSyncGrants(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	dsn, _ := testDSN()
+	cfg, err := driver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := &rdb.Credentials{Username: cfg.User, Password: cfg.Passwd}
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+		New:     rdb.Credentials{Username: user, Password: "newpass", Hostname: server},
+		Admin:   admin,
+	}
+	client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{
+		UserHost:   host,
+		SyncGrants: true,
+		Grants:     []string{"PROCESS ON *.*"},
+	})
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+	var grants string
+	err = db.QueryRow(fmt.Sprintf("SHOW GRANTS FOR '%s'@'%s'", user, host)).Scan(&grants)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(grants, "PROCESS") {
+		t.Errorf("grants %q do not include PROCESS", grants)
+	}
+
+	// A failed grant fails SetPassword
+	client = mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{
+		UserHost:   host,
+		SyncGrants: true,
+		Grants:     []string{"NO_SUCH_PRIVILEGE ON *.*"},
+	})
+	err = client.SetPassword(context.TODO(), creds.Swap())
+	if err == nil || !strings.Contains(err.Error(), "cannot grant") {
+		t.Errorf("got error %v, expected cannot grant", err)
+	}
+}
+
+func TestClientInvalidGrants(t *testing.T) {
+	// Grants are not query parameters, so they must be rejected before
+	// connecting (there is no database at this address)
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: user, Password: pass, Hostname: "127.0.0.1", Port: 1},
+		Admin:   &rdb.Credentials{Username: "admin", Password: pass},
+	}
+	for _, grants := range [][]string{{"SELECT ON app.* TO x; DROP USER root"}, {" "}} {
+		client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{CreateUser: true, Grants: grants})
+		err := client.SetPassword(context.TODO(), creds)
+		if err == nil || !strings.Contains(err.Error(), "invalid grant") {
+			t.Errorf("%q: got error %v, expected invalid grant", grants, err)
+		}
+	}
+}
+
+func TestClientPersistentConnections(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+	defer mysql.ClosePersistentConnections()
+
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+		New:     rdb.Credentials{Username: user, Password: "newpass", Hostname: server},
+	}
+	opts := mysql.RDSClientOptions{PersistentConnections: true}
+
+	// Like warm invocations: a new client each time, each rotating the
+	// password, so the persistent connection as the user is replaced
+	for i := 0; i < 3; i++ {
+		client := mysql.NewRDSClientWithOptions(opts)
+		if err := client.SetPassword(context.TODO(), creds); err != nil {
+			t.Fatalf("rotation %d: %s", i, err)
+		}
+		client.CloseConnections() // does not close persistent connections
+		if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+			t.Errorf("rotation %d: %s", i, err)
+		}
+		creds = creds.Swap()
+	}
+
+	// The pool as the user with the current password is reused
+	client := mysql.NewRDSClientWithOptions(opts)
+	if err := client.DiscardOldPassword(context.TODO(), creds.Swap()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClientDryRunReport(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{
+		DryRun:                true,
+		HashPlugin:            mysql.CACHING_SHA2_PASSWORD,
+		RetainCurrentPassword: true,
+	})
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+		New:     rdb.Credentials{Username: user, Password: "newpass", Hostname: server},
+	}
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.DiscardOldPassword(context.TODO(), creds.Swap()); err != nil {
+		t.Fatal(err)
+	}
+	expect := []mysql.DryRunStatement{
+		{
+			Hostname:   server,
+			Action:     "set",
+			ConnectAs:  user,
+			User:       "CURRENT_USER",
+			Statement:  "ALTER USER CURRENT_USER IDENTIFIED WITH caching_sha2_password AS ? RETAIN CURRENT PASSWORD",
+			TLS:        "false",
+			AuthPlugin: mysql.CACHING_SHA2_PASSWORD,
+			Hashed:     true,
+		},
+		{
+			Hostname:  server,
+			Action:    "discard old",
+			ConnectAs: user,
+			User:      "CURRENT_USER",
+			Statement: "ALTER USER CURRENT_USER DISCARD OLD PASSWORD",
+			TLS:       "false",
+		},
+	}
+	if diff := deep.Equal(client.DryRunReport(), expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Dry run does not set the password
+	if err := client.VerifyPassword(context.TODO(), creds); err == nil {
+		t.Error("new password works, expected dry run to not set it")
+	}
+}
+
+func TestClientKillSessions(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	// A session of the user, like an application with the old password
+	other, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/", user, pass, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	conn, err := other.Conn(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	creds := rdb.NewPassword{
+		New: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+	}
+	client := mysql.NewRDSClient(false, false)
+
+	// Kept by KeepClientHosts: not killed
+	n, err := client.KillSessions(context.TODO(), creds, mysql.KeepClientHosts("*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("killed %d sessions, expected 0 (kept)", n)
+	}
+	if err := conn.PingContext(context.TODO()); err != nil {
+		t.Errorf("kept session killed: %s", err)
+	}
+
+	n, err = client.KillSessions(context.TODO(), creds, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("killed %d sessions, expected 1", n)
+	}
+	if err := conn.PingContext(context.TODO()); err == nil {
+		t.Error("session not killed")
+	}
+}
+
+func TestClientListSessions(t *testing.T) {
+	db, err := setup(t)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer db.Close()
+
+	// A session of the user before ListSessions, like an application with the
+	// old password
+	other, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s)/", user, pass, server))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	conn, err := other.Conn(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	creds := rdb.NewPassword{
+		New: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+	}
+	sessions, err := mysql.NewRDSClient(false, false).ListSessions(context.TODO(), creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].User != user {
+		t.Errorf("got sessions %+v, expected 1 session of %s", sessions, user)
+	}
+}
+
+func TestKeepClientHosts(t *testing.T) {
+	keep := mysql.KeepClientHosts("10.0.8.*", "batch-*")
+	tests := map[string]bool{
+		"10.0.8.5:41234": true,
+		"10.0.8.5":       true,
+		"10.0.9.5:41234": false,
+		"batch-1:3306":   true,
+		"app-1:3306":     false,
+	}
+	for host, expect := range tests {
+		if got := keep(mysql.Session{Host: host}); got != expect {
+			t.Errorf("%s: got %t, expected %t", host, got, expect)
+		}
+	}
+}
+
+func TestClientInvalidAuthPlugin(t *testing.T) {
+	// The plugin is not a query parameter, so it must be rejected before
+	// connecting (there is no database at this address)
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{
+			Username: user,
+			Password: pass,
+			Hostname: "127.0.0.1",
+			Port:     1,
+			Extra:    map[string]string{mysql.EXTRA_AUTH_PLUGIN: "mysql_native_password BY 'x'; DROP USER root; --"},
+		},
+	}
+	client := mysql.NewRDSClient(false, false)
+	err := client.SetPassword(context.TODO(), creds)
+	if err == nil || !strings.Contains(err.Error(), "invalid auth plugin") {
+		t.Errorf("got error %v, expected invalid auth plugin", err)
+	}
+
+	client = mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{HashPlugin: "caching_sha2_password'"})
+	creds.Current.Extra = nil
+	err = client.SetPassword(context.TODO(), creds)
+	if err == nil || !strings.Contains(err.Error(), "invalid auth plugin") {
+		t.Errorf("got error %v, expected invalid auth plugin", err)
+	}
+}
+
+func TestAccountPolicyClauses(t *testing.T) {
+	tests := []struct {
+		policy  mysql.AccountPolicy
+		clauses string
+		err     bool
+	}{
+		{mysql.AccountPolicy{}, "", false},
+		{mysql.AccountPolicy{PasswordExpireDays: 90}, " PASSWORD EXPIRE INTERVAL 90 DAY", false},
+		{mysql.AccountPolicy{PasswordExpireDays: mysql.PASSWORD_EXPIRE_NEVER}, " PASSWORD EXPIRE NEVER", false},
+		{mysql.AccountPolicy{FailedLoginAttempts: 5, PasswordLockDays: 2}, " FAILED_LOGIN_ATTEMPTS 5 PASSWORD_LOCK_TIME 2", false},
+		{mysql.AccountPolicy{PasswordLockDays: mysql.PASSWORD_LOCK_UNBOUNDED, Unlock: true}, " PASSWORD_LOCK_TIME UNBOUNDED ACCOUNT UNLOCK", false},
+		{
+			mysql.AccountPolicy{PasswordExpireDays: 30, FailedLoginAttempts: 3, PasswordLockDays: 1, Unlock: true},
+			" PASSWORD EXPIRE INTERVAL 30 DAY FAILED_LOGIN_ATTEMPTS 3 PASSWORD_LOCK_TIME 1 ACCOUNT UNLOCK",
+			false,
+		},
+		{mysql.AccountPolicy{PasswordExpireDays: -2}, "", true},
+		{mysql.AccountPolicy{PasswordExpireDays: mysql.MAX_ACCOUNT_POLICY_VALUE + 1}, "", true},
+		{mysql.AccountPolicy{FailedLoginAttempts: -1}, "", true},
+		{mysql.AccountPolicy{PasswordLockDays: -2}, "", true},
+	}
+	for _, tt := range tests {
+		clauses, err := tt.policy.Clauses()
+		if (err != nil) != tt.err {
+			t.Errorf("%+v: got error %v, expected error %t", tt.policy, err, tt.err)
+		}
+		if clauses != tt.clauses {
+			t.Errorf("%+v: got %q, expected %q", tt.policy, clauses, tt.clauses)
+		}
+	}
+
+	// An invalid policy must be rejected before connecting (there is no
+	// database at this address)
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{
+			Username: user,
+			Password: pass,
+			Hostname: "127.0.0.1",
+			Port:     1,
+		},
+	}
+	client := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{AccountPolicy: mysql.AccountPolicy{FailedLoginAttempts: -1}})
+	err := client.SetPassword(context.TODO(), creds)
+	if err == nil || !strings.Contains(err.Error(), "invalid AccountPolicy") {
+		t.Errorf("got error %v, expected invalid AccountPolicy", err)
+	}
+}
+
+func FuzzClientPassword(f *testing.F) {
+	db, err := setup(f)
+	if err != nil {
+		f.Skip(err)
+	}
+	defer db.Close()
+
+	for _, p := range []string{
+		"newpass",
+		"",
+		"'",
+		"''",
+		`\`,
+		`\'`,
+		`pass\`,
+		`\\'; DROP USER root; --`,
+		`"`,
+		"?",
+		"%_",
+		"\x1a\r\n\t",
+		"пароль",
+		"密码🔑",
+	} {
+		f.Add(p)
+	}
+
+	// Passwords must be set as-is with and without NO_BACKSLASH_ESCAPES,
+	// which changes how a backslash in a quoted string is parsed
+	noBackslash := driver.NewConfig()
+	noBackslash.Params = map[string]string{"sql_mode": "'NO_BACKSLASH_ESCAPES'"}
+	clients := map[string]*mysql.RDSClient{
+		"default":              mysql.NewRDSClient(false, false),
+		"NO_BACKSLASH_ESCAPES": mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{DriverConfig: noBackslash}),
+	}
+
+	f.Fuzz(func(t *testing.T, password string) {
+		if !utf8.ValidString(password) || strings.ContainsRune(password, 0) || len(password) > 100 {
+			t.Skip("not a valid password")
+		}
+		for mode, client := range clients {
+			if _, err := db.Exec(fmt.Sprintf("ALTER USER '%s'@'%s' IDENTIFIED BY '%s'", user, host, pass)); err != nil {
+				t.Fatal(err)
+			}
+			creds := rdb.NewPassword{
+				Current: rdb.Credentials{Username: user, Password: pass, Hostname: server},
+				New:     rdb.Credentials{Username: user, Password: password, Hostname: server},
+			}
+			if err := client.SetPassword(context.TODO(), creds); err != nil {
+				t.Fatalf("%s: %q: %s", mode, password, err)
+			}
+			if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+				t.Errorf("%s: %q: password not set as-is: %s", mode, password, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,309 @@
+// Copyright 2026, Square, Inc.
+
+package mysql_test
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	driver "github.com/go-sql-driver/mysql"
+	"github.com/testcontainers/testcontainers-go"
+
+	rdb "github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+)
+
+// Integration tests need a MySQL server on 127.0.0.1:3306 (default_dsn) or at
+// MYSQL_DSN. If neither is running and Docker is running, setup starts one in
+// a container (image MYSQL_IMAGE, default default_image) with testcontainers-go,
+// publishing port 3306, so the tests run instead of skipping. TestClientServers
+// tests each of MYSQL_IMAGES (comma-separated, default default_images) in its
+// own container on a mapped port. Connections to a container come from the
+// Docker network, not 127.0.0.1, so the test user is created for the client
+// host that the server sees (see clientHost). The containers are removed when
+// the tests finish. Run "go test -short" to not start any.
+var (
+	default_image  = "mysql:8.0"
+	default_images = "mysql:5.7,mysql:8.0,mariadb:10.11"
+
+	containerMux sync.Mutex
+	containers   = map[string]*container{} // keyed on image and port
+	dockerOnce   sync.Once
+	dockerOK     bool
+)
+
+type container struct {
+	c    testcontainers.Container
+	dsn  string
+	host string // client host seen by the server, for CREATE USER
+	err  error
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	containerMux.Lock()
+	for _, c := range containers {
+		if c.c != nil {
+			c.c.Terminate(context.Background())
+		}
+	}
+	containerMux.Unlock()
+	os.Exit(code)
+}
+
+// testDSN returns MYSQL_DSN if set, else default_dsn if a server is running,
+// else the DSN of a container started with MYSQL_IMAGE on port 3306 if Docker
+// is running. For a container, it sets server and host (client_test.go) to
+// connect to the container and create the test user for the client host.
+func testDSN() (string, error) {
+	if dsn := os.Getenv("MYSQL_DSN"); dsn != "" {
+		return dsn, nil
+	}
+	if ping(default_dsn) == nil || !haveDocker() {
+		return default_dsn, nil
+	}
+	image := os.Getenv("MYSQL_IMAGE")
+	if image == "" {
+		image = default_image
+	}
+	c := startMySQL(image, 3306)
+	if c.err != nil {
+		return "", c.err
+	}
+	cfg, err := driver.ParseDSN(c.dsn)
+	if err != nil {
+		return "", err
+	}
+	server, _, _ = net.SplitHostPort(cfg.Addr)
+	host = c.host
+	return c.dsn, nil
+}
+
+func ping(dsn string) error {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.Ping()
+}
+
+// haveDocker returns true if Docker is running, unless -short.
+func haveDocker() bool {
+	if testing.Short() {
+		return false
+	}
+	dockerOnce.Do(func() {
+		// testcontainers-go panics if it cannot find Docker
+		defer func() {
+			if r := recover(); r != nil {
+				dockerOK = false
+			}
+		}()
+		p, err := testcontainers.ProviderDocker.GetProvider()
+		if err != nil {
+			return
+		}
+		defer p.Close()
+		dockerOK = p.Health(context.Background()) == nil
+	})
+	return dockerOK
+}
+
+// startMySQL starts a container with the image, once per test binary, and
+// returns it when the server is ready. The server port is published on the
+// port, or a random port if zero.
+func startMySQL(image string, port int) *container {
+	containerMux.Lock()
+	defer containerMux.Unlock()
+	key := fmt.Sprintf("%s:%d", image, port)
+	if c, ok := containers[key]; ok {
+		return c
+	}
+	c := &container{}
+	containers[key] = c
+
+	exposed := "3306/tcp"
+	if port != 0 {
+		exposed = fmt.Sprintf("%d:3306/tcp", port)
+	}
+	ctx := context.Background()
+	tc, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        image,
+			ExposedPorts: []string{exposed},
+			Env: map[string]string{
+				"MYSQL_ALLOW_EMPTY_PASSWORD":        "yes",
+				"MARIADB_ALLOW_EMPTY_ROOT_PASSWORD": "1",
+			},
+		},
+		Started: true,
+	})
+	if err != nil {
+		c.err = fmt.Errorf("start %s: %s", image, err)
+		return c
+	}
+	c.c = tc
+	server, err := tc.Host(ctx)
+	if err != nil {
+		c.err = err
+		return c
+	}
+	mapped, err := tc.MappedPort(ctx, "3306/tcp")
+	if err != nil {
+		c.err = err
+		return c
+	}
+	c.dsn = fmt.Sprintf("root@tcp(%s)/", net.JoinHostPort(server, mapped.Port()))
+
+	// The server starts after the image initializes the data dir, which does
+	// not listen on TCP, so the first successful ping is the real server
+	timeout := time.Now().Add(3 * time.Minute)
+	for {
+		if err = ping(c.dsn); err == nil {
+			break
+		}
+		if time.Now().After(timeout) {
+			c.err = fmt.Errorf("%s not ready after 3m: %s", image, err)
+			return c
+		}
+		time.Sleep(time.Second)
+	}
+	if c.host, err = clientHost(c.dsn); err != nil {
+		c.err = err
+	}
+	return c
+}
+
+// clientHost returns the host of the client as seen by the server, like the
+// Docker network gateway, which is the host part of the test user.
+func clientHost(dsn string) (string, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	var host string
+	err = db.QueryRow("SELECT SUBSTRING_INDEX(USER(), '@', -1)").Scan(&host)
+	return host, err
+}
+
+func TestClientServers(t *testing.T) {
+	// Test SetPassword and VerifyPassword on each MySQL and MariaDB version,
+	// with each auth plugin that the server has and with and without TLS
+	if !haveDocker() {
+		t.Skip("Docker not running (or -short)")
+	}
+	images := os.Getenv("MYSQL_IMAGES")
+	if images == "" {
+		images = default_images
+	}
+	for _, image := range strings.Split(images, ",") {
+		image := strings.TrimSpace(image)
+		t.Run(image, func(t *testing.T) {
+			c := startMySQL(image, 0)
+			if c.err != nil {
+				t.Fatal(c.err)
+			}
+			admin, err := sql.Open("mysql", c.dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer admin.Close()
+
+			var version string
+			if err := admin.QueryRow("SELECT VERSION()").Scan(&version); err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("%s: version %s", image, version)
+
+			// MariaDB has a different syntax for auth plugins (IDENTIFIED VIA),
+			// so test only the default plugin
+			plugins := []string{""}
+			if !strings.Contains(version, "MariaDB") {
+				for _, p := range []string{mysql.NATIVE_PASSWORD, mysql.CACHING_SHA2_PASSWORD} {
+					var n int
+					q := "SELECT COUNT(*) FROM information_schema.PLUGINS WHERE PLUGIN_NAME=? AND PLUGIN_STATUS='ACTIVE'"
+					if err := admin.QueryRow(q, p).Scan(&n); err != nil {
+						t.Fatal(err)
+					}
+					if n > 0 {
+						plugins = append(plugins, p)
+					}
+				}
+			}
+
+			// The MySQL images have self-signed certificates; MariaDB does not
+			tlsModes := []bool{false}
+			var name, haveSSL string
+			if err := admin.QueryRow("SHOW VARIABLES LIKE 'have_ssl'").Scan(&name, &haveSSL); err == nil && haveSSL == "YES" {
+				tlsModes = append(tlsModes, true)
+			}
+
+			for i, plugin := range plugins {
+				for _, useTLS := range tlsModes {
+					username := fmt.Sprintf("square_test_%d", i)
+					setPasswordOn(t, admin, c, username, plugin, useTLS)
+				}
+			}
+		})
+	}
+}
+
+// setPasswordOn creates the user with the plugin, then sets and verifies a new
+// password with an RDSClient.
+func setPasswordOn(t *testing.T, admin *sql.DB, c *container, username, plugin string, useTLS bool) {
+	t.Helper()
+	host := c.host
+	queries := []string{
+		fmt.Sprintf("DROP USER IF EXISTS '%s'@'%s'", username, host),
+		fmt.Sprintf("CREATE USER '%s'@'%s' IDENTIFIED BY '%s'", username, host, pass),
+	}
+	if plugin != "" {
+		queries[1] = fmt.Sprintf("CREATE USER '%s'@'%s' IDENTIFIED WITH %s BY '%s'", username, host, plugin, pass)
+	}
+	for _, q := range queries {
+		if _, err := admin.Exec(q); err != nil {
+			t.Fatalf("%s: %s", q, err)
+		}
+	}
+	defer admin.Exec(fmt.Sprintf("DROP USER IF EXISTS '%s'@'%s'", username, host))
+
+	opts := mysql.RDSClientOptions{}
+	if useTLS {
+		opts.TLSConfig = &tls.Config{InsecureSkipVerify: true} // self-signed
+		opts.TLSConfigName = "test-servers"
+	}
+	client := mysql.NewRDSClientWithOptions(opts)
+
+	cfg, err := driver.ParseDSN(c.dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, port, _ := net.SplitHostPort(cfg.Addr)
+	p, _ := strconv.Atoi(port)
+	extra := map[string]string{}
+	if plugin != "" {
+		extra[mysql.EXTRA_AUTH_PLUGIN] = plugin
+	}
+	creds := rdb.NewPassword{
+		Current: rdb.Credentials{Username: username, Password: pass, Hostname: server, Port: p, Extra: extra},
+		New:     rdb.Credentials{Username: username, Password: "new_" + pass, Hostname: server, Port: p, Extra: extra},
+	}
+	if err := client.SetPassword(context.TODO(), creds); err != nil {
+		t.Errorf("plugin %q, TLS %t: SetPassword: %s", plugin, useTLS, err)
+		return
+	}
+	if err := client.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Errorf("plugin %q, TLS %t: VerifyPassword: %s", plugin, useTLS, err)
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"log"
+)
+
+// DryRunStatement is a password statement that RDSClient did not execute
+// because it is configured for a dry run (RDSClientOptions.DryRun). The password
+// and hash are not included: they are query parameters (?) in Statement.
+type DryRunStatement struct {
+	Hostname   string `json:"hostname"`
+	Action     string `json:"action"`                // "set", "create", or "grant" (SetPassword), "discard old" (DiscardOldPassword), or "kill session" (KillSessions)
+	ConnectAs  string `json:"connect_as"`            // username of the connection
+	User       string `json:"user"`                  // account whose password is changed: CURRENT_USER or 'username'@'host'
+	Statement  string `json:"statement"`             // SQL statement, with ? for query parameters
+	TLS        string `json:"tls"`                   // DSN tls param of the connection, like "rds", "true", or "false"
+	AuthPlugin string `json:"auth_plugin,omitempty"` // plugin in IDENTIFIED WITH, if any
+	Hashed     bool   `json:"hashed,omitempty"`      // password set by hash (RDSClientOptions.HashPlugin)
+	NoBinlog   bool   `json:"no_binlog,omitempty"`   // binary logging disabled for the session (EXTRA_SQL_LOG_BIN)
+}
+
+// DryRunReporter is an optional interface that a PasswordClient implements to
+// report the statements that it did not execute in a dry run. PasswordSetter
+// implements it, too, by calling its PasswordClient.
+type DryRunReporter interface {
+	// DryRunReport returns and clears the statements not executed since the
+	// last call, in the order they were not executed. It returns nil if none.
+	DryRunReport() []DryRunStatement
+}
+
+var _ DryRunReporter = &RDSClient{}
+
+// DryRunReport returns and clears the statements not executed since the last
+// call. See PasswordSetter.DryRunReport.
+func (c *RDSClient) DryRunReport() []DryRunStatement {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	report := c.report
+	c.report = nil
+	return report
+}
+
+// dryRun records and logs a statement not executed.
+func (c *RDSClient) dryRun(s DryRunStatement) {
+	log.Printf("%s: dry run: %s password of %s as %s (tls=%s): %s", s.Hostname, s.Action, s.User, s.ConnectAs, s.TLS, s.Statement)
+	c.mux.Lock()
+	c.report = append(c.report, s)
+	c.mux.Unlock()
+}
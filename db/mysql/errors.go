@@ -0,0 +1,59 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HostError is a password action that failed on one db instance.
+type HostError struct {
+	Hostname string
+	Action   string // "setting", "verify", "rollback", "discard old", "kill sessions", or "count sessions"
+	Tries    uint   // number of tries, including retries
+	Err      error  // error of the last try
+}
+
+func (e HostError) Error() string {
+	return fmt.Sprintf("%s: %s password failed after %d tries: %s", e.Hostname, e.Action, e.Tries, e.Err)
+}
+
+func (e HostError) Unwrap() error {
+	return e.Err
+}
+
+// FleetError is the error returned by PasswordSetter if a password action
+// failed on one or more db instances. Use errors.As to get it from a
+// rotate.RotationError:
+//
+//	var fleetErr *mysql.FleetError
+//	if errors.As(err, &fleetErr) {
+//		for _, h := range fleetErr.Hosts {
+//			log.Printf("%s failed: %s", h.Hostname, h.Err)
+//		}
+//	}
+type FleetError struct {
+	Action    string      // "setting", "verify", "rollback", "discard old", "kill sessions", or "count sessions"
+	Instances int         // number of db instances
+	Hosts     []HostError // failed db instances, in Init order
+}
+
+func (e *FleetError) Error() string {
+	hosts := make([]string, len(e.Hosts))
+	for i, h := range e.Hosts {
+		hosts[i] = fmt.Sprintf("%s (%d tries): %s", h.Hostname, h.Tries, h.Err)
+	}
+	return fmt.Sprintf("%s password failed on %d of %d database instances: %s",
+		e.Action, len(e.Hosts), e.Instances, strings.Join(hosts, "; "))
+}
+
+// Unwrap returns the HostError of each failed db instance, so errors.Is and
+// errors.As match the errors of the db instances.
+func (e *FleetError) Unwrap() []error {
+	errs := make([]error, len(e.Hosts))
+	for i := range e.Hosts {
+		errs[i] = e.Hosts[i]
+	}
+	return errs
+}
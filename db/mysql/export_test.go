@@ -0,0 +1,6 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+// SHA256Crypt exports sha256Crypt for known-answer tests in mysql_test.
+var SHA256Crypt = sha256Crypt
@@ -0,0 +1,165 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// ParseFilter parses a filter expression and returns a Config.Filter func.
+// The expression is a comma-separated list of key=pattern where key is one of:
+//
+//	id       DB instance identifier
+//	engine   DB engine, like "mysql" or "aurora-mysql"
+//	cluster  DB cluster identifier (Aurora)
+//
+// Pattern is a shell glob (see path.Match). A db instance is included in password
+// rotation only if it matches every key=pattern; else, it is filtered out.
+// For example, "engine=aurora-mysql,cluster=prod-*" includes only Aurora MySQL
+// instances in clusters with names starting "prod-".
+func ParseFilter(expr string) (func(*rds.DBInstance) bool, error) {
+	type match struct {
+		key     string
+		pattern string
+	}
+	matches := []match{}
+	for _, kv := range strings.Split(expr, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		f := strings.SplitN(kv, "=", 2)
+		if len(f) != 2 {
+			return nil, fmt.Errorf("invalid filter %s: expected key=pattern", kv)
+		}
+		key, pattern := strings.TrimSpace(f[0]), strings.TrimSpace(f[1])
+		switch key {
+		case "id", "engine", "cluster":
+		default:
+			return nil, fmt.Errorf("invalid filter %s: unknown key %s", kv, key)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid filter %s: %s", kv, err)
+		}
+		matches = append(matches, match{key: key, pattern: pattern})
+	}
+
+	return func(db *rds.DBInstance) bool {
+		for _, m := range matches {
+			var val string
+			switch m.key {
+			case "id":
+				val = aws.StringValue(db.DBInstanceIdentifier)
+			case "engine":
+				val = aws.StringValue(db.Engine)
+			case "cluster":
+				val = aws.StringValue(db.DBClusterIdentifier)
+			}
+			if ok, _ := path.Match(m.pattern, val); !ok {
+				return true // filter out
+			}
+		}
+		return false // include
+	}, nil
+}
+
+// Predicate returns true if a db instance matches. Unlike a Config.Filter func,
+// which returns true to filter out a db instance, a Predicate is used in
+// Config.Include to include only the db instances that match. Predicates are
+// built with ByEngine, ByClusterID, ByNameGlob, and ByStatus, and combined
+// with And, Or, and Not. For example, to include only available Aurora MySQL
+// instances in clusters prod-1 or prod-2:
+//
+//	Include: mysql.And(
+//		mysql.ByEngine("aurora-mysql"),
+//		mysql.ByClusterID("prod-1", "prod-2"),
+//		mysql.ByStatus("available"),
+//	)
+type Predicate func(*rds.DBInstance) bool
+
+// ByEngine matches db instances with any of the engines, like "mysql" or
+// "aurora-mysql".
+func ByEngine(engines ...string) Predicate {
+	return func(db *rds.DBInstance) bool {
+		return matchAny(aws.StringValue(db.Engine), engines)
+	}
+}
+
+// ByClusterID matches db instances in any of the DB clusters (Aurora). Instances
+// that are not in a cluster do not match.
+func ByClusterID(clusterIds ...string) Predicate {
+	return func(db *rds.DBInstance) bool {
+		return db.DBClusterIdentifier != nil && matchAny(*db.DBClusterIdentifier, clusterIds)
+	}
+}
+
+// ByNameGlob matches db instances with an identifier that matches any of the
+// shell glob patterns (see path.Match), like "prod-*". An invalid pattern does
+// not match.
+func ByNameGlob(patterns ...string) Predicate {
+	return func(db *rds.DBInstance) bool {
+		id := aws.StringValue(db.DBInstanceIdentifier)
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, id); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ByStatus matches db instances with any of the statuses, like "available".
+// Use it to skip instances that cannot be connected to, like "stopped" ones:
+// Not(ByStatus("stopped")).
+func ByStatus(statuses ...string) Predicate {
+	return func(db *rds.DBInstance) bool {
+		return matchAny(aws.StringValue(db.DBInstanceStatus), statuses)
+	}
+}
+
+// And matches db instances that match all the predicates. With no predicates,
+// it matches all db instances.
+func And(preds ...Predicate) Predicate {
+	return func(db *rds.DBInstance) bool {
+		for _, p := range preds {
+			if !p(db) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Or matches db instances that match any of the predicates. With no predicates,
+// it matches no db instances.
+func Or(preds ...Predicate) Predicate {
+	return func(db *rds.DBInstance) bool {
+		for _, p := range preds {
+			if p(db) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not matches db instances that do not match the predicate.
+func Not(pred Predicate) Predicate {
+	return func(db *rds.DBInstance) bool {
+		return !pred(db)
+	}
+}
+
+func matchAny(val string, list []string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+)
+
+// GroupByCluster is a Config.Group func that groups db instances by cluster:
+// the Aurora cluster identifier, or the source instance identifier of a read
+// replica, so a source and its replicas are one group. Other db instances are
+// each their own group.
+func GroupByCluster(db *rds.DBInstance) string {
+	if db.DBClusterIdentifier != nil {
+		return aws.StringValue(db.DBClusterIdentifier)
+	}
+	if db.ReadReplicaSourceDBInstanceIdentifier != nil {
+		return aws.StringValue(db.ReadReplicaSourceDBInstanceIdentifier)
+	}
+	return aws.StringValue(db.DBInstanceIdentifier)
+}
+
+// GroupByAZ is a Config.Group func that groups db instances by availability zone.
+func GroupByAZ(db *rds.DBInstance) string {
+	return aws.StringValue(db.AvailabilityZone)
+}
+
+// order returns the indexes of m.dbs in the order to change them. If
+// Config.ParallelPerGroup is set, the groups are interleaved (round-robin, in
+// order of first db instance), so a group at its limit does not hold up the
+// db instances of other groups. Else, it is the Init order.
+func (m *PasswordSetter) order() []int {
+	order := make([]int, 0, len(m.dbs))
+	if m.perGroup == 0 {
+		for i := range m.dbs {
+			order = append(order, i)
+		}
+		return order
+	}
+	groups := []string{}
+	members := map[string][]int{}
+	for i, db := range m.dbs {
+		if _, ok := members[db.group]; !ok {
+			groups = append(groups, db.group)
+		}
+		members[db.group] = append(members[db.group], i)
+	}
+	for len(order) < len(m.dbs) {
+		for _, g := range groups {
+			if len(members[g]) > 0 {
+				order = append(order, members[g][0])
+				members[g] = members[g][1:]
+			}
+		}
+	}
+	return order
+}
+
+// fleetWaves returns the number of waves to change left db instances, of which
+// groupLeft are in each group: Config.Parallel at a time, and no more than
+// Config.ParallelPerGroup of each group, if set.
+func (m *PasswordSetter) fleetWaves(left int, groupLeft map[string]int) int {
+	w := waves(left, m.parallel)
+	if m.perGroup == 0 {
+		return w
+	}
+	for _, n := range groupLeft {
+		if gw := waves(n, m.perGroup); gw > w {
+			w = gw
+		}
+	}
+	return w
+}
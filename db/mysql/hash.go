@@ -0,0 +1,167 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// MySQL authentication plugins supported by HashPassword.
+const (
+	NATIVE_PASSWORD       = "mysql_native_password"
+	CACHING_SHA2_PASSWORD = "caching_sha2_password"
+)
+
+// CACHING_SHA2_ROUNDS is the number of SHA-256 rounds, in thousands, of a
+// caching_sha2_password hash. It is the MySQL default.
+const CACHING_SHA2_ROUNDS = 5
+
+// CACHING_SHA2_SALT_LENGTH is the salt length of a caching_sha2_password hash.
+const CACHING_SHA2_SALT_LENGTH = 20
+
+// HashPassword returns the authentication string of the password for the
+// MySQL authentication plugin, as stored in mysql.user and used in "ALTER USER
+// ... IDENTIFIED WITH plugin AS 'hash'":
+//
+//	mysql_native_password  "*" + hex(SHA1(SHA1(password)))
+//	caching_sha2_password  "$A$005$" + 20-byte salt + SHA-256 crypt (5000 rounds)
+//
+// The caching_sha2_password salt is random, so the hash is different on every
+// call. The salt is alphanumeric, so the hash does not need escaping.
+func HashPassword(plugin, password string) (string, error) {
+	switch plugin {
+	case NATIVE_PASSWORD:
+		h1 := sha1.Sum([]byte(password))
+		h2 := sha1.Sum(h1[:])
+		return "*" + strings.ToUpper(hex.EncodeToString(h2[:])), nil
+	case CACHING_SHA2_PASSWORD:
+		salt, err := hashSalt(CACHING_SHA2_SALT_LENGTH)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("$A$%03d$%s%s", CACHING_SHA2_ROUNDS, salt,
+			sha256Crypt([]byte(password), []byte(salt), CACHING_SHA2_ROUNDS*1000)), nil
+	}
+	return "", fmt.Errorf("cannot hash password for authentication plugin %q: only %s and %s are supported",
+		plugin, NATIVE_PASSWORD, CACHING_SHA2_PASSWORD)
+}
+
+// hashSaltChars are the salt characters. MySQL allows any byte except NUL and
+// '$', but alphanumeric salts are safe in SQL and logs.
+const hashSaltChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+func hashSalt(n int) (string, error) {
+	salt := make([]byte, n)
+	max := big.NewInt(int64(len(hashSaltChars)))
+	for i := range salt {
+		j, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		salt[i] = hashSaltChars[j.Int64()]
+	}
+	return string(salt), nil
+}
+
+// sha256Crypt returns the 43-character digest of the SHA-256 crypt algorithm
+// (https://www.akkadia.org/drepper/SHA-crypt.txt), which caching_sha2_password
+// uses. Unlike crypt(3), the salt is not truncated to 16 bytes.
+func sha256Crypt(password, salt []byte, rounds int) string {
+	b := sha256.New()
+	b.Write(password)
+	b.Write(salt)
+	b.Write(password)
+	digestB := b.Sum(nil)
+
+	a := sha256.New()
+	a.Write(password)
+	a.Write(salt)
+	a.Write(repeat(digestB, len(password)))
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			a.Write(digestB)
+		} else {
+			a.Write(password)
+		}
+	}
+	digestA := a.Sum(nil)
+
+	dp := sha256.New()
+	for i := 0; i < len(password); i++ {
+		dp.Write(password)
+	}
+	p := repeat(dp.Sum(nil), len(password))
+
+	ds := sha256.New()
+	for i := 0; i < 16+int(digestA[0]); i++ {
+		ds.Write(salt)
+	}
+	s := repeat(ds.Sum(nil), len(salt))
+
+	c := digestA
+	for i := 0; i < rounds; i++ {
+		h := sha256.New()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(nil)
+	}
+
+	const itoa64 = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	var out strings.Builder
+	b64 := func(b2, b1, b0 byte, n int) {
+		w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+		for ; n > 0; n-- {
+			out.WriteByte(itoa64[w&0x3f])
+			w >>= 6
+		}
+	}
+	for i := 0; i < 10; i++ {
+		// Byte order is (0,10,20), (21,1,11), (12,22,2), ..., (9,19,29)
+		j := []int{i, (i + 10) % 30, (i + 20) % 30}
+		switch i % 3 {
+		case 1:
+			j = []int{(i + 20) % 30, i, (i + 10) % 30}
+		case 2:
+			j = []int{(i + 10) % 30, (i + 20) % 30, i}
+		}
+		b64(c[j[0]], c[j[1]], c[j[2]], 4)
+	}
+	b64(0, c[31], c[30], 3)
+	return out.String()
+}
+
+// repeat returns b repeated to length n.
+func repeat(b []byte, n int) []byte {
+	r := make([]byte, 0, n)
+	for len(r) < n {
+		r = append(r, b[:min(len(b), n-len(r))]...)
+	}
+	return r
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,177 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// MemoryChange is a password change that MemoryClient recorded instead of
+// making on a database. The password is not included.
+type MemoryChange struct {
+	Hostname string    `json:"hostname"`
+	Username string    `json:"username"`
+	Action   string    `json:"action"` // "set" (SetPassword) or "discard old" (DiscardOldPassword)
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error,omitempty"` // simulated failure (MemoryClient.FailFunc), if any
+}
+
+// MemoryClient is a PasswordClient that keeps passwords in memory, not in a
+// database, and records every change. Use it in tests instead of a mock, or as
+// Config.DbClient in a shadow deployment to run rotations record-only: the
+// rotation runs as usual but no database is changed, and Changes reports
+// what would have been changed.
+//
+// Passwords are keyed on username and hostname. An account without a password,
+// which is the case for all accounts in a shadow deployment, is assumed to have
+// the current password (creds.Current.Password) when first verified or set, so
+// verifying the current secret succeeds and verifying the pending secret fails
+// until it is set, like a database that is in sync with the current secret.
+//
+// MemoryClient is safe for concurrent use by multiple goroutines. Create one by
+// calling NewMemoryClient.
+type MemoryClient struct {
+	// FailFunc simulates failures, if set. It is called with the action
+	// ("set", "verify", or "discard old") before every call. If it returns
+	// an error, the call returns it without changing the password. A failed
+	// set or discard is recorded with the error.
+	FailFunc func(action string, creds db.NewPassword) error
+
+	// Latency is slept before every call, like a database round trip. If the
+	// context is done first, the call returns the context error.
+	Latency time.Duration
+
+	// --
+	mux       *sync.Mutex
+	passwords map[string]string // keyed on username@hostname
+	changes   []MemoryChange
+}
+
+var (
+	_ PasswordClient    = &MemoryClient{}
+	_ OldPasswordClient = &MemoryClient{}
+)
+
+// NewMemoryClient returns a MemoryClient with the initial passwords, keyed on
+// "username@hostname". The passwords can be nil.
+func NewMemoryClient(passwords map[string]string) *MemoryClient {
+	c := &MemoryClient{
+		mux:       &sync.Mutex{},
+		passwords: map[string]string{},
+	}
+	for k, v := range passwords {
+		c.passwords[k] = v
+	}
+	return c
+}
+
+// SetPassword sets the password of the creds.New user on the creds.New host to
+// creds.New.Password in memory, and records the change.
+func (c *MemoryClient) SetPassword(ctx context.Context, creds db.NewPassword) error {
+	if err := c.call(ctx, "set", creds); err != nil {
+		c.record("set", creds, err)
+		return err
+	}
+	c.mux.Lock()
+	c.passwords[memoryKey(creds.New)] = creds.New.Password
+	c.mux.Unlock()
+	c.record("set", creds, nil)
+	log.Printf("%s: in memory: set password of %s", creds.New.Hostname, creds.New.Username)
+	return nil
+}
+
+// VerifyPassword returns nil if creds.New.Password is the password in memory
+// of the creds.New user on the creds.New host, else an error like
+// "access denied". It does not record a change.
+func (c *MemoryClient) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
+	if err := c.call(ctx, "verify", creds); err != nil {
+		return err
+	}
+	key := memoryKey(creds.New)
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	password, ok := c.passwords[key]
+	if !ok {
+		password = creds.Current.Password
+		c.passwords[key] = password
+	}
+	if password != creds.New.Password {
+		return fmt.Errorf("access denied for user %s", key)
+	}
+	return nil
+}
+
+// DiscardOldPassword records the discard. There is no old password in memory.
+func (c *MemoryClient) DiscardOldPassword(ctx context.Context, creds db.NewPassword) error {
+	err := c.call(ctx, "discard old", creds)
+	c.record("discard old", creds, err)
+	return err
+}
+
+// Changes returns the changes recorded since the last call to Reset or
+// NewMemoryClient, in the order they were made.
+func (c *MemoryClient) Changes() []MemoryChange {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	changes := make([]MemoryChange, len(c.changes))
+	copy(changes, c.changes)
+	return changes
+}
+
+// Password returns the password in memory of the user on the host, and true,
+// or an empty string and false if the account has none.
+func (c *MemoryClient) Password(username, hostname string) (string, bool) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	password, ok := c.passwords[username+"@"+hostname]
+	return password, ok
+}
+
+// Reset clears the recorded changes and all passwords in memory, including the
+// initial passwords.
+func (c *MemoryClient) Reset() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.passwords = map[string]string{}
+	c.changes = nil
+}
+
+// call sleeps Latency and returns the FailFunc error, if any.
+func (c *MemoryClient) call(ctx context.Context, action string, creds db.NewPassword) error {
+	if c.Latency > 0 {
+		select {
+		case <-time.After(c.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if c.FailFunc != nil {
+		return c.FailFunc(action, creds)
+	}
+	return nil
+}
+
+func (c *MemoryClient) record(action string, creds db.NewPassword, err error) {
+	change := MemoryChange{
+		Hostname: creds.New.Hostname,
+		Username: creds.New.Username,
+		Action:   action,
+		Time:     time.Now(),
+	}
+	if err != nil {
+		change.Error = err.Error()
+	}
+	c.mux.Lock()
+	c.changes = append(c.changes, change)
+	c.mux.Unlock()
+}
+
+func memoryKey(creds db.Credentials) string {
+	return creds.Username + "@" + creds.Hostname
+}
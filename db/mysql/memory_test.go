@@ -0,0 +1,103 @@
+// Copyright 2026, Square, Inc.
+
+package mysql_test
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/go-test/deep"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestMemoryClient(t *testing.T) {
+	// Test a PasswordSetter with a MemoryClient, like a shadow deployment: the
+	// current password verifies, the new one does not until set, and the sets
+	// are recorded
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+				},
+			}, nil
+		},
+	}
+	client := mysql.NewMemoryClient(nil)
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  client,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	cur := db.Credentials{Username: "app", Password: "p1"}
+	creds := db.NewPassword{Current: cur, New: db.Credentials{Username: "app", Password: "p2"}}
+	if err := ps.VerifyPassword(context.TODO(), creds); err == nil {
+		t.Error("new password verified before set, expected an error")
+	}
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{Current: cur, New: cur}); err != nil {
+		t.Errorf("current password did not verify: %s", err)
+	}
+	if err := ps.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Errorf("new password did not verify after set: %s", err)
+	}
+	for _, host := range []string{"addr1", "addr2"} {
+		if p, ok := client.Password("app", host); p != "p2" || !ok {
+			t.Errorf("%s: password %q, %t, expected p2, true", host, p, ok)
+		}
+	}
+	expect := []mysql.MemoryChange{
+		{Hostname: "addr1", Username: "app", Action: "set"},
+		{Hostname: "addr2", Username: "app", Action: "set"},
+	}
+	if diff := deep.Equal(changes(client), expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// FailFunc simulates a failure on one host, which is recorded
+	client.Reset()
+	client.FailFunc = func(action string, creds db.NewPassword) error {
+		if action == "set" && creds.New.Hostname == "addr2" {
+			return errors.New("injected error")
+		}
+		return nil
+	}
+	if err := ps.SetPassword(context.TODO(), creds); err == nil {
+		t.Error("SetPassword returned nil, expected an error")
+	}
+	expect = []mysql.MemoryChange{
+		{Hostname: "addr1", Username: "app", Action: "set"},
+		{Hostname: "addr2", Username: "app", Action: "set", Error: "injected error"},
+	}
+	if diff := deep.Equal(changes(client), expect); diff != nil {
+		t.Error(diff)
+	}
+	if _, ok := client.Password("app", "addr2"); ok {
+		t.Error("addr2 has a password after a failed set, expected none")
+	}
+}
+
+// changes returns the changes sorted by hostname without the times, which are
+// not deterministic.
+func changes(c *mysql.MemoryClient) []mysql.MemoryChange {
+	got := c.Changes()
+	for i := range got {
+		got[i].Time = time.Time{}
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Hostname < got[j].Hostname })
+	return got
+}
@@ -0,0 +1,82 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"fmt"
+)
+
+// PASSWORD_EXPIRE_NEVER is AccountPolicy.PasswordExpireDays for "PASSWORD
+// EXPIRE NEVER".
+const PASSWORD_EXPIRE_NEVER = -1
+
+// PASSWORD_LOCK_UNBOUNDED is AccountPolicy.PasswordLockDays for
+// "PASSWORD_LOCK_TIME UNBOUNDED": the account stays locked until unlocked.
+const PASSWORD_LOCK_UNBOUNDED = -1
+
+// MAX_ACCOUNT_POLICY_VALUE is the maximum MySQL value of PASSWORD EXPIRE
+// INTERVAL, FAILED_LOGIN_ATTEMPTS, and PASSWORD_LOCK_TIME.
+const MAX_ACCOUNT_POLICY_VALUE = 32767
+
+// AccountPolicy are MySQL account options set with the new password, so rotation
+// also enforces the account policy. Set it as RDSClientOptions.AccountPolicy.
+// Zero values do not change the option. Setting account options requires the
+// CREATE USER privilege, so it usually requires admin credentials (see
+// db.NewPassword.Admin). FAILED_LOGIN_ATTEMPTS and PASSWORD_LOCK_TIME require
+// MySQL 8.0.19 or newer.
+type AccountPolicy struct {
+	// PasswordExpireDays is "PASSWORD EXPIRE INTERVAL N DAY" if greater than
+	// zero, or "PASSWORD EXPIRE NEVER" if PASSWORD_EXPIRE_NEVER. Rotate more
+	// often than the interval, else the password expires before it's rotated.
+	PasswordExpireDays int
+
+	// FailedLoginAttempts is "FAILED_LOGIN_ATTEMPTS N": the number of
+	// consecutive failed logins that lock the account for PasswordLockDays.
+	FailedLoginAttempts int
+
+	// PasswordLockDays is "PASSWORD_LOCK_TIME N" if greater than zero, or
+	// "PASSWORD_LOCK_TIME UNBOUNDED" if PASSWORD_LOCK_UNBOUNDED.
+	PasswordLockDays int
+
+	// Unlock is "ACCOUNT UNLOCK": it unlocks the account if it was locked by
+	// FAILED_LOGIN_ATTEMPTS, like by applications retrying the old password
+	// after rotation. It also unlocks an account locked by "ACCOUNT LOCK",
+	// so do not use it for accounts that are locked on purpose.
+	Unlock bool
+}
+
+// Clauses returns the ALTER USER clauses of the account policy, with a leading
+// space, or an empty string if the policy is the zero value. It returns an
+// error if a value is out of range.
+func (p AccountPolicy) Clauses() (string, error) {
+	clauses := ""
+	switch {
+	case p.PasswordExpireDays == PASSWORD_EXPIRE_NEVER:
+		clauses += " PASSWORD EXPIRE NEVER"
+	case p.PasswordExpireDays > 0 && p.PasswordExpireDays <= MAX_ACCOUNT_POLICY_VALUE:
+		clauses += fmt.Sprintf(" PASSWORD EXPIRE INTERVAL %d DAY", p.PasswordExpireDays)
+	case p.PasswordExpireDays != 0:
+		return "", fmt.Errorf("invalid AccountPolicy.PasswordExpireDays %d: must be 1 to %d or PASSWORD_EXPIRE_NEVER",
+			p.PasswordExpireDays, MAX_ACCOUNT_POLICY_VALUE)
+	}
+	switch {
+	case p.FailedLoginAttempts > 0 && p.FailedLoginAttempts <= MAX_ACCOUNT_POLICY_VALUE:
+		clauses += fmt.Sprintf(" FAILED_LOGIN_ATTEMPTS %d", p.FailedLoginAttempts)
+	case p.FailedLoginAttempts != 0:
+		return "", fmt.Errorf("invalid AccountPolicy.FailedLoginAttempts %d: must be 1 to %d",
+			p.FailedLoginAttempts, MAX_ACCOUNT_POLICY_VALUE)
+	}
+	switch {
+	case p.PasswordLockDays == PASSWORD_LOCK_UNBOUNDED:
+		clauses += " PASSWORD_LOCK_TIME UNBOUNDED"
+	case p.PasswordLockDays > 0 && p.PasswordLockDays <= MAX_ACCOUNT_POLICY_VALUE:
+		clauses += fmt.Sprintf(" PASSWORD_LOCK_TIME %d", p.PasswordLockDays)
+	case p.PasswordLockDays != 0:
+		return "", fmt.Errorf("invalid AccountPolicy.PasswordLockDays %d: must be 1 to %d or PASSWORD_LOCK_UNBOUNDED",
+			p.PasswordLockDays, MAX_ACCOUNT_POLICY_VALUE)
+	}
+	if p.Unlock {
+		clauses += " ACCOUNT UNLOCK"
+	}
+	return clauses, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// DEFAULT_PERSISTENT_CONN_MAX_LIFETIME is the maximum time a connection is reused
+// if RDSClientOptions.PersistentConnections is true and ConnMaxLifetime is zero.
+const DEFAULT_PERSISTENT_CONN_MAX_LIFETIME = 15 * time.Minute
+
+// persistentConn is a connection pool in persistentConns.
+type persistentConn struct {
+	dsn string // includes the password; a different DSN invalidates the pool
+	db  *sql.DB
+}
+
+// persistentMux guards persistentConns, the connection pools of all RDSClient
+// with RDSClientOptions.PersistentConnections, keyed on network, address, and
+// username. They are package variables so they survive warm Lambda invocations,
+// which usually create a new RDSClient.
+var persistentMux = &sync.Mutex{}
+var persistentConns = map[string]persistentConn{}
+
+// persistent returns the persistent connection pool to target as username,
+// connecting if there is none. If the pool was made with different credentials
+// or options (a different DSN), like a password changed by rotation, it is
+// closed and replaced.
+func (c *RDSClient) persistent(ctx context.Context, username, password string, target db.Credentials) (*sql.DB, error) {
+	cfg := c.driverConfig(username, password, target)
+	key := cfg.Net + "/" + cfg.Addr + "/" + username
+	dsn := cfg.FormatDSN()
+
+	persistentMux.Lock()
+	pc, ok := persistentConns[key]
+	persistentMux.Unlock()
+	if ok && pc.dsn == dsn {
+		return pc.db, nil
+	}
+
+	db, err := c.open(ctx, dsn, target.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	db.SetConnMaxLifetime(c.opts.ConnMaxLifetime)
+
+	persistentMux.Lock()
+	defer persistentMux.Unlock()
+	if cur, ok := persistentConns[key]; ok {
+		if cur.dsn == dsn { // another goroutine connected first
+			db.Close()
+			return cur.db, nil
+		}
+		log.Printf("%s: credentials of %s changed, closing persistent connections", target.Hostname, username)
+		cur.db.Close()
+	}
+	persistentConns[key] = persistentConn{dsn: dsn, db: db}
+	return db, nil
+}
+
+// ClosePersistentConnections closes the connections of all RDSClient with
+// RDSClientOptions.PersistentConnections. Call it before the process exits,
+// or to force new connections. It is safe to call if there are none.
+func ClosePersistentConnections() {
+	persistentMux.Lock()
+	defer persistentMux.Unlock()
+	for key, pc := range persistentConns {
+		pc.db.Close()
+		delete(persistentConns, key)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// Session is a MySQL session (connection) from information_schema.PROCESSLIST.
+type Session struct {
+	Id      int64
+	User    string
+	Host    string // client host:port, or "localhost"
+	Db      string // default database, if any
+	Command string // like "Sleep" or "Query"
+	Time    int64  // seconds in the current state
+}
+
+// KeepClientHosts returns a Config.KeepSessions func that keeps sessions from
+// client hosts that match any of the shell glob patterns (see path.Match), like
+// "10.0.8.*" for the subnet of long-running batch jobs. The port of the client
+// host is ignored. An invalid pattern does not match.
+func KeepClientHosts(patterns ...string) func(Session) bool {
+	return func(s Session) bool {
+		host := s.Host
+		if h, _, err := net.SplitHostPort(s.Host); err == nil {
+			host = h
+		}
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, host); ok {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SessionClient is an optional interface that a PasswordClient implements
+// to kill sessions. PasswordSetter requires it for KillSessions.
+type SessionClient interface {
+	// KillSessions kills the sessions of the creds.New user, except its own
+	// and those for which keep returns true. keep can be nil. It returns
+	// the number of sessions killed.
+	KillSessions(ctx context.Context, creds db.NewPassword, keep func(Session) bool) (int, error)
+}
+
+var _ SessionClient = &RDSClient{}
+
+// SessionLister is an optional interface that a PasswordClient implements to
+// list sessions. PasswordSetter requires it for CountSessions.
+type SessionLister interface {
+	// ListSessions returns the sessions of the creds.New user that were
+	// connected before the call.
+	ListSessions(ctx context.Context, creds db.NewPassword) ([]Session, error)
+}
+
+var _ SessionLister = &RDSClient{}
+
+// ER_NO_SUCH_THREAD is the MySQL error number of KILL when the session has
+// already ended.
+const ER_NO_SUCH_THREAD = 1094
+
+// KillSessions connects as the creds.New user (or creds.Admin, if set) and
+// kills the other sessions of the creds.New user listed in
+// information_schema.PROCESSLIST: "KILL id". A user can see and kill its own
+// sessions without privileges; the admin requires the PROCESS and
+// CONNECTION_ADMIN (or SUPER) privileges. A session that ends before it is
+// killed is not an error.
+//
+// A new database connection is made on each call, unless ReuseConnections or
+// PersistentConnections is set. If configured for a dry run, the sessions are
+// listed but not killed; the KILL statements are recorded for DryRunReport.
+func (c *RDSClient) KillSessions(ctx context.Context, creds db.NewPassword, keep func(Session) bool) (int, error) {
+	var db *sql.DB
+	var err error
+	connectAs := creds.New.Username
+	if creds.Admin != nil {
+		db, err = c.reuse(ctx, creds.Admin.Username, creds.Admin.Password, creds.New)
+		connectAs = creds.Admin.Username
+	} else {
+		db, err = c.reuse(ctx, creds.New.Username, creds.New.Password, creds.New)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer c.release(db)
+
+	// One connection, so CONNECTION_ID() is the connection that kills the others
+	sctx, cancel := c.statementContext(ctx)
+	defer cancel()
+	conn, err := db.Conn(sctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	sessions, err := listSessions(sctx, conn, creds.New.Username, "<>")
+	if err != nil {
+		return 0, err
+	}
+
+	killed, kept := 0, 0
+	for _, s := range sessions {
+		if keep != nil && keep(s) {
+			log.Printf("%s: keep session %d of %s from %s (%s %ds)", creds.New.Hostname, s.Id, s.User, s.Host, s.Command, s.Time)
+			kept++
+			continue
+		}
+		kill := fmt.Sprintf("KILL %d", s.Id)
+		if c.dryrun {
+			c.dryRun(DryRunStatement{
+				Hostname:  creds.New.Hostname,
+				Action:    "kill session",
+				ConnectAs: connectAs,
+				User:      c.displayAccount([]interface{}{s.User, s.Host}),
+				Statement: kill,
+				TLS:       c.tlsMode(creds.New),
+			})
+			continue
+		}
+		if _, err := conn.ExecContext(sctx, kill); err != nil {
+			var myErr *mysql.MySQLError
+			if errors.As(err, &myErr) && myErr.Number == ER_NO_SUCH_THREAD {
+				continue // ended
+			}
+			return killed, fmt.Errorf("cannot kill session %d from %s: %s", s.Id, s.Host, err)
+		}
+		killed++
+	}
+	log.Printf("%s: killed %d of %d sessions of %s (%d kept)", creds.New.Hostname, killed, len(sessions), creds.New.Username, kept)
+	return killed, nil
+}
+
+// ListSessions connects as the creds.New user (or creds.Admin, if set) and
+// returns the other sessions of the creds.New user listed in
+// information_schema.PROCESSLIST that were connected before this call: the
+// sessions with a lower ID, because MySQL connection IDs increase. A user can
+// see its own sessions without privileges; the admin requires the PROCESS
+// privilege.
+//
+// A new database connection is made on each call, unless ReuseConnections or
+// PersistentConnections is set. Dry run does not affect this function.
+func (c *RDSClient) ListSessions(ctx context.Context, creds db.NewPassword) ([]Session, error) {
+	var db *sql.DB
+	var err error
+	if creds.Admin != nil {
+		db, err = c.reuse(ctx, creds.Admin.Username, creds.Admin.Password, creds.New)
+	} else {
+		db, err = c.reuse(ctx, creds.New.Username, creds.New.Password, creds.New)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer c.release(db)
+
+	sctx, cancel := c.statementContext(ctx)
+	defer cancel()
+	conn, err := db.Conn(sctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return listSessions(sctx, conn, creds.New.Username, "<")
+}
+
+// listSessions returns the sessions of user with an ID that compares to the ID
+// of conn with op: "<>" for all other sessions, "<" for sessions connected
+// before conn.
+func listSessions(ctx context.Context, conn *sql.Conn, user, op string) ([]Session, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT ID, USER, IFNULL(HOST, ''), IFNULL(DB, ''), COMMAND, IFNULL(TIME, 0)"+
+		" FROM information_schema.PROCESSLIST WHERE USER = ? AND ID "+op+" CONNECTION_ID() ORDER BY ID", user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []Session
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.Id, &s.User, &s.Host, &s.Db, &s.Command, &s.Time); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// clientHosts returns the number of sessions per client host, without the port,
+// like "10.0.1.5=3 10.0.1.6=1", for logging.
+func clientHosts(sessions []Session) string {
+	count := map[string]int{}
+	for _, s := range sessions {
+		host := s.Host
+		if h, _, err := net.SplitHostPort(s.Host); err == nil {
+			host = h
+		}
+		count[host]++
+	}
+	hosts := make([]string, 0, len(count))
+	for h := range count {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	for i, h := range hosts {
+		hosts[i] = fmt.Sprintf("%s=%d", h, count[h])
+	}
+	return strings.Join(hosts, " ")
+}
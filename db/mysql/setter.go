@@ -6,6 +6,10 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,30 +32,256 @@ type Config struct {
 	Parallel  uint
 	Retry     uint
 	RetryWait time.Duration
+	Observer  db.HostObserver
+
+	// RetryBackoff doubles the wait between retries, starting at RetryWait, up
+	// to MaxRetryWait, with random jitter (the wait is between half and all of
+	// the backoff), so retries spread out while a host recovers, like an Aurora
+	// writer failing over. If false (the default), the wait is always RetryWait.
+	RetryBackoff bool
+
+	// MaxRetryWait is the maximum wait between retries if RetryBackoff is true.
+	// If zero, DEFAULT_MAX_RETRY_WAIT is used.
+	MaxRetryWait time.Duration
+
+	// MaxRetryElapsed is the maximum time to retry one host: no retry is made if
+	// it would start after MaxRetryElapsed since the first try, even if retries
+	// remain. If zero, there is no limit.
+	MaxRetryElapsed time.Duration
+
+	// KeepSessions returns true for sessions that KillSessions does not kill,
+	// like long-running jobs that would fail if killed (see KeepClientHosts).
+	// If nil, all sessions of the rotated users are killed.
+	KeepSessions func(Session) bool
+
+	// Include matches the db instances to include in password rotation; others
+	// are filtered out. It's used in addition to Filter: a db instance is included
+	// only if Include matches it and Filter does not filter it out. Build it
+	// with the predicates in this package, like ByEngine and And. If nil, all
+	// db instances are included (unless filtered out by Filter).
+	Include Predicate
+
+	// NoBinlog returns true for db instances on which the password is set with
+	// binary logging disabled for the session (see EXTRA_SQL_LOG_BIN), so the
+	// ALTER USER is not replicated. Use it when the same user change is applied
+	// on every instance individually, where replicating it causes conflicts
+	// downstream. The connecting user must be allowed to set sql_log_bin (MySQL
+	// 8.0: SESSION_VARIABLES_ADMIN; else SUPER). If nil, binary logging is not
+	// disabled unless set by Tune or the secret.
+	NoBinlog func(*rds.DBInstance) bool
+
+	// AuthPlugin is the MySQL authentication plugin, like CACHING_SHA2_PASSWORD,
+	// to switch users to, or pin them to, when setting the password (see
+	// EXTRA_AUTH_PLUGIN). The new password is verified with the plugin. Rollback
+	// restores the old password with the plugin, too. Switching plugins requires
+	// the CREATE USER privilege, so it usually requires admin credentials. If
+	// empty (the default), the plugin is not changed unless set by Tune or the secret.
+	AuthPlugin string
+
+	// WriterOnly sets the password (and rolls back and discards the old password)
+	// only on writers: Aurora cluster writers and instances that are not read
+	// replicas. Readers get the user change by replication, which avoids the
+	// replication errors caused by applying the same ALTER USER on a writer and
+	// its readers. VerifyPassword verifies every instance, retrying readers every
+	// REPLICATION_POLL_INTERVAL until ReplicationWait for the change to replicate,
+	// except for a probe (see db.WithProbe), which verifies readers like writers.
+	// The writer of every reader must be included (see Filter and Include).
+	// Init calls RDS DescribeDBClusters to find Aurora cluster writers.
+	WriterOnly bool
+
+	// ReplicationWait is how long VerifyPassword retries a reader if WriterOnly
+	// is true. If zero, DEFAULT_REPLICATION_WAIT is used.
+	ReplicationWait time.Duration
+
+	// ReplicationLagCheck waits for replication lag to be zero before verifying
+	// the password on a reader if WriterOnly is true, so that a verify does not
+	// race the replicated user change. Config.DbClient must implement
+	// ReplicationLagClient, like RDSClient. Checking lag usually requires admin
+	// credentials with the REPLICATION CLIENT privilege. If false, readers are
+	// verified until the new password works (see ReplicationWait).
+	ReplicationLagCheck bool
+
+	// FailureThreshold is the number ("2") or percentage ("10%") of db instances
+	// that can fail SetPassword or VerifyPassword without failing the call, like
+	// an instance that is rebooting, so that one instance does not roll back the
+	// whole fleet. Failed instances are still reported to the host observers
+	// (rotate.Rotator sends rotate.EVENT_HOSTS_FAILED). Percentages are rounded
+	// down. If empty or "0" (the default), any failure fails the call.
+	FailureThreshold string
+
+	// SkipReaders filters out readers, found like WriterOnly, so the password
+	// is neither set nor verified on them. Use WriterOnly instead to verify
+	// readers. If both are true, SkipReaders takes precedence.
+	SkipReaders bool
+
+	// InstanceCacheTTL is how long Init reuses the list of db instances in a warm
+	// Lambda function for invocations that are not steps of the rotation that
+	// made the list, like user commands. A rotation always uses one point-in-time
+	// list: Init refreshes it on the createSecret step of a new rotation (new
+	// ClientRequestToken) and reuses it for the other steps of the rotation,
+	// regardless of the TTL. If zero (the default), the list is refreshed only
+	// on createSecret.
+	InstanceCacheTTL time.Duration
+
+	// ParallelPerGroup is the maximum number of db instances in the same group,
+	// like an Aurora cluster, that are changed in parallel, in addition to
+	// Parallel, so rotating a large fleet in parallel does not change all members
+	// of one cluster at once and spike the load on its writer. Groups are
+	// interleaved so other groups are changed while one is at its limit. If zero
+	// (the default), there is no limit per group.
+	ParallelPerGroup uint
+
+	// AutoParallel scales Parallel with the number of db instances, so a large
+	// fleet is changed in about AUTO_PARALLEL_WAVES waves instead of one db
+	// instance at a time: Init sets Parallel to the number of db instances
+	// divided by AUTO_PARALLEL_WAVES, rounded up, but no more than MaxParallel.
+	// For example, 300 db instances are changed 30 at a time. Parallel is
+	// ignored if true. The "parallel" Tune setting "auto" enables it per secret.
+	AutoParallel bool
+
+	// MaxParallel is the maximum Parallel if AutoParallel is true. If zero,
+	// DEFAULT_MAX_PARALLEL is used.
+	MaxParallel uint
+
+	// Group returns the group of a db instance for ParallelPerGroup, like
+	// GroupByCluster or GroupByAZ. If nil, GroupByCluster is used.
+	Group func(*rds.DBInstance) string
+
+	// ClusterWriterEndpoint sets the password (and rolls back and discards the
+	// old password) on Aurora cluster writers through the cluster (writer)
+	// endpoint instead of the instance endpoint, so if the cluster fails over
+	// during rotation, the change is made on whichever instance is the writer.
+	// VerifyPassword, KillSessions, and CountSessions still connect to every
+	// instance endpoint. Use it with WriterOnly, so readers get the change by
+	// replication. Init calls RDS DescribeDBClusters to find the endpoints.
+	// With TLS, the server certificate must be valid for the cluster endpoint,
+	// which it is for RDS certificates.
+	ClusterWriterEndpoint bool
+
+	// SummaryLog replaces the log lines per db instance (success, skip, and
+	// retry) with JSON summary lines at the end of each action: one line per
+	// SUMMARY_LOG_HOSTS db instances with the hostnames by result, and one line
+	// with the error of each failed db instance, if any. Use it for large fleets
+	// to cut CloudWatch Logs cost and make the output readable. See HostSummary.
+	SummaryLog bool
+
+	// Stagger spreads the starts of SetPassword and DiscardOldPassword on the
+	// db instances over this window, in order, with random jitter, so changes
+	// on a large fleet do not hit every writer in the same second and cause
+	// correlated connection churn on busy clusters. Each db instance starts at
+	// a random time in its share of the window (Stagger divided by the number
+	// of db instances), or later if no worker is free (see Parallel). If the
+	// context has a deadline, the window is no more than half the time left.
+	// Rollback, VerifyPassword, and the others are not staggered. If zero (the
+	// default), db instances start as soon as a worker is free.
+	Stagger time.Duration
 }
 
+// QueueObserver is an optional interface that Config.Observer implements to
+// observe the work queue of each action (set, verify, rollback, and so on) on
+// large fleets: the number of db instances queued (not started) and running.
+// PasswordSetter calls it when a db instance starts and when it finishes.
+//
+// QueueObserver implementations must be safe for concurrent use by multiple goroutines.
+type QueueObserver interface {
+	ObserveQueue(action string, queued, running int)
+}
+
+// DEFAULT_MAX_RETRY_WAIT is the maximum wait between retries if
+// Config.RetryBackoff is true and Config.MaxRetryWait is zero.
+const DEFAULT_MAX_RETRY_WAIT = 10 * time.Second
+
+// AUTO_PARALLEL_WAVES is the number of waves in which Config.AutoParallel
+// changes a fleet, unless limited by Config.MaxParallel.
+const AUTO_PARALLEL_WAVES = 10
+
+// DEFAULT_MAX_PARALLEL is the maximum Parallel if Config.AutoParallel is true
+// and Config.MaxParallel is zero.
+const DEFAULT_MAX_PARALLEL = 50
+
+// DEFAULT_REPLICATION_WAIT is how long VerifyPassword retries a reader if
+// Config.WriterOnly is true and Config.ReplicationWait is zero.
+const DEFAULT_REPLICATION_WAIT = 30 * time.Second
+
+// REPLICATION_POLL_INTERVAL is how often VerifyPassword retries a reader if
+// Config.WriterOnly is true.
+const REPLICATION_POLL_INTERVAL = time.Second
+
 // PasswordSetter implements the db.PasswordSetter interface for RDS.
+//
+// If the context has a deadline, like the Lambda deadline less
+// rotate.Config.DeadlineReserve, each db instance gets a share of the time
+// remaining when it starts: the time divided by the number of db instances
+// remaining divided by Config.Parallel (rounded up), or by the number remaining
+// in the largest group divided by Config.ParallelPerGroup, if more. Tries,
+// retry waits, and replication waits on a db instance are bounded by its
+// share, so one unresponsive db instance fails in time for the others to finish
+// (or for rotation to roll back) before the deadline, regardless of Retry and
+// RetryWait.
+//
+// A call runs a pool of Config.Parallel workers, so memory and goroutines do
+// not grow with the fleet: scheduling db instances costs about 1-2µs and 6
+// allocations (about 450 bytes) per db instance per call, which is negligible
+// next to database round trips, so a call takes
+// about the database time of one db instance times the number of waves: the
+// number of db instances divided by Config.Parallel, rounded up. For example,
+// with 1ms of database time, 500 db instances take 550ms with Parallel 1, 57ms
+// with Parallel 10, and 7ms with Parallel 100. Init takes about 2ms for 500 db
+// instances and 95ms for 5000. See the benchmarks in bench_test.go.
 type PasswordSetter struct {
 	cfg Config
 	// --
 	initDone    bool
+	initTime    time.Time // when the list of db instances was made
+	initToken   string    // ClientRequestToken of the rotation that made the list
 	tries       uint
-	maxParallel chan bool
+	parallel    uint
+	auto        bool          // Config.AutoParallel or Tune
+	perGroup    uint          // Config.ParallelPerGroup or Tune
+	stagger     time.Duration // Config.Stagger or Tune
 	dbs         []dbInstance
+	tagFilter   func(*rds.DBInstance) bool
+	tagNoBinlog func(*rds.DBInstance) bool
+	authPlugin  string
+	failures    string          // failure threshold: Config.FailureThreshold or Tune
+	observer    db.HostObserver // from SetHostObserver
+	resumed     map[string]bool // from Resume
+	results     []HostSummary   // of the last action, reused by setAll
 }
 
 var _ db.PasswordSetter = &PasswordSetter{}
+var _ db.Tunable = &PasswordSetter{}
+var _ db.HostObservable = &PasswordSetter{}
+var _ db.Resumable = &PasswordSetter{}
+var _ db.OldPasswordDiscarder = &PasswordSetter{}
+var _ db.SessionKiller = &PasswordSetter{}
+var _ db.SessionCounter = &PasswordSetter{}
+var _ DryRunReporter = &PasswordSetter{}
 
 // dbInstance is used by PasswordSetter to track work done on an RDS instance
 // (the bool vars) and if the work was successful (the error vars).
 type dbInstance struct {
 	hostname      string
+	port          int    // endpoint port, or zero if unknown
+	noBinlog      bool   // set the password with sql_log_bin=0
+	reader        bool   // gets the password by replication, if WriterOnly
+	group         string // for Config.ParallelPerGroup
+	endpoint      string // cluster writer endpoint, if Config.ClusterWriterEndpoint
+	endpointPort  int    // cluster writer endpoint port, or zero if unknown
+	nSet          int    // number of accounts set, for multi-account secrets
+	tries         uint   // number of tries of the last action, for HostError
 	set           bool
 	verified      bool
 	rolledBack    bool
+	discarded     bool
+	killed        bool
+	sessions      int // number of sessions, if counted
 	setError      error
 	verifyError   error
 	rollbackError error
+	discardError  error
+	killError     error
+	countError    error
 }
 
 // NewPasswordSetter creates a new PasswordSetter.
@@ -59,21 +289,134 @@ func NewPasswordSetter(cfg Config) *PasswordSetter {
 	if cfg.Parallel == 0 {
 		cfg.Parallel = 1
 	}
-	maxParallel := make(chan bool, cfg.Parallel)
-	for i := uint(0); i < cfg.Parallel; i++ {
-		maxParallel <- true
+	if cfg.MaxParallel == 0 {
+		cfg.MaxParallel = DEFAULT_MAX_PARALLEL
+	}
+	if cfg.MaxRetryWait == 0 {
+		cfg.MaxRetryWait = DEFAULT_MAX_RETRY_WAIT
+	}
+	if cfg.ReplicationWait == 0 {
+		cfg.ReplicationWait = DEFAULT_REPLICATION_WAIT
+	}
+	if cfg.Group == nil {
+		cfg.Group = GroupByCluster
 	}
 	return &PasswordSetter{
 		cfg: cfg,
 		// --
-		tries:       uint(1) + cfg.Retry,
-		maxParallel: maxParallel,
+		authPlugin: cfg.AuthPlugin,
+		failures:   cfg.FailureThreshold,
+		tries:      uint(1) + cfg.Retry,
+		parallel:   cfg.Parallel,
+		auto:       cfg.AutoParallel,
+		perGroup:   cfg.ParallelPerGroup,
+		stagger:    cfg.Stagger,
+	}
+}
+
+// Tune sets per-secret settings, which override the Config values:
+//
+//	parallel           Config.Parallel, or "auto" for Config.AutoParallel
+//	parallel_per_group Config.ParallelPerGroup
+//	filter             filter expression (see ParseFilter); used in addition to Config.Filter
+//	no_binlog          filter expression (see ParseFilter) of db instances on which binary
+//	                   logging is disabled to set the password; used in addition to Config.NoBinlog
+//	auth_plugin        Config.AuthPlugin
+//	failure_threshold  Config.FailureThreshold
+//	stagger            Config.Stagger, like "5m"
+//
+// Other settings are ignored. Rotator calls Tune before Init if
+// rotate.Config.SecretTagPrefix is set.
+func (m *PasswordSetter) Tune(settings map[string]string) error {
+	parallel := m.cfg.Parallel
+	m.auto = m.cfg.AutoParallel
+	if v, ok := settings["parallel"]; ok && v == "auto" {
+		m.auto = true
+	} else if ok {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || n == 0 {
+			return fmt.Errorf("invalid parallel setting: %s: must be an integer > 0 or \"auto\"", v)
+		}
+		parallel = uint(n)
+		m.auto = false
+	}
+	if m.auto {
+		parallel = m.autoParallel()
+	}
+	m.setParallel(parallel)
+
+	m.perGroup = m.cfg.ParallelPerGroup
+	if v, ok := settings["parallel_per_group"]; ok {
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid parallel_per_group setting: %s: must be an integer >= 0", v)
+		}
+		m.perGroup = uint(n)
+	}
+
+	m.tagFilter = nil
+	if v, ok := settings["filter"]; ok {
+		f, err := ParseFilter(v)
+		if err != nil {
+			return err
+		}
+		m.tagFilter = f
+	}
+
+	m.tagNoBinlog = nil
+	if v, ok := settings["no_binlog"]; ok {
+		f, err := ParseFilter(v)
+		if err != nil {
+			return err
+		}
+		m.tagNoBinlog = func(db *rds.DBInstance) bool { return !f(db) } // match = no binlog
+	}
+
+	m.authPlugin = m.cfg.AuthPlugin
+	if v, ok := settings["auth_plugin"]; ok {
+		m.authPlugin = v
+	}
+
+	m.failures = m.cfg.FailureThreshold
+	if v, ok := settings["failure_threshold"]; ok {
+		if _, err := allowedFailures(v, 0); err != nil {
+			return err
+		}
+		m.failures = v
+	}
+
+	m.stagger = m.cfg.Stagger
+	if v, ok := settings["stagger"]; ok {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return fmt.Errorf("invalid stagger setting: %s: must be a duration >= 0, like \"5m\"", v)
+		}
+		m.stagger = d
+	}
+	return nil
+}
+
+// SetHostObserver sets an observer in addition to Config.Observer. It is called
+// by rotate.Rotator to record per-host results in its state store.
+func (m *PasswordSetter) SetHostObserver(o db.HostObserver) {
+	m.observer = o
+}
+
+// Resume sets the hosts on which the new password was set by an interrupted
+// SetPassword. The next SetPassword skips them but marks them set, so Rollback
+// rolls them back. It is called by rotate.Rotator if rotate.Config.StateStore
+// is set.
+func (m *PasswordSetter) Resume(hostnames []string) {
+	m.resumed = map[string]bool{}
+	for _, h := range hostnames {
+		m.resumed[h] = true
 	}
 }
 
 // Init calls RDS DescribeDBInstances to get all RDS instances. The user-provided
-// filter func is called to filter out instances. The final list of instances is
-// cached so RDS DescribeDBInstances is called only once.
+// filter func and include predicate are called to filter out instances. The final list of instances is
+// cached so RDS DescribeDBInstances is called only once per rotation (see
+// Config.InstanceCacheTTL).
 func (m *PasswordSetter) Init(ctx context.Context, secret map[string]string) error {
 	t0 := time.Now()
 	log.Println("Init call")
@@ -82,14 +425,20 @@ func (m *PasswordSetter) Init(ctx context.Context, secret map[string]string) err
 		log.Printf("Init return: %dms", d.Milliseconds())
 	}()
 
-	// Rotator calls this func on every step, but only get the dbs once for two
-	// reasons. First, reduce AWS API calls and save money. Second, the list of
-	// dbs can change between calls (steps) which doesn't work. E.g. if a new db
-	// is provisioned after rotate step 2, we don't want to do only steps 3 and
-	// 4 on it. So rotation should happen on a point-in-time snapshot of the dbs.
-	if m.initDone {
+	// Rotator calls this func on every step, but only get the dbs once per
+	// rotation for two reasons. First, reduce AWS API calls and save money.
+	// Second, the list of dbs can change between calls (steps) which doesn't
+	// work. E.g. if a new db is provisioned after rotate step 2, we don't want
+	// to do only steps 3 and 4 on it. So rotation should happen on a point-in-time
+	// snapshot of the dbs. But a warm Lambda function runs many rotations, so
+	// refresh the dbs for the next one.
+	if m.initDone && !m.refresh(secret) {
 		return nil
 	}
+	m.DryRunReport() // clear statements made before the db instances are known
+	if _, err := allowedFailures(m.cfg.FailureThreshold, 0); err != nil {
+		return fmt.Errorf("Config.FailureThreshold: %s", err)
+	}
 
 	// Query AWS RDS API to get list of all RDS instances
 	t1 := time.Now()
@@ -100,6 +449,16 @@ func (m *PasswordSetter) Init(ctx context.Context, secret map[string]string) err
 		return err
 	}
 
+	// Find Aurora cluster writers if needed to set the password only on writers
+	// or through their cluster endpoint
+	var writers map[string]*rds.DBCluster
+	if m.cfg.WriterOnly || m.cfg.SkipReaders || m.cfg.ClusterWriterEndpoint {
+		writers, err = m.clusterWriters(result.DBInstances)
+		if err != nil {
+			return err
+		}
+	}
+
 	// Call user-provided filter func to filter out db instances. The log line
 	// is so the entire list of db instances appears as one log line in CloudWatch
 	// console, i.e. keeping it together makes it easier to see.
@@ -115,22 +474,77 @@ func (m *PasswordSetter) Init(ctx context.Context, secret map[string]string) err
 		}
 
 		// Filter out (skip) this db instance?
-		if m.cfg.Filter != nil && m.cfg.Filter(rds) {
+		if (m.cfg.Filter != nil && m.cfg.Filter(rds)) || (m.cfg.Include != nil && !m.cfg.Include(rds)) ||
+			(m.tagFilter != nil && m.tagFilter(rds)) {
 			line += fmt.Sprintf("\t%s (filtered out)\n", *rds.Endpoint.Address)
 			continue
 		}
 
+		if m.cfg.SkipReaders && isReader(rds, writers) {
+			line += fmt.Sprintf("\t%s (reader, filtered out)\n", *rds.Endpoint.Address)
+			continue
+		}
+
 		// Save db instance; include in password rotations
-		dbs = append(dbs, dbInstance{hostname: *rds.Endpoint.Address})
-		line += fmt.Sprintf(" %s", *rds.Endpoint.Address)
+		noBinlog := (m.cfg.NoBinlog != nil && m.cfg.NoBinlog(rds)) || (m.tagNoBinlog != nil && m.tagNoBinlog(rds))
+		port := int(aws.Int64Value(rds.Endpoint.Port))
+		reader := m.cfg.WriterOnly && isReader(rds, writers)
+		group := m.cfg.Group(rds)
+		endpoint, endpointPort := "", 0
+		if c := writers[aws.StringValue(rds.DBInstanceIdentifier)]; m.cfg.ClusterWriterEndpoint && c != nil && c.Endpoint != nil {
+			endpoint, endpointPort = *c.Endpoint, int(aws.Int64Value(c.Port))
+		}
+		dbs = append(dbs, dbInstance{hostname: *rds.Endpoint.Address, port: port, noBinlog: noBinlog, reader: reader, group: group,
+			endpoint: endpoint, endpointPort: endpointPort})
+		if port != 0 {
+			line += fmt.Sprintf(" %s", net.JoinHostPort(*rds.Endpoint.Address, strconv.Itoa(port)))
+		} else {
+			line += fmt.Sprintf(" %s", *rds.Endpoint.Address)
+		}
+		if noBinlog {
+			line += " (no binlog)"
+		}
+		if reader {
+			line += " (reader)"
+		}
+		if endpoint != "" {
+			line += fmt.Sprintf(" (via %s)", endpoint)
+		}
 	}
 	log.Print(line)
 
 	m.dbs = dbs
 	m.initDone = true
+	if m.auto {
+		m.setParallel(m.autoParallel())
+	}
+	m.initTime = time.Now()
+	if token := secret["ClientRequestToken"]; token != "" {
+		m.initToken = token
+	}
 	return nil
 }
 
+// refresh returns true if Init must refresh the cached list of db instances
+// for the event: on the createSecret step of a new rotation, or if the list is
+// older than Config.InstanceCacheTTL, but never during the rotation that made
+// the list.
+func (m *PasswordSetter) refresh(event map[string]string) bool {
+	token := event["ClientRequestToken"]
+	if token != "" && token == m.initToken {
+		return false // same rotation, same snapshot
+	}
+	if event["Step"] == "createSecret" {
+		log.Println("new rotation, refreshing RDS instances")
+		return true
+	}
+	if m.cfg.InstanceCacheTTL > 0 && time.Since(m.initTime) > m.cfg.InstanceCacheTTL {
+		log.Printf("RDS instances cached %s ago (TTL %s), refreshing", time.Since(m.initTime).Round(time.Second), m.cfg.InstanceCacheTTL)
+		return true
+	}
+	return false
+}
+
 // SetPassword sets the password on all RDS instances.
 func (m *PasswordSetter) SetPassword(ctx context.Context, creds db.NewPassword) error {
 	t0 := time.Now()
@@ -144,9 +558,11 @@ func (m *PasswordSetter) SetPassword(ctx context.Context, creds db.NewPassword)
 	// isn't done and run 1 fails but run 2 succeeds, it'll cause a false-positive
 	// return error from setAll because in run 2 it'll see the error from run 1.
 	for i, db := range m.dbs {
-		m.dbs[i] = dbInstance{hostname: db.hostname}
+		m.dbs[i] = dbInstance{hostname: db.hostname, port: db.port, noBinlog: db.noBinlog, reader: db.reader, group: db.group,
+			endpoint: db.endpoint, endpointPort: db.endpointPort}
 	}
 
+	defer func() { m.resumed = nil }() // only the next SetPassword resumes
 	return m.setAll(ctx, creds, set_password)
 }
 
@@ -160,11 +576,7 @@ func (m *PasswordSetter) Rollback(ctx context.Context, creds db.NewPassword) err
 		log.Printf("Rollback return: %dms", d.Milliseconds())
 	}()
 
-	swapCreds := db.NewPassword{
-		Current: creds.New,
-		New:     creds.Current,
-	}
-	return m.setAll(ctx, swapCreds, rollback_password) // true = setting
+	return m.setAll(ctx, creds.Swap(), rollback_password)
 }
 
 // VerifyPassword connects to all RDS to verify that the username and password work.
@@ -179,19 +591,134 @@ func (m *PasswordSetter) VerifyPassword(ctx context.Context, creds db.NewPasswor
 	// Reset flags and errors between attempts to verify the password to prevent
 	// potential false positives caused by two successive runs.
 	for i, db := range m.dbs {
-		m.dbs[i] = dbInstance{hostname: db.hostname}
+		m.dbs[i] = dbInstance{hostname: db.hostname, port: db.port, noBinlog: db.noBinlog, reader: db.reader, group: db.group,
+			endpoint: db.endpoint, endpointPort: db.endpointPort}
 	}
 	return m.setAll(ctx, creds, verify_password)
 }
 
+// DiscardOldPassword discards the old (secondary) password on all RDS instances.
+// Config.DbClient must implement OldPasswordClient, like RDSClient.
+func (m *PasswordSetter) DiscardOldPassword(ctx context.Context, creds db.NewPassword) error {
+	t0 := time.Now()
+	log.Println("DiscardOldPassword call")
+	defer func() {
+		d := time.Now().Sub(t0)
+		log.Printf("DiscardOldPassword return: %dms", d.Milliseconds())
+	}()
+
+	if _, ok := m.cfg.DbClient.(OldPasswordClient); !ok {
+		return fmt.Errorf("Config.DbClient %T does not implement OldPasswordClient", m.cfg.DbClient)
+	}
+	for i, db := range m.dbs {
+		m.dbs[i] = dbInstance{hostname: db.hostname, port: db.port, noBinlog: db.noBinlog, reader: db.reader, group: db.group,
+			endpoint: db.endpoint, endpointPort: db.endpointPort}
+	}
+	return m.setAll(ctx, creds, discard_password)
+}
+
+// KillSessions kills the sessions of the creds.New users on all RDS instances,
+// including readers, except sessions for which Config.KeepSessions returns true.
+// Config.DbClient must implement SessionClient, like RDSClient.
+func (m *PasswordSetter) KillSessions(ctx context.Context, creds db.NewPassword) error {
+	t0 := time.Now()
+	log.Println("KillSessions call")
+	defer func() {
+		d := time.Now().Sub(t0)
+		log.Printf("KillSessions return: %dms", d.Milliseconds())
+	}()
+
+	if _, ok := m.cfg.DbClient.(SessionClient); !ok {
+		return fmt.Errorf("Config.DbClient %T does not implement SessionClient", m.cfg.DbClient)
+	}
+	for i, db := range m.dbs {
+		m.dbs[i] = dbInstance{hostname: db.hostname, port: db.port, noBinlog: db.noBinlog, reader: db.reader, group: db.group,
+			endpoint: db.endpoint, endpointPort: db.endpointPort}
+	}
+	return m.setAll(ctx, creds, kill_sessions)
+}
+
+// CountSessions returns the number of sessions of the creds.New users that were
+// connected before the call on all RDS instances, including readers, keyed
+// on hostname. The client hosts of the sessions are logged. Config.DbClient
+// must implement SessionLister, like RDSClient. On error, the counts of the
+// RDS instances without error are returned with the error.
+func (m *PasswordSetter) CountSessions(ctx context.Context, creds db.NewPassword) (map[string]int, error) {
+	t0 := time.Now()
+	log.Println("CountSessions call")
+	defer func() {
+		d := time.Now().Sub(t0)
+		log.Printf("CountSessions return: %dms", d.Milliseconds())
+	}()
+
+	if _, ok := m.cfg.DbClient.(SessionLister); !ok {
+		return nil, fmt.Errorf("Config.DbClient %T does not implement SessionLister", m.cfg.DbClient)
+	}
+	for i, db := range m.dbs {
+		m.dbs[i] = dbInstance{hostname: db.hostname, port: db.port, noBinlog: db.noBinlog, reader: db.reader, group: db.group,
+			endpoint: db.endpoint, endpointPort: db.endpointPort}
+	}
+	err := m.setAll(ctx, creds, count_sessions)
+	sessions := map[string]int{}
+	for _, db := range m.dbs {
+		if db.countError == nil {
+			sessions[db.hostname] = db.sessions
+		}
+	}
+	return sessions, err
+}
+
+// DryRunReport returns and clears the statements that the PasswordClient did
+// not execute because it is configured for a dry run, if it implements
+// DryRunReporter; else, it returns nil. The first Init clears the report, so
+// after one rotation, it is the statements that the rotation would have
+// executed on all db instances, including rollback if rotation failed.
+func (m *PasswordSetter) DryRunReport() []DryRunStatement {
+	r, ok := m.cfg.DbClient.(DryRunReporter)
+	if !ok {
+		return nil
+	}
+	return r.DryRunReport()
+}
+
 // --------------------------------------------------------------------------
 
 const (
 	set_password      = "setting"
 	verify_password   = "verify"
 	rollback_password = "rollback"
+	discard_password  = "discard old"
+	kill_sessions     = "kill sessions"
+	count_sessions    = "count sessions"
 )
 
+// autoParallel returns Parallel for Config.AutoParallel: the number of db
+// instances divided by AUTO_PARALLEL_WAVES, rounded up, between 1 and
+// Config.MaxParallel.
+func (m *PasswordSetter) autoParallel() uint {
+	n := waves(len(m.dbs), AUTO_PARALLEL_WAVES) // at least 1
+	if uint(n) > m.cfg.MaxParallel {
+		return m.cfg.MaxParallel
+	}
+	return uint(n)
+}
+
+func (m *PasswordSetter) setParallel(parallel uint) {
+	if parallel == m.parallel {
+		return
+	}
+	log.Printf("parallel = %d", parallel)
+	m.parallel = parallel
+}
+
+func newSemaphore(n uint) chan bool {
+	sem := make(chan bool, n)
+	for i := uint(0); i < n; i++ {
+		sem <- true
+	}
+	return sem
+}
+
 // setAll sets or verifies the password on all databases in parallel. It waits
 // for all to complete, even after the context is cancelled to let the in-flight
 // database calls complete (which are also watching the context, so they should
@@ -202,127 +729,450 @@ const (
 //
 // This func is called by SetPassword and Rollback.
 func (m *PasswordSetter) setAll(ctx context.Context, creds db.NewPassword, action string) error {
-	log.Printf("%s password on %d RDS instances, %d in parallel...", action, len(m.dbs), m.cfg.Parallel)
-	var wg sync.WaitGroup
-
+	if m.perGroup > 0 {
+		log.Printf("%s password on %d RDS instances, %d in parallel, %d per group...", action, len(m.dbs), m.parallel, m.perGroup)
+	} else {
+		log.Printf("%s password on %d RDS instances, %d in parallel...", action, len(m.dbs), m.parallel)
+	}
+	if cc, ok := m.cfg.DbClient.(ConnectionCloser); ok {
+		defer cc.CloseConnections() // don't reuse connections across password changes
+	}
+	// Number of db instances remaining to change, in total and per group, for
+	// hostContext, and a semaphore per group if Config.ParallelPerGroup
+	left := 0
+	groupLeft := map[string]int{}
+	groupSem := map[string]chan bool{}
 	for i := range m.dbs {
-		// Wait for a slot in the parallel semaphore or the context to be cancelled
-		select {
-		case <-m.maxParallel:
-		case <-ctx.Done():
-			wg.Wait()
-			return ctx.Err()
+		if m.changes(i, action) {
+			left++
+			groupLeft[m.dbs[i].group]++
 		}
-
-		if action == rollback_password && !m.dbs[i].set {
-			log.Printf("%s: new password was not set, skip rollback", m.dbs[i].hostname)
-			// Sending to maxParallel so that we don't wait indefinitely
-			// for maxParallel channel in the rollback path.
-			m.maxParallel <- true
-			continue
+		if m.perGroup > 0 && groupSem[m.dbs[i].group] == nil {
+			groupSem[m.dbs[i].group] = newSemaphore(m.perGroup)
 		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && left > 0 {
+		log.Printf("%s until deadline, %d RDS instances to %s, up to %s each", time.Until(deadline).Round(time.Millisecond),
+			left, action, (time.Until(deadline) / time.Duration(m.fleetWaves(left, groupLeft))).Round(time.Millisecond))
+	}
 
-		// Change password on one database
+	// Result of each db instance for Config.SummaryLog, reusing the slice of
+	// the last action
+	if cap(m.results) < len(m.dbs) {
+		m.results = make([]HostSummary, len(m.dbs))
+	}
+	results := m.results[:len(m.dbs)]
+	for i := range results {
+		results[i] = HostSummary{}
+	}
+
+	// Start times of the db instances, if staggered
+	total := left
+	stagger := m.staggerWindow(ctx, action)
+	start := time.Now()
+	n := 0
+
+	// Bounded worker pool: Config.Parallel workers (fewer if fewer db instances
+	// to change) change the db instances sent on jobs, in order. Only the workers
+	// and one context per running db instance exist at once, so memory does not
+	// grow with the fleet. left, groupLeft, and running are guarded by mux.
+	workers := int(m.parallel)
+	if left < workers {
+		workers = left
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+	running := 0
+	for w := 0; w < workers; w++ {
 		wg.Add(1)
-		go func(dbNo int, creds db.NewPassword) {
-			defer func() {
-				if r := recover(); r != nil {
-					log.Printf("%s: PANIC: %v", m.dbs[dbNo].hostname, r)
-				}
-				m.maxParallel <- true
-				wg.Done()
-			}()
-
-			// creds is a copy, not a pointer, so this only modifies our local copy.
-			// Higher callers don't use Hostname, but we need to plumb it down to the
-			// PasswordSetter which uses it.
-			creds.Current.Hostname = m.dbs[dbNo].hostname
-			creds.New.Hostname = m.dbs[dbNo].hostname
-
-			// --------------------------------------------------------------
-			// Try to set/verify/rollback MySQL user password
-			if err := m.setOne(ctx, creds, action); err != nil {
-				log.Printf("ERROR: %s: %s password failed: %s", m.dbs[dbNo].hostname, action, err)
-
-				switch action {
-				case set_password:
-					m.dbs[dbNo].setError = err
-				case verify_password:
-					m.dbs[dbNo].verifyError = err
-				case rollback_password:
-					m.dbs[dbNo].rollbackError = err
-				default:
-					panic("invalid action passed to setAll: " + action)
+		go func() {
+			defer wg.Done()
+			for dbNo := range jobs {
+				// Change password on one database, bounded by its share of the time left
+				mux.Lock()
+				hostCtx, cancel := m.hostContext(ctx, m.fleetWaves(left, groupLeft))
+				left--
+				groupLeft[m.dbs[dbNo].group]--
+				running++
+				m.observeQueue(action, left, running)
+				mux.Unlock()
+
+				results[dbNo] = m.runHost(hostCtx, dbNo, creds, action)
+				cancel()
+				if groupSlot := groupSem[m.dbs[dbNo].group]; groupSlot != nil {
+					groupSlot <- true
 				}
 
-				return // Failed to set/verify/rollback
+				mux.Lock()
+				running--
+				m.observeQueue(action, left, running)
+				mux.Unlock()
 			}
+		}()
+	}
 
-			// Success, mark that set/verify/rollback was ok
-			log.Printf("%s: success %s password", m.dbs[dbNo].hostname, action)
+	// Send db instances to the workers, or skip them. On context cancelled,
+	// stop sending and wait for the in-flight db instances.
+	stop := func() error {
+		close(jobs)
+		wg.Wait()
+		return ctx.Err()
+	}
+	for _, i := range m.order() {
+		if action == set_password && m.resumed[m.dbs[i].hostname] {
+			m.hostLog("%s: new password already set, resume", m.dbs[i].hostname)
+			m.dbs[i].set = true
+			m.dbs[i].nSet = len(creds.All())
+			results[i] = HostSummary{Hostname: m.dbs[i].hostname, Result: "resumed"}
+			continue
+		}
+
+		if action == rollback_password && m.dbs[i].nSet == 0 {
+			m.hostLog("%s: new password was not set, skip rollback", m.dbs[i].hostname)
+			results[i] = HostSummary{Hostname: m.dbs[i].hostname, Result: "skipped"}
+			continue
+		}
+
+		if m.dbs[i].reader && !perInstance(action) {
+			m.hostLog("%s: reader, %s password by replication", m.dbs[i].hostname, action)
 			switch action {
 			case set_password:
-				m.dbs[dbNo].set = true
-			case verify_password:
-				m.dbs[dbNo].verified = true
+				m.dbs[i].set = true
+				m.dbs[i].nSet = len(creds.All())
 			case rollback_password:
-				m.dbs[dbNo].rolledBack = true
-			default:
-				panic("invalid action passed to setAll: " + action)
+				m.dbs[i].rolledBack = true
+			case discard_password:
+				m.dbs[i].discarded = true
+			}
+			results[i] = HostSummary{Hostname: m.dbs[i].hostname, Result: "replicated"}
+			continue
+		}
+
+		// Wait for the start time of the db instance, if staggered
+		if stagger > 0 {
+			wait := time.Until(start.Add(staggerDelay(stagger, n, total)))
+			n++
+			if wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-t.C:
+				case <-ctx.Done():
+					t.Stop()
+					return stop()
+				}
 			}
-		}(i, creds)
+		}
+
+		// Wait for a slot in the group semaphore, if any, which the worker
+		// releases when done with the db instance, then for a free worker
+		groupSlot := groupSem[m.dbs[i].group]
+		if groupSlot != nil {
+			select {
+			case <-groupSlot:
+			case <-ctx.Done():
+				return stop()
+			}
+		}
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			if groupSlot != nil {
+				groupSlot <- true
+			}
+			return stop()
+		}
 	}
+	close(jobs)
 
-	// Wait for all the in-flight setOne goroutines to finish
+	// Wait for all the in-flight db instances to finish
 	log.Printf("waiting for %s password on %d RDS instances...", action, len(m.dbs))
 	wg.Wait()
+	if m.cfg.SummaryLog {
+		logSummary(action, results)
+	}
 
 	// Return error if any database failed to set
-	errCount := 0
+	fleetErr := &FleetError{Action: action, Instances: len(m.dbs)}
 	for _, db := range m.dbs {
+		var err error
 		switch action {
 		case set_password:
-			if db.setError != nil {
-				errCount += 1
-			}
+			err = db.setError
 		case verify_password:
-			if db.verifyError != nil {
-				errCount += 1
-			}
+			err = db.verifyError
 		case rollback_password:
-			if db.rollbackError != nil {
-				errCount += 1
-			}
+			err = db.rollbackError
+		case discard_password:
+			err = db.discardError
+		case kill_sessions:
+			err = db.killError
+		case count_sessions:
+			err = db.countError
+		}
+		if err != nil {
+			fleetErr.Hosts = append(fleetErr.Hosts, HostError{Hostname: db.hostname, Action: action, Tries: db.tries, Err: err})
+		}
+	}
+	errCount := len(fleetErr.Hosts)
+	if errCount > 0 && (action == set_password || action == verify_password) {
+		if allowed, _ := allowedFailures(m.failures, len(m.dbs)); errCount <= allowed {
+			log.Printf("WARNING: %s failed on %d database instances, ignoring because failure threshold is %s (%d)",
+				action, errCount, m.failures, allowed)
+			return nil
 		}
 	}
 	if errCount > 0 {
-		return fmt.Errorf("%s failed on %d database instances, see previous log output", action, errCount)
+		return fleetErr
 	}
 
 	return nil
 }
 
-// setOne sets or verifies the password on one database. On error, it waits and
-// retries as configured.
+// runHost sets, verifies, or rolls back the password on db instance dbNo,
+// records the result in m.dbs, and returns its summary. A panic is recovered
+// and logged, so the worker continues with the next db instance.
+//
+// This func is called by the setAll workers.
+func (m *PasswordSetter) runHost(ctx context.Context, dbNo int, creds db.NewPassword, action string) (result HostSummary) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("%s: PANIC: %v", m.dbs[dbNo].hostname, r)
+		}
+	}()
+
+	// --------------------------------------------------------------
+	// Try to set/verify/rollback MySQL user password
+	t0 := time.Now()
+	err := m.setHost(ctx, dbNo, creds, action)
+	d := time.Now().Sub(t0)
+	if m.cfg.Observer != nil {
+		m.cfg.Observer.ObserveHost(m.dbs[dbNo].hostname, action, d, err)
+	}
+	if m.observer != nil {
+		m.observer.ObserveHost(m.dbs[dbNo].hostname, action, d, err)
+	}
+	result = HostSummary{Hostname: m.dbs[dbNo].hostname, Result: "ok", Tries: m.dbs[dbNo].tries, Ms: d.Milliseconds()}
+	if err != nil {
+		result.Result, result.Error = "failed", err.Error()
+		m.hostLog("ERROR: %s: %s password failed: %s", m.dbs[dbNo].hostname, action, err)
+
+		switch action {
+		case set_password:
+			m.dbs[dbNo].setError = err
+		case verify_password:
+			m.dbs[dbNo].verifyError = err
+		case rollback_password:
+			m.dbs[dbNo].rollbackError = err
+		case discard_password:
+			m.dbs[dbNo].discardError = err
+		case kill_sessions:
+			m.dbs[dbNo].killError = err
+		case count_sessions:
+			m.dbs[dbNo].countError = err
+		default:
+			panic("invalid action passed to setAll: " + action)
+		}
+
+		return result // Failed to set/verify/rollback
+	}
+
+	// Success, mark that set/verify/rollback was ok
+	m.hostLog("%s: success %s password", m.dbs[dbNo].hostname, action)
+	switch action {
+	case set_password:
+		m.dbs[dbNo].set = true
+	case verify_password:
+		m.dbs[dbNo].verified = true
+	case rollback_password:
+		m.dbs[dbNo].rolledBack = true
+	case discard_password:
+		m.dbs[dbNo].discarded = true
+	case kill_sessions:
+		m.dbs[dbNo].killed = true
+	case count_sessions:
+		// counted in setHost
+	default:
+		panic("invalid action passed to setAll: " + action)
+	}
+	return result
+}
+
+// staggerWindow returns the window over which setAll spreads the starts of the
+// db instances for the action: Config.Stagger (or Tune), no more than half the
+// time until the context deadline, or zero if the action is not staggered.
+func (m *PasswordSetter) staggerWindow(ctx context.Context, action string) time.Duration {
+	if m.stagger <= 0 || (action != set_password && action != discard_password) {
+		return 0
+	}
+	window := m.stagger
+	if deadline, ok := ctx.Deadline(); ok {
+		if half := time.Until(deadline) / 2; half < window {
+			log.Printf("stagger window reduced from %s to %s by deadline", window, half.Round(time.Millisecond))
+			window = half
+		}
+	}
+	if window > 0 {
+		log.Printf("%s password staggered over %s", action, window)
+	}
+	return window
+}
+
+// staggerDelay returns the start of db instance n (0 is the first) of total:
+// a random time in its share of the window.
+func staggerDelay(window time.Duration, n, total int) time.Duration {
+	if total <= 0 {
+		return 0
+	}
+	share := window / time.Duration(total)
+	return share*time.Duration(n) + time.Duration(rand.Int63n(int64(share)+1))
+}
+
+// observeQueue calls Config.Observer if it implements QueueObserver.
+func (m *PasswordSetter) observeQueue(action string, queued, running int) {
+	if qo, ok := m.cfg.Observer.(QueueObserver); ok {
+		qo.ObserveQueue(action, queued, running)
+	}
+}
+
+// changes returns true if setAll changes (sets, verifies, rolls back, or
+// discards) the password on db instance i, false if it skips it. It must
+// match the skip conditions in setAll.
+func (m *PasswordSetter) changes(i int, action string) bool {
+	switch {
+	case action == set_password && m.resumed[m.dbs[i].hostname]:
+		return false
+	case action == rollback_password && m.dbs[i].nSet == 0:
+		return false
+	case m.dbs[i].reader && !perInstance(action):
+		return false
+	}
+	return true
+}
+
+// hostContext returns the context of one db instance when the remaining db
+// instances, including it, take n waves (see fleetWaves). If ctx has a deadline,
+// the db instance gets the time until the deadline divided by n. See
+// PasswordSetter.
+func (m *PasswordSetter) hostContext(ctx context.Context, n int) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, time.Until(deadline)/time.Duration(n))
+}
+
+// perInstance returns true if the action is done on every db instance, including
+// readers, because it's not replicated: verify, kill sessions, and count sessions.
+func perInstance(action string) bool {
+	return action == verify_password || action == kill_sessions || action == count_sessions
+}
+
+// waves returns the number of waves to change n db instances, parallel at a
+// time: n / parallel, rounded up, and at least 1.
+func waves(n int, parallel uint) int {
+	if parallel == 0 {
+		parallel = 1
+	}
+	w := (n + int(parallel) - 1) / int(parallel)
+	if w < 1 {
+		return 1
+	}
+	return w
+}
+
+// setHost sets, verifies, or rolls back the password of every account (usually
+// just one) on one database. Accounts are done in order, stopping on the first
+// error. On rollback, only accounts that were set are rolled back.
 //
 // This func is called as a goroutine from setAll.
-func (m *PasswordSetter) setOne(ctx context.Context, creds db.NewPassword, action string) error {
+func (m *PasswordSetter) setHost(ctx context.Context, dbNo int, creds db.NewPassword, action string) error {
+	m.dbs[dbNo].tries = 0
+	accounts := creds.All()
+	if action == rollback_password {
+		accounts = accounts[:m.dbs[dbNo].nSet]
+	}
+	for n, acct := range accounts {
+		// acct is a copy, not a pointer, so this only modifies our local copy.
+		// Higher callers don't use Hostname, but we need to plumb it down to the
+		// PasswordSetter which uses it.
+		// The writer change is made through the cluster endpoint, if enabled.
+		hostname, port := m.dbs[dbNo].hostname, m.dbs[dbNo].port
+		if m.dbs[dbNo].endpoint != "" && !perInstance(action) {
+			hostname, port = m.dbs[dbNo].endpoint, m.dbs[dbNo].endpointPort
+		}
+		acct.Current.Hostname = hostname
+		acct.New.Hostname = hostname
+		if port != 0 {
+			acct.Current.Port = port
+			acct.New.Port = port
+		}
+		if m.dbs[dbNo].noBinlog && (action == set_password || action == rollback_password) {
+			acct.Current.Extra = withExtra(acct.Current.Extra, EXTRA_SQL_LOG_BIN, "0")
+		}
+		if m.authPlugin != "" {
+			acct.Current.Extra = withExtra(acct.Current.Extra, EXTRA_AUTH_PLUGIN, m.authPlugin)
+			acct.New.Extra = withExtra(acct.New.Extra, EXTRA_AUTH_PLUGIN, m.authPlugin)
+		}
+		if action == count_sessions {
+			sessions, err := m.cfg.DbClient.(SessionLister).ListSessions(ctx, acct)
+			m.dbs[dbNo].tries++
+			if err != nil {
+				return err
+			}
+			m.dbs[dbNo].sessions += len(sessions)
+			if len(sessions) > 0 {
+				log.Printf("%s: %d sessions of %s connected with the old password, from client hosts: %s",
+					m.dbs[dbNo].hostname, len(sessions), acct.New.Username, clientHosts(sessions))
+			}
+			continue
+		}
+		setOne := m.setOne
+		if action == verify_password && m.dbs[dbNo].reader && !db.IsProbe(ctx) {
+			setOne = m.verifyReplica
+		}
+		tries, err := setOne(ctx, acct, action)
+		m.dbs[dbNo].tries += tries
+		if err != nil {
+			if len(accounts) > 1 {
+				return fmt.Errorf("account %s: %s", acct.Current.Username, err)
+			}
+			return err
+		}
+		if action == set_password {
+			m.dbs[dbNo].nSet = n + 1
+		}
+	}
+	return nil
+}
+
+// setOne sets or verifies the password on one database. On error, it waits and
+// retries as configured. It returns the number of tries.
+//
+// This func is called from setHost.
+func (m *PasswordSetter) setOne(ctx context.Context, creds db.NewPassword, action string) (uint, error) {
+	t0 := time.Now()
 	for tryNo := uint(1); tryNo <= m.tries; tryNo++ {
 		// Do the low-level password change on the database
 		var err error
-		if action == verify_password {
+		switch action {
+		case verify_password:
 			err = m.cfg.DbClient.VerifyPassword(ctx, creds)
-		} else {
+		case discard_password:
+			err = m.cfg.DbClient.(OldPasswordClient).DiscardOldPassword(ctx, creds)
+		case kill_sessions:
+			_, err = m.cfg.DbClient.(SessionClient).KillSessions(ctx, creds, m.cfg.KeepSessions)
+		default:
 			err = m.cfg.DbClient.SetPassword(ctx, creds)
 		}
 		if err == nil { // early return on success
-			return nil
+			return tryNo, nil
 		}
 
 		// ------------------------------------------------------------------
 		// Error, retry?
 		if tryNo == m.tries { // early return on last try (don't sleep)
-			return err
+			return tryNo, err
 		}
 
 		// Error setting password and retries remain...
@@ -332,26 +1182,173 @@ func (m *PasswordSetter) setOne(ctx context.Context, creds db.NewPassword, actio
 		// SetPassword err because  that's the last thing we ran.
 		select {
 		case <-ctx.Done():
-			log.Printf("%s: context cancelled after %s password, not retrying (%d tries remained)", creds.Current.Hostname, action, m.tries-tryNo)
-			return err
+			m.hostLog("%s: context cancelled after %s password, not retrying (%d tries remained)", creds.Current.Hostname, action, m.tries-tryNo)
+			return tryNo, err
 		default:
 		}
 
-		// Sleep between tries
-		log.Printf("%s: error %s password try %d of %d, retry in %s: %s", creds.Current.Hostname, action, tryNo, m.tries, m.cfg.RetryWait, err)
-		time.Sleep(m.cfg.RetryWait)
+		// Sleep between tries, unless that exceeds the max retry time or
+		// the deadline of the db instance
+		wait := m.retryWait(tryNo)
+		if m.cfg.MaxRetryElapsed > 0 && time.Now().Add(wait).Sub(t0) > m.cfg.MaxRetryElapsed {
+			m.hostLog("%s: error %s password try %d of %d, not retrying after %s (%d tries remained): %s", creds.Current.Hostname, action, tryNo, m.tries, m.cfg.MaxRetryElapsed, m.tries-tryNo, err)
+			return tryNo, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			m.hostLog("%s: error %s password try %d of %d, not retrying because deadline is in %s (%d tries remained): %s", creds.Current.Hostname, action, tryNo, m.tries, time.Until(deadline).Round(time.Millisecond), m.tries-tryNo, err)
+			return tryNo, err
+		}
+		m.hostLog("%s: error %s password try %d of %d, retry in %s: %s", creds.Current.Hostname, action, tryNo, m.tries, wait, err)
 
-		// Check context again in case it was cancelled during the sleep. Return
-		// the context error because we'd only return here if it's cancelled;
+		// Return the context error if it's cancelled during the sleep;
 		// returning the SetPassword err here would be misleading.
 		select {
 		case <-ctx.Done():
-			log.Printf("%s: context cancelled after %s password retry wait, not retrying (%d tries remained)", creds.Current.Hostname, action, m.tries-tryNo)
-			return ctx.Err()
-		default:
+			m.hostLog("%s: context cancelled during %s password retry wait, not retrying (%d tries remained)", creds.Current.Hostname, action, m.tries-tryNo)
+			return tryNo, ctx.Err()
+		case <-time.After(wait):
 		}
 	}
 
 	// Code shouldn't reach here. Don't panic (caller doesn't recover), just return an error.
-	return fmt.Errorf("mysql.PasswordSetter.setOne() reached end of function on %s password", action)
+	return m.tries, fmt.Errorf("mysql.PasswordSetter.setOne() reached end of function on %s password", action)
+}
+
+// retryWait returns the wait after try number tryNo (1 is the first try):
+// Config.RetryWait, or if Config.RetryBackoff is true, RetryWait * 2^(tryNo-1)
+// up to Config.MaxRetryWait, with jitter.
+func (m *PasswordSetter) retryWait(tryNo uint) time.Duration {
+	if !m.cfg.RetryBackoff || m.cfg.RetryWait <= 0 {
+		return m.cfg.RetryWait
+	}
+	wait := m.cfg.RetryWait
+	for i := uint(1); i < tryNo && wait < m.cfg.MaxRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > m.cfg.MaxRetryWait {
+		wait = m.cfg.MaxRetryWait
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// verifyReplica verifies the password on a reader, retrying every
+// REPLICATION_POLL_INTERVAL until Config.ReplicationWait for the password set
+// on the writer to replicate. It returns the number of tries, like setOne.
+//
+// This func is called from setHost if Config.WriterOnly is true.
+func (m *PasswordSetter) verifyReplica(ctx context.Context, creds db.NewPassword, action string) (uint, error) {
+	t0 := time.Now()
+	deadline := t0.Add(m.cfg.ReplicationWait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d // deadline of the db instance
+	}
+	tries := uint(0)
+	for {
+		err := m.replicationLag(ctx, creds)
+		if err == nil {
+			var n uint
+			n, err = m.setOne(ctx, creds, action)
+			tries += n
+		}
+		if err == nil {
+			return tries, nil
+		}
+		if time.Now().Add(REPLICATION_POLL_INTERVAL).After(deadline) {
+			return tries, fmt.Errorf("not replicated after %s: %s", time.Since(t0).Round(time.Millisecond), err)
+		}
+		m.hostLog("%s: reader: %s password failed, retry in %s: %s", creds.Current.Hostname, action, REPLICATION_POLL_INTERVAL, err)
+		select {
+		case <-ctx.Done():
+			return tries, ctx.Err()
+		case <-time.After(REPLICATION_POLL_INTERVAL):
+		}
+	}
+}
+
+// replicationLag returns an error if Config.ReplicationLagCheck is true and
+// the reader has replication lag. This func is called from verifyReplica.
+func (m *PasswordSetter) replicationLag(ctx context.Context, creds db.NewPassword) error {
+	if !m.cfg.ReplicationLagCheck {
+		return nil
+	}
+	lc, ok := m.cfg.DbClient.(ReplicationLagClient)
+	if !ok {
+		return fmt.Errorf("Config.DbClient %T does not implement ReplicationLagClient", m.cfg.DbClient)
+	}
+	lag, err := lc.ReplicationLag(ctx, creds)
+	if err != nil {
+		return fmt.Errorf("cannot check replication lag: %s", err)
+	}
+	if lag > 0 {
+		return fmt.Errorf("replication lag %s", lag)
+	}
+	return nil
+}
+
+// clusterWriters calls RDS DescribeDBClusters and returns the clusters keyed on
+// the identifiers of their writers, for the clusters of the db instances, if any.
+func (m *PasswordSetter) clusterWriters(instances []*rds.DBInstance) (map[string]*rds.DBCluster, error) {
+	writers := map[string]*rds.DBCluster{}
+	clusters := false
+	for _, i := range instances {
+		clusters = clusters || i.DBClusterIdentifier != nil
+	}
+	if !clusters {
+		return writers, nil
+	}
+	t0 := time.Now()
+	result, err := m.cfg.RDSClient.DescribeDBClusters(&rds.DescribeDBClustersInput{}) // all clusters
+	log.Printf("RDS.DescribeDBClusters response time: %dms", time.Now().Sub(t0).Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range result.DBClusters {
+		for _, member := range c.DBClusterMembers {
+			if aws.BoolValue(member.IsClusterWriter) {
+				writers[aws.StringValue(member.DBInstanceIdentifier)] = c
+			}
+		}
+	}
+	return writers, nil
+}
+
+// isReader returns true if the db instance is an Aurora cluster reader (not
+// in writers) or a read replica.
+func isReader(db *rds.DBInstance, writers map[string]*rds.DBCluster) bool {
+	if db.ReadReplicaSourceDBInstanceIdentifier != nil {
+		return true
+	}
+	return db.DBClusterIdentifier != nil && writers[aws.StringValue(db.DBInstanceIdentifier)] == nil
+}
+
+// allowedFailures returns the number of n db instances that can fail for the
+// failure threshold, which is a number ("2") or percentage ("10%"), or empty
+// for zero.
+func allowedFailures(threshold string, n int) (int, error) {
+	if threshold == "" {
+		return 0, nil
+	}
+	if pct := strings.TrimSuffix(threshold, "%"); pct != threshold {
+		p, err := strconv.ParseFloat(pct, 64)
+		if err != nil || p < 0 || p > 100 {
+			return 0, fmt.Errorf("invalid failure threshold: %s: must be a percentage between 0%% and 100%%", threshold)
+		}
+		return int(float64(n) * p / 100), nil
+	}
+	allowed, err := strconv.ParseUint(threshold, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid failure threshold: %s: must be an integer >= 0 or a percentage", threshold)
+	}
+	return int(allowed), nil
+}
+
+// withExtra returns a copy of extra with key set to val. The copy is required
+// because all goroutines in setAll share the same creds.
+func withExtra(extra map[string]string, key, val string) map[string]string {
+	cp := make(map[string]string, len(extra)+1)
+	for k, v := range extra {
+		cp[k] = v
+	}
+	cp[key] = val
+	return cp
 }
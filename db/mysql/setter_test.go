@@ -4,7 +4,9 @@ package mysql_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -35,7 +37,7 @@ func TestPasswordSetterOne(t *testing.T) {
 						DBInstanceArn:        aws.String("arn"),
 						DBInstanceIdentifier: aws.String("db-1"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr:3306"),
+							Address: aws.String("addr"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -97,12 +99,12 @@ func TestPasswordSetterOne(t *testing.T) {
 		Current: db.Credentials{
 			Username: "user",
 			Password: "old_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 		New: db.Credentials{
 			Username: "user",
 			Password: "new_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 	}
 	err = ps.SetPassword(context.TODO(), creds)
@@ -119,8 +121,8 @@ func TestPasswordSetterOne(t *testing.T) {
 	// and RDS hostname, which our mock smashes together as one string
 	expectCreds := []db.NewPassword{
 		{
-			Current: db.Credentials{Username: "user", Password: "old_pass", Hostname: "addr:3306"},
-			New:     db.Credentials{Username: "user", Password: "new_pass", Hostname: "addr:3306"},
+			Current: db.Credentials{Username: "user", Password: "old_pass", Hostname: "addr", Port: 3306},
+			New:     db.Credentials{Username: "user", Password: "new_pass", Hostname: "addr", Port: 3306},
 		},
 	}
 	if diff := deep.Equal(gotCreds, expectCreds); diff != nil {
@@ -160,7 +162,7 @@ func TestPasswordSetterRetry(t *testing.T) {
 						DBInstanceArn:        aws.String("arn"),
 						DBInstanceIdentifier: aws.String("db-1"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr:3306"),
+							Address: aws.String("addr"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -209,12 +211,12 @@ func TestPasswordSetterRetry(t *testing.T) {
 		Current: db.Credentials{
 			Username: "user",
 			Password: "old_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 		New: db.Credentials{
 			Username: "user",
 			Password: "new_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 	}
 	err = ps.SetPassword(context.TODO(), creds)
@@ -305,6 +307,208 @@ func TestPasswordSetterRetry(t *testing.T) {
 	}
 }
 
+func TestPasswordSetterRetryBackoff(t *testing.T) {
+	// Test that Config.RetryBackoff doubles the wait between retries, with jitter,
+	// up to MaxRetryWait, and that MaxRetryElapsed stops retrying
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr")}},
+				},
+			}, nil
+		},
+	}
+	callTimes := []time.Time{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			callTimes = append(callTimes, time.Now())
+			return fmt.Errorf("fake db error")
+		},
+	}
+
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:    rdsClient,
+		DbClient:     mysqlClient,
+		Retry:        4,
+		RetryWait:    20 * time.Millisecond,
+		RetryBackoff: true,
+		MaxRetryWait: 60 * time.Millisecond,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err == nil {
+		t.Error("no error, expected fake db error")
+	}
+	if len(callTimes) != 5 {
+		t.Fatalf("got %d tries, expected 5", len(callTimes))
+	}
+	// Backoff 20, 40, 60 (max), 60 ms; with jitter, waits are half to all of that
+	backoff := []time.Duration{20, 40, 60, 60}
+	for i, b := range backoff {
+		b *= time.Millisecond
+		wait := callTimes[i+1].Sub(callTimes[i])
+		if wait < b/2 || wait > b+50*time.Millisecond {
+			t.Errorf("wait %d = %s, expected between %s and %s", i+1, wait, b/2, b)
+		}
+	}
+
+	// MaxRetryElapsed: tries at 0, 50, 100 ms, then the next at 150 ms would
+	// exceed 120 ms, so only 3 tries of 11
+	callTimes = []time.Time{}
+	ps = mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:       rdsClient,
+		DbClient:        mysqlClient,
+		Retry:           10,
+		RetryWait:       50 * time.Millisecond,
+		MaxRetryElapsed: 120 * time.Millisecond,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err == nil {
+		t.Error("no error, expected fake db error")
+	}
+	if len(callTimes) != 3 {
+		t.Errorf("got %d tries, expected 3", len(callTimes))
+	}
+}
+
+func TestPasswordSetterFailureThreshold(t *testing.T) {
+	// Test that Config.FailureThreshold allows that many instances to fail
+	// SetPassword and VerifyPassword, but not more
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			dbs := []*rds.DBInstance{}
+			for i := 1; i <= 10; i++ {
+				dbs = append(dbs, &rds.DBInstance{Endpoint: &rds.Endpoint{Address: aws.String(fmt.Sprintf("addr%d", i))}})
+			}
+			return &rds.DescribeDBInstancesOutput{DBInstances: dbs}, nil
+		},
+	}
+	failHosts := map[string]bool{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if failHosts[creds.Current.Hostname] {
+				return fmt.Errorf("rebooting")
+			}
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if failHosts[creds.New.Hostname] {
+				return fmt.Errorf("rebooting")
+			}
+			return nil
+		},
+	}
+
+	tests := []struct {
+		threshold string
+		failHosts []string
+		ok        bool
+	}{
+		{"", []string{"addr1"}, false},
+		{"1", []string{"addr1"}, true},
+		{"1", []string{"addr1", "addr2"}, false},
+		{"20%", []string{"addr1", "addr2"}, true},
+		{"25%", []string{"addr1", "addr2", "addr3"}, false}, // 25% of 10 = 2
+	}
+	for _, tt := range tests {
+		failHosts = map[string]bool{}
+		for _, h := range tt.failHosts {
+			failHosts[h] = true
+		}
+		ps := mysql.NewPasswordSetter(mysql.Config{
+			RDSClient:        rdsClient,
+			DbClient:         mysqlClient,
+			FailureThreshold: tt.threshold,
+		})
+		if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+			t.Fatal(err)
+		}
+		err := ps.SetPassword(context.TODO(), db.NewPassword{})
+		if tt.ok && err != nil {
+			t.Errorf("threshold %q, %d failed: SetPassword error %s, expected no error", tt.threshold, len(tt.failHosts), err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("threshold %q, %d failed: SetPassword no error, expected an error", tt.threshold, len(tt.failHosts))
+		}
+		err = ps.VerifyPassword(context.TODO(), db.NewPassword{})
+		if tt.ok && err != nil {
+			t.Errorf("threshold %q, %d failed: VerifyPassword error %s, expected no error", tt.threshold, len(tt.failHosts), err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("threshold %q, %d failed: VerifyPassword no error, expected an error", tt.threshold, len(tt.failHosts))
+		}
+	}
+
+	// Invalid thresholds are errors
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:        rdsClient,
+		DbClient:         mysqlClient,
+		FailureThreshold: "ten",
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err == nil {
+		t.Error("no error for invalid Config.FailureThreshold, expected an error")
+	}
+	if err := ps.Tune(map[string]string{"failure_threshold": "101%"}); err == nil {
+		t.Error("no error for invalid failure_threshold setting, expected an error")
+	}
+}
+
+func TestPasswordSetterFleetError(t *testing.T) {
+	// Test that a failed password action returns a FleetError with the failed
+	// hosts, and that errors.Is matches the host errors
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr3")}},
+				},
+			}, nil
+		},
+	}
+	errDenied := errors.New("access denied")
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.Current.Hostname == "addr2" {
+				return errDenied
+			}
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  mysqlClient,
+		Retry:     1,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	err := ps.SetPassword(context.TODO(), db.NewPassword{})
+	var fleetErr *mysql.FleetError
+	if !errors.As(err, &fleetErr) {
+		t.Fatalf("got error %v (%T), expected a *mysql.FleetError", err, err)
+	}
+	expect := &mysql.FleetError{
+		Action:    "setting",
+		Instances: 3,
+		Hosts: []mysql.HostError{
+			{Hostname: "addr2", Action: "setting", Tries: 2, Err: errDenied},
+		},
+	}
+	if diff := deep.Equal(fleetErr, expect); diff != nil {
+		t.Error(diff)
+	}
+	if !errors.Is(err, errDenied) {
+		t.Error("errors.Is(err, errDenied) = false, expected true")
+	}
+	if expect := "setting password failed on 1 of 3 database instances: addr2 (2 tries): access denied"; err.Error() != expect {
+		t.Errorf("got error %q, expected %q", err.Error(), expect)
+	}
+}
+
 func TestPasswordSetterFilterFunc(t *testing.T) {
 	// Test that the user-provided filter func filters out RDS instances. Since
 	// the final list is internal the PaswordSetter, we'll have to call SetPassword
@@ -319,7 +523,7 @@ func TestPasswordSetterFilterFunc(t *testing.T) {
 						DBInstanceArn:        aws.String("arn1"),
 						DBInstanceIdentifier: aws.String("db-1"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr1:3306"),
+							Address: aws.String("addr1"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -327,7 +531,7 @@ func TestPasswordSetterFilterFunc(t *testing.T) {
 						DBInstanceArn:        aws.String("arn2"),
 						DBInstanceIdentifier: aws.String("db-2"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr2:3306"),
+							Address: aws.String("addr2"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -336,7 +540,7 @@ func TestPasswordSetterFilterFunc(t *testing.T) {
 						DBInstanceArn:        aws.String("arn3"),
 						DBInstanceIdentifier: aws.String("db-3"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr3:3306"),
+							Address: aws.String("addr3"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -355,7 +559,7 @@ func TestPasswordSetterFilterFunc(t *testing.T) {
 
 	filter := func(in *rds.DBInstance) bool {
 		// Keep only the last instance, filter out the first two
-		return *in.Endpoint.Address != "addr3:3306"
+		return *in.Endpoint.Address != "addr3"
 	}
 
 	// Create new PasswordSetter with a filter func
@@ -377,12 +581,12 @@ func TestPasswordSetterFilterFunc(t *testing.T) {
 		Current: db.Credentials{
 			Username: "user",
 			Password: "old_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 		New: db.Credentials{
 			Username: "user",
 			Password: "new_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 	}
 	err = ps.SetPassword(context.TODO(), creds)
@@ -393,13 +597,1072 @@ func TestPasswordSetterFilterFunc(t *testing.T) {
 	// We know the filter func worked because only addr3 was used
 	expectCreds := []db.NewPassword{
 		{
-			Current: db.Credentials{Username: "user", Password: "old_pass", Hostname: "addr3:3306"},
-			New:     db.Credentials{Username: "user", Password: "new_pass", Hostname: "addr3:3306"},
+			Current: db.Credentials{Username: "user", Password: "old_pass", Hostname: "addr3", Port: 3306},
+			New:     db.Credentials{Username: "user", Password: "new_pass", Hostname: "addr3", Port: 3306},
+		},
+	}
+	if diff := deep.Equal(gotCreds, expectCreds); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPasswordSetterTuneFilter(t *testing.T) {
+	// Test that a filter expression set by Tune (from secret tags) filters out
+	// RDS instances just like the Config.Filter func
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier: aws.String("db-1"),
+						DBClusterIdentifier:  aws.String("prod-1"),
+						Engine:               aws.String("aurora-mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr1")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("db-2"),
+						DBClusterIdentifier:  aws.String("test-1"),
+						Engine:               aws.String("aurora-mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr2")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("db-3"),
+						Engine:               aws.String("mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr3")},
+					},
+				},
+			}, nil
+		},
+	}
+
+	gotHosts := []string{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotHosts = append(gotHosts, creds.Current.Hostname)
+			return nil
+		},
+	}
+
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  mysqlClient,
+	})
+
+	err := ps.Tune(map[string]string{"filter": "engine=aurora-mysql, cluster=prod-*", "parallel": "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Error(err)
+	}
+	if diff := deep.Equal(gotHosts, []string{"addr1"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Invalid settings are errors
+	if err := ps.Tune(map[string]string{"parallel": "0"}); err == nil {
+		t.Error("no error for parallel=0, expected an error")
+	}
+	if err := ps.Tune(map[string]string{"filter": "region=us-east-1"}); err == nil {
+		t.Error("no error for unknown filter key, expected an error")
+	}
+}
+
+func TestPasswordSetterInclude(t *testing.T) {
+	// Test that Config.Include with built-in predicates includes only matching
+	// RDS instances, in addition to Config.Filter
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier: aws.String("prod-db-1"),
+						DBClusterIdentifier:  aws.String("prod-1"),
+						DBInstanceStatus:     aws.String("available"),
+						Engine:               aws.String("aurora-mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr1")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("prod-db-2"),
+						DBClusterIdentifier:  aws.String("prod-1"),
+						DBInstanceStatus:     aws.String("stopped"),
+						Engine:               aws.String("aurora-mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr2")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("prod-db-3"),
+						DBInstanceStatus:     aws.String("available"),
+						Engine:               aws.String("mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr3")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("test-db-1"),
+						DBInstanceStatus:     aws.String("available"),
+						Engine:               aws.String("mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr4")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("prod-db-5"),
+						DBInstanceStatus:     aws.String("available"),
+						Engine:               aws.String("mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr5")},
+					},
+				},
+			}, nil
+		},
+	}
+
+	gotHosts := []string{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotHosts = append(gotHosts, creds.Current.Hostname)
+			return nil
+		},
+	}
+
+	// Available instances that are in cluster prod-1 or are MySQL named prod-*,
+	// except addr5 which Filter filters out
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  mysqlClient,
+		Include: mysql.And(
+			mysql.Not(mysql.ByStatus("stopped", "stopping")),
+			mysql.Or(
+				mysql.ByClusterID("prod-1"),
+				mysql.And(mysql.ByEngine("mysql"), mysql.ByNameGlob("prod-*")),
+			),
+		),
+		Filter: func(db *rds.DBInstance) bool {
+			return *db.Endpoint.Address == "addr5"
+		},
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Error(err)
+	}
+	if diff := deep.Equal(gotHosts, []string{"addr1", "addr3"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Edge cases: no cluster, invalid glob, empty And and Or
+	noCluster := &rds.DBInstance{DBInstanceIdentifier: aws.String("db-1")}
+	if mysql.ByClusterID("")(noCluster) {
+		t.Error("ByClusterID matched an instance not in a cluster")
+	}
+	if mysql.ByNameGlob("[")(noCluster) {
+		t.Error("ByNameGlob matched an invalid pattern")
+	}
+	if !mysql.And()(noCluster) {
+		t.Error("And() did not match, expected it to match all")
+	}
+	if mysql.Or()(noCluster) {
+		t.Error("Or() matched, expected it to match none")
+	}
+}
+
+func TestPasswordSetterWriterOnly(t *testing.T) {
+	// Test that with Config.WriterOnly, the password is set only on writers
+	// (Aurora cluster writer and non-replicas) and verified on all instances,
+	// retrying readers until the change replicates
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier: aws.String("aurora-1"),
+						DBClusterIdentifier:  aws.String("cluster-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("writer1")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("aurora-2"),
+						DBClusterIdentifier:  aws.String("cluster-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("reader1")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("mysql-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("writer2")},
+					},
+					{
+						DBInstanceIdentifier:                  aws.String("mysql-2"),
+						ReadReplicaSourceDBInstanceIdentifier: aws.String("mysql-1"),
+						Endpoint:                              &rds.Endpoint{Address: aws.String("reader2")},
+					},
+				},
+			}, nil
+		},
+		DescribeDBClustersFunc: func(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+			return &rds.DescribeDBClustersOutput{
+				DBClusters: []*rds.DBCluster{
+					{
+						DBClusterIdentifier: aws.String("cluster-1"),
+						DBClusterMembers: []*rds.DBClusterMember{
+							{DBInstanceIdentifier: aws.String("aurora-1"), IsClusterWriter: aws.Bool(true)},
+							{DBInstanceIdentifier: aws.String("aurora-2"), IsClusterWriter: aws.Bool(false)},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	var mux sync.Mutex
+	gotSet := []string{}
+	gotVerified := map[string]int{}
+	lagging := true // reader1 fails first verify (replication lag)
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			defer mux.Unlock()
+			gotSet = append(gotSet, creds.Current.Hostname)
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			defer mux.Unlock()
+			gotVerified[creds.New.Hostname]++
+			if creds.New.Hostname == "reader1" && lagging {
+				lagging = false
+				return fmt.Errorf("access denied")
+			}
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:  rdsClient,
+		DbClient:   mysqlClient,
+		Parallel:   4,
+		WriterOnly: true,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(gotSet)
+	if diff := deep.Equal(gotSet, []string{"writer1", "writer2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Rollback is also only on writers
+	gotSet = []string{}
+	if err := ps.Rollback(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(gotSet)
+	if diff := deep.Equal(gotSet, []string{"writer1", "writer2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	expectVerified := map[string]int{"writer1": 1, "reader1": 2, "writer2": 1, "reader2": 1}
+	if diff := deep.Equal(gotVerified, expectVerified); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPasswordSetterClusterWriterEndpoint(t *testing.T) {
+	// Test that with Config.ClusterWriterEndpoint, the password is set on the
+	// Aurora cluster writer through the cluster endpoint, but verified on the
+	// instance endpoints
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier: aws.String("aurora-1"),
+						DBClusterIdentifier:  aws.String("cluster-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("writer1"), Port: aws.Int64(3306)},
+					},
+					{
+						DBInstanceIdentifier: aws.String("aurora-2"),
+						DBClusterIdentifier:  aws.String("cluster-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("reader1"), Port: aws.Int64(3306)},
+					},
+					{
+						DBInstanceIdentifier: aws.String("mysql-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("writer2"), Port: aws.Int64(3306)},
+					},
+				},
+			}, nil
+		},
+		DescribeDBClustersFunc: func(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+			return &rds.DescribeDBClustersOutput{
+				DBClusters: []*rds.DBCluster{
+					{
+						DBClusterIdentifier: aws.String("cluster-1"),
+						Endpoint:            aws.String("cluster-1.cluster"),
+						Port:                aws.Int64(3307),
+						DBClusterMembers: []*rds.DBClusterMember{
+							{DBInstanceIdentifier: aws.String("aurora-1"), IsClusterWriter: aws.Bool(true)},
+							{DBInstanceIdentifier: aws.String("aurora-2"), IsClusterWriter: aws.Bool(false)},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	var mux sync.Mutex
+	gotSet := []string{}
+	gotVerified := []string{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			defer mux.Unlock()
+			gotSet = append(gotSet, fmt.Sprintf("%s:%d", creds.Current.Hostname, creds.Current.Port))
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			defer mux.Unlock()
+			gotVerified = append(gotVerified, fmt.Sprintf("%s:%d", creds.New.Hostname, creds.New.Port))
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:             rdsClient,
+		DbClient:              mysqlClient,
+		Parallel:              4,
+		WriterOnly:            true,
+		ClusterWriterEndpoint: true,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(gotSet)
+	if diff := deep.Equal(gotSet, []string{"cluster-1.cluster:3307", "writer2:3306"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Rollback is through the cluster endpoint, too
+	gotSet = []string{}
+	if err := ps.Rollback(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(gotSet)
+	if diff := deep.Equal(gotSet, []string{"cluster-1.cluster:3307", "writer2:3306"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Verify is on every instance endpoint
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(gotVerified)
+	if diff := deep.Equal(gotVerified, []string{"reader1:3306", "writer1:3306", "writer2:3306"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+type lagClient struct {
+	test.MockMySQLPasswordClient
+	lags *[]time.Duration
+}
+
+func (c lagClient) ReplicationLag(ctx context.Context, creds db.NewPassword) (time.Duration, error) {
+	lag := (*c.lags)[0]
+	*c.lags = (*c.lags)[1:]
+	return lag, nil
+}
+
+func TestPasswordSetterReplicationLagCheck(t *testing.T) {
+	// Test that with Config.ReplicationLagCheck, a reader is not verified until
+	// its replication lag is zero
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier:                  aws.String("mysql-2"),
+						ReadReplicaSourceDBInstanceIdentifier: aws.String("mysql-1"),
+						Endpoint:                              &rds.Endpoint{Address: aws.String("reader")},
+					},
+				},
+			}, nil
+		},
+	}
+
+	// DbClient must implement ReplicationLagClient
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:           rdsClient,
+		DbClient:            test.MockMySQLPasswordClient{},
+		WriterOnly:          true,
+		ReplicationWait:     time.Millisecond,
+		ReplicationLagCheck: true,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err == nil {
+		t.Error("no error, expected error because DbClient does not implement ReplicationLagClient")
+	}
+
+	nVerified := 0
+	lags := []time.Duration{2 * time.Second, 0}
+	ps = mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient: lagClient{
+			MockMySQLPasswordClient: test.MockMySQLPasswordClient{
+				VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+					nVerified++
+					return nil
+				},
+			},
+			lags: &lags,
+		},
+		WriterOnly:          true,
+		ReplicationLagCheck: true,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if nVerified != 1 {
+		t.Errorf("VerifyPassword called %d times, expected 1 (after lag is zero)", nVerified)
+	}
+	if len(lags) != 0 {
+		t.Errorf("ReplicationLag not called until lag is zero, %d lags remain", len(lags))
+	}
+}
+
+func TestPasswordSetterSkipReaders(t *testing.T) {
+	// Test that Config.SkipReaders filters out Aurora readers and read replicas
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier: aws.String("aurora-1"),
+						DBClusterIdentifier:  aws.String("cluster-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("writer1")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("aurora-2"),
+						DBClusterIdentifier:  aws.String("cluster-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("reader1")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("mysql-1"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("writer2")},
+					},
+					{
+						DBInstanceIdentifier:                  aws.String("mysql-2"),
+						ReadReplicaSourceDBInstanceIdentifier: aws.String("mysql-1"),
+						Endpoint:                              &rds.Endpoint{Address: aws.String("reader2")},
+					},
+				},
+			}, nil
+		},
+		DescribeDBClustersFunc: func(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+			return &rds.DescribeDBClustersOutput{
+				DBClusters: []*rds.DBCluster{
+					{
+						DBClusterIdentifier: aws.String("cluster-1"),
+						DBClusterMembers: []*rds.DBClusterMember{
+							{DBInstanceIdentifier: aws.String("aurora-1"), IsClusterWriter: aws.Bool(true)},
+							{DBInstanceIdentifier: aws.String("aurora-2"), IsClusterWriter: aws.Bool(false)},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	gotSet := []string{}
+	gotVerified := []string{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotSet = append(gotSet, creds.Current.Hostname)
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotVerified = append(gotVerified, creds.New.Hostname)
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:   rdsClient,
+		DbClient:    mysqlClient,
+		SkipReaders: true,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotSet, []string{"writer1", "writer2"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(gotVerified, []string{"writer1", "writer2"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPasswordSetterWriterOnlyNotReplicated(t *testing.T) {
+	// Test that VerifyPassword fails if the change does not replicate to a
+	// reader within Config.ReplicationWait
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier:                  aws.String("mysql-2"),
+						ReadReplicaSourceDBInstanceIdentifier: aws.String("mysql-1"),
+						Endpoint:                              &rds.Endpoint{Address: aws.String("reader")},
+					},
+				},
+			}, nil
+		},
+	}
+	mysqlClient := test.MockMySQLPasswordClient{
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			return fmt.Errorf("access denied")
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:       rdsClient,
+		DbClient:        mysqlClient,
+		WriterOnly:      true,
+		ReplicationWait: time.Millisecond,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err == nil {
+		t.Error("no error, expected VerifyPassword to fail on the reader")
+	}
+}
+
+func TestPasswordSetterWriterOnlyProbe(t *testing.T) {
+	// Test that a probe, like Rotator checking if the new password is already
+	// set, fails fast on a reader instead of waiting Config.ReplicationWait
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier:                  aws.String("mysql-2"),
+						ReadReplicaSourceDBInstanceIdentifier: aws.String("mysql-1"),
+						Endpoint:                              &rds.Endpoint{Address: aws.String("reader")},
+					},
+				},
+			}, nil
+		},
+	}
+	tries := 0
+	mysqlClient := test.MockMySQLPasswordClient{
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			tries++
+			return fmt.Errorf("access denied")
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:       rdsClient,
+		DbClient:        mysqlClient,
+		WriterOnly:      true,
+		ReplicationWait: 10 * time.Second,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	t0 := time.Now()
+	if err := ps.VerifyPassword(db.WithProbe(context.TODO()), db.NewPassword{}); err == nil {
+		t.Error("no error, expected VerifyPassword to fail on the reader")
+	}
+	if d := time.Since(t0); d > time.Second {
+		t.Errorf("probe took %s, expected it to not wait ReplicationWait", d)
+	}
+	if tries != 1 {
+		t.Errorf("got %d tries, expected 1", tries)
+	}
+}
+
+func TestPasswordSetterNoBinlog(t *testing.T) {
+	// Test that Config.NoBinlog and the no_binlog tune setting set sql_log_bin=0
+	// in Extra only for matching instances, and not for verify
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier: aws.String("db-1"),
+						Engine:               aws.String("mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr1")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("db-2"),
+						Engine:               aws.String("mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr2")},
+					},
+					{
+						DBInstanceIdentifier: aws.String("db-3"),
+						Engine:               aws.String("aurora-mysql"),
+						Endpoint:             &rds.Endpoint{Address: aws.String("addr3")},
+					},
+				},
+			}, nil
+		},
+	}
+
+	var mux sync.Mutex
+	gotSet := map[string]string{}
+	gotVerify := map[string]string{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			gotSet[creds.Current.Hostname] = creds.Current.Extra[mysql.EXTRA_SQL_LOG_BIN]
+			mux.Unlock()
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			gotVerify[creds.Current.Hostname] = creds.Current.Extra[mysql.EXTRA_SQL_LOG_BIN]
+			mux.Unlock()
+			return nil
+		},
+	}
+
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  mysqlClient,
+		Parallel:  3,
+		NoBinlog: func(db *rds.DBInstance) bool {
+			return *db.DBInstanceIdentifier == "db-1"
+		},
+	})
+	if err := ps.Tune(map[string]string{"no_binlog": "engine=aurora-mysql"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	creds := db.NewPassword{Current: db.Credentials{Extra: map[string]string{"engine": "mysql"}}}
+	if err := ps.SetPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+	if err := ps.VerifyPassword(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+	if diff := deep.Equal(gotSet, map[string]string{"addr1": "0", "addr2": "", "addr3": "0"}); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(gotVerify, map[string]string{"addr1": "", "addr2": "", "addr3": ""}); diff != nil {
+		t.Error(diff)
+	}
+	if len(creds.Current.Extra) != 1 {
+		t.Errorf("caller creds.Current.Extra modified: %v", creds.Current.Extra)
+	}
+}
+
+func TestPasswordSetterAuthPlugin(t *testing.T) {
+	// Test that Config.AuthPlugin, or the auth_plugin tune setting, is set in
+	// Extra to set and verify the password
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{{Endpoint: &rds.Endpoint{Address: aws.String("addr1")}}},
+			}, nil
+		},
+	}
+	var got []string
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			got = append(got, "set "+creds.Current.Extra[mysql.EXTRA_AUTH_PLUGIN])
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			got = append(got, "verify "+creds.New.Extra[mysql.EXTRA_AUTH_PLUGIN])
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:  rdsClient,
+		DbClient:   mysqlClient,
+		AuthPlugin: mysql.CACHING_SHA2_PASSWORD,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Error(err)
+	}
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Error(err)
+	}
+	if err := ps.Tune(map[string]string{"auth_plugin": mysql.NATIVE_PASSWORD}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Error(err)
+	}
+	expect := []string{
+		"set " + mysql.CACHING_SHA2_PASSWORD,
+		"verify " + mysql.CACHING_SHA2_PASSWORD,
+		"set " + mysql.NATIVE_PASSWORD,
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+type discardClient struct {
+	test.MockMySQLPasswordClient
+	mux   *sync.Mutex
+	hosts *[]string
+}
+
+func (c discardClient) DiscardOldPassword(ctx context.Context, creds db.NewPassword) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	*c.hosts = append(*c.hosts, creds.New.Hostname)
+	return nil
+}
+
+func TestPasswordSetterDiscardOldPassword(t *testing.T) {
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+				},
+			}, nil
+		},
+	}
+
+	// DbClient must implement OldPasswordClient
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  test.MockMySQLPasswordClient{},
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.DiscardOldPassword(context.TODO(), db.NewPassword{}); err == nil {
+		t.Error("no error, expected error because DbClient does not implement OldPasswordClient")
+	}
+
+	gotHosts := []string{}
+	ps = mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  discardClient{mux: &sync.Mutex{}, hosts: &gotHosts},
+		Parallel:  2,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.DiscardOldPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Error(err)
+	}
+	sort.Strings(gotHosts)
+	if diff := deep.Equal(gotHosts, []string{"addr1", "addr2"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+type closerClient struct {
+	test.MockMySQLPasswordClient
+	closed *int
+}
+
+func (c closerClient) CloseConnections() {
+	*c.closed++
+}
+
+func TestPasswordSetterCloseConnections(t *testing.T) {
+	// If DbClient reuses connections, PasswordSetter closes them after each
+	// password change so they're not reused in the next
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+				},
+			}, nil
+		},
+	}
+	closed := 0
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  closerClient{closed: &closed},
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if closed != 1 {
+		t.Errorf("CloseConnections called %d times after SetPassword, expected 1", closed)
+	}
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if closed != 2 {
+		t.Errorf("CloseConnections called %d times after VerifyPassword, expected 2", closed)
+	}
+}
+
+func TestPasswordSetterHostDeadline(t *testing.T) {
+	// With a context deadline, a hung db instance gets only its share of the
+	// time (2s / 4 db instances = 500ms), so the others are still changed
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr3")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr4")}},
+				},
+			}, nil
+		},
+	}
+	var mux sync.Mutex
+	set := []string{}
+	var hung time.Duration
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient: test.MockMySQLPasswordClient{
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				if creds.Current.Hostname == "addr1" {
+					deadline, _ := ctx.Deadline()
+					hung = time.Until(deadline)
+					<-ctx.Done() // hung
+					return ctx.Err()
+				}
+				mux.Lock()
+				set = append(set, creds.Current.Hostname)
+				mux.Unlock()
+				return nil
+			},
+		},
+		Retry:     2,
+		RetryWait: 5 * time.Second, // longer than the deadline, so not retried
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	t0 := time.Now()
+	err := ps.SetPassword(ctx, db.NewPassword{})
+	if d := time.Since(t0); d > 1500*time.Millisecond {
+		t.Errorf("SetPassword took %s, expected about 500ms", d)
+	}
+	if hung > 500*time.Millisecond || hung < 400*time.Millisecond {
+		t.Errorf("hung db instance had %s, expected about 500ms", hung)
+	}
+	var fleetErr *mysql.FleetError
+	if !errors.As(err, &fleetErr) {
+		t.Fatalf("got error %v, expected *FleetError", err)
+	}
+	if len(fleetErr.Hosts) != 1 || fleetErr.Hosts[0].Hostname != "addr1" || fleetErr.Hosts[0].Tries != 1 {
+		t.Errorf("got failed hosts %+v, expected addr1 after 1 try", fleetErr.Hosts)
+	}
+	if diff := deep.Equal(set, []string{"addr2", "addr3", "addr4"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+type sessionClient struct {
+	test.MockMySQLPasswordClient
+	mux    *sync.Mutex
+	killed *[]string
+}
+
+func (c sessionClient) KillSessions(ctx context.Context, creds db.NewPassword, keep func(mysql.Session) bool) (int, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if keep(mysql.Session{Host: "10.0.8.5:41234"}) {
+		*c.killed = append(*c.killed, creds.New.Hostname)
+	}
+	return 1, nil
+}
+
+func TestPasswordSetterKillSessions(t *testing.T) {
+	// KillSessions kills sessions on all db instances, including readers that
+	// get the password by replication, with Config.KeepSessions
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{DBInstanceIdentifier: aws.String("db-1"), DBClusterIdentifier: aws.String("c1"), Endpoint: &rds.Endpoint{Address: aws.String("addr1")}},
+					{DBInstanceIdentifier: aws.String("db-2"), DBClusterIdentifier: aws.String("c1"), Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+				},
+			}, nil
+		},
+		DescribeDBClustersFunc: func(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+			return &rds.DescribeDBClustersOutput{
+				DBClusters: []*rds.DBCluster{
+					{
+						DBClusterIdentifier: aws.String("c1"),
+						DBClusterMembers: []*rds.DBClusterMember{
+							{DBInstanceIdentifier: aws.String("db-1"), IsClusterWriter: aws.Bool(true)},
+							{DBInstanceIdentifier: aws.String("db-2"), IsClusterWriter: aws.Bool(false)},
+						},
+					},
+				},
+			}, nil
+		},
+	}
+	killed := []string{}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:    rdsClient,
+		DbClient:     sessionClient{mux: &sync.Mutex{}, killed: &killed},
+		WriterOnly:   true,
+		Parallel:     2,
+		KeepSessions: mysql.KeepClientHosts("10.0.8.*"),
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.KillSessions(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(killed)
+	if diff := deep.Equal(killed, []string{"addr1", "addr2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// DbClient must implement SessionClient
+	ps = mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  test.MockMySQLPasswordClient{},
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.KillSessions(context.TODO(), db.NewPassword{}); err == nil {
+		t.Error("no error, expected error for DbClient without KillSessions")
+	}
+}
+
+type listClient struct {
+	test.MockMySQLPasswordClient
+}
+
+func (c listClient) ListSessions(ctx context.Context, creds db.NewPassword) ([]mysql.Session, error) {
+	switch creds.New.Hostname {
+	case "addr1":
+		return []mysql.Session{{Id: 1, Host: "10.0.1.5:1234"}, {Id: 2, Host: "10.0.1.5:1235"}}, nil
+	case "addr3":
+		return nil, fmt.Errorf("forced error")
+	}
+	return nil, nil
+}
+
+func TestPasswordSetterCountSessions(t *testing.T) {
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr3")}},
+				},
+			}, nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  listClient{},
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ps.CountSessions(context.TODO(), db.NewPassword{})
+	var fleetErr *mysql.FleetError
+	if !errors.As(err, &fleetErr) || len(fleetErr.Hosts) != 1 || fleetErr.Hosts[0].Hostname != "addr3" {
+		t.Errorf("got error %v, expected FleetError for addr3", err)
+	}
+	expect := map[string]int{"addr1": 2, "addr2": 0}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+type reportClient struct {
+	test.MockMySQLPasswordClient
+	report *[]mysql.DryRunStatement
+}
+
+func (c reportClient) DryRunReport() []mysql.DryRunStatement {
+	report := *c.report
+	*c.report = nil
+	return report
+}
+
+func TestPasswordSetterDryRunReport(t *testing.T) {
+	// If DbClient reports dry-run statements, PasswordSetter returns them,
+	// but not statements made before Init
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr1")}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+				},
+			}, nil
+		},
+	}
+	report := []mysql.DryRunStatement{{Hostname: "stale"}}
+	client := reportClient{
+		MockMySQLPasswordClient: test.MockMySQLPasswordClient{
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				report = append(report, mysql.DryRunStatement{Hostname: creds.Current.Hostname, Action: "set"})
+				return nil
+			},
 		},
+		report: &report,
 	}
-	if diff := deep.Equal(gotCreds, expectCreds); diff != nil {
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  client,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	expect := []mysql.DryRunStatement{
+		{Hostname: "addr1", Action: "set"},
+		{Hostname: "addr2", Action: "set"},
+	}
+	if diff := deep.Equal(ps.DryRunReport(), expect); diff != nil {
 		t.Error(diff)
 	}
+	if got := ps.DryRunReport(); got != nil {
+		t.Errorf("got %v after DryRunReport, expected nil (cleared)", got)
+	}
+
+	// Without DryRunReporter, the report is nil
+	ps = mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  test.MockMySQLPasswordClient{},
+	})
+	if got := ps.DryRunReport(); got != nil {
+		t.Errorf("got %v, expected nil", got)
+	}
 }
 
 func TestPasswordSetterParallel(t *testing.T) {
@@ -420,7 +1683,7 @@ func TestPasswordSetterParallel(t *testing.T) {
 						DBInstanceArn:        aws.String("arn1"),
 						DBInstanceIdentifier: aws.String("db-1"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr1:3306"),
+							Address: aws.String("addr1"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -428,7 +1691,7 @@ func TestPasswordSetterParallel(t *testing.T) {
 						DBInstanceArn:        aws.String("arn2"),
 						DBInstanceIdentifier: aws.String("db-2"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr2:3306"),
+							Address: aws.String("addr2"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -437,7 +1700,7 @@ func TestPasswordSetterParallel(t *testing.T) {
 						DBInstanceArn:        aws.String("arn3"),
 						DBInstanceIdentifier: aws.String("db-3"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr3:3306"),
+							Address: aws.String("addr3"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -485,12 +1748,12 @@ func TestPasswordSetterParallel(t *testing.T) {
 		Current: db.Credentials{
 			Username: "user",
 			Password: "old_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 		New: db.Credentials{
 			Username: "user",
 			Password: "new_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 	}
 
@@ -548,7 +1811,7 @@ func TestPasswordRollback(t *testing.T) {
 						DBInstanceArn:        aws.String("arn"),
 						DBInstanceIdentifier: aws.String("db-1"),
 						Endpoint: &rds.Endpoint{
-							Address: aws.String("addr:3306"),
+							Address: aws.String("addr"),
 							Port:    aws.Int64(3306),
 						},
 					},
@@ -592,12 +1855,12 @@ func TestPasswordRollback(t *testing.T) {
 		Current: db.Credentials{
 			Username: "user",
 			Password: "old_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 		New: db.Credentials{
 			Username: "user",
 			Password: "new_pass",
-			Hostname: "addr:3306",
+			Hostname: "addr",
 		},
 	}
 
@@ -613,12 +1876,101 @@ func TestPasswordRollback(t *testing.T) {
 
 	expectCreds := []db.NewPassword{
 		{ // SetPassword (old pass -> new)
-			Current: db.Credentials{Username: "user", Password: "old_pass", Hostname: "addr:3306"},
-			New:     db.Credentials{Username: "user", Password: "new_pass", Hostname: "addr:3306"},
+			Current: db.Credentials{Username: "user", Password: "old_pass", Hostname: "addr", Port: 3306},
+			New:     db.Credentials{Username: "user", Password: "new_pass", Hostname: "addr", Port: 3306},
 		},
 		{ // Rollback (new pass -> old)
-			Current: db.Credentials{Username: "user", Password: "new_pass", Hostname: "addr:3306"}, // swapped
-			New:     db.Credentials{Username: "user", Password: "old_pass", Hostname: "addr:3306"}, // swapped
+			Current: db.Credentials{Username: "user", Password: "new_pass", Hostname: "addr", Port: 3306}, // swapped
+			New:     db.Credentials{Username: "user", Password: "old_pass", Hostname: "addr", Port: 3306}, // swapped
+		},
+	}
+	if diff := deep.Equal(gotCreds, expectCreds); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPasswordRollbackMultiAccount(t *testing.T) {
+	// Test that all accounts in creds.Accounts are set in order and, when one
+	// account fails, Rollback rolls back only the accounts that were set
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceArn:        aws.String("arn"),
+						DBInstanceIdentifier: aws.String("db-1"),
+						Endpoint: &rds.Endpoint{
+							Address: aws.String("addr"),
+							Port:    aws.Int64(3306),
+						},
+					},
+				},
+			}, nil
+		},
+	}
+
+	// The third account (migrator) fails
+	gotCreds := []db.NewPassword{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotCreds = append(gotCreds, creds)
+			if creds.Current.Username == "migrator" {
+				return fmt.Errorf("access denied")
+			}
+			return nil
+		},
+	}
+
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  mysqlClient,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	creds := db.NewPassword{
+		Current: db.Credentials{Username: "app_rw", Password: "rw_old"},
+		New:     db.Credentials{Username: "app_rw", Password: "rw_new"},
+		Accounts: []db.NewPassword{
+			{
+				Current: db.Credentials{Username: "app_ro", Password: "ro_old"},
+				New:     db.Credentials{Username: "app_ro", Password: "ro_new"},
+			},
+			{
+				Current: db.Credentials{Username: "migrator", Password: "m_old"},
+				New:     db.Credentials{Username: "migrator", Password: "m_new"},
+			},
+		},
+	}
+
+	if err := ps.SetPassword(context.TODO(), creds); err == nil {
+		t.Error("no error, expected error because migrator account failed")
+	}
+	if err := ps.Rollback(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+
+	expectCreds := []db.NewPassword{
+		{ // SetPassword
+			Current: db.Credentials{Username: "app_rw", Password: "rw_old", Hostname: "addr", Port: 3306},
+			New:     db.Credentials{Username: "app_rw", Password: "rw_new", Hostname: "addr", Port: 3306},
+		},
+		{
+			Current: db.Credentials{Username: "app_ro", Password: "ro_old", Hostname: "addr", Port: 3306},
+			New:     db.Credentials{Username: "app_ro", Password: "ro_new", Hostname: "addr", Port: 3306},
+		},
+		{ // fails
+			Current: db.Credentials{Username: "migrator", Password: "m_old", Hostname: "addr", Port: 3306},
+			New:     db.Credentials{Username: "migrator", Password: "m_new", Hostname: "addr", Port: 3306},
+		},
+		{ // Rollback, only the 2 accounts that were set
+			Current: db.Credentials{Username: "app_rw", Password: "rw_new", Hostname: "addr", Port: 3306},
+			New:     db.Credentials{Username: "app_rw", Password: "rw_old", Hostname: "addr", Port: 3306},
+		},
+		{
+			Current: db.Credentials{Username: "app_ro", Password: "ro_new", Hostname: "addr", Port: 3306},
+			New:     db.Credentials{Username: "app_ro", Password: "ro_old", Hostname: "addr", Port: 3306},
 		},
 	}
 	if diff := deep.Equal(gotCreds, expectCreds); diff != nil {
@@ -670,3 +2022,511 @@ func TestPasswordSetterNilEndpoint(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestPasswordSetterEndpointPort(t *testing.T) {
+	// Test that the port of each RDS endpoint is used, overriding the port
+	// in the secret (creds), but only if RDS returns a port
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr1"), Port: aws.Int64(3307)}},
+					{Endpoint: &rds.Endpoint{Address: aws.String("addr2")}},
+				},
+			}, nil
+		},
+	}
+
+	var mux sync.Mutex
+	gotPorts := map[string][]int{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			defer mux.Unlock()
+			gotPorts[creds.Current.Hostname] = []int{creds.Current.Port, creds.New.Port}
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  mysqlClient,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	creds := db.NewPassword{
+		Current: db.Credentials{Username: "foo", Port: 3306},
+		New:     db.Credentials{Username: "foo", Port: 3306},
+	}
+	if err := ps.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string][]int{
+		"addr1": {3307, 3307},
+		"addr2": {3306, 3306},
+	}
+	if diff := deep.Equal(gotPorts, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPasswordSetterResume(t *testing.T) {
+	// Test that SetPassword after Resume skips the hosts that were already set,
+	// and Rollback rolls back those hosts, too
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceArn:        aws.String("arn1"),
+						DBInstanceIdentifier: aws.String("db-1"),
+						Endpoint: &rds.Endpoint{
+							Address: aws.String("addr1"),
+							Port:    aws.Int64(3306),
+						},
+					},
+					{
+						DBInstanceArn:        aws.String("arn2"),
+						DBInstanceIdentifier: aws.String("db-2"),
+						Endpoint: &rds.Endpoint{
+							Address: aws.String("addr2"),
+							Port:    aws.Int64(3306),
+						},
+					},
+				},
+			}, nil
+		},
+	}
+	gotSet := []string{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotSet = append(gotSet, creds.New.Password+"@"+creds.Current.Hostname)
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  mysqlClient,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	creds := db.NewPassword{
+		Current: db.Credentials{Username: "user", Password: "old_pass"},
+		New:     db.Credentials{Username: "user", Password: "new_pass"},
+	}
+
+	ps.Resume([]string{"addr1"})
+	if err := ps.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotSet, []string{"new_pass@addr2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	gotSet = []string{}
+	if err := ps.Rollback(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotSet, []string{"old_pass@addr1", "old_pass@addr2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Resume applies only to the next SetPassword
+	gotSet = []string{}
+	if err := ps.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotSet, []string{"new_pass@addr1", "new_pass@addr2"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestPasswordSetterInstanceCacheTTL(t *testing.T) {
+	// A warm Lambda function must refresh the db instances for a new rotation,
+	// and after InstanceCacheTTL for other invocations, but never during the
+	// rotation that made the list
+	nRDSCalls := 0
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			nRDSCalls++
+			dbs := []*rds.DBInstance{}
+			for i := 1; i <= nRDSCalls; i++ {
+				dbs = append(dbs, &rds.DBInstance{
+					DBInstanceIdentifier: aws.String(fmt.Sprintf("db-%d", i)),
+					Endpoint:             &rds.Endpoint{Address: aws.String(fmt.Sprintf("db-%d", i))},
+				})
+			}
+			return &rds.DescribeDBInstancesOutput{DBInstances: dbs}, nil
+		},
+	}
+	var mux sync.Mutex
+	gotHosts := []string{}
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			mux.Lock()
+			gotHosts = append(gotHosts, creds.Current.Hostname)
+			mux.Unlock()
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:        rdsClient,
+		DbClient:         mysqlClient,
+		InstanceCacheTTL: 50 * time.Millisecond,
+	})
+
+	init := func(step, token string, expect int) {
+		t.Helper()
+		if err := ps.Init(context.TODO(), map[string]string{"Step": step, "ClientRequestToken": token}); err != nil {
+			t.Fatal(err)
+		}
+		if nRDSCalls != expect {
+			t.Errorf("%s %s: RDS calls = %d, expected %d", step, token, nRDSCalls, expect)
+		}
+	}
+
+	// Rotation 1: one list for all steps, even after the TTL
+	init("createSecret", "v1", 1)
+	init("createSecret", "v1", 1) // retry
+	time.Sleep(60 * time.Millisecond)
+	init("setSecret", "v1", 1)
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(gotHosts, []string{"db-1"}); diff != nil {
+		t.Error(diff)
+	}
+	init("finishSecret", "v1", 1)
+
+	// Rotation 2: createSecret refreshes, so the new db instance is rotated
+	init("createSecret", "v2", 2)
+	gotHosts = []string{}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(gotHosts)
+	if diff := deep.Equal(gotHosts, []string{"db-1", "db-2"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Commands (no rotation) refresh only after the TTL
+	init("", "", 2)
+	time.Sleep(60 * time.Millisecond)
+	init("", "", 3)
+}
+
+func TestPasswordSetterParallelPerGroup(t *testing.T) {
+	// Cluster "a" has 4 instances and db-5 and db-6 are their own groups. With
+	// ParallelPerGroup=1, only one instance of cluster "a" is changed at once,
+	// but the other groups are changed in parallel with it.
+	group := map[string]string{}
+	instances := []*rds.DBInstance{}
+	for i := 1; i <= 6; i++ {
+		id := fmt.Sprintf("db-%d", i)
+		in := &rds.DBInstance{
+			DBInstanceIdentifier: aws.String(id),
+			Endpoint:             &rds.Endpoint{Address: aws.String(id)},
+		}
+		group[id] = id
+		if i <= 4 {
+			in.DBClusterIdentifier = aws.String("a")
+			group[id] = "a"
+		}
+		instances = append(instances, in)
+	}
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{DBInstances: instances}, nil
+		},
+	}
+
+	mux := &sync.Mutex{}
+	running := map[string]int{}
+	maxRunning := map[string]int{}
+	total, maxTotal := 0, 0
+	mysqlClient := test.MockMySQLPasswordClient{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			g := group[creds.Current.Hostname]
+			mux.Lock()
+			running[g]++
+			total++
+			if running[g] > maxRunning[g] {
+				maxRunning[g] = running[g]
+			}
+			if total > maxTotal {
+				maxTotal = total
+			}
+			mux.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mux.Lock()
+			running[g]--
+			total--
+			mux.Unlock()
+			return nil
+		},
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:        rdsClient,
+		DbClient:         mysqlClient,
+		Parallel:         4,
+		ParallelPerGroup: 1,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning["a"] != 1 {
+		t.Errorf("max running in cluster a = %d, expected 1", maxRunning["a"])
+	}
+	if maxTotal < 2 {
+		t.Errorf("max running = %d, expected other groups in parallel with cluster a", maxTotal)
+	}
+
+	// Tune overrides ParallelPerGroup: 0 is no limit
+	if err := ps.Tune(map[string]string{"parallel_per_group": "0"}); err != nil {
+		t.Fatal(err)
+	}
+	maxRunning = map[string]int{}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning["a"] < 2 {
+		t.Errorf("max running in cluster a = %d, expected > 1 without limit", maxRunning["a"])
+	}
+	if err := ps.Tune(map[string]string{"parallel_per_group": "x"}); err == nil {
+		t.Error("no error for invalid parallel_per_group")
+	}
+}
+
+func TestGroupBy(t *testing.T) {
+	tests := []struct {
+		db    *rds.DBInstance
+		group string
+		az    string
+	}{
+		{&rds.DBInstance{DBInstanceIdentifier: aws.String("db-1"), DBClusterIdentifier: aws.String("c1"), AvailabilityZone: aws.String("us-east-1a")}, "c1", "us-east-1a"},
+		{&rds.DBInstance{DBInstanceIdentifier: aws.String("db-2"), ReadReplicaSourceDBInstanceIdentifier: aws.String("db-3")}, "db-3", ""},
+		{&rds.DBInstance{DBInstanceIdentifier: aws.String("db-3")}, "db-3", ""},
+	}
+	for _, tt := range tests {
+		if g := mysql.GroupByCluster(tt.db); g != tt.group {
+			t.Errorf("%s: GroupByCluster = %q, expected %q", *tt.db.DBInstanceIdentifier, g, tt.group)
+		}
+		if g := mysql.GroupByAZ(tt.db); g != tt.az {
+			t.Errorf("%s: GroupByAZ = %q, expected %q", *tt.db.DBInstanceIdentifier, g, tt.az)
+		}
+	}
+}
+
+func TestPasswordSetterHostFaults(t *testing.T) {
+	// Test per-host fault injection: SetPassword fails once on one host, so the
+	// PasswordSetter retries only that host and the other host is set once
+	rdsClient := test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{DBInstanceIdentifier: aws.String("db-1"), Endpoint: &rds.Endpoint{Address: aws.String("host1"), Port: aws.Int64(3306)}},
+					{DBInstanceIdentifier: aws.String("db-2"), Endpoint: &rds.Endpoint{Address: aws.String("host2"), Port: aws.Int64(3306)}},
+				},
+			}, nil
+		},
+	}
+	faults := test.NewFaults()
+	faults.Set("mysql.SetPassword@host2", test.Fault{Err: errors.New("connection reset"), Times: 1})
+
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: rdsClient,
+		DbClient:  test.FaultyPasswordClient{PasswordClient: test.MockMySQLPasswordClient{}, Faults: faults},
+		Retry:     1,
+		RetryWait: 10 * time.Millisecond,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	creds := db.NewPassword{
+		Current: db.Credentials{Username: "user", Password: "old_pass"},
+		New:     db.Credentials{Username: "user", Password: "new_pass"},
+	}
+	if err := ps.SetPassword(context.TODO(), creds); err != nil {
+		t.Fatal(err)
+	}
+	if n := faults.Calls("mysql.SetPassword@host1"); n != 1 {
+		t.Errorf("host1 SetPassword called %d times, expected 1", n)
+	}
+	if n := faults.Calls("mysql.SetPassword@host2"); n != 2 {
+		t.Errorf("host2 SetPassword called %d times, expected 2 (fault, retry)", n)
+	}
+}
+
+func TestPasswordSetterAutoParallel(t *testing.T) {
+	// Test that Config.AutoParallel and the "parallel" Tune setting "auto" run
+	// the number of db instances / AUTO_PARALLEL_WAVES at once, up to MaxParallel
+	tests := []struct {
+		name     string
+		dbs      int
+		cfg      mysql.Config
+		settings map[string]string
+		expect   int
+	}{
+		{name: "auto", dbs: 25, cfg: mysql.Config{AutoParallel: true}, expect: 3},
+		{name: "small fleet", dbs: 4, cfg: mysql.Config{AutoParallel: true}, expect: 1},
+		{name: "max parallel", dbs: 100, cfg: mysql.Config{AutoParallel: true, MaxParallel: 4}, expect: 4},
+		{name: "tune auto", dbs: 25, cfg: mysql.Config{Parallel: 1}, settings: map[string]string{"parallel": "auto"}, expect: 3},
+		{name: "tune number", dbs: 25, cfg: mysql.Config{AutoParallel: true}, settings: map[string]string{"parallel": "2"}, expect: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := &sync.Mutex{}
+			running, peak := 0, 0
+			tt.cfg.RDSClient = fleet(tt.dbs)
+			tt.cfg.DbClient = test.MockMySQLPasswordClient{
+				SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+					mux.Lock()
+					running++
+					if running > peak {
+						peak = running
+					}
+					mux.Unlock()
+					time.Sleep(5 * time.Millisecond)
+					mux.Lock()
+					running--
+					mux.Unlock()
+					return nil
+				},
+			}
+			ps := mysql.NewPasswordSetter(tt.cfg)
+			if tt.settings != nil {
+				if err := ps.Tune(tt.settings); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+				t.Fatal(err)
+			}
+			if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+				t.Fatal(err)
+			}
+			if peak != tt.expect {
+				t.Errorf("got %d dbs running in parallel, expected %d", peak, tt.expect)
+			}
+		})
+	}
+}
+
+// queueObserver is a HostObserver and QueueObserver that records the peak
+// queue depth and running db instances.
+type queueObserver struct {
+	mux                                   *sync.Mutex
+	calls, running, maxQueued, maxRunning int
+}
+
+func (o *queueObserver) ObserveHost(hostname, action string, d time.Duration, err error) {}
+
+func (o *queueObserver) ObserveQueue(action string, queued, running int) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.calls++
+	o.running = running
+	if queued > o.maxQueued {
+		o.maxQueued = queued
+	}
+	if running > o.maxRunning {
+		o.maxRunning = running
+	}
+}
+
+func TestPasswordSetterQueueObserver(t *testing.T) {
+	// Test that Config.Observer that implements QueueObserver gets the queue
+	// depth when each db instance starts and finishes, and that the worker pool
+	// runs no more than Parallel at once
+	obs := &queueObserver{mux: &sync.Mutex{}}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: fleet(50),
+		DbClient:  latencyClient(time.Millisecond),
+		Parallel:  5,
+		Observer:  obs,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if obs.calls != 100 {
+		t.Errorf("got %d ObserveQueue calls, expected 100 (start and finish of 50)", obs.calls)
+	}
+	if obs.maxQueued != 49 {
+		t.Errorf("max queued %d, expected 49", obs.maxQueued)
+	}
+	if obs.maxRunning != 5 || obs.running != 0 {
+		t.Errorf("max running %d, last %d, expected 5 and 0", obs.maxRunning, obs.running)
+	}
+}
+
+func TestPasswordSetterStagger(t *testing.T) {
+	// Test that Config.Stagger spreads the starts of SetPassword over the
+	// window, but not VerifyPassword, and that the deadline limits the window
+	mux := &sync.Mutex{}
+	var starts []time.Duration
+	var t0 time.Time
+	record := func(ctx context.Context, creds db.NewPassword) error {
+		mux.Lock()
+		starts = append(starts, time.Since(t0))
+		mux.Unlock()
+		return nil
+	}
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: fleet(10),
+		DbClient:  test.MockMySQLPasswordClient{SetPasswordFunc: record, VerifyPasswordFunc: record},
+		Parallel:  10,
+		Stagger:   200 * time.Millisecond,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	t0 = time.Now()
+	if err := ps.SetPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(starts) != 10 {
+		t.Fatalf("got %d starts, expected 10", len(starts))
+	}
+	// Each db instance starts in its 20ms share of the 200ms window
+	if starts[0] > 40*time.Millisecond || starts[9] < 180*time.Millisecond {
+		t.Errorf("first start at %s, last at %s, expected <40ms and >=180ms", starts[0], starts[9])
+	}
+
+	starts = nil
+	t0 = time.Now()
+	if err := ps.VerifyPassword(context.TODO(), db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if last := starts[len(starts)-1]; last > 100*time.Millisecond {
+		t.Errorf("VerifyPassword last start at %s, expected no stagger", last)
+	}
+
+	// Tune overrides Stagger, and the deadline limits it to half the time left
+	if err := ps.Tune(map[string]string{"stagger": "10s"}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	starts = nil
+	t0 = time.Now()
+	if err := ps.SetPassword(ctx, db.NewPassword{}); err != nil {
+		t.Fatal(err)
+	}
+	if last := starts[len(starts)-1]; last > 150*time.Millisecond {
+		t.Errorf("last start at %s, expected <150ms (window of 100ms)", last)
+	}
+
+	if err := ps.Tune(map[string]string{"stagger": "-1s"}); err == nil {
+		t.Error("no error for negative stagger setting")
+	}
+}
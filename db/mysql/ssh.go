@@ -0,0 +1,186 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"golang.org/x/crypto/ssh"
+)
+
+// DEFAULT_SSH_NETWORK is the network name under which RDSClient registers the
+// SSHTunnel dial func with the MySQL driver if RDSClientOptions.SSHNetwork is empty.
+const DEFAULT_SSH_NETWORK = "ssh"
+
+// SSH_TIMEOUT is how long connecting to the SSH jump host can take.
+const SSH_TIMEOUT = 10 * time.Second
+
+// Keys of the SSH secret read by NewSSHTunnelFromSecret.
+const (
+	SSH_SECRET_KEY_HOST        = "host"        // jump host address
+	SSH_SECRET_KEY_PORT        = "port"        // jump host port; 22 if not set
+	SSH_SECRET_KEY_USERNAME    = "username"    // SSH user
+	SSH_SECRET_KEY_PRIVATE_KEY = "private_key" // PEM-encoded private key
+	SSH_SECRET_KEY_PASSPHRASE  = "passphrase"  // private key passphrase, if encrypted
+	SSH_SECRET_KEY_HOST_KEY    = "host_key"    // jump host public key, authorized_keys format
+)
+
+// SSHTunnel dials database connections through an SSH jump host (bastion), for
+// databases that are not reachable directly from the Lambda. Set it in
+// RDSClientOptions.SSHTunnel. One SSH connection to the jump host is made on
+// the first dial and reused for all database connections; it is made again
+// if it fails.
+//
+// Create an SSHTunnel by calling NewSSHTunnel or NewSSHTunnelFromSecret. It is
+// safe for concurrent use by multiple goroutines.
+type SSHTunnel struct {
+	addr   string
+	config *ssh.ClientConfig
+	// --
+	mux    *sync.Mutex
+	client *ssh.Client
+}
+
+// NewSSHTunnel creates a new SSHTunnel through the jump host at addr (host:port).
+// The config must set User, Auth, and HostKeyCallback. Do not use
+// ssh.InsecureIgnoreHostKey: the jump host sees the database passwords.
+func NewSSHTunnel(addr string, config *ssh.ClientConfig) *SSHTunnel {
+	return &SSHTunnel{
+		addr:   addr,
+		config: config,
+		mux:    &sync.Mutex{},
+	}
+}
+
+// NewSSHTunnelFromSecret creates a new SSHTunnel from the current version of
+// a secret with the SSH_SECRET_KEY_ values. The host key is required, so the
+// jump host is verified.
+func NewSSHTunnelFromSecret(sm secretsmanageriface.SecretsManagerAPI, secretId string) (*SSHTunnel, error) {
+	out, err := sm.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(secretId),
+		VersionStage: aws.String("AWSCURRENT"),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot get SSH secret %s: %s", secretId, err)
+	}
+	var v map[string]string
+	if err := json.Unmarshal([]byte(aws.StringValue(out.SecretString)), &v); err != nil {
+		return nil, fmt.Errorf("cannot decode SSH secret %s: %s", secretId, err)
+	}
+	for _, key := range []string{SSH_SECRET_KEY_HOST, SSH_SECRET_KEY_USERNAME, SSH_SECRET_KEY_PRIVATE_KEY, SSH_SECRET_KEY_HOST_KEY} {
+		if v[key] == "" {
+			return nil, fmt.Errorf("SSH secret %s: %s not set", secretId, key)
+		}
+	}
+
+	var signer ssh.Signer
+	if v[SSH_SECRET_KEY_PASSPHRASE] != "" {
+		signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(v[SSH_SECRET_KEY_PRIVATE_KEY]), []byte(v[SSH_SECRET_KEY_PASSPHRASE]))
+	} else {
+		signer, err = ssh.ParsePrivateKey([]byte(v[SSH_SECRET_KEY_PRIVATE_KEY]))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("SSH secret %s: invalid %s: %s", secretId, SSH_SECRET_KEY_PRIVATE_KEY, err)
+	}
+	hostKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(v[SSH_SECRET_KEY_HOST_KEY]))
+	if err != nil {
+		return nil, fmt.Errorf("SSH secret %s: invalid %s: %s", secretId, SSH_SECRET_KEY_HOST_KEY, err)
+	}
+
+	port := v[SSH_SECRET_KEY_PORT]
+	if port == "" {
+		port = "22"
+	}
+	config := &ssh.ClientConfig{
+		User:            v[SSH_SECRET_KEY_USERNAME],
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.FixedHostKey(hostKey),
+		Timeout:         SSH_TIMEOUT,
+	}
+	return NewSSHTunnel(net.JoinHostPort(v[SSH_SECRET_KEY_HOST], port), config), nil
+}
+
+// DialContext dials addr (host:port) from the jump host. It is the dial func
+// registered with the MySQL driver.
+func (t *SSHTunnel) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	client, err := t.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := client.DialContext(ctx, "tcp", addr)
+	if err == nil {
+		return conn, nil
+	}
+	if ctx.Err() != nil {
+		return nil, err
+	}
+
+	// The SSH connection might have been closed by the jump host, so reconnect
+	// and try once more
+	log.Printf("SSH tunnel: cannot dial %s, reconnecting to %s: %s", addr, t.addr, err)
+	t.reset(client)
+	if client, err = t.connect(ctx); err != nil {
+		return nil, err
+	}
+	return client.DialContext(ctx, "tcp", addr)
+}
+
+// Close closes the SSH connection to the jump host, if connected.
+func (t *SSHTunnel) Close() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+	return err
+}
+
+// connect returns the SSH client, connecting to the jump host if needed.
+func (t *SSHTunnel) connect(ctx context.Context) (*ssh.Client, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.client != nil {
+		return t.client, nil
+	}
+	t0 := time.Now()
+	timeout := t.config.Timeout
+	if timeout == 0 {
+		timeout = SSH_TIMEOUT
+	}
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return nil, fmt.Errorf("SSH tunnel: %s", err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout)) // for the SSH handshake
+	c, chans, reqs, err := ssh.NewClientConn(conn, t.addr, t.config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SSH tunnel: %s", err)
+	}
+	conn.SetDeadline(time.Time{})
+	t.client = ssh.NewClient(c, chans, reqs)
+	log.Printf("SSH tunnel: connected to %s as %s: %dms", t.addr, t.config.User, time.Now().Sub(t0).Milliseconds())
+	return t.client, nil
+}
+
+// reset closes the client if it is still the current client.
+func (t *SSHTunnel) reset(client *ssh.Client) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	if t.client == client {
+		t.client.Close()
+		t.client = nil
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2026, Square, Inc.
+
+package mysql_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+// sshServer starts an SSH server on localhost that accepts clientKey and
+// forwards direct-tcpip channels. It returns the server address.
+func sshServer(t *testing.T, hostKey ssh.Signer, clientKey ssh.PublicKey) string {
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(clientKey.Marshal()) {
+				return nil, io.EOF
+			}
+			return nil, nil
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nc := range chans {
+					if nc.ChannelType() != "direct-tcpip" {
+						nc.Reject(ssh.UnknownChannelType, "")
+						continue
+					}
+					var msg struct {
+						Host     string
+						Port     uint32
+						OrigHost string
+						OrigPort uint32
+					}
+					ssh.Unmarshal(nc.ExtraData(), &msg)
+					dst, err := net.Dial("tcp", net.JoinHostPort(msg.Host, strconv.Itoa(int(msg.Port))))
+					if err != nil {
+						nc.Reject(ssh.ConnectionFailed, err.Error())
+						continue
+					}
+					ch, chReqs, err := nc.Accept()
+					if err != nil {
+						dst.Close()
+						continue
+					}
+					go ssh.DiscardRequests(chReqs)
+					go func() {
+						io.Copy(ch, dst)
+						ch.Close()
+					}()
+					go func() {
+						io.Copy(dst, ch)
+						dst.Close()
+					}()
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func sshSecret(t *testing.T, v map[string]string) test.MockSecretsManager {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(string(bytes))}, nil
+		},
+	}
+}
+
+func TestSSHTunnel(t *testing.T) {
+	// Keys for the jump host and the client
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, clientPriv, _ := ed25519.GenerateKey(rand.Reader)
+	clientSigner, err := ssh.NewSignerFromKey(clientPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// "Database" behind the jump host that echoes one line
+	db, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	go func() {
+		for {
+			conn, err := db.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(sshServer(t, hostSigner, clientSigner.PublicKey()))
+	secret := map[string]string{
+		mysql.SSH_SECRET_KEY_HOST:        host,
+		mysql.SSH_SECRET_KEY_PORT:        port,
+		mysql.SSH_SECRET_KEY_USERNAME:    "rotate",
+		mysql.SSH_SECRET_KEY_PRIVATE_KEY: string(pem.EncodeToMemory(block)),
+		mysql.SSH_SECRET_KEY_HOST_KEY:    string(ssh.MarshalAuthorizedKey(hostSigner.PublicKey())),
+	}
+	tunnel, err := mysql.NewSSHTunnelFromSecret(sshSecret(t, secret), "ssh-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Close()
+
+	// Dial twice to reuse the SSH connection
+	for i := 0; i < 2; i++ {
+		conn, err := tunnel.DialContext(context.TODO(), db.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Write([]byte("hello\n")); err != nil {
+			t.Fatal(err)
+		}
+		buf := make([]byte, 6)
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+		if string(buf) != "hello\n" {
+			t.Errorf("read %q, expected %q", buf, "hello\n")
+		}
+	}
+
+	// Wrong host key: the jump host must be rejected
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+	otherSigner, _ := ssh.NewSignerFromKey(otherPriv)
+	secret[mysql.SSH_SECRET_KEY_HOST_KEY] = string(ssh.MarshalAuthorizedKey(otherSigner.PublicKey()))
+	tunnel, err = mysql.NewSSHTunnelFromSecret(sshSecret(t, secret), "ssh-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tunnel.Close()
+	if _, err := tunnel.DialContext(context.TODO(), db.Addr().String()); err == nil {
+		t.Error("no error dialing through jump host with wrong host key")
+	}
+
+	// Host key is required
+	delete(secret, mysql.SSH_SECRET_KEY_HOST_KEY)
+	_, err = mysql.NewSSHTunnelFromSecret(sshSecret(t, secret), "ssh-secret")
+	if err == nil {
+		t.Error("no error without host key")
+	} else if !strings.Contains(err.Error(), mysql.SSH_SECRET_KEY_HOST_KEY) {
+		t.Errorf("error does not mention %s: %s", mysql.SSH_SECRET_KEY_HOST_KEY, err)
+	}
+}
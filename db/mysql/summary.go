@@ -0,0 +1,95 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// SUMMARY_LOG_HOSTS is the number of db instances per summary line if
+// Config.SummaryLog is true.
+const SUMMARY_LOG_HOSTS = 100
+
+// HostSummary is the result of one action on one db instance. If Config.SummaryLog
+// is true, the failed db instances are logged as JSON HostSummary records.
+type HostSummary struct {
+	Hostname string `json:"hostname"`
+	Result   string `json:"result"`          // "ok", "failed", "resumed" (set before), "skipped" (not set, so no rollback), or "replicated" (reader)
+	Tries    uint   `json:"tries,omitempty"` // tries of the last account, if the action was made
+	Ms       int64  `json:"ms,omitempty"`    // duration of the action, if made
+	Error    string `json:"error,omitempty"` // if failed
+}
+
+// summaryLine is one line of the summary log: the hostnames of a batch of
+// db instances by result.
+type summaryLine struct {
+	Action string              `json:"action"`
+	Batch  int                 `json:"batch"`
+	Of     int                 `json:"of"`
+	Counts map[string]int      `json:"counts"`
+	Hosts  map[string][]string `json:"hosts"`
+}
+
+// failureLine is the last line of the summary log, if any db instance failed.
+type failureLine struct {
+	Action   string        `json:"action"`
+	Failed   int           `json:"failed"`
+	Failures []HostSummary `json:"failures"`
+}
+
+// logSummary logs the results of an action on all db instances in batches of
+// SUMMARY_LOG_HOSTS, then the failures, if any. Results of db instances not
+// done, which happens only if the context was cancelled, are not logged.
+func logSummary(action string, results []HostSummary) {
+	done := make([]HostSummary, 0, len(results))
+	for _, r := range results {
+		if r.Result != "" {
+			done = append(done, r)
+		}
+	}
+	of := (len(done) + SUMMARY_LOG_HOSTS - 1) / SUMMARY_LOG_HOSTS
+	var failures []HostSummary
+	for b := 0; b < of; b++ {
+		line := summaryLine{
+			Action: action,
+			Batch:  b + 1,
+			Of:     of,
+			Counts: map[string]int{},
+			Hosts:  map[string][]string{},
+		}
+		end := (b + 1) * SUMMARY_LOG_HOSTS
+		if end > len(done) {
+			end = len(done)
+		}
+		for _, r := range done[b*SUMMARY_LOG_HOSTS : end] {
+			line.Counts[r.Result]++
+			line.Hosts[r.Result] = append(line.Hosts[r.Result], r.Hostname)
+			if r.Result == "failed" {
+				failures = append(failures, r)
+			}
+		}
+		logJSON("summary", line)
+	}
+	if len(failures) > 0 {
+		logJSON("ERROR: failures", failureLine{Action: action, Failed: len(failures), Failures: failures})
+	}
+}
+
+func logJSON(prefix string, v interface{}) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("%s: cannot encode JSON: %s", prefix, err)
+		return
+	}
+	log.Output(3, prefix+": "+string(bytes))
+}
+
+// hostLog logs a line about one db instance, unless Config.SummaryLog is true.
+func (m *PasswordSetter) hostLog(format string, v ...interface{}) {
+	if m.cfg.SummaryLog {
+		return
+	}
+	log.Output(2, fmt.Sprintf(format, v...))
+}
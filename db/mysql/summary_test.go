@@ -0,0 +1,90 @@
+// Copyright 2026, Square, Inc.
+
+package mysql_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestPasswordSetterSummaryLog(t *testing.T) {
+	// Test that Config.SummaryLog logs one line per SUMMARY_LOG_HOSTS db
+	// instances and one line of failures instead of lines per db instance
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: fleet(250),
+		DbClient: test.MockMySQLPasswordClient{
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				if creds.New.Hostname == "db-7.rds" {
+					return errors.New("access denied")
+				}
+				return nil
+			},
+		},
+		Parallel:         10,
+		FailureThreshold: "1",
+		SummaryLog:       true,
+	})
+	if err := ps.Init(context.TODO(), map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	err := ps.SetPassword(context.TODO(), db.NewPassword{})
+	log.SetOutput(os.Stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var summary, failures []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, "db-") && !strings.Contains(line, "summary: ") && !strings.Contains(line, "failures: ") {
+			t.Errorf("got line per db instance: %s", line)
+		}
+		if i := strings.Index(line, "summary: "); i >= 0 {
+			summary = append(summary, line[i+len("summary: "):])
+		}
+		if i := strings.Index(line, "ERROR: failures: "); i >= 0 {
+			failures = append(failures, line[i+len("ERROR: failures: "):])
+		}
+	}
+	if len(summary) != 3 {
+		t.Fatalf("got %d summary lines, expected 3 (250 db instances, 100 per line):\n%s", len(summary), buf.String())
+	}
+	ok := 0
+	for _, s := range summary {
+		var line struct {
+			Counts map[string]int      `json:"counts"`
+			Hosts  map[string][]string `json:"hosts"`
+		}
+		if err := json.Unmarshal([]byte(s), &line); err != nil {
+			t.Fatalf("%s: %s", s, err)
+		}
+		ok += line.Counts["ok"]
+	}
+	if ok != 249 {
+		t.Errorf("got %d ok db instances, expected 249", ok)
+	}
+	if len(failures) != 1 {
+		t.Fatalf("got %d failure lines, expected 1:\n%s", len(failures), buf.String())
+	}
+	var got struct {
+		Failures []mysql.HostSummary `json:"failures"`
+	}
+	if err := json.Unmarshal([]byte(failures[0]), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Failures) != 1 || got.Failures[0].Hostname != "db-7.rds" || got.Failures[0].Error != "access denied" {
+		t.Errorf("got failures %+v, expected db-7.rds: access denied", got.Failures)
+	}
+}
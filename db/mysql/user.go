@@ -0,0 +1,99 @@
+// Copyright 2026, Square, Inc.
+
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// validGrants returns an error if a grant in RDSClientOptions.Grants is empty
+// or not a single statement.
+func validGrants(grants []string) error {
+	for _, g := range grants {
+		if strings.TrimSpace(g) == "" || strings.Contains(g, ";") {
+			return fmt.Errorf("invalid grant %q: must be privileges or a role, without ;", g)
+		}
+	}
+	return nil
+}
+
+// userExists returns true if the account (username and host query parameters,
+// from account) exists.
+func (c *RDSClient) userExists(ctx context.Context, db *sql.DB, account []interface{}) (bool, error) {
+	sctx, cancel := c.statementContext(ctx)
+	defer cancel()
+	var n int
+	err := db.QueryRowContext(sctx, "SELECT COUNT(*) FROM mysql.user WHERE User = ? AND Host = ?", account...).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("cannot check if user exists: %s", err)
+	}
+	return n > 0, nil
+}
+
+// createUser executes the CREATE USER statement with the account and secret
+// query parameters, then grants RDSClientOptions.Grants to the account. If
+// a grant fails, the user is dropped so that the next try creates it again
+// with all grants, instead of setting the password of a user missing grants.
+func (c *RDSClient) createUser(ctx context.Context, exec execFunc, creds db.Credentials, create string, account []interface{}, secret string) error {
+	sctx, cancel := c.statementContext(ctx)
+	_, err := exec(sctx, create, append(account, secret)...)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("cannot create user: %s", err)
+	}
+	if err := c.grant(ctx, exec, account); err != nil {
+		sctx, cancel := c.statementContext(ctx)
+		defer cancel()
+		if _, dropErr := exec(sctx, "DROP USER ?@?", account...); dropErr != nil {
+			log.Printf("%s: ERROR: cannot drop user %s after failed grant: %s", creds.Hostname, c.displayAccount(account), dropErr)
+		}
+		return err
+	}
+	log.Printf("%s: created user %s with %d grants", creds.Hostname, c.displayAccount(account), len(c.opts.Grants))
+	return nil
+}
+
+// grant executes "GRANT grant TO ?@?" for each of RDSClientOptions.Grants.
+// GRANT is idempotent, so it is safe to repeat.
+func (c *RDSClient) grant(ctx context.Context, exec execFunc, account []interface{}) error {
+	for _, g := range c.opts.Grants {
+		sctx, cancel := c.statementContext(ctx)
+		_, err := exec(sctx, "GRANT "+g+" TO ?@?", account...)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("cannot grant %s: %s", g, err)
+		}
+	}
+	return nil
+}
+
+// logGrants logs the grants of the account (SHOW GRANTS) for auditing, so
+// privileges granted outside RDSClientOptions.Grants (drift) are visible.
+// Errors are logged, not returned, because the grants are already set.
+func (c *RDSClient) logGrants(ctx context.Context, db *sql.DB, creds db.Credentials, account []interface{}) {
+	sctx, cancel := c.statementContext(ctx)
+	defer cancel()
+	rows, err := db.QueryContext(sctx, "SHOW GRANTS FOR ?@?", account...)
+	if err != nil {
+		log.Printf("%s: ERROR: cannot show grants of %s: %s (ignored)", creds.Hostname, c.displayAccount(account), err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var g string
+		if err := rows.Scan(&g); err != nil {
+			log.Printf("%s: ERROR: cannot show grants of %s: %s (ignored)", creds.Hostname, c.displayAccount(account), err)
+			return
+		}
+		log.Printf("%s: grants of %s: %s", creds.Hostname, c.displayAccount(account), g)
+	}
+}
+
+// execFunc is the ExecContext method of a sql.DB or sql.Conn.
+type execFunc func(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
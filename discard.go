@@ -0,0 +1,118 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// discardCommand runs COMMAND_DISCARD_OLD_PASSWORD. It returns whether the old
+// password was discarded and, if not yet, when it will be.
+func (r *Rotator) discardCommand(ctx context.Context) (map[string]string, error) {
+	if !r.discardOld {
+		return nil, fmt.Errorf("command %s: Config.DiscardOldPassword is false", COMMAND_DISCARD_OLD_PASSWORD)
+	}
+	out, err := r.sm.DescribeSecret(&secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(r.secretId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]string{
+		"SecretId":  r.secretId,
+		"Discarded": "false",
+	}
+
+	// Do not discard during a rotation: after setSecret, the old password is
+	// the current secret that services still use
+	for _, stages := range out.VersionIdsToStages {
+		pending, current := false, false
+		for _, stage := range stages {
+			pending = pending || aws.StringValue(stage) == AWSPENDING
+			current = current || aws.StringValue(stage) == AWSCURRENT
+		}
+		if pending && !current {
+			log.Println("rotation in progress, not discarding old password")
+			res["Reason"] = "rotation in progress"
+			return res, nil
+		}
+	}
+
+	if out.LastRotatedDate == nil {
+		log.Println("secret never rotated, not discarding old password")
+		res["Reason"] = "never rotated"
+		return res, nil
+	}
+	age := time.Now().Sub(*out.LastRotatedDate)
+	if age < r.discardGrace {
+		after := out.LastRotatedDate.Add(r.discardGrace)
+		log.Printf("grace period has not passed, not discarding old password until %s", after.UTC().Format(time.RFC3339))
+		res["Reason"] = "grace period"
+		res["DiscardAfter"] = after.UTC().Format(time.RFC3339)
+		return res, nil
+	}
+
+	// The last rotation changed the password from the previous to the current
+	// secret, so those are the creds, like in finishSecret
+	_, prevVals, err := r.getSecret(AWSPREVIOUS)
+	if err != nil {
+		return nil, err
+	}
+	curSec, curVals, err := r.getSecret(AWSCURRENT)
+	if err != nil {
+		return nil, err
+	}
+	r.event.versionId = *curSec.VersionId
+	if err := r.ss.Init(ctx, map[string]string{"SecretId": r.secretId}); err != nil {
+		return nil, err
+	}
+	if err := r.db.Init(ctx, map[string]string{"SecretId": r.secretId}); err != nil {
+		return nil, err
+	}
+	r.admin = nil
+	if err := r.loadAdmin(); err != nil {
+		return nil, err
+	}
+	if err := r.discardOldPassword(ctx, r.dbCreds(prevVals, curVals), "", *out.LastRotatedDate); err != nil {
+		return nil, err
+	}
+	res["Discarded"] = strconv.FormatBool(!r.skipDatabase())
+	return res, nil
+}
+
+// discardOldPassword discards the old password on the databases and sends
+// EVENT_OLD_PASSWORD_DISCARDED. rotated is when the new secret was made current,
+// or zero if unknown. It's called by FinishSecret if there's no grace period,
+// else by discardCommand.
+func (r *Rotator) discardOldPassword(ctx context.Context, creds db.NewPassword, step string, rotated time.Time) error {
+	if r.skipDatabase() {
+		log.Println("database skipped, not discarding old password")
+		return nil
+	}
+	d, ok := r.db.(db.OldPasswordDiscarder)
+	if !ok {
+		return fmt.Errorf("PasswordSetter %T does not implement db.OldPasswordDiscarder", r.db)
+	}
+	if err := d.DiscardOldPassword(ctx, creds); err != nil {
+		return err
+	}
+	e := Event{
+		Name: EVENT_OLD_PASSWORD_DISCARDED,
+		Step: step,
+		Time: time.Now(),
+	}
+	if !rotated.IsZero() {
+		e.Duration = e.Time.Sub(rotated)
+	}
+	r.event.Receive(e)
+	return nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type discardPasswordSetter struct {
+	test.MockPasswordSetter
+	discarded *[]db.NewPassword
+}
+
+func (m discardPasswordSetter) DiscardOldPassword(ctx context.Context, creds db.NewPassword) error {
+	*m.discarded = append(*m.discarded, creds)
+	return nil
+}
+
+func discardSecretsManager(lastRotated time.Time, stages map[string][]*string) test.MockSecretsManager {
+	return test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSPREVIOUS:
+				return &secretsmanager.GetSecretValueOutput{SecretString: &secretString1, VersionId: aws.String("v1")}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{SecretString: &secretString2, VersionId: aws.String("v2")}, nil
+			}
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{
+				LastRotatedDate:    aws.Time(lastRotated),
+				VersionIdsToStages: stages,
+			}, nil
+		},
+	}
+}
+
+func TestCommandDiscardOldPassword(t *testing.T) {
+	var discarded []db.NewPassword
+	var events []rotate.Event
+	lastRotated := time.Now().Add(-2 * time.Hour)
+	rotated := map[string][]*string{
+		"v1": {aws.String(rotate.AWSPREVIOUS)},
+		"v2": {aws.String(rotate.AWSCURRENT)},
+	}
+	newRotator := func(grace time.Duration, stages map[string][]*string) *rotate.Rotator {
+		cfg := rotate.Config{
+			SecretsManager:     discardSecretsManager(lastRotated, stages),
+			PasswordSetter:     discardPasswordSetter{discarded: &discarded},
+			EventReceiver:      eventRecorder(func(e rotate.Event) { events = append(events, e) }),
+			UserCommands:       true,
+			DiscardOldPassword: true,
+			DiscardGracePeriod: grace,
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		return rotate.NewRotator(cfg)
+	}
+	event := map[string]string{"command": rotate.COMMAND_DISCARD_OLD_PASSWORD, "SecretId": "def"}
+
+	// Grace period not passed: not discarded
+	got, err := newRotator(3*time.Hour, rotated).Handler(context.TODO(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"SecretId":     "def",
+		"Discarded":    "false",
+		"Reason":       "grace period",
+		"DiscardAfter": lastRotated.Add(3 * time.Hour).UTC().Format(time.RFC3339),
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Rotation in progress: not discarded
+	got, err = newRotator(time.Hour, map[string][]*string{
+		"v1": {aws.String(rotate.AWSCURRENT)},
+		"v2": {aws.String(rotate.AWSPENDING)},
+	}).Handler(context.TODO(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["Discarded"] != "false" || got["Reason"] != "rotation in progress" {
+		t.Errorf("got %v, expected not discarded because rotation in progress", got)
+	}
+	if len(discarded) != 0 {
+		t.Fatalf("discarded %d times, expected 0", len(discarded))
+	}
+
+	// Grace period passed: discarded for the current (new) password
+	got, err = newRotator(time.Hour, rotated).Handler(context.TODO(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["Discarded"] != "true" {
+		t.Errorf("got %v, expected discarded", got)
+	}
+	if len(discarded) != 1 {
+		t.Fatalf("discarded %d times, expected 1", len(discarded))
+	}
+	if discarded[0].New.Password != "p2" || discarded[0].Current.Password != "p1" {
+		t.Errorf("discarded with creds %+v, expected new password p2", discarded[0])
+	}
+	if len(events) != 1 || events[0].Name != rotate.EVENT_OLD_PASSWORD_DISCARDED || events[0].VersionId != "v2" {
+		t.Fatalf("got events %+v, expected %s for v2", events, rotate.EVENT_OLD_PASSWORD_DISCARDED)
+	}
+	if events[0].Duration < 2*time.Hour {
+		t.Errorf("got event duration %s, expected at least 2h", events[0].Duration)
+	}
+}
+
+func TestFinishSecretDiscardOldPassword(t *testing.T) {
+	var discarded []db.NewPassword
+	var events []string
+	sm := discardSecretsManager(time.Now(), map[string][]*string{
+		"v1": {aws.String(rotate.AWSCURRENT)},
+		"v2": {aws.String(rotate.AWSPENDING)},
+	})
+	sm.GetSecretValueFunc = func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+		switch *input.VersionStage {
+		case rotate.AWSCURRENT:
+			return &secretsmanager.GetSecretValueOutput{SecretString: &secretString1, VersionId: aws.String("v1")}, nil
+		default:
+			return &secretsmanager.GetSecretValueOutput{SecretString: &secretString2, VersionId: aws.String("v2")}, nil
+		}
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager:     sm,
+		PasswordSetter:     discardPasswordSetter{discarded: &discarded},
+		EventReceiver:      eventRecorder(func(e rotate.Event) { events = append(events, e.Name) }),
+		DiscardOldPassword: true,
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(discarded) != 1 || discarded[0].New.Password != "p2" {
+		t.Fatalf("discarded %+v, expected once with new password p2", discarded)
+	}
+	if !hasEvent(events, rotate.EVENT_OLD_PASSWORD_DISCARDED) {
+		t.Errorf("no %s event", rotate.EVENT_OLD_PASSWORD_DISCARDED)
+	}
+}
+
+func TestDiscardOldPasswordValidate(t *testing.T) {
+	cfg := rotate.Config{
+		SecretsManager:     test.MockSecretsManager{},
+		PasswordSetter:     test.MockPasswordSetter{},
+		DiscardOldPassword: true,
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("no error, expected error for PasswordSetter without DiscardOldPassword")
+	}
+	cfg.PasswordSetter = discardPasswordSetter{discarded: &[]db.NewPassword{}}
+	cfg.DiscardGracePeriod = time.Hour
+	if err := cfg.Validate(); err == nil {
+		t.Error("no error, expected error for DiscardGracePeriod without UserCommands")
+	}
+	cfg.UserCommands = true
+	if err := cfg.Validate(); err != nil {
+		t.Error(err)
+	}
+}
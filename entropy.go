@@ -0,0 +1,101 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	crand "crypto/rand"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+)
+
+// DEFAULT_FIPS_ENTROPY_BITS is the minimum password entropy in FIPS mode if
+// RandomPassword.MinEntropyBits is zero. 112 bits is the minimum security strength
+// allowed by NIST SP 800-131A.
+const DEFAULT_FIPS_ENTROPY_BITS = 112
+
+// intnFunc returns a uniform random int in [0, n).
+type intnFunc func(n int) (int, error)
+
+// readerIntn returns an intnFunc that reads random bytes from r, like crypto/rand.Reader.
+func readerIntn(r io.Reader) intnFunc {
+	return func(n int) (int, error) {
+		v, err := crand.Int(r, big.NewInt(int64(n)))
+		if err != nil {
+			return 0, fmt.Errorf("error reading entropy source: %s", err)
+		}
+		return int(v.Int64()), nil
+	}
+}
+
+// entropyBits returns the entropy of a password generated by PasswordPolicy.generate:
+// the minimum number of characters from each class are picked from only that class,
+// and the rest from the whole charset. The shuffle and prefix and suffix are not
+// counted, so it's a lower bound.
+func entropyBits(classes map[int][]rune, mins map[int]int, charset []rune, length int) float64 {
+	bits := 0.0
+	rest := length
+	for class, n := range mins {
+		bits += float64(n) * math.Log2(float64(len(classes[class])))
+		rest -= n
+	}
+	bits += float64(rest) * math.Log2(float64(len(charset)))
+	return bits
+}
+
+// KMS_RANDOM_BYTES is the number of random bytes KMSRandom gets per call to
+// kms:GenerateRandom. 1024 is the maximum.
+const KMS_RANDOM_BYTES = 1024
+
+// KMSRandom is an entropy source (io.Reader) backed by kms:GenerateRandom.
+// Set it as RandomPassword.Entropy to generate passwords with randomness from
+// AWS KMS HSMs. Random bytes are buffered, so one password usually requires only
+// one call to KMS. It is safe for concurrent use by multiple goroutines.
+type KMSRandom struct {
+	kms kmsiface.KMSAPI
+	// --
+	mux *sync.Mutex
+	buf []byte
+}
+
+var _ io.Reader = &KMSRandom{}
+
+// NewKMSRandom creates a new KMSRandom that uses the KMS client. Create one by
+// calling kms.New() using package github.com/aws/aws-sdk-go/service/kms.
+func NewKMSRandom(kmsClient kmsiface.KMSAPI) *KMSRandom {
+	return &KMSRandom{
+		kms: kmsClient,
+		mux: &sync.Mutex{},
+	}
+}
+
+// Read reads len(p) random bytes from KMS.
+func (r *KMSRandom) Read(p []byte) (int, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			out, err := r.kms.GenerateRandom(&kms.GenerateRandomInput{
+				NumberOfBytes: aws.Int64(KMS_RANDOM_BYTES),
+			})
+			if err != nil {
+				return n, err
+			}
+			if len(out.Plaintext) == 0 {
+				return n, fmt.Errorf("kms:GenerateRandom returned no bytes")
+			}
+			r.buf = out.Plaintext
+		}
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+	return n, nil
+}
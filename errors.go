@@ -0,0 +1,78 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Rotation failure classes. Handler returns a *RotationError for these failures,
+// so callers and EventReceivers (see Event.Error) can branch on the class with
+// errors.Is, like errors.Is(err, rotate.ErrRollbackFailed), and get the step and
+// cause with errors.As.
+var (
+	// ErrPendingConflict is returned by createSecret if another pending secret
+	// exists: another process is rotating the secret, or a previous rotation
+	// failed without cleaning up (see COMMAND_ABORT). It is returned by the other
+	// steps if the pending secret is not the version of the rotation, because
+	// a concurrent rotation took over; the databases are not changed.
+	ErrPendingConflict = errors.New("another pending secret exists")
+
+	// ErrSetPasswordFailed is returned by setSecret if setting the new password
+	// failed and the password was rolled back.
+	ErrSetPasswordFailed = errors.New("setting new password failed, rolled back")
+
+	// ErrVerifyFailed is returned by setSecret or testSecret if the current or
+	// new password does not work on the databases and the password was rolled back.
+	ErrVerifyFailed = errors.New("password verification failed, rolled back")
+
+	// ErrCanaryFailed is returned by testSecret if the Config.Canary check failed
+	// and the password was rolled back.
+	ErrCanaryFailed = errors.New("canary check failed, rolled back")
+
+	// ErrRollbackFailed is returned by setSecret or testSecret if the rollback
+	// failed. This is the most severe failure: databases might have different
+	// passwords, and the pending secret might be the only copy of the new password.
+	ErrRollbackFailed = errors.New("rollback failed")
+
+	// ErrReplicationTimeout is returned by finishSecret if the secret was not
+	// replicated to all regions within Config.ReplicationWait. The new secret
+	// is current in the primary region.
+	ErrReplicationTimeout = errors.New("timeout waiting for secret replication")
+
+	// ErrRotationVetoed is returned by createSecret or setSecret if a
+	// VetoEventReceiver vetoed the rotation.
+	ErrRotationVetoed = errors.New("rotation vetoed by event receiver")
+
+	// ErrInterrupted is returned by setSecret if the Lambda context was cancelled
+	// while setting the new password, so there was no time to roll back. If
+	// Config.StateStore is set, the hosts that were changed are saved, and the
+	// retry resumes setting the new password. See RotationState.Interrupted.
+	ErrInterrupted = errors.New("interrupted while setting new password")
+)
+
+// RotationError is a rotation failure. Kind is one of the failure classes above,
+// like ErrSetPasswordFailed, and Err is the cause, which can be nil.
+type RotationError struct {
+	Step string // "createSecret", "setSecret", "testSecret", or "finishSecret"
+	Kind error
+	Err  error
+}
+
+func (e *RotationError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s: %s", e.Step, e.Kind)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Step, e.Kind, e.Err)
+}
+
+// Unwrap returns the cause.
+func (e *RotationError) Unwrap() error {
+	return e.Err
+}
+
+// Is returns true if target is the failure class (Kind).
+func (e *RotationError) Is(target error) bool {
+	return target == e.Kind
+}
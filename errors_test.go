@@ -0,0 +1,93 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestRotationErrors(t *testing.T) {
+	// Test that step failures return a RotationError with the failure class
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString:  &secretString1,
+					VersionId:     aws.String("v1"),
+					VersionStages: []*string{aws.String(rotate.AWSCURRENT)},
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString:  &secretString2,
+					VersionId:     aws.String("v2"),
+					VersionStages: []*string{aws.String(rotate.AWSPENDING)},
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+	}
+	verifyErr := fmt.Errorf("access denied")
+	var rollbackErr error
+	ps := test.MockPasswordSetter{
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			return verifyErr
+		},
+		RollbackFunc: func(ctx context.Context, creds db.NewPassword) error {
+			return rollbackErr
+		},
+	}
+	handle := func(step, token string) error {
+		r := rotate.NewRotator(rotate.Config{
+			SecretsManager: sm,
+			PasswordSetter: ps,
+		})
+		_, err := r.Handler(context.TODO(), map[string]string{
+			"ClientRequestToken": token,
+			"SecretId":           "def",
+			"Step":               step,
+		})
+		return err
+	}
+
+	// Pending secret v2 is not ours (v3)
+	err := handle("createSecret", "v3")
+	if !errors.Is(err, rotate.ErrPendingConflict) {
+		t.Errorf("got error %v, expected ErrPendingConflict", err)
+	}
+
+	// New password does not work, rolled back
+	err = handle("testSecret", "v2")
+	if !errors.Is(err, rotate.ErrVerifyFailed) {
+		t.Errorf("got error %v, expected ErrVerifyFailed", err)
+	}
+	if !errors.Is(err, verifyErr) {
+		t.Errorf("got error %v, expected cause %v", err, verifyErr)
+	}
+	var rerr *rotate.RotationError
+	if !errors.As(err, &rerr) || rerr.Step != "testSecret" {
+		t.Errorf("got error %#v, expected RotationError for testSecret", err)
+	}
+
+	// Rollback fails
+	rollbackErr = fmt.Errorf("host down")
+	err = handle("testSecret", "v2")
+	if !errors.Is(err, rotate.ErrRollbackFailed) || errors.Is(err, rotate.ErrVerifyFailed) {
+		t.Errorf("got error %v, expected only ErrRollbackFailed", err)
+	}
+	if !errors.Is(err, rollbackErr) {
+		t.Errorf("got error %v, expected cause %v", err, rollbackErr)
+	}
+}
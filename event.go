@@ -7,23 +7,55 @@ import (
 )
 
 const (
-	EVENT_BEGIN_ROTATION              = "begin-rotation"
-	EVENT_BEGIN_PASSWORD_ROTATION     = "begin-password-rotation"
-	EVENT_END_PASSWORD_ROTATION       = "end-password-rotation"
-	EVENT_BEGIN_PASSWORD_VERIFICATION = "begin-password-verification"
-	EVENT_END_PASSWORD_VERIFICATION   = "end-password-verification"
-	EVENT_NEW_PASSWORD_IS_CURRENT     = "new-password-is-current"
-	EVENT_END_ROTATION                = "end-rotation"
-	EVENT_BEGIN_PASSWORD_ROLLBACK     = "begin-password-rollback"
-	EVENT_ERROR                       = "error"
+	EVENT_BEGIN_ROTATION                = "begin-rotation"
+	EVENT_BEGIN_PASSWORD_ROTATION       = "begin-password-rotation"
+	EVENT_END_PASSWORD_ROTATION         = "end-password-rotation"
+	EVENT_BEGIN_PASSWORD_VERIFICATION   = "begin-password-verification"
+	EVENT_END_PASSWORD_VERIFICATION     = "end-password-verification"
+	EVENT_NEW_PASSWORD_IS_CURRENT       = "new-password-is-current"
+	EVENT_END_ROTATION                  = "end-rotation"
+	EVENT_BEGIN_PASSWORD_ROLLBACK       = "begin-password-rollback"
+	EVENT_ERROR                         = "error"
+	EVENT_FLEET_VERIFIED                = "fleet-verified"
+	EVENT_SECRET_REPLICATED             = "secret-replicated"
+	EVENT_END_STEP                      = "end-step"
+	EVENT_PASSWORD_ROTATION_INTERRUPTED = "password-rotation-interrupted"
+	EVENT_OLD_PASSWORD_DISCARDED        = "old-password-discarded"
+	EVENT_HOSTS_FAILED                  = "hosts-failed"
+	EVENT_SESSIONS_KILLED               = "sessions-killed"
+	EVENT_LINGERING_SESSIONS            = "lingering-sessions"
 )
 
 // Event is an important event during the four-step Secrets Manager rotation process.
 type Event struct {
-	Name  string    // EVENT_ const
-	Step  string    // "createSecret", "setSecret", "testSecret", or "finishSecret"
-	Time  time.Time // when event occurred
-	Error error     // non-nil if Step failed (Name will be EVENT_ERROR)
+	Name      string    // EVENT_ const
+	SecretId  string    // secret ID (name or ARN) from the Secrets Manager event
+	VersionId string    // ClientRequestToken from the Secrets Manager event: the new secret version
+	Step      string    // "createSecret", "setSecret", "testSecret", or "finishSecret"
+	Time      time.Time // when event occurred
+	Error     error     // non-nil if Step failed (Name will be EVENT_ERROR), or the failed hosts for EVENT_HOSTS_FAILED
+
+	// Duration is how long the rotation or a phase of it took, for these events:
+	//
+	//	EVENT_END_STEP                   step run time, including middleware
+	//	EVENT_END_PASSWORD_ROTATION      time to set the new password on the databases
+	//	EVENT_END_PASSWORD_VERIFICATION  time to verify the new password on the databases
+	//	EVENT_NEW_PASSWORD_IS_CURRENT    password downtime: from setting the new password
+	//	                                 on the databases to making it current
+	//	EVENT_SECRET_REPLICATED          time waiting for secret replication
+	//	EVENT_END_ROTATION               total rotation time, from createSecret
+	//	EVENT_OLD_PASSWORD_DISCARDED     time the old password worked after the rotation
+	//	EVENT_SESSIONS_KILLED            time to kill the sessions on the databases
+	//	EVENT_LINGERING_SESSIONS         time to count the sessions on the databases
+	//
+	// It is zero for other events, or if unknown. Error is set for EVENT_END_STEP
+	// if the step failed.
+	Duration time.Duration
+
+	// Sessions is the number of database sessions per database hostname still
+	// connected with the old password for EVENT_LINGERING_SESSIONS, including
+	// zero counts. It is nil for other events.
+	Sessions map[string]int
 }
 
 // EventReceiver receives events from a Rotator during the four-step Secrets Manager
@@ -34,6 +66,22 @@ type EventReceiver interface {
 	Receive(Event)
 }
 
+// VetoEventReceiver is an optional interface that an EventReceiver implements
+// to stop the rotation, like a compliance check that refuses rotations during
+// a change freeze. ReceiveVeto is called instead of Receive for the events that
+// begin a change, before the change:
+//
+//	EVENT_BEGIN_ROTATION           createSecret, before the new secret is made
+//	EVENT_BEGIN_PASSWORD_ROTATION  setSecret, before the new password is set
+//
+// If ReceiveVeto returns an error, the step stops, the pending secret (if any)
+// is removed, and Handler returns a RotationError with Kind ErrRotationVetoed.
+// All other events are sent to Receive.
+type VetoEventReceiver interface {
+	EventReceiver
+	ReceiveVeto(Event) error
+}
+
 // NullEventReceiver is the default EventReceiver if none is provided in the Config.
 // It ignores all events.
 type NullEventReceiver struct{}
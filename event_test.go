@@ -0,0 +1,197 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type vetoReceiver struct {
+	veto   map[string]error // event name => error
+	vetoed []string
+	events []string
+}
+
+func (r *vetoReceiver) Receive(e rotate.Event) {
+	r.events = append(r.events, e.Name)
+}
+
+func (r *vetoReceiver) ReceiveVeto(e rotate.Event) error {
+	r.vetoed = append(r.vetoed, e.Name)
+	return r.veto[e.Name]
+}
+
+func TestVetoEventReceiver(t *testing.T) {
+	var gotUpdates []string
+	put := false
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString:  &secretString1,
+					VersionId:     aws.String("v1"),
+					VersionStages: []*string{aws.String(rotate.AWSCURRENT)},
+				}, nil
+			default:
+				if put {
+					return &secretsmanager.GetSecretValueOutput{
+						SecretString:  &secretString2,
+						VersionId:     aws.String("v2"),
+						VersionStages: []*string{aws.String(rotate.AWSPENDING)},
+					}, nil
+				}
+				return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+			}
+		},
+		PutSecretValueFunc: func(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+			put = true
+			return &secretsmanager.PutSecretValueOutput{}, nil
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			gotUpdates = append(gotUpdates, fmt.Sprintf("%s remove %s", *input.VersionStage, aws.StringValue(input.RemoveFromVersionId)))
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+	}
+	setPassword := false
+	ps := test.MockPasswordSetter{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			setPassword = true
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.New.Password == "p2" {
+				return fmt.Errorf("not set")
+			}
+			return nil
+		},
+	}
+	freeze := fmt.Errorf("change freeze")
+	recv := &vetoReceiver{veto: map[string]error{rotate.EVENT_BEGIN_ROTATION: freeze}}
+	handle := func(step string) error {
+		r := rotate.NewRotator(rotate.Config{
+			SecretsManager: sm,
+			PasswordSetter: ps,
+			EventReceiver:  recv,
+		})
+		_, err := r.Handler(context.TODO(), map[string]string{
+			"ClientRequestToken": "v2",
+			"SecretId":           "def",
+			"Step":               step,
+		})
+		return err
+	}
+
+	// Vetoed in createSecret: new secret not put
+	err := handle("createSecret")
+	if !errors.Is(err, rotate.ErrRotationVetoed) || !errors.Is(err, freeze) {
+		t.Errorf("got error %v, expected ErrRotationVetoed", err)
+	}
+	if put {
+		t.Error("new secret put after veto")
+	}
+	if diff := deep.Equal(recv.vetoed, []string{rotate.EVENT_BEGIN_ROTATION}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Vetoed in setSecret: new password not set, pending secret removed
+	recv.veto = map[string]error{rotate.EVENT_BEGIN_PASSWORD_ROTATION: freeze}
+	recv.vetoed = nil
+	if err := handle("createSecret"); err != nil {
+		t.Fatal(err)
+	}
+	err = handle("setSecret")
+	if !errors.Is(err, rotate.ErrRotationVetoed) {
+		t.Errorf("got error %v, expected ErrRotationVetoed", err)
+	}
+	if setPassword {
+		t.Error("new password set after veto")
+	}
+	if diff := deep.Equal(gotUpdates, []string{"AWSPENDING remove v2"}); diff != nil {
+		t.Error(diff)
+	}
+	expect := []string{rotate.EVENT_BEGIN_ROTATION, rotate.EVENT_BEGIN_PASSWORD_ROTATION}
+	if diff := deep.Equal(recv.vetoed, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestTimingEvents(t *testing.T) {
+	created := time.Now().Add(-time.Minute)
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+					CreatedDate:  &created,
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{}, nil
+		},
+	}
+	durations := map[string]time.Duration{}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{
+			VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				time.Sleep(5 * time.Millisecond)
+				return nil
+			},
+		},
+		EventReceiver: eventRecorder(func(e rotate.Event) {
+			durations[e.Step+" "+e.Name] = e.Duration
+			if e.SecretId != "def" || e.VersionId != "v2" {
+				t.Errorf("%s event SecretId %q VersionId %q, expected def v2", e.Name, e.SecretId, e.VersionId)
+			}
+		}),
+	})
+	for _, step := range []string{"testSecret", "finishSecret"} {
+		_, err := r.Handler(context.TODO(), map[string]string{
+			"ClientRequestToken": "v2",
+			"SecretId":           "def",
+			"Step":               step,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	atLeast := map[string]time.Duration{
+		"testSecret " + rotate.EVENT_END_PASSWORD_VERIFICATION: 5 * time.Millisecond,
+		"testSecret " + rotate.EVENT_END_STEP:                  5 * time.Millisecond,
+		"finishSecret " + rotate.EVENT_END_ROTATION:            time.Minute,
+	}
+	for event, d := range atLeast {
+		if durations[event] < d {
+			t.Errorf("%s duration %s, expected at least %s", event, durations[event], d)
+		}
+	}
+	if _, ok := durations["finishSecret "+rotate.EVENT_SECRET_REPLICATED]; !ok {
+		t.Errorf("no %s event", rotate.EVENT_SECRET_REPLICATED)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+)
+
+// EventBridge event source and detail type used by EventBridgeReceiver.
+// Match them in EventBridge rules, like:
+//
+//	{"source": ["password-rotation-lambda"], "detail-type": ["Password Rotation Event"]}
+const (
+	DEFAULT_EVENTBRIDGE_SOURCE = "password-rotation-lambda"
+	EVENTBRIDGE_DETAIL_TYPE    = "Password Rotation Event"
+)
+
+// EVENTBRIDGE_DETAIL_VERSION is EventBridgeDetail.Version. It changes only if
+// the detail schema changes incompatibly.
+const EVENTBRIDGE_DETAIL_VERSION = "1"
+
+// EventBridgeDetail is the detail (JSON) of the EventBridge events put by
+// EventBridgeReceiver. Fields are only added, never changed or removed, unless
+// Version changes.
+type EventBridgeDetail struct {
+	Version    string `json:"version"`              // EVENTBRIDGE_DETAIL_VERSION
+	Event      string `json:"event"`                // Event.Name, like EVENT_END_ROTATION
+	SecretId   string `json:"secretId"`             // Event.SecretId
+	SecretName string `json:"secretName"`           // secret name from SecretId
+	Step       string `json:"step,omitempty"`       // Event.Step
+	DurationMs int64  `json:"durationMs,omitempty"` // Event.Duration, if known
+	Error      string `json:"error,omitempty"`      // Event.Error (redacted)
+
+	// Sessions is Event.Sessions for EVENT_LINGERING_SESSIONS: the number of
+	// database sessions still connected with the old password per database hostname.
+	Sessions map[string]int `json:"sessions,omitempty"`
+}
+
+// EventBridgeReceiver is an EventReceiver that puts rotation lifecycle events
+// onto an EventBridge event bus, so other automation, like compliance recorders
+// or cache invalidation, can react to rotations account-wide. The event detail
+// type is EVENTBRIDGE_DETAIL_TYPE and the detail is an EventBridgeDetail. If
+// the secret ID is an ARN, it is the event resource.
+//
+// Events are put synchronously; wrap it in an AsyncReceiver to put events without
+// blocking the rotation. Errors are logged and ignored. The Lambda function must
+// allow events:PutEvents on the event bus.
+type EventBridgeReceiver struct {
+	EventBridge  eventbridgeiface.EventBridgeAPI
+	EventBusName string // default event bus if empty
+	Source       string // DEFAULT_EVENTBRIDGE_SOURCE if empty
+
+	// Events are the event names to put, like EVENT_END_ROTATION. If nil,
+	// all events except EVENT_END_STEP are put.
+	Events []string
+}
+
+var _ EventReceiver = EventBridgeReceiver{}
+
+func (r EventBridgeReceiver) Receive(e Event) {
+	if !r.put(e.Name) {
+		return
+	}
+	detail := EventBridgeDetail{
+		Version:    EVENTBRIDGE_DETAIL_VERSION,
+		Event:      e.Name,
+		SecretId:   e.SecretId,
+		SecretName: secretName(e.SecretId),
+		Step:       e.Step,
+		DurationMs: e.Duration.Milliseconds(),
+		Sessions:   e.Sessions,
+	}
+	if e.Error != nil {
+		detail.Error = e.Error.Error()
+	}
+	bytes, err := json.Marshal(detail)
+	if err != nil {
+		log.Printf("ERROR: EventBridge event %s: %s", e.Name, err)
+		return
+	}
+
+	source := r.Source
+	if source == "" {
+		source = DEFAULT_EVENTBRIDGE_SOURCE
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	entry := &eventbridge.PutEventsRequestEntry{
+		Source:     aws.String(source),
+		DetailType: aws.String(EVENTBRIDGE_DETAIL_TYPE),
+		Detail:     aws.String(string(bytes)),
+		Time:       aws.Time(t),
+	}
+	if r.EventBusName != "" {
+		entry.EventBusName = aws.String(r.EventBusName)
+	}
+	if strings.HasPrefix(e.SecretId, "arn:") {
+		entry.Resources = []*string{aws.String(e.SecretId)}
+	}
+	out, err := r.EventBridge.PutEvents(&eventbridge.PutEventsInput{
+		Entries: []*eventbridge.PutEventsRequestEntry{entry},
+	})
+	if err != nil {
+		log.Printf("ERROR: EventBridge event %s: %s", e.Name, err)
+		return
+	}
+	if aws.Int64Value(out.FailedEntryCount) > 0 && len(out.Entries) > 0 {
+		log.Printf("ERROR: EventBridge event %s: %s: %s", e.Name,
+			aws.StringValue(out.Entries[0].ErrorCode), aws.StringValue(out.Entries[0].ErrorMessage))
+	}
+}
+
+// put returns true if the event should be put.
+func (r EventBridgeReceiver) put(name string) bool {
+	if r.Events == nil {
+		return name != EVENT_END_STEP
+	}
+	for _, n := range r.Events {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+type mockEventBridge struct {
+	eventbridgeiface.EventBridgeAPI
+	entries []*eventbridge.PutEventsRequestEntry
+}
+
+func (m *mockEventBridge) PutEvents(input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	m.entries = append(m.entries, input.Entries...)
+	return &eventbridge.PutEventsOutput{}, nil
+}
+
+func TestEventBridgeReceiver(t *testing.T) {
+	eb := &mockEventBridge{}
+	r := rotate.EventBridgeReceiver{EventBridge: eb, EventBusName: "ops"}
+	arn := "arn:aws:secretsmanager:us-east-1:123456789012:secret:prod/db-a1b2c3"
+
+	r.Receive(rotate.Event{Name: rotate.EVENT_END_STEP, SecretId: arn, Step: "setSecret"}) // not put by default
+	r.Receive(rotate.Event{Name: rotate.EVENT_ERROR, SecretId: arn, Step: "setSecret", Error: errors.New("fail"), Time: time.Now()})
+	r.Receive(rotate.Event{Name: rotate.EVENT_END_ROTATION, SecretId: "db", Step: "finishSecret", Duration: time.Minute})
+
+	if len(eb.entries) != 2 {
+		t.Fatalf("got %d entries, expected 2", len(eb.entries))
+	}
+	e := eb.entries[0]
+	got := []string{*e.Source, *e.DetailType, *e.EventBusName, *e.Resources[0]}
+	expect := []string{rotate.DEFAULT_EVENTBRIDGE_SOURCE, rotate.EVENTBRIDGE_DETAIL_TYPE, "ops", arn}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	var details []rotate.EventBridgeDetail
+	for _, e := range eb.entries {
+		var d rotate.EventBridgeDetail
+		if err := json.Unmarshal([]byte(*e.Detail), &d); err != nil {
+			t.Fatal(err)
+		}
+		details = append(details, d)
+	}
+	expectDetails := []rotate.EventBridgeDetail{
+		{Version: "1", Event: rotate.EVENT_ERROR, SecretId: arn, SecretName: "prod/db", Step: "setSecret", Error: "fail"},
+		{Version: "1", Event: rotate.EVENT_END_ROTATION, SecretId: "db", SecretName: "db", Step: "finishSecret", DurationMs: 60000},
+	}
+	if diff := deep.Equal(details, expectDetails); diff != nil {
+		t.Error(diff)
+	}
+	if eb.entries[1].Resources != nil {
+		t.Errorf("got resources %v, expected none for secret name", eb.entries[1].Resources)
+	}
+
+	// Only the given events
+	eb.entries = nil
+	r.Events = []string{rotate.EVENT_END_ROTATION}
+	r.Receive(rotate.Event{Name: rotate.EVENT_BEGIN_ROTATION, SecretId: "db"})
+	r.Receive(rotate.Event{Name: rotate.EVENT_END_ROTATION, SecretId: "db"})
+	if len(eb.entries) != 1 {
+		t.Errorf("got %d entries, expected 1", len(eb.entries))
+	}
+}
@@ -0,0 +1,70 @@
+// This example rotates an RDS MySQL secret with alternating users: each
+// rotation changes the password of the user that is not current (app or
+// app_clone), then switches the secret to it, so the current user keeps
+// working during the rotation. The other user's password is set as the admin
+// in the secret named by env var ADMIN_SECRET_ID.
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	"github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+)
+
+func main() {
+	// Redact passwords from log output
+	log.SetOutput(rotate.RedactWriter{W: os.Stderr})
+
+	// Start AWS session using env vars automatically set by Lambda
+	sess, err := session.NewSession()
+	if err != nil {
+		log.Fatalf("error making AWS session: %s", err)
+	}
+
+	adminSecretId := os.Getenv("ADMIN_SECRET_ID")
+	if adminSecretId == "" {
+		log.Fatal("env var ADMIN_SECRET_ID is not set")
+	}
+
+	// RDS MySQL client with TLS. The connections of each user are kept across
+	// warm Lambda invocations.
+	dbClient := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{
+		TLS:                   true,
+		PersistentConnections: true,
+	})
+
+	// Make password setter for MySQL (RDS). Aurora cluster writers are changed
+	// through the cluster endpoint and readers get the change by replication.
+	// No more than 1 db instance of each cluster is changed at a time.
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient:             rds.New(sess),
+		DbClient:              dbClient,
+		Parallel:              10,
+		ParallelPerGroup:      1,
+		WriterOnly:            true,
+		ClusterWriterEndpoint: true,
+		Retry:                 2,
+		RetryWait:             time.Second,
+		RetryBackoff:          true,
+		InstanceCacheTTL:      time.Hour,
+	})
+
+	// Make Rotator which is the Lambda function/handler
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager:   secretsmanager.New(sess),
+		PasswordSetter:   ps,
+		AdminSecretId:    adminSecretId,
+		RotationStrategy: rotate.AlternatingUsers{}, // app <-> app_clone
+	})
+
+	// Run Rotator in Lambda, waiting for events from Secrets Manager
+	lambda.Start(r.Handler)
+}
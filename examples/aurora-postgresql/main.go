@@ -0,0 +1,166 @@
+// This example rotates the password of a PostgreSQL user on an Aurora
+// PostgreSQL cluster. The cluster is the one named by dbClusterIdentifier in
+// the secret. All instances of an Aurora cluster share one catalog, so the
+// password is changed once through the cluster writer endpoint. If env var
+// ADMIN_SECRET_ID is set, the password is changed by the admin user in that
+// secret, like the cluster master user, instead of by the user itself.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/lib/pq"
+
+	"github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// pgSetter is a minimal db.PasswordSetter for Aurora PostgreSQL. It does not
+// retry or roll back partially: there is only one host, the cluster writer
+// endpoint, so the password is either changed or not.
+type pgSetter struct {
+	rds rdsiface.RDSAPI
+	// --
+	host string // cluster writer endpoint, set by Init
+	port int
+	set  bool // true if SetPassword changed the password
+}
+
+var _ db.PasswordSetter = &pgSetter{}
+
+// Init finds the writer endpoint of the cluster in the secret.
+func (s *pgSetter) Init(ctx context.Context, secret map[string]string) error {
+	clusterId := secret["dbClusterIdentifier"]
+	if clusterId == "" {
+		return fmt.Errorf("dbClusterIdentifier not set in secret")
+	}
+	out, err := s.rds.DescribeDBClustersWithContext(ctx, &rds.DescribeDBClustersInput{
+		DBClusterIdentifier: aws.String(clusterId),
+	})
+	if err != nil {
+		return fmt.Errorf("rds.DescribeDBClusters(%s): %s", clusterId, err)
+	}
+	if len(out.DBClusters) == 0 || out.DBClusters[0].Endpoint == nil {
+		return fmt.Errorf("rds.DescribeDBClusters(%s): no cluster endpoint", clusterId)
+	}
+	s.host = aws.StringValue(out.DBClusters[0].Endpoint)
+	s.port = int(aws.Int64Value(out.DBClusters[0].Port))
+	log.Printf("cluster %s writer endpoint: %s:%d", clusterId, s.host, s.port)
+	return nil
+}
+
+// SetPassword changes the password of the current user to the new password.
+// PostgreSQL does not take parameters in ALTER ROLE, so the user and password
+// are quoted. The server hashes the password with password_encryption
+// (scram-sha-256 by default on Aurora PostgreSQL 14 and newer).
+func (s *pgSetter) SetPassword(ctx context.Context, creds db.NewPassword) error {
+	s.set = false
+	conn := creds.Current
+	if creds.Admin != nil {
+		conn = *creds.Admin
+	}
+	c, err := s.connect(conn)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	q := "ALTER ROLE " + pq.QuoteIdentifier(creds.Current.Username) + " WITH PASSWORD " + pq.QuoteLiteral(creds.New.Password)
+	if _, err := c.ExecContext(ctx, q); err != nil {
+		return fmt.Errorf("ALTER ROLE %s: %s", creds.Current.Username, err)
+	}
+	s.set = true
+	log.Printf("changed password of %s on %s", creds.Current.Username, s.host)
+	return nil
+}
+
+// VerifyPassword connects as the new user with the new password.
+func (s *pgSetter) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
+	c, err := s.connect(creds.New)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.PingContext(ctx); err != nil {
+		return fmt.Errorf("%s@%s: %s", creds.New.Username, s.host, err)
+	}
+	return nil
+}
+
+// Rollback changes the password back to the current password if SetPassword
+// changed it.
+func (s *pgSetter) Rollback(ctx context.Context, creds db.NewPassword) error {
+	if !s.set {
+		return nil
+	}
+	if err := s.SetPassword(ctx, creds.Swap()); err != nil {
+		s.set = true // still needs a rollback
+		return err
+	}
+	s.set = false
+	return nil
+}
+
+func (s *pgSetter) connect(creds db.Credentials) (*sql.DB, error) {
+	port := s.port
+	if port == 0 {
+		port = 5432
+	}
+	database := creds.Database
+	if database == "" {
+		database = "postgres"
+	}
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(creds.Username, creds.Password),
+		Host:     net.JoinHostPort(s.host, strconv.Itoa(port)),
+		Path:     "/" + database,
+		RawQuery: "sslmode=require&connect_timeout=5",
+	}
+	c, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	c.SetMaxOpenConns(1)
+	return c, nil
+}
+
+func main() {
+	// Redact passwords from log output
+	log.SetOutput(rotate.RedactWriter{W: os.Stderr})
+
+	// Start AWS session using env vars automatically set by Lambda
+	sess, err := session.NewSession()
+	if err != nil {
+		log.Fatalf("error making AWS session: %s", err)
+	}
+
+	// Make Rotator which is the Lambda function/handler. The password has no
+	// characters that need quoting in a connection URL or SQL, so it's easy
+	// to use by hand too.
+	sm := secretsmanager.New(sess)
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter: rotate.AWSRandomPassword{
+			SecretsManager:    sm,
+			ExcludeCharacters: `'"\@/:?#%`,
+		},
+		PasswordSetter: &pgSetter{rds: rds.New(sess)},
+		AdminSecretId:  os.Getenv("ADMIN_SECRET_ID"),
+	})
+
+	// Run Rotator in Lambda, waiting for events from Secrets Manager
+	lambda.Start(r.Handler)
+}
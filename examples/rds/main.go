@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws/session"
@@ -13,6 +14,9 @@ import (
 )
 
 func main() {
+	// Redact passwords from log output
+	log.SetOutput(rotate.RedactWriter{W: os.Stderr})
+
 	// Start AWS session using env vars automatically set by Lambda
 	sess, err := session.NewSession()
 	if err != nil {
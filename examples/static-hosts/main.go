@@ -0,0 +1,94 @@
+// This example rotates the password of a MySQL user on databases that are not
+// in RDS, like MySQL on EC2 or on premises. The hosts are listed in env var
+// DB_HOSTS ("host:port,host:port"), and the server certificates are signed by
+// the CA in env var DB_CA_CERT (PEM).
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	"github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+)
+
+// staticHosts is an RDS client that returns a fixed list of db instances
+// instead of calling the RDS API. mysql.PasswordSetter calls only
+// DescribeDBInstances, and DescribeDBClusters if Config.WriterOnly,
+// Config.SkipReaders, or Config.ClusterWriterEndpoint is set.
+type staticHosts struct {
+	rdsiface.RDSAPI
+	dbs []*rds.DBInstance
+}
+
+func newStaticHosts(hosts string) (staticHosts, error) {
+	s := staticHosts{}
+	for _, hostport := range strings.Split(hosts, ",") {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(hostport))
+		if err != nil {
+			return s, fmt.Errorf("invalid host %q: %s", hostport, err)
+		}
+		p, err := strconv.ParseInt(port, 10, 64)
+		if err != nil {
+			return s, fmt.Errorf("invalid port in %q: %s", hostport, err)
+		}
+		s.dbs = append(s.dbs, &rds.DBInstance{
+			DBInstanceIdentifier: aws.String(host),
+			Endpoint:             &rds.Endpoint{Address: aws.String(host), Port: aws.Int64(p)},
+		})
+	}
+	return s, nil
+}
+
+func (s staticHosts) DescribeDBInstances(*rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+	return &rds.DescribeDBInstancesOutput{DBInstances: s.dbs}, nil
+}
+
+func main() {
+	// Redact passwords from log output
+	log.SetOutput(rotate.RedactWriter{W: os.Stderr})
+
+	// Start AWS session using env vars automatically set by Lambda
+	sess, err := session.NewSession()
+	if err != nil {
+		log.Fatalf("error making AWS session: %s", err)
+	}
+
+	hosts, err := newStaticHosts(os.Getenv("DB_HOSTS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// MySQL client that trusts the private CA (in addition to the RDS CA)
+	dbClient := mysql.NewRDSClientWithOptions(mysql.RDSClientOptions{
+		CACerts:          []byte(os.Getenv("DB_CA_CERT")),
+		ReuseConnections: true,
+	})
+
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: hosts,
+		DbClient:  dbClient,
+		Parallel:  uint(len(hosts.dbs)),
+		Retry:     2,
+	})
+
+	// Make Rotator which is the Lambda function/handler
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: secretsmanager.New(sess),
+		PasswordSetter: ps,
+	})
+
+	// Run Rotator in Lambda, waiting for events from Secrets Manager
+	lambda.Start(r.Handler)
+}
@@ -0,0 +1,98 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"log"
+	"sort"
+)
+
+// SecretKeyOwner is an optional interface that a SecretSetter implements to
+// declare which secret keys (JSON fields) it owns. Rotate can add, change, or
+// remove only owned keys. All other keys, like host, port, or comments, are
+// passed through CreateSecret untouched: if Rotate changes or removes them,
+// the current values are restored, and new keys that are not owned are removed.
+//
+// If a SecretSetter does not implement SecretKeyOwner, Rotate can add or change
+// any key, but removed keys are restored. Either way, the new secret string is
+// JSON with keys in sorted order, so the order is stable between rotations.
+type SecretKeyOwner interface {
+	OwnedKeys() []string
+}
+
+var _ SecretKeyOwner = RandomPassword{}
+var _ SecretKeyOwner = MultiRandomPassword{}
+var _ SecretKeyOwner = AWSRandomPassword{}
+
+// OwnedKeys returns "password" because RandomPassword changes only the password.
+func (s RandomPassword) OwnedKeys() []string {
+	return []string{"password"}
+}
+
+// OwnedKeys returns the password key of every account.
+func (s MultiRandomPassword) OwnedKeys() []string {
+	keys := []string{}
+	for _, prefix := range s.accounts() {
+		keys = append(keys, prefix+"password")
+	}
+	return keys
+}
+
+// OwnedKeys returns "password" because AWSRandomPassword changes only the password.
+func (s AWSRandomPassword) OwnedKeys() []string {
+	return []string{"password"}
+}
+
+// preserveFields restores keys in newVals that the SecretSetter does not own
+// to their values in curVals. See SecretKeyOwner.
+func preserveFields(ss SecretSetter, curVals, newVals map[string]string) {
+	ko, ok := ss.(SecretKeyOwner)
+	if !ok {
+		// Rotate can change anything, but cannot remove keys
+		for k, v := range curVals {
+			if _, ok := newVals[k]; !ok {
+				log.Printf("secret key %s removed by SecretSetter.Rotate, restoring it", k)
+				newVals[k] = v
+			}
+		}
+		return
+	}
+
+	owned := map[string]bool{}
+	for _, k := range ko.OwnedKeys() {
+		owned[k] = true
+	}
+	for k, v := range curVals {
+		if owned[k] {
+			continue
+		}
+		if nv, ok := newVals[k]; !ok || nv != v {
+			log.Printf("secret key %s changed by SecretSetter.Rotate but not owned by it, restoring it", k)
+			newVals[k] = v
+		}
+	}
+	for k := range newVals {
+		if _, ok := curVals[k]; !ok && !owned[k] {
+			log.Printf("secret key %s added by SecretSetter.Rotate but not owned by it, removing it", k)
+			delete(newVals, k)
+		}
+	}
+}
+
+// changedFields returns the sorted keys that were added, changed, or removed
+// between curVals and newVals, or nil if none.
+func changedFields(curVals, newVals map[string]string) []string {
+	var changed []string
+	for k, v := range newVals {
+		if cv, ok := curVals[k]; !ok || cv != v {
+			changed = append(changed, k)
+		}
+	}
+	for k := range curVals {
+		if _, ok := newVals[k]; !ok {
+			changed = append(changed, k)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
@@ -0,0 +1,108 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type keyOwnerSecretSetter struct {
+	test.MockSecretSetter
+	owned []string
+}
+
+func (s keyOwnerSecretSetter) OwnedKeys() []string {
+	return s.owned
+}
+
+func createSecret(t *testing.T, ss rotate.SecretSetter, curSecret string) string {
+	t.Helper()
+	var gotSecretString string
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString:  aws.String(curSecret),
+					VersionId:     aws.String("v1"),
+					VersionStages: []*string{aws.String(rotate.AWSCURRENT)},
+				}, nil
+			default:
+				return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+			}
+		},
+		PutSecretValueFunc: func(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+			gotSecretString = *input.SecretString
+			return &secretsmanager.PutSecretValueOutput{}, nil
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter:   ss,
+		PasswordSetter: test.MockPasswordSetter{},
+	})
+	event := map[string]string{
+		"ClientRequestToken": "abc",
+		"SecretId":           "def",
+		"Step":               "createSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	return gotSecretString
+}
+
+func TestCreateSecretPreservesFields(t *testing.T) {
+	// Test that extra fields pass through createSecret in sorted key order
+	// even if the SecretSetter removes them
+	ss := test.MockSecretSetter{
+		RotateFunc: func(secret map[string]string) error {
+			delete(secret, "host")
+			secret["password"] = "new"
+			return nil
+		},
+	}
+	cur := `{"username":"foo","password":"old","port":"3306","host":"db.local","comment":"hi"}`
+	got := createSecret(t, ss, cur)
+	expect := `{"comment":"hi","host":"db.local","password":"new","port":"3306","username":"foo"}`
+	if got != expect {
+		t.Errorf("got secret %s, expected %s", got, expect)
+	}
+}
+
+func TestCreateSecretOwnedKeys(t *testing.T) {
+	// Test that a SecretKeyOwner can change only the keys it owns
+	ss := keyOwnerSecretSetter{
+		MockSecretSetter: test.MockSecretSetter{
+			RotateFunc: func(secret map[string]string) error {
+				secret["password"] = "new"
+				secret["username"] = "bar" // not owned
+				secret["rotated"] = "yes"  // owned
+				secret["debug"] = "true"   // not owned
+				return nil
+			},
+		},
+		owned: []string{"password", "rotated"},
+	}
+	cur := `{"username":"foo","password":"old","host":"db.local"}`
+	got := createSecret(t, ss, cur)
+	expect := `{"host":"db.local","password":"new","rotated":"yes","username":"foo"}`
+	if got != expect {
+		t.Errorf("got secret %s, expected %s", got, expect)
+	}
+
+	// RandomPassword owns only the password
+	got = createSecret(t, rotate.RandomPassword{ValidCharset: []rune("x"), PasswordLength: 3}, cur)
+	expect = `{"host":"db.local","password":"xxx","username":"foo"}`
+	if got != expect {
+		t.Errorf("got secret %s, expected %s", got, expect)
+	}
+}
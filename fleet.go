@@ -0,0 +1,206 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+const (
+	// FLEET_VERIFY_ACTION is the "action" value of a user event that makes
+	// Rotator run the FleetVerifier instead of calling SecretSetter.Handler.
+	FLEET_VERIFY_ACTION = "verify-fleet"
+
+	// DEFAULT_FLEET_PARALLEL is the default number of secrets that a FleetVerifier
+	// verifies in parallel.
+	DEFAULT_FLEET_PARALLEL = 4
+)
+
+// FleetConfig configures a FleetVerifier when passed to NewFleetVerifier.
+type FleetConfig struct {
+	// SecretsManager is an AWS Secrets Manager client. It is used only to get the
+	// AWSCURRENT value of each secret.
+	SecretsManager SecretsManager
+
+	// SecretSetter returns the credentials from each secret. If none is provided,
+	// RandomPassword is used.
+	SecretSetter SecretSetter
+
+	// NewPasswordSetter returns a new PasswordSetter for the secret ID. It is
+	// called once per secret per verification because secrets are verified in
+	// parallel and a PasswordSetter is not safe for concurrent use.
+	NewPasswordSetter func(secretId string) db.PasswordSetter
+
+	// SecretIds are the IDs (name or ARN) of all secrets to verify.
+	SecretIds []string
+
+	// Parallel is the maximum number of secrets verified in parallel. Each
+	// PasswordSetter has its own parallelism for the databases of one secret.
+	// If zero, DEFAULT_FLEET_PARALLEL is used.
+	Parallel uint
+
+	// EventReceiver receives one EVENT_FLEET_VERIFIED event per verification.
+	// The Error is non-nil if any secret failed. If none is provided,
+	// NullEventReceiver is used.
+	EventReceiver EventReceiver
+}
+
+// FleetReport is the consolidated health report of one FleetVerifier.Verify.
+type FleetReport struct {
+	Time    time.Time      `json:"time"`
+	Secrets []SecretHealth `json:"secrets"`
+	OK      int            `json:"ok"`
+	Failed  int            `json:"failed"`
+}
+
+// SecretHealth is the verification result for one secret in a FleetReport.
+type SecretHealth struct {
+	SecretId string `json:"secretId"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"durationMs"`
+}
+
+// FleetVerifier verifies that the AWSCURRENT value of many secrets works on
+// their databases. It does not change anything, so it is safe to run at any time
+// between rotations to detect drift between Secrets Manager and the databases.
+//
+// Run it on a schedule by creating an EventBridge rule that invokes the Lambda
+// function with the constant input {"action":"verify-fleet"} and setting
+// Config.FleetVerifier for the Rotator, or by calling Verify directly.
+type FleetVerifier struct {
+	cfg FleetConfig
+}
+
+// NewFleetVerifier creates a new FleetVerifier.
+func NewFleetVerifier(cfg FleetConfig) *FleetVerifier {
+	if cfg.SecretSetter == nil {
+		cfg.SecretSetter = RandomPassword{}
+	}
+	if cfg.Parallel == 0 {
+		cfg.Parallel = DEFAULT_FLEET_PARALLEL
+	}
+	if cfg.EventReceiver == nil {
+		cfg.EventReceiver = NullEventReceiver{}
+	}
+	return &FleetVerifier{cfg: cfg}
+}
+
+// Handler verifies all secrets and returns a summary of the FleetReport.
+// The full report is logged as one JSON line. It returns an error if any
+// secret failed verification.
+func (f *FleetVerifier) Handler(ctx context.Context, event map[string]string) (map[string]string, error) {
+	report := f.Verify(ctx)
+
+	bytes, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("fleet report: %s", string(bytes))
+
+	failed := []string{}
+	for _, s := range report.Secrets {
+		if !s.OK {
+			failed = append(failed, s.SecretId)
+		}
+	}
+	ret := map[string]string{
+		"secrets":       strconv.Itoa(len(report.Secrets)),
+		"ok":            strconv.Itoa(report.OK),
+		"failed":        strconv.Itoa(report.Failed),
+		"failedSecrets": strings.Join(failed, ","),
+	}
+	if report.Failed > 0 {
+		return ret, fmt.Errorf("fleet verification failed on %d of %d secrets: %s", report.Failed, len(report.Secrets), strings.Join(failed, ", "))
+	}
+	return ret, nil
+}
+
+// Verify verifies all secrets, at most FleetConfig.Parallel at once, and returns
+// the report. Secrets in the report are sorted by secret ID.
+func (f *FleetVerifier) Verify(ctx context.Context) FleetReport {
+	t0 := time.Now()
+	log.Printf("verifying %d secrets, %d in parallel...", len(f.cfg.SecretIds), f.cfg.Parallel)
+
+	sem := make(chan bool, f.cfg.Parallel)
+	results := make([]SecretHealth, len(f.cfg.SecretIds))
+	var wg sync.WaitGroup
+	for i, secretId := range f.cfg.SecretIds {
+		select {
+		case sem <- true:
+		case <-ctx.Done():
+			results[i] = SecretHealth{SecretId: secretId, Error: ctx.Err().Error()}
+			continue
+		}
+		wg.Add(1)
+		go func(i int, secretId string) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("%s: PANIC: %v", secretId, r)
+					results[i] = SecretHealth{SecretId: secretId, Error: fmt.Sprintf("panic: %v", r)}
+				}
+				<-sem
+				wg.Done()
+			}()
+			t1 := time.Now()
+			err := f.verifyOne(ctx, secretId)
+			results[i] = SecretHealth{
+				SecretId: secretId,
+				OK:       err == nil,
+				Duration: time.Now().Sub(t1).Milliseconds(),
+			}
+			if err != nil {
+				log.Printf("ERROR: %s: verify failed: %s", secretId, err)
+				results[i].Error = err.Error()
+			}
+		}(i, secretId)
+	}
+	wg.Wait()
+
+	report := FleetReport{Time: t0, Secrets: results}
+	for _, s := range results {
+		if s.OK {
+			report.OK++
+		} else {
+			report.Failed++
+		}
+	}
+	sort.Slice(report.Secrets, func(i, j int) bool { return report.Secrets[i].SecretId < report.Secrets[j].SecretId })
+
+	var err error
+	if report.Failed > 0 {
+		err = fmt.Errorf("%d of %d secrets failed verification", report.Failed, len(results))
+	}
+	f.cfg.EventReceiver.Receive(Event{
+		Name:  EVENT_FLEET_VERIFIED,
+		Time:  time.Now(),
+		Error: err,
+	})
+	log.Printf("verified %d secrets: %d ok, %d failed: %dms", len(results), report.OK, report.Failed, time.Now().Sub(t0).Milliseconds())
+	return report
+}
+
+// verifyOne verifies the AWSCURRENT value of one secret on its databases.
+func (f *FleetVerifier) verifyOne(ctx context.Context, secretId string) error {
+	_, curVals, err := getSecret(f.cfg.SecretsManager, secretId, AWSCURRENT)
+	if err != nil {
+		return err
+	}
+	cred := secretCredentials(f.cfg.SecretSetter, curVals)
+
+	ps := f.cfg.NewPasswordSetter(secretId)
+	if err := ps.Init(ctx, map[string]string{"SecretId": secretId}); err != nil {
+		return err
+	}
+	return ps.VerifyPassword(ctx, db.NewPassword{Current: cred, New: cred})
+}
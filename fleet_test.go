@@ -0,0 +1,94 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestFleetVerifier(t *testing.T) {
+	// Test that the "verify-fleet" user event verifies the current secret of
+	// every secret and returns a consolidated report without changing anything
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			if *input.VersionStage != rotate.AWSCURRENT {
+				return nil, fmt.Errorf("got stage %s, expected only AWSCURRENT", *input.VersionStage)
+			}
+			return &secretsmanager.GetSecretValueOutput{
+				SecretString: aws.String(fmt.Sprintf(`{"username":"%s","password":"p"}`, *input.SecretId)),
+				VersionId:    aws.String("v1"),
+			}, nil
+		},
+		PutSecretValueFunc: func(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+			t.Error("PutSecretValue called, expected no changes")
+			return nil, nil
+		},
+	}
+
+	newPasswordSetter := func(secretId string) db.PasswordSetter {
+		return test.MockPasswordSetter{
+			VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				if creds.New.Username != secretId {
+					return fmt.Errorf("got username %s, expected %s", creds.New.Username, secretId)
+				}
+				if secretId == "s2" {
+					return fmt.Errorf("access denied")
+				}
+				return nil
+			},
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				t.Error("SetPassword called, expected no changes")
+				return nil
+			},
+		}
+	}
+
+	var gotEvents []rotate.Event
+	fv := rotate.NewFleetVerifier(rotate.FleetConfig{
+		SecretsManager:    sm,
+		NewPasswordSetter: newPasswordSetter,
+		SecretIds:         []string{"s3", "s1", "s2"},
+		Parallel:          2,
+		EventReceiver:     eventRecorder(func(e rotate.Event) { gotEvents = append(gotEvents, e) }),
+	})
+
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{},
+		FleetVerifier:  fv,
+	})
+
+	got, err := r.Handler(context.TODO(), map[string]string{"action": rotate.FLEET_VERIFY_ACTION})
+	if err == nil {
+		t.Error("no error, expected error because s2 failed")
+	}
+	expect := map[string]string{
+		"secrets":       "3",
+		"ok":            "2",
+		"failed":        "1",
+		"failedSecrets": "s2",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	if len(gotEvents) != 1 || gotEvents[0].Name != rotate.EVENT_FLEET_VERIFIED || gotEvents[0].Error == nil {
+		t.Errorf("got events %+v, expected 1 fleet-verified event with error", gotEvents)
+	}
+}
+
+type eventRecorder func(rotate.Event)
+
+func (f eventRecorder) Receive(e rotate.Event) {
+	f(e)
+}
@@ -0,0 +1,49 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+)
+
+// RotateHooks is an optional interface that a SecretSetter implements to be
+// called before and after Rotate in CreateSecret. curVals are the current secret
+// values, which must not be changed, and newVals are the new secret values.
+//
+// BeforeRotate is called before Rotate, when newVals is still a copy of curVals.
+// AfterRotate is called after Rotate and after the other secret values are
+// restored (see SecretKeyOwner), so it can add or change any value, like a
+// connection string or checksum derived from the new password. If either
+// returns an error, CreateSecret returns it and the new secret is not put
+// in Secrets Manager.
+type RotateHooks interface {
+	BeforeRotate(curVals, newVals map[string]string) error
+	AfterRotate(curVals, newVals map[string]string) error
+}
+
+// FinishHooks is an optional interface that a SecretSetter implements to be
+// called before and after FinishSecret makes the new secret current. curVals
+// are the current (old) secret values and newVals are the new (pending) secret
+// values. Neither can be changed.
+//
+// If BeforeFinish returns an error, FinishSecret returns it and the new secret
+// is not made current. An error from AfterFinish is logged but does not fail
+// the rotation because the new secret is already current.
+type FinishHooks interface {
+	BeforeFinish(curVals, newVals map[string]string) error
+	AfterFinish(curVals, newVals map[string]string) error
+}
+
+// StepHooks are called by Rotator before and after every Secrets Manager
+// rotation step: "createSecret", "setSecret", "testSecret", or "finishSecret".
+// Set Config.StepHooks to gate steps on custom checks, like a feature flag
+// or a lock, without reimplementing Rotator.Handler.
+//
+// BeforeStep is called after the SecretSetter and PasswordSetter are initialized.
+// If it returns an error, the step is not run and Handler returns the error.
+// AfterStep is called after the step with the error that Handler returns,
+// which is nil on success. It is not called if BeforeStep returns an error.
+type StepHooks interface {
+	BeforeStep(ctx context.Context, step string, event map[string]string) error
+	AfterStep(ctx context.Context, step string, err error)
+}
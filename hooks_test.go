@@ -0,0 +1,194 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type hookSecretSetter struct {
+	rotate.RandomPassword
+	calls        []string
+	beforeFinish error
+}
+
+func (s *hookSecretSetter) BeforeRotate(curVals, newVals map[string]string) error {
+	s.calls = append(s.calls, "BeforeRotate")
+	return nil
+}
+
+func (s *hookSecretSetter) AfterRotate(curVals, newVals map[string]string) error {
+	s.calls = append(s.calls, "AfterRotate")
+	newVals["dsn"] = newVals["username"] + ":" + newVals["password"] + "@tcp(" + newVals["host"] + ")/"
+	return nil
+}
+
+func (s *hookSecretSetter) BeforeFinish(curVals, newVals map[string]string) error {
+	s.calls = append(s.calls, "BeforeFinish")
+	return s.beforeFinish
+}
+
+func (s *hookSecretSetter) AfterFinish(curVals, newVals map[string]string) error {
+	s.calls = append(s.calls, "AfterFinish")
+	return nil
+}
+
+func TestRotateHooks(t *testing.T) {
+	// Test that AfterRotate can set a derived value even though RandomPassword
+	// owns only the password
+	ss := &hookSecretSetter{
+		RandomPassword: rotate.RandomPassword{ValidCharset: []rune("x"), PasswordLength: 3},
+	}
+	cur := `{"username":"foo","password":"old","host":"db.local"}`
+	got := createSecret(t, ss, cur)
+	expect := `{"dsn":"foo:xxx@tcp(db.local)/","host":"db.local","password":"xxx","username":"foo"}`
+	if got != expect {
+		t.Errorf("got secret %s, expected %s", got, expect)
+	}
+	if len(ss.calls) != 2 || ss.calls[0] != "BeforeRotate" || ss.calls[1] != "AfterRotate" {
+		t.Errorf("got calls %v, expected [BeforeRotate AfterRotate]", ss.calls)
+	}
+}
+
+func TestFinishHooks(t *testing.T) {
+	// Test that an error from BeforeFinish stops FinishSecret from making
+	// the new secret current
+	updated := false
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString:  &secretString1,
+					VersionId:     aws.String("v1"),
+					VersionStages: []*string{aws.String(rotate.AWSCURRENT)},
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString:  &secretString2,
+					VersionId:     aws.String("v2"),
+					VersionStages: []*string{aws.String(rotate.AWSPENDING)},
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			updated = true
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{}, nil
+		},
+	}
+	ss := &hookSecretSetter{beforeFinish: fmt.Errorf("not ready")}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter:   ss,
+		PasswordSetter: test.MockPasswordSetter{},
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err == nil {
+		t.Error("no error, expected BeforeFinish error")
+	}
+	if updated {
+		t.Error("secret version stage updated, expected no update")
+	}
+
+	// Without error, both hooks are called
+	ss.beforeFinish = nil
+	ss.calls = nil
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Error(err)
+	}
+	if len(ss.calls) != 2 || ss.calls[0] != "BeforeFinish" || ss.calls[1] != "AfterFinish" {
+		t.Errorf("got calls %v, expected [BeforeFinish AfterFinish]", ss.calls)
+	}
+}
+
+type mockStepHooks struct {
+	before error
+	calls  []string
+	errs   []error
+}
+
+func (h *mockStepHooks) BeforeStep(ctx context.Context, step string, event map[string]string) error {
+	h.calls = append(h.calls, "before "+step)
+	return h.before
+}
+
+func (h *mockStepHooks) AfterStep(ctx context.Context, step string, err error) {
+	h.calls = append(h.calls, "after "+step)
+	h.errs = append(h.errs, err)
+}
+
+func TestStepHooks(t *testing.T) {
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+	}
+	hooks := &mockStepHooks{}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{},
+		StepHooks:      hooks,
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "testSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Error(err)
+	}
+	if len(hooks.calls) != 2 || hooks.calls[0] != "before testSecret" || hooks.calls[1] != "after testSecret" {
+		t.Errorf("got calls %v, expected [before testSecret after testSecret]", hooks.calls)
+	}
+	if hooks.errs[0] != nil {
+		t.Errorf("AfterStep got error %v, expected nil", hooks.errs[0])
+	}
+
+	// Error from BeforeStep stops the step, and AfterStep is not called
+	hooks.before = fmt.Errorf("feature flag off")
+	hooks.calls = nil
+	ps := test.MockPasswordSetter{
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			t.Error("VerifyPassword called, expected step not to run")
+			return nil
+		},
+	}
+	r = rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: ps,
+		StepHooks:      hooks,
+	})
+	if _, err := r.Handler(context.TODO(), event); err != hooks.before {
+		t.Errorf("got error %v, expected %v", err, hooks.before)
+	}
+	if len(hooks.calls) != 1 {
+		t.Errorf("got calls %v, expected [before testSecret]", hooks.calls)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// Kubernetes API patch content types.
+const (
+	KUBERNETES_STRATEGIC_MERGE_PATCH = "application/strategic-merge-patch+json"
+	KUBERNETES_MERGE_PATCH           = "application/merge-patch+json"
+)
+
+// KubernetesClient is a minimal Kubernetes API client used by KubernetesRestartNotifier
+// and KubernetesSecretMirror.
+// It makes HTTP requests directly to the API server, so it does not require
+// a Kubernetes client library.
+type KubernetesClient struct {
+	// Server is the API server URL, like "https://ABC.gr7.us-east-1.eks.amazonaws.com".
+	Server string
+
+	// Token returns the bearer token for each request, like a service account
+	// token. For EKS, use EKSToken. If nil, no Authorization header is sent.
+	Token func(ctx context.Context) (string, error)
+
+	// Client is the HTTP client, which must trust the API server CA. If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Patch sends a PATCH request to the API path, like "/api/v1/namespaces/default/secrets/db",
+// with the patch encoded as JSON. contentType is a patch type, like KUBERNETES_MERGE_PATCH.
+func (k *KubernetesClient) Patch(ctx context.Context, path, contentType string, patch interface{}) error {
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	return k.do(ctx, http.MethodPatch, path, contentType, body)
+}
+
+func (k *KubernetesClient) do(ctx context.Context, method, path, contentType string, body []byte) error {
+	url := strings.TrimSuffix(k.Server, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	if k.Token != nil {
+		token, err := k.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("cannot get Kubernetes token: %s", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := k.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s returned %s: %s", method, path, resp.Status, msg)
+	}
+	return nil
+}
+
+// EKSToken returns a KubernetesClient.Token func that makes EKS authentication
+// tokens for the IAM role of the STS client, like "aws eks get-token". The role
+// must be mapped to a Kubernetes user or group in the EKS cluster.
+func EKSToken(stsClient stsiface.STSAPI, clusterName string) func(ctx context.Context) (string, error) {
+	return func(ctx context.Context) (string, error) {
+		req, _ := stsClient.GetCallerIdentityRequest(&sts.GetCallerIdentityInput{})
+		req.HTTPRequest.Header.Add("x-k8s-aws-id", clusterName)
+		url, err := req.Presign(60 * time.Second)
+		if err != nil {
+			return "", err
+		}
+		return "k8s-aws-v1." + base64.RawURLEncoding.EncodeToString([]byte(url)), nil
+	}
+}
+
+// KubernetesSecretMirror is a Mirror that writes the new secret values to the
+// data of a Kubernetes Secret, which must exist. Other data in the Kubernetes
+// Secret is not changed. Keys maps secret keys to Kubernetes Secret data keys,
+// like {"password":"DB_PASSWORD"}; if nil, all secret values are written with
+// the same keys.
+type KubernetesSecretMirror struct {
+	Kubernetes *KubernetesClient
+	Namespace  string
+	Name       string
+	Keys       map[string]string
+}
+
+var _ Mirror = KubernetesSecretMirror{}
+
+func (m KubernetesSecretMirror) Mirror(ctx context.Context, secretId string, curVals, newVals map[string]string) error {
+	data := map[string]string{}
+	for k, v := range mirrorKeys(newVals, m.Keys) {
+		data[k] = base64.StdEncoding.EncodeToString([]byte(v))
+	}
+	patch := map[string]interface{}{"data": data}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/secrets/%s", m.Namespace, m.Name)
+	if err := m.Kubernetes.Patch(ctx, path, KUBERNETES_MERGE_PATCH, patch); err != nil {
+		return fmt.Errorf("cannot update Kubernetes secret %s/%s: %s", m.Namespace, m.Name, err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	crand "crypto/rand"
+	"fmt"
+	"log"
+	"time"
+)
+
+// rotationSteps are the four Secrets Manager rotation steps in order.
+var rotationSteps = []string{"createSecret", "setSecret", "testSecret", "finishSecret"}
+
+// Rotate rotates the secret by running all four steps in order, like Secrets
+// Manager does when it invokes the Lambda function. Use it to rotate without
+// Lambda, like from an ECS task, a CLI, or tests. It does not call the Secrets
+// Manager RotateSecret API, so the secret does not need rotation enabled, but
+// the caller needs the same IAM permissions as the Lambda function.
+//
+// Each step is run by Handler, so everything configured (hooks, state store,
+// locker, events, and so on) works the same. Rotate stops on the first step
+// error and returns it, after the step rolls back if it can. It returns the
+// version ID (client request token) of the new secret, which is also returned
+// on error so the rotation can be inspected or finished (see COMMAND_FORCE_FINISH).
+//
+// A Rotator is not safe for concurrent use: do not call Rotate or Handler from
+// multiple goroutines at the same time.
+func (r *Rotator) Rotate(ctx context.Context, secretId string) (string, error) {
+	t0 := time.Now()
+	token, err := newClientRequestToken()
+	if err != nil {
+		return "", fmt.Errorf("cannot generate client request token: %s", err)
+	}
+	log.Printf("rotating secret %s: version id = %s", secretId, token)
+	for _, step := range rotationSteps {
+		if err := ctx.Err(); err != nil {
+			return token, fmt.Errorf("%s not run: %s", step, err)
+		}
+		event := map[string]string{
+			"Step":               step,
+			"SecretId":           secretId,
+			"ClientRequestToken": token,
+		}
+		if _, err := r.Handler(ctx, event); err != nil {
+			return token, fmt.Errorf("%s: %w", step, err)
+		}
+	}
+	log.Printf("rotated secret %s in %dms", secretId, time.Now().Sub(t0).Milliseconds())
+	return token, nil
+}
+
+// newClientRequestToken returns a random version 4 UUID, which is the format
+// Secrets Manager uses for client request tokens.
+func newClientRequestToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
@@ -0,0 +1,266 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestRotate(t *testing.T) {
+	// Test that Rotate runs all four steps: the new secret is put as pending,
+	// set on the database, and made current
+	stages := map[string]string{rotate.AWSCURRENT: "v1"} // stage => version ID
+	values := map[string]string{"v1": secretString1}     // version ID => secret
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			v, ok := stages[*input.VersionStage]
+			if !ok {
+				return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+			}
+			return &secretsmanager.GetSecretValueOutput{
+				SecretString:  aws.String(values[v]),
+				VersionId:     aws.String(v),
+				VersionStages: []*string{input.VersionStage},
+				CreatedDate:   &now,
+			}, nil
+		},
+		PutSecretValueFunc: func(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+			v := *input.ClientRequestToken
+			values[v] = *input.SecretString
+			stages[rotate.AWSPENDING] = v
+			return &secretsmanager.PutSecretValueOutput{VersionId: aws.String(v)}, nil
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			if input.MoveToVersionId != nil {
+				stages[*input.VersionStage] = *input.MoveToVersionId
+			} else {
+				delete(stages, *input.VersionStage)
+			}
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{}, nil
+		},
+	}
+
+	dbPassword := "p1"
+	var gotSteps []string
+	ps := test.MockPasswordSetter{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			dbPassword = creds.New.Password
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.New.Password != dbPassword {
+				return fmt.Errorf("access denied")
+			}
+			return nil
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: ps,
+		SecretSetter: test.MockSecretSetter{
+			RotateFunc: func(secret map[string]string) error {
+				secret["password"] = "p2"
+				return nil
+			},
+			CredentialsFunc: func(secret map[string]string) (string, string) {
+				return secret["username"], secret["password"]
+			},
+		},
+		EventReceiver: eventRecorder(func(e rotate.Event) {
+			if e.Name == rotate.EVENT_BEGIN_ROTATION || e.Name == rotate.EVENT_END_ROTATION {
+				gotSteps = append(gotSteps, e.Step)
+			}
+		}),
+	})
+
+	version, err := r.Rotate(context.TODO(), "def")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(version) != 36 {
+		t.Errorf("got version ID %q, expected a UUID", version)
+	}
+	if diff := deep.Equal(stages, map[string]string{rotate.AWSCURRENT: version}); diff != nil {
+		t.Error(diff)
+	}
+	if dbPassword != "p2" {
+		t.Errorf("got database password %s, expected p2", dbPassword)
+	}
+	if diff := deep.Equal(gotSteps, []string{"createSecret", "finishSecret"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Another rotation has a different version ID. If a step fails, it stops
+	// and returns the error.
+	ps.SetPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		return fmt.Errorf("set failed")
+	}
+	r = rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: ps,
+	})
+	version2, err := r.Rotate(context.TODO(), "def")
+	if err == nil {
+		t.Error("no error when setSecret fails")
+	}
+	if version2 == version || version2 == "" {
+		t.Errorf("got version ID %q, expected new version ID", version2)
+	}
+	if stages[rotate.AWSCURRENT] != version {
+		t.Errorf("current secret changed to %s after failed rotation", stages[rotate.AWSCURRENT])
+	}
+}
+
+func TestRotateFakeSecretsManager(t *testing.T) {
+	// Test rotation end to end against the stateful fake Secrets Manager: the
+	// new version is current, the old one is previous, and a failed rotation
+	// leaves the secret as it was
+	sm := test.NewFakeSecretsManager()
+	sm.AddSecret("def", map[string]string{"username": "foo", "password": "p1"})
+	sm.AddReplica("def", "us-west-2")
+	sm.ReplicationLag = 1
+
+	dbPassword := "p1"
+	verifyErr := error(nil)
+	ps := test.MockPasswordSetter{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.Current.Password != dbPassword {
+				return fmt.Errorf("access denied")
+			}
+			dbPassword = creds.New.Password
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if verifyErr != nil {
+				return verifyErr
+			}
+			if creds.New.Password != dbPassword {
+				return fmt.Errorf("access denied")
+			}
+			return nil
+		},
+		RollbackFunc: func(ctx context.Context, creds db.NewPassword) error {
+			dbPassword = creds.Current.Password
+			return nil
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: ps,
+		SecretSetter:   rotate.RandomPassword{},
+	})
+
+	version, err := r.Rotate(context.TODO(), "def")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(sm.Stages("def"), map[string]string{rotate.AWSCURRENT: version, rotate.AWSPREVIOUS: "v1"}); diff != nil {
+		t.Error(diff)
+	}
+	if p := sm.Values("def", rotate.AWSCURRENT)["password"]; p != dbPassword || p == "p1" {
+		t.Errorf("current password %q, database password %q, expected same new password", p, dbPassword)
+	}
+
+	// A failed verify rolls back the database and removes the pending version
+	verifyErr = fmt.Errorf("verify failed")
+	version2, err := r.Rotate(context.TODO(), "def")
+	if err == nil {
+		t.Fatal("no error when testSecret fails")
+	}
+	if diff := deep.Equal(sm.Stages("def"), map[string]string{rotate.AWSCURRENT: version, rotate.AWSPREVIOUS: "v1"}); diff != nil {
+		t.Error(diff)
+	}
+	if p := sm.Values("def", rotate.AWSCURRENT)["password"]; p != dbPassword {
+		t.Errorf("database password %q not rolled back to current password %q", dbPassword, p)
+	}
+	if _, err := sm.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String("def"), VersionId: aws.String(version2)}); err != nil {
+		t.Errorf("version %s of failed rotation: %s", version2, err)
+	}
+}
+
+func TestRotateFaults(t *testing.T) {
+	// Test that steps are idempotent when a Secrets Manager call fails after
+	// making the change, like a timeout: Secrets Manager retries the step, and
+	// the retry must not fail or change the database password again
+	sm := test.NewFakeSecretsManager()
+	sm.AddSecret("def", map[string]string{"username": "foo", "password": "p1"})
+	faults := test.NewFaults()
+
+	dbPassword := "p1"
+	nSet := 0
+	ps := test.MockPasswordSetter{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			nSet++
+			if creds.Current.Password != dbPassword && creds.New.Password != dbPassword {
+				return fmt.Errorf("access denied")
+			}
+			dbPassword = creds.New.Password
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.New.Password != dbPassword {
+				return fmt.Errorf("access denied")
+			}
+			return nil
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: test.FaultySecretsManager{SecretsManagerAPI: sm, Faults: faults},
+		PasswordSetter: ps,
+		SecretSetter:   rotate.RandomPassword{},
+	})
+
+	timeout := fmt.Errorf("RequestTimeout")
+	faults.Set("secretsmanager.PutSecretValue", test.Fault{Err: timeout, After: true, Times: 1})
+	faults.Set("secretsmanager.UpdateSecretVersionStage", test.Fault{Err: timeout, After: true, Times: 1})
+
+	token := "00000000-0000-4000-8000-000000000001"
+	steps := []struct {
+		step  string
+		fails bool
+	}{
+		{"createSecret", true}, // PutSecretValue made pending, then timed out
+		{"createSecret", false},
+		{"setSecret", false},
+		{"testSecret", false},
+		{"finishSecret", true}, // made current, then timed out
+		{"finishSecret", false},
+	}
+	for i, s := range steps {
+		event := map[string]string{"Step": s.step, "SecretId": "def", "ClientRequestToken": token}
+		_, err := r.Handler(context.TODO(), event)
+		if s.fails && err == nil {
+			t.Errorf("%d %s: no error, expected injected fault", i, s.step)
+		}
+		if !s.fails && err != nil {
+			t.Errorf("%d %s: %s", i, s.step, err)
+		}
+	}
+
+	if n := faults.Calls("secretsmanager.PutSecretValue"); n != 1 {
+		t.Errorf("PutSecretValue called %d times, expected 1 (retry finds pending secret)", n)
+	}
+	if nSet != 1 {
+		t.Errorf("SetPassword called %d times, expected 1", nSet)
+	}
+	if diff := deep.Equal(sm.Stages("def"), map[string]string{rotate.AWSCURRENT: token, rotate.AWSPREVIOUS: "v1"}); diff != nil {
+		t.Error(diff)
+	}
+	if p := sm.Values("def", rotate.AWSCURRENT)["password"]; p != dbPassword {
+		t.Errorf("current password %q, database password %q, expected same", p, dbPassword)
+	}
+}
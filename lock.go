@@ -0,0 +1,132 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+var (
+	// ErrRotationLocked is returned by CreateSecret if another rotation of the
+	// same secret holds the lock. See Locker.
+	ErrRotationLocked = errors.New("another rotation of the secret holds the rotation lock")
+)
+
+// DEFAULT_LOCK_TTL is how long a DynamoDBLocker lock is held if not unlocked,
+// like when a rotation fails without rolling back.
+const DEFAULT_LOCK_TTL = 1 * time.Hour
+
+// Locker is a distributed lock per secret. If Config.Locker is set, Rotator
+// locks the secret in CreateSecret and unlocks it at the end of FinishSecret
+// or after a rollback, so two concurrent rotations of the same secret (like
+// a scheduled and a manual rotation) cannot interleave. The owner is the
+// ClientRequestToken, which is the same for every step of one rotation.
+//
+// DynamoDBLocker implements this interface.
+type Locker interface {
+	// Lock locks the secret for the owner. It must be idempotent: if the owner
+	// already holds the lock, it returns nil. If another owner holds the lock,
+	// it returns ErrRotationLocked.
+	Lock(ctx context.Context, secretId, owner string) error
+
+	// Unlock unlocks the secret if the owner holds the lock, else it does nothing.
+	Unlock(ctx context.Context, secretId, owner string) error
+}
+
+// DynamoDBLocker is a Locker backed by a DynamoDB table with partition key
+// "SecretId" (string). A lock expires after TTL (DEFAULT_LOCK_TTL if zero) so
+// a failed rotation does not lock the secret forever. Enable DynamoDB TTL on
+// attribute "ExpiresAt" to delete expired locks automatically; it's optional
+// because expired locks are taken over by the next rotation.
+type DynamoDBLocker struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+	TTL      time.Duration
+}
+
+var _ Locker = DynamoDBLocker{}
+
+func (l DynamoDBLocker) Lock(ctx context.Context, secretId, owner string) error {
+	ttl := l.TTL
+	if ttl == 0 {
+		ttl = DEFAULT_LOCK_TTL
+	}
+	now := time.Now()
+	_, err := l.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(l.Table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"SecretId":  {S: aws.String(secretId)},
+			"Owner":     {S: aws.String(owner)},
+			"ExpiresAt": {N: aws.String(strconv.FormatInt(now.Add(ttl).Unix(), 10))},
+		},
+		// Lock if not locked, already locked by owner, or lock expired
+		ConditionExpression: aws.String("attribute_not_exists(SecretId) OR #owner = :owner OR ExpiresAt < :now"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(owner)},
+			":now":   {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrRotationLocked
+	}
+	return err
+}
+
+func (l DynamoDBLocker) Unlock(ctx context.Context, secretId, owner string) error {
+	_, err := l.DynamoDB.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(l.Table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"SecretId": {S: aws.String(secretId)},
+		},
+		ConditionExpression: aws.String("#owner = :owner"),
+		ExpressionAttributeNames: map[string]*string{
+			"#owner": aws.String("Owner"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":owner": {S: aws.String(owner)},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil // not our lock
+	}
+	return err
+}
+
+// --------------------------------------------------------------------------
+
+// lock locks the secret if Config.Locker is set.
+func (r *Rotator) lock(ctx context.Context) error {
+	if r.locker == nil {
+		return nil
+	}
+	if err := r.locker.Lock(ctx, r.secretId, r.clientRequestToken); err != nil {
+		return err
+	}
+	debug("locked secret %s for %s", r.secretId, r.clientRequestToken)
+	return nil
+}
+
+// unlock unlocks the secret if Config.Locker is set. Errors are only logged
+// because the rotation is done (or rolled back) and the lock expires.
+func (r *Rotator) unlock(ctx context.Context) {
+	if r.locker == nil {
+		return
+	}
+	if err := r.locker.Unlock(ctx, r.secretId, r.clientRequestToken); err != nil {
+		log.Printf("ERROR: cannot unlock secret (ignored, lock will expire): %s", err)
+		return
+	}
+	debug("unlocked secret %s for %s", r.secretId, r.clientRequestToken)
+}
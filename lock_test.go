@@ -0,0 +1,242 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type memLocker map[string]string // secret ID => owner
+
+func (l memLocker) Lock(ctx context.Context, secretId, owner string) error {
+	if o, ok := l[secretId]; ok && o != owner {
+		return rotate.ErrRotationLocked
+	}
+	l[secretId] = owner
+	return nil
+}
+
+func (l memLocker) Unlock(ctx context.Context, secretId, owner string) error {
+	if l[secretId] == owner {
+		delete(l, secretId)
+	}
+	return nil
+}
+
+func TestLocker(t *testing.T) {
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			if *input.VersionStage != rotate.AWSCURRENT {
+				return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+			}
+			return &secretsmanager.GetSecretValueOutput{
+				SecretString: &secretString1,
+				VersionId:    aws.String("v1"),
+			}, nil
+		},
+		PutSecretValueFunc: func(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+			return &secretsmanager.PutSecretValueOutput{}, nil
+		},
+	}
+	locker := memLocker{}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{},
+		Locker:         locker,
+	})
+	event := map[string]string{
+		"ClientRequestToken": "t1",
+		"SecretId":           "def",
+		"Step":               "createSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if locker["def"] != "t1" {
+		t.Errorf("secret locked by %q, expected t1", locker["def"])
+	}
+
+	// Retry by same rotation is ok
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Error(err)
+	}
+
+	// Another rotation is locked out
+	event["ClientRequestToken"] = "t2"
+	if _, err := r.Handler(context.TODO(), event); err != rotate.ErrRotationLocked {
+		t.Errorf("got error %v, expected ErrRotationLocked", err)
+	}
+}
+
+type failHooks struct {
+	test.MockSecretSetter
+}
+
+func (s failHooks) BeforeRotate(curVals, newVals map[string]string) error {
+	return errors.New("hook error")
+}
+
+func (s failHooks) AfterRotate(curVals, newVals map[string]string) error {
+	return nil
+}
+
+type failStrategy struct {
+	rotate.SingleUser
+}
+
+func (s failStrategy) Rotate(curVals, newVals map[string]string) error {
+	return errors.New("strategy error")
+}
+
+type rejectPolicy struct{}
+
+func (p rejectPolicy) Validate(password string) error {
+	return errors.New("too weak")
+}
+
+func TestLockerUnlockOnError(t *testing.T) {
+	// Test that CreateSecret unlocks the secret if it fails after locking,
+	// else every retry (new token) is locked out until the lock expires
+	current := func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+		if *input.VersionStage != rotate.AWSCURRENT {
+			return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+		}
+		return &secretsmanager.GetSecretValueOutput{
+			SecretString: &secretString1,
+			VersionId:    aws.String("v1"),
+		}, nil
+	}
+	put := func(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+		return &secretsmanager.PutSecretValueOutput{}, nil
+	}
+	cases := []struct {
+		name string
+		cfg  rotate.Config
+	}{
+		{
+			name: "get current secret",
+			cfg: rotate.Config{
+				SecretsManager: test.MockSecretsManager{
+					GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+						return nil, errors.New("throttled")
+					},
+				},
+			},
+		},
+		{
+			name: "pending conflict",
+			cfg: rotate.Config{
+				SecretsManager: test.MockSecretsManager{
+					GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+						if *input.VersionStage == rotate.AWSPENDING {
+							return &secretsmanager.GetSecretValueOutput{
+								SecretString: &secretString2,
+								VersionId:    aws.String("other"),
+							}, nil
+						}
+						return current(input)
+					},
+				},
+			},
+		},
+		{
+			name: "rotate",
+			cfg: rotate.Config{
+				SecretsManager: test.MockSecretsManager{GetSecretValueFunc: current, PutSecretValueFunc: put},
+				SecretSetter: test.MockSecretSetter{
+					RotateFunc: func(secret map[string]string) error {
+						return errors.New("rotate error")
+					},
+				},
+			},
+		},
+		{
+			name: "rotate hook",
+			cfg: rotate.Config{
+				SecretsManager: test.MockSecretsManager{GetSecretValueFunc: current, PutSecretValueFunc: put},
+				SecretSetter:   failHooks{},
+			},
+		},
+		{
+			name: "strategy",
+			cfg: rotate.Config{
+				SecretsManager:   test.MockSecretsManager{GetSecretValueFunc: current, PutSecretValueFunc: put},
+				RotationStrategy: failStrategy{},
+			},
+		},
+		{
+			name: "password policy",
+			cfg: rotate.Config{
+				SecretsManager: test.MockSecretsManager{GetSecretValueFunc: current, PutSecretValueFunc: put},
+				PasswordPolicy: rejectPolicy{},
+			},
+		},
+		{
+			name: "put secret",
+			cfg: rotate.Config{
+				SecretsManager: test.MockSecretsManager{
+					GetSecretValueFunc: current,
+					PutSecretValueFunc: func(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+						return nil, errors.New("throttled")
+					},
+				},
+			},
+		},
+	}
+	for _, c := range cases {
+		locker := memLocker{}
+		c.cfg.PasswordSetter = test.MockPasswordSetter{}
+		c.cfg.Locker = locker
+		r := rotate.NewRotator(c.cfg)
+		event := map[string]string{
+			"ClientRequestToken": "t1",
+			"SecretId":           "def",
+			"Step":               "createSecret",
+		}
+		if _, err := r.Handler(context.TODO(), event); err == nil {
+			t.Errorf("%s: no error, expected one", c.name)
+		}
+		if owner, ok := locker["def"]; ok {
+			t.Errorf("%s: secret locked by %q after error, expected unlocked", c.name, owner)
+		}
+	}
+}
+
+type lockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	input *dynamodb.PutItemInput
+	err   error
+}
+
+func (m *lockDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	m.input = input
+	return &dynamodb.PutItemOutput{}, m.err
+}
+
+func TestDynamoDBLocker(t *testing.T) {
+	m := &lockDynamoDB{}
+	l := rotate.DynamoDBLocker{DynamoDB: m, Table: "locks"}
+	if err := l.Lock(context.TODO(), "def", "t1"); err != nil {
+		t.Fatal(err)
+	}
+	if m.input.ConditionExpression == nil || *m.input.Item["Owner"].S != "t1" {
+		t.Errorf("lock not conditional or wrong owner: %+v", m.input)
+	}
+
+	m.err = awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "condition failed", nil)
+	if err := l.Lock(context.TODO(), "def", "t2"); err != rotate.ErrRotationLocked {
+		t.Errorf("got error %v, expected ErrRotationLocked", err)
+	}
+}
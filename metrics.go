@@ -0,0 +1,268 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// DEFAULT_METRICS_BUCKETS are the histogram buckets, in seconds, used by Metrics
+// if none are specified.
+var DEFAULT_METRICS_BUCKETS = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Metrics is an in-memory metrics registry for a Rotator that is embedded in a
+// long-running service (not Lambda). It is an EventReceiver, so set it as
+// Config.EventReceiver, and a db.HostObserver, so set it as mysql.Config.Observer
+// to observe per-host password actions. It is also an http.Handler that writes
+// all metrics in the Prometheus text exposition format; mount it on the service's
+// HTTP server, usually at /metrics.
+//
+// Create a Metrics by calling NewMetrics. Metrics tracks the one rotation in
+// progress, so use one Metrics per Rotator. It is safe for concurrent use by multiple goroutines.
+type Metrics struct {
+	// Namespace is prefixed to all metric names. If not set, "password_rotation"
+	// is used.
+	Namespace string
+
+	// --
+	buckets          []float64
+	mux              *sync.Mutex
+	rotations        uint64
+	rotationsDone    uint64
+	rollbacks        uint64
+	failures         map[string]uint64 // keyed on step
+	rotationTime     *histogram
+	passwordTime     *histogram
+	verificationTime *histogram
+	downtime         *histogram
+	hosts            map[string]*histogram // keyed on hostname + action
+	hostFailures     map[string]uint64     // keyed on hostname + action
+	rotationStart    time.Time
+	passwordStart    time.Time
+	verifyStart      time.Time
+}
+
+var _ EventReceiver = &Metrics{}
+var _ db.HostObserver = &Metrics{}
+var _ http.Handler = &Metrics{}
+
+// NewMetrics creates a new Metrics with the given histogram buckets in seconds.
+// If no buckets are given, DEFAULT_METRICS_BUCKETS is used.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DEFAULT_METRICS_BUCKETS
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		Namespace:        "password_rotation",
+		buckets:          buckets,
+		mux:              &sync.Mutex{},
+		failures:         map[string]uint64{},
+		rotationTime:     newHistogram(buckets),
+		passwordTime:     newHistogram(buckets),
+		verificationTime: newHistogram(buckets),
+		downtime:         newHistogram(buckets),
+		hosts:            map[string]*histogram{},
+		hostFailures:     map[string]uint64{},
+	}
+}
+
+// Receive counts rotations, rollbacks, and failures, and times the rotation,
+// password rotation (setSecret), password verification (testSecret), and
+// password downtime (Event.Duration).
+func (m *Metrics) Receive(e Event) {
+	now := time.Now()
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	switch e.Name {
+	case EVENT_BEGIN_ROTATION:
+		m.rotations++
+		m.rotationStart = now
+	case EVENT_END_ROTATION:
+		m.rotationsDone++
+		observeSince(m.rotationTime, &m.rotationStart, now, e.Duration)
+	case EVENT_BEGIN_PASSWORD_ROTATION:
+		m.passwordStart = now
+	case EVENT_END_PASSWORD_ROTATION:
+		observeSince(m.passwordTime, &m.passwordStart, now, e.Duration)
+	case EVENT_BEGIN_PASSWORD_VERIFICATION:
+		m.verifyStart = now
+	case EVENT_END_PASSWORD_VERIFICATION:
+		observeSince(m.verificationTime, &m.verifyStart, now, e.Duration)
+	case EVENT_NEW_PASSWORD_IS_CURRENT:
+		if e.Duration > 0 {
+			m.downtime.observe(e.Duration)
+		}
+	case EVENT_BEGIN_PASSWORD_ROLLBACK:
+		m.rollbacks++
+	case EVENT_ERROR:
+		m.failures[e.Step]++
+	}
+}
+
+// observeSince observes the event duration d, if set, else the time since start,
+// if set. It resets start.
+func observeSince(h *histogram, start *time.Time, now time.Time, d time.Duration) {
+	if d == 0 && !start.IsZero() {
+		d = now.Sub(*start)
+	}
+	if d > 0 {
+		h.observe(d)
+	}
+	*start = time.Time{}
+}
+
+// ObserveHost records the duration of one password action on one database host,
+// and counts the action as failed if err is not nil.
+func (m *Metrics) ObserveHost(hostname, action string, d time.Duration, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	key := hostname + "\x00" + action
+	h, ok := m.hosts[key]
+	if !ok {
+		h = newHistogram(m.buckets)
+		m.hosts[key] = h
+	}
+	h.observe(d)
+	if err != nil {
+		m.hostFailures[key]++
+	}
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format to w.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	ns := m.Namespace
+	if ns == "" {
+		ns = "password_rotation"
+	}
+	var b strings.Builder
+
+	writeHeader(&b, ns+"_rotations_total", "counter", "Number of rotations started.")
+	fmt.Fprintf(&b, "%s_rotations_total %d\n", ns, m.rotations)
+
+	writeHeader(&b, ns+"_rotations_completed_total", "counter", "Number of rotations completed.")
+	fmt.Fprintf(&b, "%s_rotations_completed_total %d\n", ns, m.rotationsDone)
+
+	writeHeader(&b, ns+"_rollbacks_total", "counter", "Number of password rollbacks started.")
+	fmt.Fprintf(&b, "%s_rollbacks_total %d\n", ns, m.rollbacks)
+
+	writeHeader(&b, ns+"_failures_total", "counter", "Number of failed rotation steps.")
+	for _, step := range sortedKeys(m.failures) {
+		fmt.Fprintf(&b, "%s_failures_total{step=%q} %d\n", ns, step, m.failures[step])
+	}
+
+	writeHeader(&b, ns+"_rotation_duration_seconds", "histogram", "Duration of complete rotations, from createSecret to finishSecret.")
+	m.rotationTime.write(&b, ns+"_rotation_duration_seconds", "")
+
+	writeHeader(&b, ns+"_password_rotation_duration_seconds", "histogram", "Duration of setting the new password on all databases.")
+	m.passwordTime.write(&b, ns+"_password_rotation_duration_seconds", "")
+
+	writeHeader(&b, ns+"_password_verification_duration_seconds", "histogram", "Duration of verifying the new password on all databases.")
+	m.verificationTime.write(&b, ns+"_password_verification_duration_seconds", "")
+
+	writeHeader(&b, ns+"_password_downtime_seconds", "histogram", "Time from setting the new password on the databases to making it current in Secrets Manager.")
+	m.downtime.write(&b, ns+"_password_downtime_seconds", "")
+
+	hostKeys := make([]string, 0, len(m.hosts))
+	for k := range m.hosts {
+		hostKeys = append(hostKeys, k)
+	}
+	sort.Strings(hostKeys)
+
+	writeHeader(&b, ns+"_host_duration_seconds", "histogram", "Duration of password actions on one database host.")
+	for _, k := range hostKeys {
+		m.hosts[k].write(&b, ns+"_host_duration_seconds", hostLabels(k))
+	}
+
+	writeHeader(&b, ns+"_host_failures_total", "counter", "Number of failed password actions on one database host.")
+	for _, k := range hostKeys {
+		fmt.Fprintf(&b, "%s_host_failures_total{%s} %d\n", ns, hostLabels(k), m.hostFailures[k])
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// --------------------------------------------------------------------------
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64 // cumulative, same len as buckets
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+	for i, le := range h.buckets {
+		if v <= le {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *histogram) write(b *strings.Builder, name, labels string) {
+	sep := ""
+	if labels != "" {
+		sep = ","
+	}
+	for i, le := range h.buckets {
+		fmt.Fprintf(b, "%s_bucket{%s%sle=\"%s\"} %d\n", name, labels, sep, formatFloat(le), h.counts[i])
+	}
+	fmt.Fprintf(b, "%s_bucket{%s%sle=\"+Inf\"} %d\n", name, labels, sep, h.count)
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	fmt.Fprintf(b, "%s_sum%s %s\n", name, labels, formatFloat(h.sum))
+	fmt.Fprintf(b, "%s_count%s %d\n", name, labels, h.count)
+}
+
+func writeHeader(b *strings.Builder, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func hostLabels(key string) string {
+	f := strings.SplitN(key, "\x00", 2)
+	return fmt.Sprintf("hostname=%q,action=%q", f[0], f[1])
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys(m map[string]uint64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
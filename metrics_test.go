@@ -0,0 +1,66 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+func TestMetrics(t *testing.T) {
+	// Test that Metrics counts events and host observations and writes them
+	// in the Prometheus text format when served over HTTP
+	m := rotate.NewMetrics(1, 10)
+
+	for _, name := range []string{
+		rotate.EVENT_BEGIN_ROTATION,
+		rotate.EVENT_BEGIN_PASSWORD_ROTATION,
+		rotate.EVENT_END_PASSWORD_ROTATION,
+		rotate.EVENT_BEGIN_PASSWORD_VERIFICATION,
+		rotate.EVENT_END_PASSWORD_VERIFICATION,
+		rotate.EVENT_NEW_PASSWORD_IS_CURRENT,
+		rotate.EVENT_END_ROTATION,
+	} {
+		m.Receive(rotate.Event{Name: name, Time: time.Now()})
+	}
+	m.Receive(rotate.Event{Name: rotate.EVENT_BEGIN_ROTATION, Step: "createSecret", Time: time.Now()})
+	m.Receive(rotate.Event{Name: rotate.EVENT_BEGIN_PASSWORD_ROLLBACK, Step: "setSecret", Time: time.Now()})
+	m.Receive(rotate.Event{Name: rotate.EVENT_ERROR, Step: "setSecret", Time: time.Now(), Error: errors.New("fail")})
+
+	m.ObserveHost("db1", "setting", 2*time.Second, nil)
+	m.ObserveHost("db1", "setting", 20*time.Second, errors.New("fail"))
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	bytes, _ := io.ReadAll(rec.Body)
+	got := string(bytes)
+
+	expect := []string{
+		"password_rotation_rotations_total 2\n",
+		"password_rotation_rotations_completed_total 1\n",
+		"password_rotation_rollbacks_total 1\n",
+		"password_rotation_failures_total{step=\"setSecret\"} 1\n",
+		"password_rotation_rotation_duration_seconds_count 1\n",
+		"password_rotation_password_rotation_duration_seconds_count 1\n",
+		"password_rotation_password_verification_duration_seconds_count 1\n",
+		"password_rotation_host_duration_seconds_bucket{hostname=\"db1\",action=\"setting\",le=\"1\"} 0\n",
+		"password_rotation_host_duration_seconds_bucket{hostname=\"db1\",action=\"setting\",le=\"10\"} 1\n",
+		"password_rotation_host_duration_seconds_bucket{hostname=\"db1\",action=\"setting\",le=\"+Inf\"} 2\n",
+		"password_rotation_host_duration_seconds_sum{hostname=\"db1\",action=\"setting\"} 22\n",
+		"password_rotation_host_failures_total{hostname=\"db1\",action=\"setting\"} 1\n",
+	}
+	for _, line := range expect {
+		if !strings.Contains(got, line) {
+			t.Errorf("metric not found: %s", line)
+		}
+	}
+	if t.Failed() {
+		t.Log(got)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"errors"
+	"log"
+)
+
+// StepFunc runs one Secrets Manager rotation step: "createSecret", "setSecret",
+// "testSecret", or "finishSecret". event is the Secrets Manager event.
+type StepFunc func(ctx context.Context, step string, event map[string]string) error
+
+// Middleware wraps a StepFunc to add behavior before and after the step, like
+// tracing, timing, or auditing. It calls next to run the step (and the rest
+// of the chain), or returns an error without calling next to skip the step.
+// For example:
+//
+//	r.Use(func(next rotate.StepFunc) rotate.StepFunc {
+//		return func(ctx context.Context, step string, event map[string]string) error {
+//			t0 := time.Now()
+//			err := next(ctx, step, event)
+//			log.Printf("%s took %s", step, time.Since(t0))
+//			return err
+//		}
+//	})
+type Middleware func(next StepFunc) StepFunc
+
+// Use adds middleware to run every step. Middleware runs in the order added:
+// the first added is the outermost. All middleware runs after Handler has
+// initialized the SecretSetter and PasswordSetter, and around Config.StepHooks
+// and Config.StateStore, which are middleware, too. Use must not be called
+// while Handler is running.
+func (r *Rotator) Use(mw ...Middleware) {
+	r.middleware = append(r.middleware, mw...)
+}
+
+// chain returns the StepFunc that runs the step with all middleware.
+func (r *Rotator) chain() StepFunc {
+	next := r.runStep
+	next = r.stateMiddleware(next)
+	if r.stepHooks != nil {
+		next = stepHooksMiddleware(r.stepHooks)(next)
+	}
+	if r.cleanupOnFailure {
+		next = r.cleanupMiddleware(next)
+	}
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		next = r.middleware[i](next)
+	}
+	return next
+}
+
+// runStep runs the step. It's the end of the chain.
+func (r *Rotator) runStep(ctx context.Context, step string, event map[string]string) error {
+	switch step {
+	case "createSecret":
+		return r.CreateSecret(ctx, event)
+	case "setSecret":
+		return r.SetSecret(ctx, event)
+	case "testSecret":
+		return r.TestSecret(ctx, event)
+	case "finishSecret":
+		return r.FinishSecret(ctx, event)
+	}
+	return ErrInvalidStep
+}
+
+// stateMiddleware saves the rotation state after the step. A save error is
+// returned only if the step succeeded.
+func (r *Rotator) stateMiddleware(next StepFunc) StepFunc {
+	return func(ctx context.Context, step string, event map[string]string) error {
+		err := next(ctx, step, event)
+		if serr := r.saveState(ctx, step, err); err == nil {
+			err = serr
+		}
+		return err
+	}
+}
+
+// stepHooksMiddleware calls the StepHooks before and after the step.
+func stepHooksMiddleware(hooks StepHooks) Middleware {
+	return func(next StepFunc) StepFunc {
+		return func(ctx context.Context, step string, event map[string]string) error {
+			if err := hooks.BeforeStep(ctx, step, event); err != nil {
+				log.Printf("%s not run: BeforeStep error: %s", step, err)
+				return err
+			}
+			err := next(ctx, step, event)
+			hooks.AfterStep(ctx, step, err)
+			return err
+		}
+	}
+}
+
+// cleanupMiddleware removes the pending secret if setSecret or testSecret fails
+// without rolling back. See Config.CleanupOnFailure.
+func (r *Rotator) cleanupMiddleware(next StepFunc) StepFunc {
+	return func(ctx context.Context, step string, event map[string]string) error {
+		err := next(ctx, step, event)
+		if err == nil || (step != "setSecret" && step != "testSecret") {
+			return err
+		}
+		switch {
+		case errors.Is(err, ErrSetPasswordFailed), errors.Is(err, ErrVerifyFailed), errors.Is(err, ErrCanaryFailed),
+			errors.Is(err, ErrRotationVetoed):
+			return err // pending secret already removed
+		case errors.Is(err, ErrRollbackFailed):
+			log.Printf("ERROR: not removing pending secret because rollback failed")
+			return err
+		case errors.Is(err, ErrInterrupted):
+			log.Printf("not removing pending secret because retry resumes setting it")
+			return err
+		}
+		if cerr := r.cleanupPending(ctx); cerr != nil {
+			log.Printf("ERROR: cannot remove pending secret after %s failed: %s", step, cerr)
+		}
+		return err
+	}
+}
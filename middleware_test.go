@@ -0,0 +1,102 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestMiddleware(t *testing.T) {
+	// Test that middleware runs in the order added, around StepHooks, and can
+	// skip the step by not calling next
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+	}
+	var calls []string
+	var stop error
+	ps := test.MockPasswordSetter{
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			calls = append(calls, "step")
+			return nil
+		},
+	}
+	hooks := &mockStepHooks{}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: ps,
+		StepHooks:      hooks,
+	})
+	mw := func(name string) rotate.Middleware {
+		return func(next rotate.StepFunc) rotate.StepFunc {
+			return func(ctx context.Context, step string, event map[string]string) error {
+				calls = append(calls, name+" before "+step)
+				if stop != nil {
+					return stop
+				}
+				err := next(ctx, step, event)
+				calls = append(calls, fmt.Sprintf("%s after %v", name, err))
+				return err
+			}
+		}
+	}
+	r.Use(mw("m1"), mw("m2"))
+
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "testSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"m1 before testSecret",
+		"m2 before testSecret",
+		"step",
+		"m2 after <nil>",
+		"m1 after <nil>",
+	}
+	if diff := deep.Equal(calls, expect); diff != nil {
+		t.Error(diff)
+	}
+	if diff := deep.Equal(hooks.calls, []string{"before testSecret", "after testSecret"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Middleware that does not call next skips the step, and Handler returns its error
+	calls = nil
+	hooks.calls = nil
+	stop = fmt.Errorf("stop")
+	if _, err := r.Handler(context.TODO(), event); err != stop {
+		t.Errorf("got error %v, expected %v", err, stop)
+	}
+	if diff := deep.Equal(calls, []string{"m1 before testSecret"}); diff != nil {
+		t.Error(diff)
+	}
+	if hooks.calls != nil {
+		t.Errorf("StepHooks called: %v", hooks.calls)
+	}
+}
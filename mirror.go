@@ -0,0 +1,213 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+)
+
+// Mirror copies the new secret to another store, like a Kubernetes Secret, for
+// consumers that do not read Secrets Manager. Set Config.Mirrors to enable.
+//
+// Mirrors are called in order by finishSecret right after it makes the new
+// secret current, before waiting for replication. curVals are the previous
+// secret values and newVals are the new secret values. Neither can be changed.
+// A Mirror must not restore curVals on error because the databases already
+// use the new password. Instead, an error is logged, the other mirrors are
+// still called, and then finishSecret fails so that Secrets Manager retries it.
+// On finishSecret retry, mirrors are called again, so Mirror must be idempotent.
+//
+// ConsulKVMirror, KubernetesSecretMirror, SSMParameterMirror, and VaultKVMirror
+// implement this interface.
+type Mirror interface {
+	Mirror(ctx context.Context, secretId string, curVals, newVals map[string]string) error
+}
+
+// mirrorKeys returns the secret values to mirror: all values if keys is nil,
+// else only the keys, renamed to the map values.
+func mirrorKeys(secret, keys map[string]string) map[string]string {
+	if keys == nil {
+		return secret
+	}
+	vals := make(map[string]string, len(keys))
+	for from, to := range keys {
+		if v, ok := secret[from]; ok {
+			vals[to] = v
+		}
+	}
+	return vals
+}
+
+// SSMParameterMirror is a Mirror that writes secret values to SSM Parameter
+// Store parameters, for consumers that only read Parameter Store. Parameters
+// maps secret keys to parameter names, like {"password":"/prod/db/password"}.
+// Parameters are written as SecureString, encrypted with KeyId if set, else
+// the AWS managed key for SSM.
+//
+// If writing a parameter fails, the error is returned and the parameters
+// already written keep the new values, which the databases use. finishSecret
+// retry writes all parameters again.
+type SSMParameterMirror struct {
+	SSM        ssmiface.SSMAPI
+	Parameters map[string]string
+	KeyId      string
+}
+
+var _ Mirror = SSMParameterMirror{}
+
+func (m SSMParameterMirror) Mirror(ctx context.Context, secretId string, curVals, newVals map[string]string) error {
+	// Write in order of parameter name so errors are deterministic
+	keys := make([]string, 0, len(m.Parameters))
+	for k := range m.Parameters {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return m.Parameters[keys[i]] < m.Parameters[keys[j]] })
+
+	for _, k := range keys {
+		v, ok := newVals[k]
+		if !ok {
+			continue // key not in secret; nothing to mirror
+		}
+		if err := m.put(ctx, m.Parameters[k], v); err != nil {
+			return fmt.Errorf("cannot put SSM parameter %s: %s", m.Parameters[k], err)
+		}
+	}
+	return nil
+}
+
+func (m SSMParameterMirror) put(ctx context.Context, name, value string) error {
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(name),
+		Value:     aws.String(value),
+		Type:      aws.String(ssm.ParameterTypeSecureString),
+		Overwrite: aws.Bool(true),
+	}
+	if m.KeyId != "" {
+		input.KeyId = aws.String(m.KeyId)
+	}
+	_, err := m.SSM.PutParameterWithContext(ctx, input)
+	return err
+}
+
+// ConsulKVMirror is a Mirror that writes secret values to Consul KV keys, for
+// on-prem consumers that read Consul. Keys maps secret keys to Consul keys,
+// like {"password":"db/prod/password"}; if nil, all secret values are written
+// with the same keys. All keys are written in one Consul
+// transaction, so either all or none are changed. Token is the Consul ACL
+// token, which needs write access to the keys; if empty, no token is sent.
+type ConsulKVMirror struct {
+	Address string // like "https://consul.local:8501"
+	Token   string
+	Keys    map[string]string
+	Client  *http.Client // http.DefaultClient if nil
+}
+
+var _ Mirror = ConsulKVMirror{}
+
+func (m ConsulKVMirror) Mirror(ctx context.Context, secretId string, curVals, newVals map[string]string) error {
+	type kvOp struct {
+		Verb  string
+		Key   string
+		Value string
+	}
+	txn := []map[string]kvOp{}
+	vals := mirrorKeys(newVals, m.Keys)
+	keys := make([]string, 0, len(vals))
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		txn = append(txn, map[string]kvOp{
+			"KV": {Verb: "set", Key: k, Value: base64.StdEncoding.EncodeToString([]byte(vals[k]))},
+		})
+	}
+	body, err := json.Marshal(txn)
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	if m.Token != "" {
+		header.Set("X-Consul-Token", m.Token)
+	}
+	url := strings.TrimSuffix(m.Address, "/") + "/v1/txn"
+	if err := sendJSON(ctx, httpClient(m.Client), http.MethodPut, url, header, body); err != nil {
+		return fmt.Errorf("cannot set Consul keys: %s", err)
+	}
+	return nil
+}
+
+// VaultKVMirror is a Mirror that writes secret values to a Vault KV version 2
+// secret, for on-prem consumers that read Vault. Keys maps secret keys to Vault
+// secret keys; if nil, all secret values are written with the same keys. Each
+// rotation writes a new version of the Vault secret with only the mirrored
+// keys, so other keys in the Vault secret are not kept. Token is the Vault
+// token, which needs create and update capabilities on the secret.
+type VaultKVMirror struct {
+	Address string // like "https://vault.local:8200"
+	Token   string
+	Mount   string // KV mount path, like "secret"
+	Path    string // secret path in the mount, like "db/prod"
+	Keys    map[string]string
+	Client  *http.Client // http.DefaultClient if nil
+}
+
+var _ Mirror = VaultKVMirror{}
+
+func (m VaultKVMirror) Mirror(ctx context.Context, secretId string, curVals, newVals map[string]string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"data": mirrorKeys(newVals, m.Keys),
+	})
+	if err != nil {
+		return err
+	}
+	header := http.Header{}
+	header.Set("X-Vault-Token", m.Token)
+	url := fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimSuffix(m.Address, "/"), strings.Trim(m.Mount, "/"), strings.Trim(m.Path, "/"))
+	if err := sendJSON(ctx, httpClient(m.Client), http.MethodPost, url, header, body); err != nil {
+		return fmt.Errorf("cannot write Vault secret %s/%s: %s", m.Mount, m.Path, err)
+	}
+	return nil
+}
+
+// httpClient returns c, or http.DefaultClient if c is nil.
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+	return c
+}
+
+// --------------------------------------------------------------------------
+
+// mirror calls the mirrors. It's called by finishSecret. It returns an error
+// if any mirror fails, after calling all the mirrors.
+func (r *Rotator) mirror(ctx context.Context, curVals, newVals map[string]string) error {
+	failed := []string{}
+	for _, m := range r.mirrors {
+		t0 := time.Now()
+		if err := m.Mirror(ctx, r.secretId, curVals, newVals); err != nil {
+			msg := Redact(err.Error())
+			log.Printf("ERROR: mirror %T: %s", m, msg)
+			failed = append(failed, fmt.Sprintf("%T: %s", m, msg))
+			continue
+		}
+		debug("mirror %T: %dms", m, time.Now().Sub(t0).Milliseconds())
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d mirrors failed: %s", len(failed), len(r.mirrors), strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type mirrorFunc func(ctx context.Context, secretId string, curVals, newVals map[string]string) error
+
+func (f mirrorFunc) Mirror(ctx context.Context, secretId string, curVals, newVals map[string]string) error {
+	return f(ctx, secretId, curVals, newVals)
+}
+
+func TestMirrors(t *testing.T) {
+	// Test that mirrors are called with the current and new secret values
+	// when finishSecret makes the new secret current, and an error does not
+	// stop the other mirrors but fails finishSecret so that it's retried
+	var moved bool
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			if *input.VersionStage == rotate.AWSCURRENT {
+				moved = true
+			}
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{}, nil
+		},
+	}
+	var got []string
+	mirror := func(name string, err error) rotate.Mirror {
+		return mirrorFunc(func(ctx context.Context, secretId string, curVals, newVals map[string]string) error {
+			if !moved {
+				t.Errorf("%s called before new secret is current", name)
+			}
+			got = append(got, fmt.Sprintf("%s %s %s %s", name, secretId, curVals["password"], newVals["password"]))
+			return err
+		})
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{},
+		Mirrors:        []rotate.Mirror{mirror("m1", fmt.Errorf("down")), mirror("m2", nil)},
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err == nil {
+		t.Error("no error, expected mirror m1 error")
+	}
+	if diff := deep.Equal(got, []string{"m1 def p1 p2", "m2 def p1 p2"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestKubernetesSecretMirror(t *testing.T) {
+	var gotPath, gotType string
+	var gotPatch map[string]map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotType = r.URL.Path, r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&gotPatch)
+	}))
+	defer ts.Close()
+
+	newVals := map[string]string{"username": "foo", "password": "p2"}
+
+	// All keys when Keys is nil
+	m := rotate.KubernetesSecretMirror{
+		Kubernetes: &rotate.KubernetesClient{Server: ts.URL},
+		Namespace:  "prod",
+		Name:       "db",
+	}
+	if err := m.Mirror(context.TODO(), "def", nil, newVals); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal([]string{gotPath, gotType}, []string{"/api/v1/namespaces/prod/secrets/db", rotate.KUBERNETES_MERGE_PATCH}); diff != nil {
+		t.Error(diff)
+	}
+	expect := map[string]map[string]string{
+		"data": {"username": "Zm9v", "password": "cDI="},
+	}
+	if diff := deep.Equal(gotPatch, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Only and renamed keys when Keys is set
+	gotPatch = nil
+	m.Keys = map[string]string{"password": "DB_PASSWORD"}
+	if err := m.Mirror(context.TODO(), "def", nil, newVals); err != nil {
+		t.Fatal(err)
+	}
+	expect = map[string]map[string]string{
+		"data": {"DB_PASSWORD": "cDI="},
+	}
+	if diff := deep.Equal(gotPatch, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+type mockSSM struct {
+	ssmiface.SSMAPI
+	fail string
+	puts []string
+}
+
+func (m *mockSSM) PutParameterWithContext(ctx aws.Context, input *ssm.PutParameterInput, opts ...request.Option) (*ssm.PutParameterOutput, error) {
+	m.puts = append(m.puts, *input.Name+"="+*input.Value)
+	if *input.Name == m.fail {
+		return nil, fmt.Errorf("throttled")
+	}
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func TestSSMParameterMirror(t *testing.T) {
+	curVals := map[string]string{"username": "foo", "password": "p1"}
+	newVals := map[string]string{"username": "foo", "password": "p2"}
+	params := map[string]string{
+		"password": "/db/password",
+		"username": "/db/username",
+	}
+
+	m := &mockSSM{}
+	mirror := rotate.SSMParameterMirror{SSM: m, Parameters: params}
+	if err := mirror.Mirror(context.TODO(), "def", curVals, newVals); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(m.puts, []string{"/db/password=p2", "/db/username=foo"}); diff != nil {
+		t.Error(diff)
+	}
+
+	// Failure on the second parameter does not restore the first to the old
+	// password, which no longer works on the databases
+	m = &mockSSM{fail: "/db/username"}
+	mirror.SSM = m
+	if err := mirror.Mirror(context.TODO(), "def", curVals, newVals); err == nil {
+		t.Error("no error, expected put error")
+	}
+	if diff := deep.Equal(m.puts, []string{"/db/password=p2", "/db/username=foo"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestConsulKVMirror(t *testing.T) {
+	var gotMethod, gotPath, gotToken string
+	var gotTxn []map[string]map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotToken = r.Method, r.URL.Path, r.Header.Get("X-Consul-Token")
+		json.NewDecoder(r.Body).Decode(&gotTxn)
+	}))
+	defer ts.Close()
+
+	m := rotate.ConsulKVMirror{
+		Address: ts.URL,
+		Token:   "tok",
+		Keys: map[string]string{
+			"password": "db/prod/password",
+			"username": "db/prod/username",
+		},
+	}
+	newVals := map[string]string{"username": "foo", "password": "p2", "v": "2"}
+	if err := m.Mirror(context.TODO(), "def", nil, newVals); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal([]string{gotMethod, gotPath, gotToken}, []string{"PUT", "/v1/txn", "tok"}); diff != nil {
+		t.Error(diff)
+	}
+	expect := []map[string]map[string]string{
+		{"KV": {"Verb": "set", "Key": "db/prod/password", "Value": "cDI="}},
+		{"KV": {"Verb": "set", "Key": "db/prod/username", "Value": "Zm9v"}},
+	}
+	if diff := deep.Equal(gotTxn, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestVaultKVMirror(t *testing.T) {
+	var gotMethod, gotPath, gotToken string
+	var gotBody map[string]map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotToken = r.Method, r.URL.Path, r.Header.Get("X-Vault-Token")
+		json.NewDecoder(r.Body).Decode(&gotBody)
+		if r.URL.Path == "/v1/secret/data/denied" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer ts.Close()
+
+	m := rotate.VaultKVMirror{
+		Address: ts.URL,
+		Token:   "tok",
+		Mount:   "secret",
+		Path:    "db/prod",
+		Keys:    map[string]string{"password": "password"},
+	}
+	newVals := map[string]string{"username": "foo", "password": "p2"}
+	if err := m.Mirror(context.TODO(), "def", nil, newVals); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal([]string{gotMethod, gotPath, gotToken}, []string{"POST", "/v1/secret/data/db/prod", "tok"}); diff != nil {
+		t.Error(diff)
+	}
+	expect := map[string]map[string]string{"data": {"password": "p2"}}
+	if diff := deep.Equal(gotBody, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	m.Path = "denied"
+	if err := m.Mirror(context.TODO(), "def", nil, newVals); err == nil {
+		t.Error("no error, expected 403 error")
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// DEFAULT_MULTI_RECEIVER_TIMEOUT is the MultiReceiver timeout if none is given.
+const DEFAULT_MULTI_RECEIVER_TIMEOUT = 5 * time.Second
+
+// MultiReceiver is an EventReceiver that sends every event to multiple receivers,
+// because Config takes only one EventReceiver. Receivers are isolated from each
+// other:
+//
+//   - Receivers are called concurrently, so a slow receiver does not delay the others.
+//   - A receiver that panics is logged; the panic does not reach the other receivers
+//     or the rotation.
+//   - Receive returns when all receivers return or after the timeout, whichever is
+//     first. A receiver that times out is logged and keeps running, but it does not
+//     receive more events until it returns; those events are dropped for it (and
+//     logged) so that each receiver receives events in order.
+//
+// If a receiver is a VetoEventReceiver, ReceiveVeto calls its ReceiveVeto, and
+// any veto stops the rotation. A veto receiver that panics or times out vetoes,
+// because a compliance check that cannot answer should not be assumed to pass.
+// Flush flushes every receiver with a Flush method, like an AsyncReceiver.
+//
+// Create a MultiReceiver by calling NewMultiReceiver. It is safe for concurrent
+// use by multiple goroutines.
+type MultiReceiver struct {
+	timeout time.Duration
+	lanes   []multiLane
+}
+
+var _ VetoEventReceiver = &MultiReceiver{}
+
+// multiLane is one receiver. busy has one slot that is held while the receiver
+// is called, so a receiver that timed out is not called again until it returns.
+type multiLane struct {
+	r    EventReceiver
+	busy chan struct{}
+}
+
+// NewMultiReceiver creates a new MultiReceiver that sends events to the receivers.
+// timeout is how long Receive and ReceiveVeto wait for each receiver. If zero,
+// DEFAULT_MULTI_RECEIVER_TIMEOUT is used. Nil receivers are ignored.
+func NewMultiReceiver(timeout time.Duration, receivers ...EventReceiver) *MultiReceiver {
+	if timeout == 0 {
+		timeout = DEFAULT_MULTI_RECEIVER_TIMEOUT
+	}
+	m := &MultiReceiver{
+		timeout: timeout,
+		lanes:   make([]multiLane, 0, len(receivers)),
+	}
+	for _, r := range receivers {
+		if r == nil {
+			continue
+		}
+		m.lanes = append(m.lanes, multiLane{r: r, busy: make(chan struct{}, 1)})
+	}
+	return m
+}
+
+// Receive sends the event to all receivers and waits for them, up to the timeout.
+func (m *MultiReceiver) Receive(e Event) {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	errs := make([]chan error, len(m.lanes))
+	for i := range m.lanes {
+		lane := m.lanes[i]
+		errs[i] = lane.call(ctx, func() error {
+			lane.r.Receive(e)
+			return nil
+		})
+	}
+	for i := range errs {
+		if err := <-errs[i]; err != nil {
+			log.Printf("ERROR: event %s: %T: %s", e.Name, m.lanes[i].r, err)
+		}
+	}
+}
+
+// ReceiveVeto sends the event to all receivers, calling ReceiveVeto on those
+// that are a VetoEventReceiver, and waits for them, up to the timeout. It
+// returns the first veto error in receiver order, if any.
+func (m *MultiReceiver) ReceiveVeto(e Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+	errs := make([]chan error, len(m.lanes))
+	for i := range m.lanes {
+		lane := m.lanes[i]
+		errs[i] = lane.call(ctx, func() error {
+			if v, ok := lane.r.(VetoEventReceiver); ok {
+				return v.ReceiveVeto(e)
+			}
+			lane.r.Receive(e)
+			return nil
+		})
+	}
+	var veto error
+	for i := range errs {
+		err := <-errs[i]
+		if err == nil {
+			continue
+		}
+		if _, ok := m.lanes[i].r.(VetoEventReceiver); !ok {
+			log.Printf("ERROR: event %s: %T: %s", e.Name, m.lanes[i].r, err)
+			continue
+		}
+		if veto == nil {
+			veto = fmt.Errorf("%T: %w", m.lanes[i].r, err)
+		}
+	}
+	return veto
+}
+
+// Flush flushes every receiver with a Flush method, concurrently, and returns
+// the first error in receiver order, if any.
+func (m *MultiReceiver) Flush(ctx context.Context) error {
+	errs := make([]chan error, len(m.lanes))
+	for i := range m.lanes {
+		errs[i] = make(chan error, 1)
+		f, ok := m.lanes[i].r.(interface{ Flush(context.Context) error })
+		if !ok {
+			errs[i] <- nil
+			continue
+		}
+		go func(i int) {
+			errs[i] <- f.Flush(ctx)
+		}(i)
+	}
+	var first error
+	for i := range errs {
+		if err := <-errs[i]; err != nil && first == nil {
+			first = fmt.Errorf("%T: %w", m.lanes[i].r, err)
+		}
+	}
+	return first
+}
+
+// call calls fn on a goroutine and returns a channel that receives its error,
+// an error if it panics, or an error if ctx is done first. If the receiver is
+// still busy from a previous call that timed out, fn is not called.
+func (l multiLane) call(ctx context.Context, fn func() error) chan error {
+	errc := make(chan error, 1)
+	select {
+	case l.busy <- struct{}{}:
+	default:
+		errc <- fmt.Errorf("event dropped: receiver still busy after timeout")
+		return errc
+	}
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("panic: %v", r)
+			}
+			<-l.busy
+		}()
+		done <- fn()
+	}()
+	go func() {
+		select {
+		case err := <-done:
+			errc <- err
+		case <-ctx.Done():
+			errc <- fmt.Errorf("timeout: %s", ctx.Err())
+		}
+	}()
+	return errc
+}
@@ -0,0 +1,99 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+type vetoRecorder struct {
+	eventRecorder
+	err error
+}
+
+func (v vetoRecorder) ReceiveVeto(e rotate.Event) error {
+	v.eventRecorder(e)
+	return v.err
+}
+
+func TestMultiReceiver(t *testing.T) {
+	var mux sync.Mutex
+	got := map[string][]string{}
+	recorder := func(name string) eventRecorder {
+		return func(e rotate.Event) {
+			mux.Lock()
+			got[name] = append(got[name], e.Name)
+			mux.Unlock()
+		}
+	}
+	unblock := make(chan struct{})
+	slow := eventRecorder(func(e rotate.Event) {
+		recorder("slow")(e)
+		<-unblock
+	})
+	panics := eventRecorder(func(e rotate.Event) { panic("oops") })
+
+	m := rotate.NewMultiReceiver(50*time.Millisecond, recorder("a"), nil, panics, slow, recorder("b"))
+
+	t0 := time.Now()
+	m.Receive(rotate.Event{Name: rotate.EVENT_BEGIN_ROTATION})
+	if d := time.Since(t0); d < 50*time.Millisecond || d > 500*time.Millisecond {
+		t.Errorf("Receive took %s, expected about 50ms (timeout)", d)
+	}
+	// slow is still busy, so it does not receive this event
+	m.Receive(rotate.Event{Name: rotate.EVENT_END_STEP})
+	close(unblock)
+
+	mux.Lock()
+	expect := map[string][]string{
+		"a":    {rotate.EVENT_BEGIN_ROTATION, rotate.EVENT_END_STEP},
+		"b":    {rotate.EVENT_BEGIN_ROTATION, rotate.EVENT_END_STEP},
+		"slow": {rotate.EVENT_BEGIN_ROTATION},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+	mux.Unlock()
+}
+
+func TestMultiReceiverVeto(t *testing.T) {
+	var gotA, gotB []string
+	a := vetoRecorder{eventRecorder: func(e rotate.Event) { gotA = append(gotA, e.Name) }}
+	b := vetoRecorder{eventRecorder: func(e rotate.Event) { gotB = append(gotB, e.Name) }, err: errors.New("change freeze")}
+	plain := eventRecorder(func(e rotate.Event) {})
+
+	m := rotate.NewMultiReceiver(0, plain, a, b)
+	err := m.ReceiveVeto(rotate.Event{Name: rotate.EVENT_BEGIN_ROTATION})
+	if err == nil || !errors.Is(err, b.err) {
+		t.Errorf("got error %v, expected change freeze veto", err)
+	}
+	if len(gotA) != 1 || len(gotB) != 1 {
+		t.Errorf("got %v and %v, expected both veto receivers called", gotA, gotB)
+	}
+
+	// A veto receiver that panics vetoes
+	p := vetoRecorder{eventRecorder: func(e rotate.Event) { panic("oops") }}
+	m = rotate.NewMultiReceiver(0, a, p)
+	if err := m.ReceiveVeto(rotate.Event{Name: rotate.EVENT_BEGIN_ROTATION}); err == nil {
+		t.Error("no error, expected veto from panic")
+	}
+
+	// Flush flushes AsyncReceivers
+	async := rotate.NewAsyncReceiver(a, 0)
+	m = rotate.NewMultiReceiver(0, async, plain)
+	m.Receive(rotate.Event{Name: rotate.EVENT_END_STEP})
+	if err := m.Flush(context.TODO()); err != nil {
+		t.Error(err)
+	}
+	if diff := deep.Equal(gotA, []string{rotate.EVENT_BEGIN_ROTATION, rotate.EVENT_BEGIN_ROTATION, rotate.EVENT_END_STEP}); diff != nil {
+		t.Error(diff)
+	}
+}
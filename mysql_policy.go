@@ -0,0 +1,133 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MySQL validate_password.policy values.
+const (
+	VALIDATE_PASSWORD_LOW    = "LOW"
+	VALIDATE_PASSWORD_MEDIUM = "MEDIUM"
+	VALIDATE_PASSWORD_STRONG = "STRONG"
+)
+
+// MySQLPasswordPolicy mirrors the MySQL validate_password component (or plugin)
+// system variables. Set it as RandomPassword.MySQLPolicy to generate passwords
+// that are guaranteed to be accepted by the database, so rotation does not fail
+// at SetPassword because a random password happened to violate the policy.
+//
+// Zero values are the MySQL defaults: MEDIUM policy, length 8, and 1 for each
+// count. Set the values to match the database, e.g. from
+// SHOW VARIABLES LIKE 'validate_password%'.
+type MySQLPasswordPolicy struct {
+	// Policy is validate_password.policy: LOW, MEDIUM, or STRONG.
+	Policy string
+
+	// Length, MixedCaseCount, NumberCount, and SpecialCharCount are the
+	// validate_password variables of the same names.
+	Length           int
+	MixedCaseCount   int
+	NumberCount      int
+	SpecialCharCount int
+
+	// Dictionary is the words in the validate_password.dictionary_file.
+	// For the STRONG policy, no substring of length 4 or longer can match
+	// a word, case-insensitive.
+	Dictionary []string
+
+	// CheckUserName is validate_password.check_user_name: the password cannot
+	// be the username or the username reversed.
+	CheckUserName bool
+}
+
+// passwordPolicy returns the PasswordPolicy with the character class minimums
+// for the MySQL policy, and the minimum password length.
+func (p MySQLPasswordPolicy) passwordPolicy() (PasswordPolicy, int, error) {
+	length := p.Length
+	if length == 0 {
+		length = 8
+	}
+	switch strings.ToUpper(p.Policy) {
+	case VALIDATE_PASSWORD_LOW:
+		return PasswordPolicy{}, length, nil
+	case "", VALIDATE_PASSWORD_MEDIUM, VALIDATE_PASSWORD_STRONG:
+	default:
+		return PasswordPolicy{}, 0, fmt.Errorf("invalid validate_password policy: %s: expected LOW, MEDIUM, or STRONG", p.Policy)
+	}
+	pp := PasswordPolicy{
+		MinUpper:   p.MixedCaseCount,
+		MinLower:   p.MixedCaseCount,
+		MinDigits:  p.NumberCount,
+		MinSpecial: p.SpecialCharCount,
+	}
+	if pp.MinUpper == 0 {
+		pp.MinUpper, pp.MinLower = 1, 1
+	}
+	if pp.MinDigits == 0 {
+		pp.MinDigits = 1
+	}
+	if pp.MinSpecial == 0 {
+		pp.MinSpecial = 1
+	}
+	// Like MySQL, the effective length is at least the sum of the counts
+	if min := pp.MinUpper + pp.MinLower + pp.MinDigits + pp.MinSpecial; length < min {
+		length = min
+	}
+	return pp, length, nil
+}
+
+// Validate returns an error if the MySQL validate_password component would
+// reject the password for the username. Username is only used if CheckUserName
+// is true.
+func (p MySQLPasswordPolicy) Validate(password, username string) error {
+	pp, length, err := p.passwordPolicy()
+	if err != nil {
+		return err
+	}
+	if len([]rune(password)) < length {
+		return fmt.Errorf("password has %d characters, minimum is %d", len([]rune(password)), length)
+	}
+	if err := pp.Validate(password); err != nil {
+		return err
+	}
+	return p.check(password, username)
+}
+
+// check checks the rules that are not in PasswordPolicy: username and dictionary.
+func (p MySQLPasswordPolicy) check(password, username string) error {
+	if p.CheckUserName && username != "" {
+		lp := strings.ToLower(password)
+		lu := strings.ToLower(username)
+		if lp == lu || lp == reverse(lu) {
+			return fmt.Errorf("password matches username")
+		}
+	}
+
+	if strings.ToUpper(p.Policy) != VALIDATE_PASSWORD_STRONG || len(p.Dictionary) == 0 {
+		return nil
+	}
+	words := make(map[string]bool, len(p.Dictionary))
+	for _, w := range p.Dictionary {
+		words[strings.ToLower(w)] = true
+	}
+	lp := []rune(strings.ToLower(password))
+	for n := 4; n <= len(lp) && n <= 100; n++ {
+		for i := 0; i+n <= len(lp); i++ {
+			if words[string(lp[i:i+n])] {
+				return fmt.Errorf("password contains dictionary word")
+			}
+		}
+	}
+	return nil
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
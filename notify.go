@@ -0,0 +1,171 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+)
+
+// Notifier notifies a service that uses the secret after finishSecret makes
+// the new secret current, so a service that caches credentials can pick up
+// the new password promptly. Set Config.Notifiers to enable.
+//
+// Notifiers are called in order after FinishHooks.AfterFinish. An error is
+// logged but does not fail the rotation because the new secret is already
+// current, and the other notifiers are still called.
+//
+// ECSServiceNotifier, KubernetesRestartNotifier, and WebhookNotifier implement
+// this interface.
+type Notifier interface {
+	Notify(ctx context.Context, secretId, versionId string) error
+}
+
+// NotifierFunc is a function that implements Notifier.
+type NotifierFunc func(ctx context.Context, secretId, versionId string) error
+
+var _ Notifier = NotifierFunc(nil)
+
+func (f NotifierFunc) Notify(ctx context.Context, secretId, versionId string) error {
+	return f(ctx, secretId, versionId)
+}
+
+// ECSServiceNotifier restarts the tasks of an ECS service by forcing a new
+// deployment, like "aws ecs update-service --force-new-deployment".
+type ECSServiceNotifier struct {
+	ECS     ecsiface.ECSAPI
+	Cluster string
+	Service string
+}
+
+var _ Notifier = ECSServiceNotifier{}
+
+func (n ECSServiceNotifier) Notify(ctx context.Context, secretId, versionId string) error {
+	_, err := n.ECS.UpdateServiceWithContext(ctx, &ecs.UpdateServiceInput{
+		Cluster:            aws.String(n.Cluster),
+		Service:            aws.String(n.Service),
+		ForceNewDeployment: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot restart ECS service %s/%s: %s", n.Cluster, n.Service, err)
+	}
+	return nil
+}
+
+// KubernetesRestartNotifier restarts a Kubernetes deployment, like "kubectl
+// rollout restart deployment", by setting the restartedAt annotation of the
+// pod template.
+type KubernetesRestartNotifier struct {
+	Kubernetes *KubernetesClient
+	Namespace  string
+	Deployment string
+}
+
+var _ Notifier = KubernetesRestartNotifier{}
+
+func (n KubernetesRestartNotifier) Notify(ctx context.Context, secretId, versionId string) error {
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]string{
+						"kubectl.kubernetes.io/restartedAt": time.Now().UTC().Format(time.RFC3339),
+					},
+				},
+			},
+		},
+	}
+	path := fmt.Sprintf("/apis/apps/v1/namespaces/%s/deployments/%s", n.Namespace, n.Deployment)
+	if err := n.Kubernetes.Patch(ctx, path, KUBERNETES_STRATEGIC_MERGE_PATCH, patch); err != nil {
+		return fmt.Errorf("cannot restart Kubernetes deployment %s/%s: %s", n.Namespace, n.Deployment, err)
+	}
+	return nil
+}
+
+// WebhookNotifier sends an HTTP POST request to each URL with a JSON object
+// {"SecretId":"...","VersionId":"..."}. The secret value is not sent. A request
+// fails unless the response status code is 2xx. All URLs are tried, and the
+// first error is returned.
+type WebhookNotifier struct {
+	URLs   []string
+	Client *http.Client // http.DefaultClient if nil
+}
+
+var _ Notifier = WebhookNotifier{}
+
+func (n WebhookNotifier) Notify(ctx context.Context, secretId, versionId string) error {
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(map[string]string{
+		"SecretId":  secretId,
+		"VersionId": versionId,
+	})
+	if err != nil {
+		return err
+	}
+	var firstErr error
+	for _, url := range n.URLs {
+		if err := postJSON(ctx, client, url, body); err != nil {
+			log.Printf("ERROR: webhook %s: %s", url, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// postJSON sends an HTTP POST request with the JSON body. It returns an error
+// unless the response status code is 2xx.
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte) error {
+	return sendJSON(ctx, client, http.MethodPost, url, nil, body)
+}
+
+// sendJSON sends an HTTP request with the JSON body and extra headers, if any.
+// It returns an error unless the response status code is 2xx.
+func sendJSON(ctx context.Context, client *http.Client, method, url string, header http.Header, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", url, resp.Status, msg)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+
+// notify calls the notifiers. It's called at the end of finishSecret.
+func (r *Rotator) notify(ctx context.Context, versionId string) {
+	for _, n := range r.notifiers {
+		t0 := time.Now()
+		if err := n.Notify(ctx, r.secretId, versionId); err != nil {
+			log.Printf("ERROR: notifier %T: %s (ignored, new secret is current)", n, err)
+			continue
+		}
+		debug("notifier %T: %dms", n, time.Now().Sub(t0).Milliseconds())
+	}
+}
@@ -0,0 +1,162 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestNotifiers(t *testing.T) {
+	// Test that notifiers are called after finishSecret, and an error does
+	// not fail the rotation or stop the other notifiers
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{}, nil
+		},
+	}
+	var got []string
+	notifier := func(name string, err error) rotate.Notifier {
+		return rotate.NotifierFunc(func(ctx context.Context, secretId, versionId string) error {
+			got = append(got, name+" "+secretId+" "+versionId)
+			return err
+		})
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{},
+		Notifiers:      []rotate.Notifier{notifier("n1", fmt.Errorf("down")), notifier("n2", nil)},
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(got, []string{"n1 def v2", "n2 def v2"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var got []map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		got = append(got, body)
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+	n := rotate.WebhookNotifier{URLs: []string{ts.URL + "/fail", ts.URL + "/ok"}}
+	if err := n.Notify(context.TODO(), "def", "v2"); err == nil {
+		t.Error("no error, expected error from /fail")
+	}
+	body := map[string]string{"SecretId": "def", "VersionId": "v2"}
+	if diff := deep.Equal(got, []map[string]string{body, body}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func TestKubernetesRestartNotifier(t *testing.T) {
+	var gotMethod, gotPath, gotType, gotAuth, gotBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		gotType, gotAuth = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		gotBody = string(b)
+	}))
+	defer ts.Close()
+	n := rotate.KubernetesRestartNotifier{
+		Kubernetes: &rotate.KubernetesClient{
+			Server: ts.URL,
+			Token:  func(context.Context) (string, error) { return "tok", nil },
+		},
+		Namespace:  "prod",
+		Deployment: "app",
+	}
+	if err := n.Notify(context.TODO(), "def", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{gotMethod, gotPath, gotType, gotAuth}
+	expect := []string{"PATCH", "/apis/apps/v1/namespaces/prod/deployments/app", rotate.KUBERNETES_STRATEGIC_MERGE_PATCH, "Bearer tok"}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+	var patch struct {
+		Spec struct {
+			Template struct {
+				Metadata struct {
+					Annotations map[string]string
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(gotBody), &patch); err != nil {
+		t.Fatal(err)
+	}
+	if patch.Spec.Template.Metadata.Annotations["kubectl.kubernetes.io/restartedAt"] == "" {
+		t.Errorf("restartedAt annotation not set: %s", gotBody)
+	}
+}
+
+type mockECS struct {
+	ecsiface.ECSAPI
+	input *ecs.UpdateServiceInput
+}
+
+func (m *mockECS) UpdateServiceWithContext(ctx aws.Context, input *ecs.UpdateServiceInput, opts ...request.Option) (*ecs.UpdateServiceOutput, error) {
+	m.input = input
+	return &ecs.UpdateServiceOutput{}, nil
+}
+
+func TestECSServiceNotifier(t *testing.T) {
+	m := &mockECS{}
+	n := rotate.ECSServiceNotifier{ECS: m, Cluster: "c1", Service: "app"}
+	if err := n.Notify(context.TODO(), "def", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	expect := &ecs.UpdateServiceInput{
+		Cluster:            aws.String("c1"),
+		Service:            aws.String("app"),
+		ForceNewDeployment: aws.Bool(true),
+	}
+	if diff := deep.Equal(m.input, expect); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SecretRotationEvent is the event from Secrets Manager for each rotation step.
+// It has the same fields and JSON as events.SecretsManagerSecretRotationEvent
+// in newer versions of github.com/aws/aws-lambda-go, so either decodes the same
+// payloads. RotationToken is set only by newer invocation payloads.
+type SecretRotationEvent struct {
+	Step               string `json:"Step"`
+	SecretId           string `json:"SecretId"`
+	ClientRequestToken string `json:"ClientRequestToken"`
+	RotationToken      string `json:"RotationToken,omitempty"`
+}
+
+// Map returns the event as the map passed to Rotator.Handler.
+func (e SecretRotationEvent) Map() map[string]string {
+	m := map[string]string{
+		"Step":               e.Step,
+		"SecretId":           e.SecretId,
+		"ClientRequestToken": e.ClientRequestToken,
+	}
+	if e.RotationToken != "" {
+		m["RotationToken"] = e.RotationToken
+	}
+	return m
+}
+
+// RawHandler decodes the raw invocation payload and calls Handler. Use it
+// instead of Handler, lambda.Start(r.RawHandler), to accept any JSON object:
+// a Secrets Manager event (see SecretRotationEvent) or a user event with
+// non-string values, which Handler cannot decode. Numbers and booleans are
+// converted to strings, nested objects and arrays to JSON strings, and null
+// values are ignored.
+func (r *Rotator) RawHandler(ctx context.Context, payload json.RawMessage) (map[string]string, error) {
+	event, err := decodeEvent(payload)
+	if err != nil {
+		return nil, err
+	}
+	return r.Handler(ctx, event)
+}
+
+// RotationHandler calls Handler with the typed Secrets Manager event.
+func (r *Rotator) RotationHandler(ctx context.Context, event SecretRotationEvent) (map[string]string, error) {
+	return r.Handler(ctx, event.Map())
+}
+
+// decodeEvent decodes a JSON object into the map passed to Handler.
+func decodeEvent(payload json.RawMessage) (map[string]string, error) {
+	// Fast path: all values are strings, like the Secrets Manager event
+	event := map[string]string{}
+	if err := json.Unmarshal(payload, &event); err == nil {
+		return event, nil
+	}
+
+	var v map[string]interface{}
+	d := json.NewDecoder(bytes.NewReader(payload))
+	d.UseNumber() // keep numbers as is, e.g. 10 not 1e+01
+	if err := d.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid event: expected a JSON object: %s", err)
+	}
+	event = make(map[string]string, len(v))
+	for k, val := range v {
+		switch val := val.(type) {
+		case nil:
+			continue
+		case string:
+			event[k] = val
+		case json.Number:
+			event[k] = val.String()
+		case bool:
+			event[k] = fmt.Sprint(val)
+		default: // object or array
+			b, err := json.Marshal(val)
+			if err != nil {
+				return nil, err
+			}
+			event[k] = string(b)
+		}
+	}
+	return event, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestRawHandler(t *testing.T) {
+	// Test that a user event with non-string values is decoded and passed
+	// to SecretSetter.Handler
+	var gotEvent map[string]string
+	ss := test.MockSecretSetter{
+		HandlerFunc: func(ctx context.Context, event map[string]string) (map[string]string, error) {
+			gotEvent = event
+			return nil, nil
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: test.MockSecretsManager{},
+		SecretSetter:   ss,
+		PasswordSetter: test.MockPasswordSetter{},
+	})
+	payload := json.RawMessage(`{"action":"rotate","count":10,"force":true,"hosts":["a","b"],"note":null}`)
+	if _, err := r.RawHandler(context.TODO(), payload); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"action": "rotate",
+		"count":  "10",
+		"force":  "true",
+		"hosts":  `["a","b"]`,
+	}
+	if diff := deep.Equal(gotEvent, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// Not an object
+	if _, err := r.RawHandler(context.TODO(), json.RawMessage(`[1,2]`)); err == nil {
+		t.Error("no error for array payload, expected an error")
+	}
+}
+
+func TestSecretRotationEvent(t *testing.T) {
+	payload := `{"Step":"createSecret","SecretId":"def","ClientRequestToken":"abc","RotationToken":"xyz"}`
+	var e rotate.SecretRotationEvent
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		t.Fatal(err)
+	}
+	got := e.Map()
+	expect := map[string]string{
+		"Step":               "createSecret",
+		"SecretId":           "def",
+		"ClientRequestToken": "abc",
+		"RotationToken":      "xyz",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+	if !rotate.InvokedBySecretsManager(got) {
+		t.Error("InvokedBySecretsManager is false, expected true")
+	}
+}
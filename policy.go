@@ -0,0 +1,221 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// DEFAULT_POLICY_TRIES is the number of passwords RandomPassword generates
+// trying to satisfy its Policy before returning an error.
+const DEFAULT_POLICY_TRIES = 100
+
+// PasswordPolicy is a set of rules for passwords generated by RandomPassword.
+// The zero value has no rules. Character classes are: upper case letters,
+// lower case letters, digits, and special (every other character).
+type PasswordPolicy struct {
+	// MinUpper, MinLower, MinDigits, and MinSpecial are the minimum number of
+	// characters from each character class.
+	MinUpper   int
+	MinLower   int
+	MinDigits  int
+	MinSpecial int
+
+	// Exclude are characters never used, even if they're in the charset.
+	// For example, `'"\` to avoid characters that break DSNs or SQL.
+	Exclude string
+
+	// NoRepeat disallows the same character twice in a row, like "aa".
+	NoRepeat bool
+
+	// Prefix and Suffix are added to the start and end of every password.
+	// They are not counted in RandomPassword.PasswordLength.
+	Prefix string
+	Suffix string
+}
+
+var _ PasswordValidator = PasswordPolicy{}
+
+// Validate returns an error if the password does not satisfy the policy.
+func (p PasswordPolicy) Validate(password string) error {
+	if !strings.HasPrefix(password, p.Prefix) {
+		return fmt.Errorf("password does not have prefix %q", p.Prefix)
+	}
+	if !strings.HasSuffix(password, p.Suffix) {
+		return fmt.Errorf("password does not have suffix %q", p.Suffix)
+	}
+	if p.Exclude != "" && strings.ContainsAny(password, p.Exclude) {
+		return fmt.Errorf("password contains excluded characters")
+	}
+
+	var upper, lower, digits, special int
+	var prev rune
+	for i, c := range password {
+		if p.NoRepeat && i > 0 && c == prev {
+			return fmt.Errorf("password has repeated characters")
+		}
+		prev = c
+		switch charClass(c) {
+		case classUpper:
+			upper++
+		case classLower:
+			lower++
+		case classDigit:
+			digits++
+		default:
+			special++
+		}
+	}
+	if upper < p.MinUpper {
+		return fmt.Errorf("password has %d upper case letters, minimum is %d", upper, p.MinUpper)
+	}
+	if lower < p.MinLower {
+		return fmt.Errorf("password has %d lower case letters, minimum is %d", lower, p.MinLower)
+	}
+	if digits < p.MinDigits {
+		return fmt.Errorf("password has %d digits, minimum is %d", digits, p.MinDigits)
+	}
+	if special < p.MinSpecial {
+		return fmt.Errorf("password has %d special characters, minimum is %d", special, p.MinSpecial)
+	}
+	return nil
+}
+
+// generate returns a new password of length random characters from charset
+// (plus prefix and suffix) that satisfies the policy and the optional check func.
+// The minimum number of characters from each class are picked first, then the
+// rest from the whole charset, then they're shuffled. Random numbers are from
+// intn. If minBits is greater than zero, an error is returned if the password
+// entropy is less than minBits.
+func (p PasswordPolicy) generate(charset []rune, length int, intn intnFunc, minBits int, check func(string) error) (string, error) {
+	if p.Exclude != "" {
+		filtered := make([]rune, 0, len(charset))
+		for _, c := range charset {
+			if !strings.ContainsRune(p.Exclude, c) {
+				filtered = append(filtered, c)
+			}
+		}
+		charset = filtered
+	}
+	if len(charset) == 0 {
+		return "", fmt.Errorf("no valid characters: all characters are excluded")
+	}
+
+	classes := map[int][]rune{}
+	for _, c := range charset {
+		classes[charClass(c)] = append(classes[charClass(c)], c)
+	}
+	mins := []struct {
+		class int
+		n     int
+		name  string
+	}{
+		{classUpper, p.MinUpper, "upper case letters"},
+		{classLower, p.MinLower, "lower case letters"},
+		{classDigit, p.MinDigits, "digits"},
+		{classSpecial, p.MinSpecial, "special characters"},
+	}
+	total := 0
+	for _, m := range mins {
+		if m.n > 0 && len(classes[m.class]) == 0 {
+			return "", fmt.Errorf("password policy requires %d %s but the charset has none", m.n, m.name)
+		}
+		total += m.n
+	}
+	if total > length {
+		return "", fmt.Errorf("password policy requires %d characters but password length is %d", total, length)
+	}
+	if minBits > 0 {
+		classMins := map[int]int{}
+		for _, m := range mins {
+			if m.n > 0 {
+				classMins[m.class] = m.n
+			}
+		}
+		if bits := entropyBits(classes, classMins, charset, length); bits < float64(minBits) {
+			return "", fmt.Errorf("password entropy is %.1f bits, minimum is %d bits: "+
+				"increase the password length or charset", bits, minBits)
+		}
+	}
+
+	var err error
+	for try := 0; try < DEFAULT_POLICY_TRIES; try++ {
+		pw := make([]rune, 0, length)
+		for _, m := range mins {
+			for i := 0; i < m.n; i++ {
+				n, err := intn(len(classes[m.class]))
+				if err != nil {
+					return "", err
+				}
+				pw = append(pw, classes[m.class][n])
+			}
+		}
+		for len(pw) < length {
+			n, err := intn(len(charset))
+			if err != nil {
+				return "", err
+			}
+			pw = append(pw, charset[n])
+		}
+		// Fisher-Yates shuffle
+		for i := len(pw) - 1; i > 0; i-- {
+			j, err := intn(i + 1)
+			if err != nil {
+				return "", err
+			}
+			pw[i], pw[j] = pw[j], pw[i]
+		}
+
+		password := p.Prefix + string(pw) + p.Suffix
+		if err = p.Validate(password); err != nil {
+			continue
+		}
+		if check != nil {
+			if err = check(password); err != nil {
+				continue
+			}
+		}
+		return password, nil
+	}
+	return "", fmt.Errorf("cannot generate password that satisfies policy after %d tries: %s", DEFAULT_POLICY_TRIES, err)
+}
+
+// merge returns a copy of the policy with the greater character class minimums
+// of the policy and q.
+func (p PasswordPolicy) merge(q PasswordPolicy) PasswordPolicy {
+	if q.MinUpper > p.MinUpper {
+		p.MinUpper = q.MinUpper
+	}
+	if q.MinLower > p.MinLower {
+		p.MinLower = q.MinLower
+	}
+	if q.MinDigits > p.MinDigits {
+		p.MinDigits = q.MinDigits
+	}
+	if q.MinSpecial > p.MinSpecial {
+		p.MinSpecial = q.MinSpecial
+	}
+	return p
+}
+
+const (
+	classUpper = iota
+	classLower
+	classDigit
+	classSpecial
+)
+
+func charClass(c rune) int {
+	switch {
+	case unicode.IsUpper(c):
+		return classUpper
+	case unicode.IsLower(c):
+		return classLower
+	case unicode.IsDigit(c):
+		return classDigit
+	default:
+		return classSpecial
+	}
+}
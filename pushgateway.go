@@ -0,0 +1,121 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DEFAULT_PUSHGATEWAY_JOB is the PushgatewayReceiver job if none is set.
+const DEFAULT_PUSHGATEWAY_JOB = "password_rotation"
+
+// PUSHGATEWAY_TIMEOUT is how long one push to the Pushgateway can take.
+const PUSHGATEWAY_TIMEOUT = 5 * time.Second
+
+// PushgatewayReceiver is an EventReceiver that pushes rotation metrics to a
+// Prometheus Pushgateway at the end of each step, because Prometheus cannot
+// scrape a Lambda function. The grouping key is the job and the secret name
+// (see Event.SecretId), so each secret has its own metrics:
+//
+//	password_rotation_last_step_timestamp_seconds{step}  when the last step ended
+//	password_rotation_last_step_duration_seconds{step}   last step run time
+//	password_rotation_last_step_success{step}            1 if the last step succeeded, else 0
+//	password_rotation_last_success_timestamp_seconds     when the last rotation completed
+//
+// Alert on stale rotations with, for example,
+// time() - password_rotation_last_success_timestamp_seconds > 86400*35.
+//
+// Metrics are pushed with POST, so a push replaces only the metrics it sends,
+// and last_success_timestamp_seconds is kept until the next rotation completes.
+// Push errors are logged and ignored. It is safe for concurrent use by multiple
+// goroutines.
+type PushgatewayReceiver struct {
+	URL    string       // Pushgateway URL, like "http://pushgateway:9091"
+	Job    string       // DEFAULT_PUSHGATEWAY_JOB if empty
+	Client *http.Client // http.DefaultClient if nil
+
+	// --
+	mux         sync.Mutex
+	lastSuccess time.Time
+}
+
+var _ EventReceiver = &PushgatewayReceiver{}
+
+// Receive records when the rotation completes (EVENT_END_ROTATION), and pushes
+// the metrics on EVENT_END_STEP.
+func (p *PushgatewayReceiver) Receive(e Event) {
+	switch e.Name {
+	case EVENT_END_ROTATION:
+		p.mux.Lock()
+		p.lastSuccess = e.Time
+		p.mux.Unlock()
+	case EVENT_END_STEP:
+		p.mux.Lock()
+		lastSuccess := p.lastSuccess
+		p.lastSuccess = time.Time{}
+		p.mux.Unlock()
+		if err := p.push(e, lastSuccess); err != nil {
+			log.Printf("ERROR: Pushgateway %s: %s", p.URL, err)
+		}
+	}
+}
+
+func (p *PushgatewayReceiver) push(e Event, lastSuccess time.Time) error {
+	end := e.Time
+	if end.IsZero() {
+		end = time.Now()
+	}
+	success := 1
+	if e.Error != nil {
+		success = 0
+	}
+	step := fmt.Sprintf("{step=%q}", e.Step)
+	var b strings.Builder
+	writeGauge(&b, "password_rotation_last_step_timestamp_seconds", "When the last rotation step ended.", step, formatFloat(float64(end.UnixNano())/1e9))
+	writeGauge(&b, "password_rotation_last_step_duration_seconds", "Run time of the last rotation step.", step, formatFloat(e.Duration.Seconds()))
+	writeGauge(&b, "password_rotation_last_step_success", "1 if the last rotation step succeeded, else 0.", step, fmt.Sprintf("%d", success))
+	if !lastSuccess.IsZero() {
+		writeGauge(&b, "password_rotation_last_success_timestamp_seconds", "When the last rotation completed.", "", formatFloat(float64(lastSuccess.UnixNano())/1e9))
+	}
+
+	job := p.Job
+	if job == "" {
+		job = DEFAULT_PUSHGATEWAY_JOB
+	}
+	// Label values are base64-encoded because secret names can contain "/"
+	url := fmt.Sprintf("%s/metrics/job@base64/%s/secret@base64/%s", strings.TrimSuffix(p.URL, "/"),
+		base64.RawURLEncoding.EncodeToString([]byte(job)),
+		base64.RawURLEncoding.EncodeToString([]byte(secretName(e.SecretId))))
+
+	ctx, cancel := context.WithTimeout(context.Background(), PUSHGATEWAY_TIMEOUT)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBufferString(b.String()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	resp, err := httpClient(p.Client).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push returned %s: %s", resp.Status, msg)
+	}
+	return nil
+}
+
+func writeGauge(b *strings.Builder, name, help, labels, value string) {
+	writeHeader(b, name, "gauge", help)
+	fmt.Fprintf(b, "%s%s %s\n", name, labels, value)
+}
@@ -0,0 +1,63 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+func TestPushgatewayReceiver(t *testing.T) {
+	var gotMethod, gotPath string
+	var pushes []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.Path
+		b, _ := io.ReadAll(r.Body)
+		pushes = append(pushes, string(b))
+	}))
+	defer ts.Close()
+
+	p := &rotate.PushgatewayReceiver{URL: ts.URL}
+	now := time.Unix(1700000000, 0)
+	p.Receive(rotate.Event{Name: rotate.EVENT_END_STEP, SecretId: "prod/db", Step: "setSecret", Time: now, Error: errors.New("fail"), Duration: 2 * time.Second})
+	p.Receive(rotate.Event{Name: rotate.EVENT_END_ROTATION, SecretId: "prod/db", Time: now})
+	p.Receive(rotate.Event{Name: rotate.EVENT_END_STEP, SecretId: "prod/db", Step: "finishSecret", Time: now, Duration: time.Second})
+
+	if gotMethod != "POST" {
+		t.Errorf("got method %s, expected POST", gotMethod)
+	}
+	// job@base64/password_rotation, secret@base64/prod/db
+	if expect := "/metrics/job@base64/cGFzc3dvcmRfcm90YXRpb24/secret@base64/cHJvZC9kYg"; gotPath != expect {
+		t.Errorf("got path %s, expected %s", gotPath, expect)
+	}
+	if len(pushes) != 2 {
+		t.Fatalf("got %d pushes, expected 2", len(pushes))
+	}
+	for _, line := range []string{
+		`password_rotation_last_step_success{step="setSecret"} 0`,
+		`password_rotation_last_step_duration_seconds{step="setSecret"} 2`,
+		`password_rotation_last_step_timestamp_seconds{step="setSecret"} 1.7e+09`,
+	} {
+		if !strings.Contains(pushes[0], line+"\n") {
+			t.Errorf("first push missing %q:\n%s", line, pushes[0])
+		}
+	}
+	if strings.Contains(pushes[0], "last_success_timestamp") {
+		t.Errorf("first push has last success timestamp, expected none before end-rotation:\n%s", pushes[0])
+	}
+	for _, line := range []string{
+		`password_rotation_last_step_success{step="finishSecret"} 1`,
+		`password_rotation_last_success_timestamp_seconds 1.7e+09`,
+	} {
+		if !strings.Contains(pushes[1], line+"\n") {
+			t.Errorf("second push missing %q:\n%s", line, pushes[1])
+		}
+	}
+}
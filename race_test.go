@@ -0,0 +1,228 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+var rotationSteps = []string{"createSecret", "setSecret", "testSecret", "finishSecret"}
+
+// raceDb is a database with one password shared by racing rotations.
+type raceDb struct {
+	mux      sync.Mutex
+	password string
+	set      []string // new passwords, in order
+}
+
+func (d *raceDb) setter() test.MockPasswordSetter {
+	return test.MockPasswordSetter{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			d.mux.Lock()
+			defer d.mux.Unlock()
+			if creds.Current.Password != d.password {
+				return fmt.Errorf("access denied")
+			}
+			d.password = creds.New.Password
+			d.set = append(d.set, creds.New.Password)
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			d.mux.Lock()
+			defer d.mux.Unlock()
+			if creds.New.Password != d.password {
+				return fmt.Errorf("access denied")
+			}
+			return nil
+		},
+		RollbackFunc: func(ctx context.Context, creds db.NewPassword) error {
+			d.mux.Lock()
+			defer d.mux.Unlock()
+			d.password = creds.Current.Password
+			return nil
+		},
+	}
+}
+
+// interleavings returns every order of a steps of rotation "a" and b steps of
+// rotation "b", like "aabb", "abab", and so on.
+func interleavings(a, b int) []string {
+	if a == 0 {
+		return []string{strings.Repeat("b", b)}
+	}
+	if b == 0 {
+		return []string{strings.Repeat("a", a)}
+	}
+	all := []string{}
+	for _, rest := range interleavings(a-1, b) {
+		all = append(all, "a"+rest)
+	}
+	for _, rest := range interleavings(a, b-1) {
+		all = append(all, "b"+rest)
+	}
+	return all
+}
+
+func TestConcurrentRotationInterleavings(t *testing.T) {
+	// Two rotations of the same secret (two Rotators, like two Lambda
+	// invocations) run their steps in every possible order. If they overlap,
+	// exactly one must win and the other must fail cleanly; if not, both win
+	// in turn. Either way, the secret must end with no pending version and
+	// the current password must be the database password.
+	for _, order := range interleavings(4, 4) {
+		sm := test.NewFakeSecretsManager()
+		sm.AddSecret("s1", map[string]string{"username": "foo", "password": "p1"})
+		d := &raceDb{password: "p1"}
+		rotators := map[byte]*rotate.Rotator{}
+		for _, id := range []byte("ab") {
+			rotators[id] = rotate.NewRotator(rotate.Config{
+				SecretsManager: sm,
+				PasswordSetter: d.setter(),
+				SecretSetter:   rotate.RandomPassword{},
+			})
+		}
+
+		next := map[byte]int{}       // next step of each rotation
+		failed := map[byte]error{}   // Secrets Manager stops a rotation on error
+		finished := map[byte]bool{}  // rotation won
+		createdAt := map[byte]int{}  // position of createSecret in order
+		finishedAt := map[byte]int{} // position of finishSecret in order
+		for i := 0; i < len(order); i++ {
+			id := order[i]
+			step := rotationSteps[next[id]]
+			next[id]++
+			if failed[id] != nil {
+				continue
+			}
+			event := map[string]string{"Step": step, "SecretId": "s1", "ClientRequestToken": "token-" + string(id)}
+			if _, err := rotators[id].Handler(context.TODO(), event); err != nil {
+				failed[id] = err
+				continue
+			}
+			switch step {
+			case "createSecret":
+				createdAt[id] = i
+			case "finishSecret":
+				finishedAt[id] = i
+				finished[id] = true
+			}
+		}
+
+		overlap := !(finished['a'] && createdAt['b'] > finishedAt['a']) &&
+			!(finished['b'] && createdAt['a'] > finishedAt['b'])
+		if overlap {
+			if len(finished) != 1 {
+				t.Errorf("%s: %d rotations won, expected 1 (errors: %v)", order, len(finished), failed)
+			}
+			for id, err := range failed {
+				if !errors.Is(err, rotate.ErrPendingConflict) {
+					t.Errorf("%s: rotation %c failed with %v, expected ErrPendingConflict", order, id, err)
+				}
+			}
+		} else if len(finished) != 2 {
+			t.Errorf("%s: %d rotations won, expected 2 (errors: %v)", order, len(finished), failed)
+		}
+
+		stages := sm.Stages("s1")
+		if _, ok := stages[rotate.AWSPENDING]; ok {
+			t.Errorf("%s: pending version left: %v", order, stages)
+		}
+		if p := sm.Values("s1", rotate.AWSCURRENT)["password"]; p != d.password {
+			t.Errorf("%s: current password is not the database password (stages %v)", order, stages)
+		}
+	}
+}
+
+// racingSM calls beforePut before PutSecretValue, so a test can hold one
+// rotation between its check for a pending secret and putting its own.
+type racingSM struct {
+	secretsmanageriface.SecretsManagerAPI
+	beforePut func()
+}
+
+func (m racingSM) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	m.beforePut()
+	return m.SecretsManagerAPI.PutSecretValue(input)
+}
+
+func TestConcurrentRotationTakeover(t *testing.T) {
+	// Both rotations find no pending secret in createSecret, then both put one:
+	// b puts second, which takes AWSPENDING from a. Rotation a must fail every
+	// later step without changing or rolling back the database, and b must win
+	sm := test.NewFakeSecretsManager()
+	sm.AddSecret("s1", map[string]string{"username": "foo", "password": "p1"})
+	d := &raceDb{password: "p1"}
+
+	aPut := make(chan struct{})
+	bChecked := make(chan struct{})
+	ra := rotate.NewRotator(rotate.Config{
+		SecretsManager: racingSM{sm, func() { <-bChecked }},
+		PasswordSetter: d.setter(),
+		SecretSetter:   rotate.RandomPassword{},
+	})
+	rb := rotate.NewRotator(rotate.Config{
+		SecretsManager: racingSM{sm, func() { close(bChecked); <-aPut }},
+		PasswordSetter: d.setter(),
+		SecretSetter:   rotate.RandomPassword{},
+	})
+	event := func(step, token string) map[string]string {
+		return map[string]string{"Step": step, "SecretId": "s1", "ClientRequestToken": token}
+	}
+
+	var wg sync.WaitGroup
+	var errA, errB error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, errA = ra.Handler(context.TODO(), event("createSecret", "token-a"))
+		close(aPut)
+	}()
+	go func() {
+		defer wg.Done()
+		_, errB = rb.Handler(context.TODO(), event("createSecret", "token-b"))
+	}()
+	wg.Wait()
+	if errA != nil || errB != nil {
+		t.Fatalf("createSecret: a: %v, b: %v; expected both to put a pending secret", errA, errB)
+	}
+	if v := sm.Stages("s1")[rotate.AWSPENDING]; v != "token-b" {
+		t.Fatalf("AWSPENDING is version %s, expected token-b", v)
+	}
+
+	// Loser: every step fails without touching the database
+	for _, step := range rotationSteps[1:] {
+		_, err := ra.Handler(context.TODO(), event(step, "token-a"))
+		if !errors.Is(err, rotate.ErrPendingConflict) {
+			t.Errorf("a %s: got error %v, expected ErrPendingConflict", step, err)
+		}
+	}
+	if d.password != "p1" || len(d.set) != 0 {
+		t.Errorf("rotation a changed the database password")
+	}
+
+	// Winner
+	for _, step := range rotationSteps[1:] {
+		if _, err := rb.Handler(context.TODO(), event(step, "token-b")); err != nil {
+			t.Errorf("b %s: %s", step, err)
+		}
+	}
+	if diff := deep.Equal(sm.Stages("s1"), map[string]string{rotate.AWSCURRENT: "token-b", rotate.AWSPREVIOUS: "v1"}); diff != nil {
+		t.Error(diff)
+	}
+	if p := sm.Values("s1", rotate.AWSCURRENT)["password"]; p != d.password {
+		t.Errorf("current password is not the database password")
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+)
+
+// RateLimiter is a token bucket that limits the rate of AWS API calls made by
+// one process. Use it with RateLimitHandler. It is safe for concurrent use by
+// multiple goroutines.
+//
+// The bucket is in memory and starts full, so it does not limit calls across
+// processes, like concurrent Lambda invocations, which run in separate execution
+// environments. One rotation step makes fewer calls than a typical burst, so a
+// RateLimiter only limits a long-running embedding that makes many calls, like
+// a FleetVerifier that verifies many secrets in one invocation. It does not
+// prevent account-level throttling by many concurrent rotations; for that, limit
+// the concurrency of the Lambda function and rely on the AWS SDK retries.
+type RateLimiter struct {
+	perSecond float64
+	burst     float64
+	// --
+	mux    *sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter that allows perSecond calls per second
+// on average, and up to burst calls at once. The bucket starts full. If burst
+// is zero, it is one. perSecond must be greater than zero; if not,
+// NewRateLimiter will panic.
+func NewRateLimiter(perSecond float64, burst uint) *RateLimiter {
+	if !(perSecond > 0) { // also NaN
+		panic(fmt.Sprintf("non-positive rate for NewRateLimiter: %v", perSecond))
+	}
+	if burst == 0 {
+		burst = 1
+	}
+	return &RateLimiter{
+		perSecond: perSecond,
+		burst:     float64(burst),
+		mux:       &sync.Mutex{},
+		tokens:    float64(burst),
+	}
+}
+
+// Wait waits for a token. It returns the context error if the context is
+// cancelled or its deadline is before the token is available.
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	wait := l.reserve()
+	if wait <= 0 {
+		return nil
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+		l.cancel()
+		return context.DeadlineExceeded
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		l.cancel()
+		return ctx.Err()
+	}
+}
+
+// reserve takes a token and returns how long to wait until it's available.
+// Tokens can go negative, which queues callers in order.
+func (l *RateLimiter) reserve() time.Duration {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	now := time.Now()
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.perSecond
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens -= 1
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.perSecond * float64(time.Second))
+}
+
+// cancel returns a reserved token that was not used.
+func (l *RateLimiter) cancel() {
+	l.mux.Lock()
+	l.tokens += 1
+	l.mux.Unlock()
+}
+
+// RateLimitHandler returns an AWS SDK request handler that waits for a token
+// from the RateLimiter for the API before each request, including retries.
+// limits are keyed on service and operation, like "rds.DescribeDBInstances",
+// or only service, like "secretsmanager", for all operations of the service.
+// The operation limit is used if both are set. Requests to other APIs are not
+// limited. Add it to the session used to make the API clients:
+//
+//	sess.Handlers.Send.PushFrontNamed(rotate.RateLimitHandler(map[string]*rotate.RateLimiter{
+//		"rds.DescribeDBInstances": rotate.NewRateLimiter(5, 5),
+//		"secretsmanager":          rotate.NewRateLimiter(20, 40),
+//	}))
+//
+// The limits apply only to the process with the session; see RateLimiter. If
+// the request context is cancelled while waiting, the request fails with error
+// code "RequestCanceled".
+func RateLimitHandler(limits map[string]*RateLimiter) request.NamedHandler {
+	return request.NamedHandler{
+		Name: "rotate.RateLimitHandler",
+		Fn: func(req *request.Request) {
+			l, ok := limits[req.ClientInfo.ServiceName+"."+req.Operation.Name]
+			if !ok {
+				l, ok = limits[req.ClientInfo.ServiceName]
+			}
+			if !ok {
+				return
+			}
+			if err := l.Wait(req.Context()); err != nil {
+				req.Error = awserr.New(request.CanceledErrorCode, "rate limit wait cancelled", err)
+			}
+		},
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+func TestRateLimiter(t *testing.T) {
+	l := rotate.NewRateLimiter(20, 2) // 50ms per token after burst of 2
+	t0 := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := l.Wait(context.TODO()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(t0); d < 90*time.Millisecond {
+		t.Errorf("4 calls took %s, expected >= 100ms", d)
+	}
+
+	// Context deadline before the next token
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, expected context.DeadlineExceeded", err)
+	}
+}
+
+func TestNewRateLimiterInvalidRate(t *testing.T) {
+	// Test that a non-positive rate panics instead of not limiting or blocking
+	// forever, which depends on the CPU architecture
+	for _, perSecond := range []float64{0, -1, math.NaN()} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("NewRateLimiter(%v, 1) did not panic", perSecond)
+				}
+			}()
+			rotate.NewRateLimiter(perSecond, 1)
+		}()
+	}
+}
+
+func TestRateLimitHandler(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer ts.Close()
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(ts.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	}))
+	sess.Handlers.Send.PushFrontNamed(rotate.RateLimitHandler(map[string]*rotate.RateLimiter{
+		"secretsmanager.DescribeSecret": rotate.NewRateLimiter(20, 1),
+		"secretsmanager":                rotate.NewRateLimiter(1000, 1000),
+	}))
+	sm := secretsmanager.New(sess)
+
+	// DescribeSecret has its own limit
+	t0 := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := sm.DescribeSecret(&secretsmanager.DescribeSecretInput{SecretId: aws.String("def")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(t0); d < 90*time.Millisecond {
+		t.Errorf("3 DescribeSecret calls took %s, expected >= 100ms", d)
+	}
+
+	// Other operations use the service limit
+	t0 = time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := sm.GetSecretValue(&secretsmanager.GetSecretValueInput{SecretId: aws.String("def")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(t0); d > 50*time.Millisecond {
+		t.Errorf("3 GetSecretValue calls took %s, expected no wait", d)
+	}
+
+	// Request fails if the context is cancelled while waiting
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := sm.DescribeSecretWithContext(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String("def")})
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != request.CanceledErrorCode {
+		t.Errorf("got error %v, expected %s", err, request.CanceledErrorCode)
+	}
+}
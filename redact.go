@@ -0,0 +1,221 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// REDACTED replaces secret values in log output, errors, and events.
+	REDACTED = "[REDACTED]"
+
+	// REDACT_MIN_LENGTH is the minimum length of a secret value to redact.
+	// Shorter values are not redacted because they would mangle unrelated output.
+	REDACT_MIN_LENGTH = 4
+
+	// REDACT_MAX_VALUES is the maximum number of secret values that Rotator adds
+	// to redact. When there are more, the least recently added value is no longer
+	// redacted, so a long-running process that rotates many secrets does not
+	// grow without bound. Values added by RedactValue are always redacted.
+	REDACT_MAX_VALUES = 1000
+)
+
+// The package redactor. Rotator adds every password it gets or makes, and
+// users can add other values by calling RedactValue.
+var redactor = &redact{values: map[string]uint64{}, max: REDACT_MAX_VALUES}
+
+// RedactValue adds secret values to redact from log output (see RedactWriter),
+// errors returned by Rotator.Handler, and event errors. Rotator adds passwords
+// and values of keys owned by the SecretSetter (see SecretKeyOwner) automatically.
+// Values shorter than REDACT_MIN_LENGTH are ignored. Unlike values added by
+// Rotator, these values are never evicted (see REDACT_MAX_VALUES). It is safe
+// to call from multiple goroutines.
+func RedactValue(values ...string) {
+	redactor.add(true, values...)
+}
+
+// Redact returns s with all secret values replaced by REDACTED.
+func Redact(s string) string {
+	return redactor.redact(s)
+}
+
+// RedactWriter is an io.Writer that redacts secret values before writing to W.
+// Rotator and the db/ packages log with the standard logger, so to redact their
+// log output, set it as the output of the standard logger in main:
+//
+//	log.SetOutput(rotate.RedactWriter{W: os.Stderr})
+//
+// The package does not do this itself because the standard logger belongs to
+// the program.
+type RedactWriter struct {
+	W io.Writer
+}
+
+func (w RedactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.W, redactor.redact(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil // caller wrote p, even if redacted output is a different length
+}
+
+// redactedError has the redacted message of the wrapped error. The message is
+// redacted when the error is made, not when Error is called, because the secret
+// values might be evicted by then. Unwrap returns the original error so errors.Is
+// and errors.As work.
+type redactedError struct {
+	err error
+	msg string
+}
+
+// redactError returns err if its message has no secret values, so sentinel
+// errors like ErrInvalidStep can be compared with ==, else it returns err
+// wrapped in a redactedError.
+func redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(redactedError); ok {
+		return err
+	}
+	msg := err.Error()
+	redacted := redactor.redact(msg)
+	if redacted == msg {
+		return err
+	}
+	return redactedError{err: err, msg: redacted}
+}
+
+func (e redactedError) Error() string {
+	return e.msg
+}
+
+func (e redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactReceiver is the EventReceiver that redacts Event.Error and sets
+// Event.SecretId and VersionId before passing the event to the user-provided
+// EventReceiver.
+type redactReceiver struct {
+	r         EventReceiver
+	secretId  string
+	versionId string
+}
+
+func (r redactReceiver) Receive(e Event) {
+	e.Error = redactError(e.Error)
+	e.SecretId = r.secretId
+	e.VersionId = r.versionId
+	r.r.Receive(e)
+}
+
+func (r redactReceiver) ReceiveVeto(e Event) error {
+	e.Error = redactError(e.Error)
+	e.SecretId = r.secretId
+	e.VersionId = r.versionId
+	if v, ok := r.r.(VetoEventReceiver); ok {
+		return v.ReceiveVeto(e)
+	}
+	r.r.Receive(e)
+	return nil
+}
+
+// redactSecret adds the secret values that are passwords (keys ending in
+// "password") or owned by the SecretSetter.
+func redactSecret(ss SecretSetter, secret map[string]string) {
+	for k, v := range secret {
+		if strings.HasSuffix(strings.ToLower(k), SECRET_KEY_PASSWORD) {
+			redactor.add(false, v)
+		}
+	}
+	if ko, ok := ss.(SecretKeyOwner); ok {
+		for _, k := range ko.OwnedKeys() {
+			redactor.add(false, secret[k])
+		}
+	}
+}
+
+// --------------------------------------------------------------------------
+
+type redact struct {
+	mux      sync.RWMutex
+	values   map[string]uint64 // value -> last add sequence number, or 0 if pinned
+	seq      uint64
+	max      int // max unpinned values
+	replacer *strings.Replacer
+}
+
+// add adds the values to redact. Pinned values are never evicted. Adding a value
+// again makes it the most recently added, unless it's pinned.
+func (r *redact) add(pin bool, values ...string) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	changed := false
+	for _, v := range values {
+		if len(v) < REDACT_MIN_LENGTH {
+			continue
+		}
+		seq, ok := r.values[v]
+		if ok && seq == 0 {
+			continue // pinned
+		}
+		if pin {
+			r.values[v] = 0
+		} else {
+			r.seq++
+			r.values[v] = r.seq
+		}
+		if !ok {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	r.evict()
+	// Replace longer values first in case one value contains another
+	sorted := make([]string, 0, len(r.values))
+	for v := range r.values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	oldnew := make([]string, 0, len(sorted)*2)
+	for _, v := range sorted {
+		oldnew = append(oldnew, v, REDACTED)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+// evict removes the least recently added unpinned values until there are no
+// more than max. The caller must hold the lock.
+func (r *redact) evict() {
+	n := 0
+	for _, seq := range r.values {
+		if seq != 0 {
+			n++
+		}
+	}
+	for ; n > r.max; n-- {
+		var oldest string
+		var oldestSeq uint64
+		for v, seq := range r.values {
+			if seq != 0 && (oldestSeq == 0 || seq < oldestSeq) {
+				oldest, oldestSeq = v, seq
+			}
+		}
+		delete(r.values, oldest)
+	}
+}
+
+func (r *redact) redact(s string) string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
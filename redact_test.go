@@ -0,0 +1,142 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestRedact(t *testing.T) {
+	rotate.RedactValue("correct-horse", "abc") // abc too short
+	got := rotate.Redact("ALTER USER CURRENT_USER IDENTIFIED BY 'correct-horse' abc")
+	expect := "ALTER USER CURRENT_USER IDENTIFIED BY '" + rotate.REDACTED + "' abc"
+	if got != expect {
+		t.Errorf("got %s, expected %s", got, expect)
+	}
+
+	var buf bytes.Buffer
+	w := rotate.RedactWriter{W: &buf}
+	if _, err := fmt.Fprintf(w, "password is correct-horse"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "correct-horse") {
+		t.Errorf("RedactWriter wrote secret: %s", buf.String())
+	}
+
+	// The package must not set the output of the standard logger
+	if _, ok := log.Writer().(rotate.RedactWriter); ok {
+		t.Error("standard logger output is RedactWriter, expected the program's output")
+	}
+}
+
+func TestRedactHandlerError(t *testing.T) {
+	// Test that a secret value in an error is redacted in the error returned
+	// by Handler and in the event error, but the original error is wrapped
+	errSecret := errors.New("secret error")
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			if *input.VersionStage != rotate.AWSCURRENT {
+				return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+			}
+			return &secretsmanager.GetSecretValueOutput{
+				SecretString: aws.String(`{"username":"foo","password":"hunter22"}`),
+				VersionId:    aws.String("v1"),
+			}, nil
+		},
+	}
+	ss := test.MockSecretSetter{
+		RotateFunc: func(secret map[string]string) error {
+			return fmt.Errorf("cannot rotate %s: %w", secret["password"], errSecret)
+		},
+	}
+	events := []rotate.Event{}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter:   ss,
+		PasswordSetter: test.MockPasswordSetter{},
+		EventReceiver:  eventRecorder(func(e rotate.Event) { events = append(events, e) }),
+	})
+	event := map[string]string{
+		"ClientRequestToken": "abc",
+		"SecretId":           "def",
+		"Step":               "createSecret",
+	}
+	_, err := r.Handler(context.TODO(), event)
+	if err == nil {
+		t.Fatal("no error, expected an error")
+	}
+	if strings.Contains(err.Error(), "hunter22") {
+		t.Errorf("error not redacted: %s", err)
+	}
+	if !errors.Is(err, errSecret) {
+		t.Errorf("error does not wrap original error")
+	}
+	last := events[len(events)-1]
+	if last.Name != rotate.EVENT_ERROR || strings.Contains(last.Error.Error(), "hunter22") {
+		t.Errorf("event error not redacted: %+v", last)
+	}
+}
+
+func TestRedactEviction(t *testing.T) {
+	// Test that the values added by Rotator are bounded by REDACT_MAX_VALUES,
+	// evicting the least recently added, but values from RedactValue are not
+	password := ""
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			if *input.VersionStage != rotate.AWSCURRENT {
+				return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+			}
+			return &secretsmanager.GetSecretValueOutput{
+				SecretString: aws.String(`{"username":"foo","password":"` + password + `"}`),
+				VersionId:    aws.String("v1"),
+			}, nil
+		},
+	}
+	ss := test.MockSecretSetter{
+		RotateFunc: func(secret map[string]string) error {
+			return errors.New("stop after getting current secret")
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter:   ss,
+		PasswordSetter: test.MockPasswordSetter{},
+	})
+	event := map[string]string{
+		"ClientRequestToken": "abc",
+		"SecretId":           "def",
+		"Step":               "createSecret",
+	}
+	rotate.RedactValue("pinned-password")
+	for i := 0; i <= rotate.REDACT_MAX_VALUES; i++ {
+		password = fmt.Sprintf("evict-password-%d", i)
+		if _, err := r.Handler(context.TODO(), event); err == nil {
+			t.Fatal("no error, expected Rotate error")
+		}
+		if i == 0 && rotate.Redact(password) != rotate.REDACTED {
+			t.Fatalf("%s not redacted", password)
+		}
+	}
+	if got := rotate.Redact("evict-password-0"); got != "evict-password-0" {
+		t.Errorf("least recently added value not evicted: got %s", got)
+	}
+	if got := rotate.Redact(password); got != rotate.REDACTED {
+		t.Errorf("most recently added value not redacted: got %s", got)
+	}
+	if got := rotate.Redact("pinned-password"); got != rotate.REDACTED {
+		t.Errorf("RedactValue value evicted: got %s", got)
+	}
+}
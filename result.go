@@ -0,0 +1,59 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Keys in the map returned by Handler when a Secrets Manager step succeeds.
+// The version ID of every secret stage that the step read or changed is also
+// returned, keyed on the stage: AWSCURRENT, AWSPENDING, and AWSPREVIOUS.
+const (
+	RESULT_STEP        = "Step"               // step that ran, like "setSecret"
+	RESULT_SECRET_ID   = "SecretId"           // secret ID from the event
+	RESULT_VERSION_ID  = "ClientRequestToken" // new secret version ID from the event
+	RESULT_DURATION_MS = "DurationMs"         // step run time in milliseconds
+	RESULT_HOSTS       = "Hosts"              // comma-separated database hosts, sorted
+)
+
+// stepResult collects the result of one step. Hosts are reported only if the
+// PasswordSetter implements db.HostObservable.
+type stepResult struct {
+	versions map[string]string // stage => version ID
+	hosts    map[string]bool
+}
+
+func newStepResult() *stepResult {
+	return &stepResult{
+		versions: map[string]string{},
+		hosts:    map[string]bool{},
+	}
+}
+
+// result returns the Handler result map.
+func (r *Rotator) result(step string, d time.Duration) map[string]string {
+	r.stateMux.Lock()
+	defer r.stateMux.Unlock()
+	res := map[string]string{
+		RESULT_STEP:        step,
+		RESULT_SECRET_ID:   r.secretId,
+		RESULT_VERSION_ID:  r.clientRequestToken,
+		RESULT_DURATION_MS: strconv.FormatInt(d.Milliseconds(), 10),
+	}
+	for stage, versionId := range r.stepResult.versions {
+		res[stage] = versionId
+	}
+	if len(r.stepResult.hosts) > 0 {
+		hosts := make([]string, 0, len(r.stepResult.hosts))
+		for h := range r.stepResult.hosts {
+			hosts = append(hosts, h)
+		}
+		sort.Strings(hosts)
+		res[RESULT_HOSTS] = strings.Join(hosts, ",")
+	}
+	return res
+}
@@ -0,0 +1,70 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+func TestHandlerResult(t *testing.T) {
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+	}
+	ps := &observablePasswordSetter{}
+	ps.VerifyPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		ps.o.ObserveHost("db2", "verify", time.Millisecond, nil)
+		ps.o.ObserveHost("db1", "verify", time.Millisecond, nil)
+		return nil
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: ps,
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "testSecret",
+	}
+	got, err := r.Handler(context.TODO(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got[rotate.RESULT_DURATION_MS] == "" {
+		t.Errorf("%s not set", rotate.RESULT_DURATION_MS)
+	}
+	delete(got, rotate.RESULT_DURATION_MS)
+	expect := map[string]string{
+		rotate.RESULT_STEP:       "testSecret",
+		rotate.RESULT_SECRET_ID:  "def",
+		rotate.RESULT_VERSION_ID: "v2",
+		rotate.RESULT_HOSTS:      "db1,db2",
+		rotate.AWSCURRENT:        "v1",
+		rotate.AWSPENDING:        "v2",
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"path"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -34,6 +36,20 @@ func init() {
 	log.SetFlags(log.Lshortfile)
 }
 
+// SecretsManager is the subset of the AWS Secrets Manager API that the package
+// uses. The AWS SDK client, secretsmanager.New(), and any
+// secretsmanageriface.SecretsManagerAPI implement it, so existing code does not
+// change, but a test mock or an adapter for another SDK needs only these methods.
+type SecretsManager interface {
+	GetSecretValue(*secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValue(*secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error)
+	UpdateSecretVersionStage(*secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error)
+	DescribeSecret(*secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error)
+	GetRandomPassword(*secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error)
+}
+
+var _ SecretsManager = (secretsmanageriface.SecretsManagerAPI)(nil)
+
 var (
 	// ErrInvalidStep is returned if the "Step" value in the Secrets Manager event
 	// is not one of "createSecret", "setSecret", "testSecret", or "finishSecret".
@@ -45,8 +61,9 @@ type Config struct {
 	// SecretsManager is an AWS Secrets Manager client. Create one by calling
 	// secretsmanager.New() using package github.com/aws/aws-sdk-go/service/secretsmanager.
 	// See https://pkg.go.dev/github.com/aws/aws-sdk-go@v1.30.4/service/secretsmanager?tab=doc#SecretsManager
-	// for more details. The client implements this data type.
-	SecretsManager secretsmanageriface.SecretsManagerAPI
+	// for more details. The client implements this data type, but only the methods
+	// of SecretsManager are used.
+	SecretsManager SecretsManager
 
 	// SecretSetter manages the secret value and rotates the password. This is
 	// the most important user-provided object. If none is provided, RandomPassword
@@ -66,11 +83,219 @@ type Config struct {
 
 	// EventReceiver receives events during the four-step password rotation process.
 	// If none is provided, NullEventReceiver is used. See EventReceiver for more details.
+	// To send events to multiple receivers, use a MultiReceiver.
 	EventReceiver EventReceiver
 
 	// ReplicationWait governs the duration password rotation lambda will wait for
 	// secret replication to secondary regions to complete
 	ReplicationWait time.Duration
+
+	// ReplicationPollInterval is the wait between the first DescribeSecret calls
+	// that check secret replication during ReplicationWait. The wait doubles after
+	// each call, with random jitter, up to MaxReplicationPollInterval, so many
+	// secrets finishing at once do not throttle DescribeSecret. If zero,
+	// DEFAULT_REPLICATION_POLL_INTERVAL is used.
+	ReplicationPollInterval time.Duration
+
+	// MaxReplicationPollInterval is the maximum wait between DescribeSecret calls
+	// that check secret replication. If zero, DEFAULT_MAX_REPLICATION_POLL_INTERVAL
+	// is used.
+	MaxReplicationPollInterval time.Duration
+
+	// SecretTagPrefix enables per-secret configuration from tags on the secret.
+	// If set, Rotator calls DescribeSecret on every Secrets Manager invocation
+	// and reads all tags with keys that start with the prefix, like "rotation:".
+	// These tags are used by Rotator (the prefix is removed from the keys):
+	//
+	//   skip-database       "true" overrides SkipDatabase for the secret
+	//   maintenance-window  "HH:MM-HH:MM" (UTC); rotations start only in the window
+	//
+	// All tags (including these) are passed to the PasswordSetter if it implements
+	// db.Tunable. For example, mysql.PasswordSetter uses "parallel" and "filter".
+	// If empty (the default), tags are not read.
+	SecretTagPrefix string
+
+	// FleetVerifier verifies many secrets when the Lambda function is invoked
+	// with a user event {"action":"verify-fleet"}, usually by an EventBridge
+	// schedule. If nil (the default), all user events go to SecretSetter.Handler.
+	FleetVerifier *FleetVerifier
+
+	// PasswordPolicy validates the new password after SecretSetter.Rotate and
+	// before the new secret is put in Secrets Manager as AWSPENDING. If the new
+	// password is not valid, CreateSecret returns the error and nothing is changed.
+	// PasswordPolicy implements this interface. If nil (the default), the new
+	// password is not validated.
+	PasswordPolicy PasswordValidator
+
+	// AdminSecretId is the ID (name or ARN) of a secret with admin (superuser)
+	// credentials, like the RDS master user secret. If set, Rotator gets the
+	// AWSCURRENT value of the admin secret in setSecret and testSecret and passes
+	// the "username" and "password" values to the PasswordSetter as db.NewPassword.Admin.
+	// The PasswordSetter connects as the admin to change the password instead of
+	// as the user being rotated. If empty (the default), no admin is used.
+	AdminSecretId string
+
+	// RotationStrategy determines which database users a rotation changes.
+	// If nil (the default), MultiUser is used if the SecretSetter implements
+	// MultiCredentials, else SingleUser. See RotationStrategy for details.
+	RotationStrategy RotationStrategy
+
+	// DeadlineReserve is the time reserved at the end of the Lambda invocation
+	// to roll back. The Lambda runtime sets the invocation deadline on the context
+	// (see lambdacontext). Setting and verifying the password on the databases,
+	// and waiting for secret replication, must finish DeadlineReserve before the
+	// deadline, else they are cancelled and the password is rolled back before
+	// Lambda kills the function. If zero, DEFAULT_DEADLINE_RESERVE is used.
+	// If the context has no deadline, there are no time budgets.
+	DeadlineReserve time.Duration
+
+	// StepHooks are called before and after every Secrets Manager rotation step.
+	// If nil (the default), there are no hooks. See StepHooks for details.
+	StepHooks StepHooks
+
+	// StateStore persists the rotation state between steps, which run in separate
+	// Lambda invocations: the last completed step, when the password was set,
+	// and per-host results. If nil (the default), state is not persisted.
+	// DynamoDBStateStore implements this interface.
+	StateStore StateStore
+
+	// Locker locks the secret from CreateSecret to FinishSecret (or rollback)
+	// so concurrent rotations of the same secret cannot interleave. If nil
+	// (the default), there is no lock. DynamoDBLocker implements this interface.
+	Locker Locker
+
+	// UserCommands enables operational commands sent as user events with a
+	// "command" value, like {"command":"status","SecretId":"my-secret"}: status,
+	// force-finish, rollback, and abort (see COMMAND_STATUS and others). If false
+	// (the default), all user events go to SecretSetter.Handler.
+	UserCommands bool
+
+	// CleanupOnFailure removes the pending secret when setSecret or testSecret
+	// fails without rolling back, like when the databases cannot be reached, so
+	// the pending secret does not block the next rotation. It is removed only if
+	// the databases still use the current password. Enable only if failed steps
+	// are not retried because a retry fails without the pending secret. (Steps
+	// that roll back always remove the pending secret.)
+	CleanupOnFailure bool
+
+	// Canary is an optional application-level check of the new credentials in
+	// testSecret. If it fails, the new password is rolled back. See Canary.
+	Canary Canary
+
+	// Notifiers are called after finishSecret makes the new secret current to
+	// notify services that use the secret, like restarting an ECS service.
+	// See Notifier.
+	Notifiers []Notifier
+
+	// Mirrors copy the new secret to other stores, like a Kubernetes Secret,
+	// when finishSecret makes it current. If a mirror fails, finishSecret fails
+	// and is retried. See Mirror.
+	Mirrors []Mirror
+
+	// DiscardOldPassword discards the old password on the databases after the
+	// new secret is current, when the PasswordSetter retains it, like with
+	// mysql.RDSClientOptions.RetainCurrentPassword. The PasswordSetter must
+	// implement db.OldPasswordDiscarder. If DiscardGracePeriod is zero, finishSecret
+	// discards the old password after the Notifiers. Else, see DiscardGracePeriod.
+	// When the old password is discarded, EVENT_OLD_PASSWORD_DISCARDED is sent.
+	DiscardOldPassword bool
+
+	// DiscardGracePeriod is how long the old password keeps working after the
+	// new secret is current, so services that use the secret have time to get
+	// the new password. If non-zero, finishSecret does not discard the old
+	// password; instead, invoke the Lambda function on a schedule with the user
+	// event {"command":"discard-old-password","SecretId":"..."} (see
+	// COMMAND_DISCARD_OLD_PASSWORD), which discards it once the grace period
+	// has passed since the last rotation. UserCommands must be true.
+	DiscardGracePeriod time.Duration
+
+	// KillSessions kills the database sessions of the rotated users after the
+	// new secret is current, so clients that keep connections open reconnect
+	// with the new secret, instead of finding out that they do not have it
+	// when they reconnect later. The PasswordSetter must implement
+	// db.SessionKiller. finishSecret kills the sessions after the Notifiers and
+	// after discarding the old password (if DiscardOldPassword and no grace
+	// period), then sends EVENT_SESSIONS_KILLED. An error is logged but does
+	// not fail the rotation because the new secret is already current. To not
+	// kill some sessions, like long-running jobs, see mysql.Config.KeepSessions.
+	KillSessions bool
+
+	// ReportLingeringSessions counts the database sessions of the rotated users
+	// that are still connected with the old password at the end of finishSecret,
+	// after the Notifiers and KillSessions, and sends EVENT_LINGERING_SESSIONS
+	// with the counts per database hostname, so teams know which applications
+	// have not reconnected with the new secret. The PasswordSetter must implement
+	// db.SessionCounter. An error is logged but does not fail the rotation.
+	ReportLingeringSessions bool
+
+	// SecretCache caches GetSecretValue responses across invocations in a warm
+	// Lambda container. If nil (the default), every step gets the secrets from
+	// Secrets Manager. See SecretCache for details.
+	SecretCache *SecretCache
+}
+
+// Validate returns an error if the Config is not valid: a required value is
+// nil, a duration is negative, or options conflict. NewRotator does not return
+// an error, so call Validate before NewRotator to fail fast. Rotator.Handler
+// returns the same error on every Secrets Manager invocation if the Config
+// is not valid.
+func (c Config) Validate() error {
+	if c.SecretsManager == nil {
+		return fmt.Errorf("Config.SecretsManager is nil; it is required")
+	}
+	if c.PasswordSetter == nil {
+		return fmt.Errorf("Config.PasswordSetter is nil; it is required, even if SkipDatabase is true")
+	}
+	if c.ReplicationWait < 0 {
+		return fmt.Errorf("Config.ReplicationWait is negative: %s", c.ReplicationWait)
+	}
+	if c.ReplicationPollInterval < 0 {
+		return fmt.Errorf("Config.ReplicationPollInterval is negative: %s", c.ReplicationPollInterval)
+	}
+	if c.MaxReplicationPollInterval < 0 {
+		return fmt.Errorf("Config.MaxReplicationPollInterval is negative: %s", c.MaxReplicationPollInterval)
+	}
+	if c.DeadlineReserve < 0 {
+		return fmt.Errorf("Config.DeadlineReserve is negative: %s", c.DeadlineReserve)
+	}
+	if c.DiscardGracePeriod < 0 {
+		return fmt.Errorf("Config.DiscardGracePeriod is negative: %s", c.DiscardGracePeriod)
+	}
+	if c.DiscardOldPassword {
+		if _, ok := c.PasswordSetter.(db.OldPasswordDiscarder); !ok {
+			return fmt.Errorf("Config.DiscardOldPassword requires a PasswordSetter that implements db.OldPasswordDiscarder; %T does not", c.PasswordSetter)
+		}
+		if c.DiscardGracePeriod > 0 && !c.UserCommands {
+			return fmt.Errorf("Config.DiscardGracePeriod requires Config.UserCommands")
+		}
+	}
+	if c.KillSessions {
+		if _, ok := c.PasswordSetter.(db.SessionKiller); !ok {
+			return fmt.Errorf("Config.KillSessions requires a PasswordSetter that implements db.SessionKiller; %T does not", c.PasswordSetter)
+		}
+	}
+	if c.ReportLingeringSessions {
+		if _, ok := c.PasswordSetter.(db.SessionCounter); !ok {
+			return fmt.Errorf("Config.ReportLingeringSessions requires a PasswordSetter that implements db.SessionCounter; %T does not", c.PasswordSetter)
+		}
+	}
+	if c.FleetVerifier != nil && c.FleetVerifier.cfg.NewPasswordSetter == nil {
+		return fmt.Errorf("Config.FleetVerifier has nil FleetConfig.NewPasswordSetter; it is required")
+	}
+	if _, ok := c.RotationStrategy.(AlternatingUsers); ok {
+		if c.AdminSecretId == "" {
+			return fmt.Errorf("Config.RotationStrategy AlternatingUsers requires Config.AdminSecretId")
+		}
+		if _, ok := c.SecretSetter.(MultiCredentials); ok {
+			return fmt.Errorf("Config.RotationStrategy AlternatingUsers does not support a SecretSetter with MultiCredentials")
+		}
+	}
+	return nil
+}
+
+// PasswordValidator validates new passwords. See Config.PasswordPolicy.
+type PasswordValidator interface {
+	Validate(password string) error
 }
 
 // InvokedBySecretsManager returns true if the event is from Secrets Manager.
@@ -81,12 +306,6 @@ func InvokedBySecretsManager(event map[string]string) bool {
 	return haveToken && haveSecretId && haveStep
 }
 
-// Generic return error because errors are logged when/whey they occur so the log
-// output in CloudWatch Logs reads in the correct order.  Lambda logs the return
-// error last, of course, which makes it appear after "SetSecret return:" logs in
-// from defer funcs.
-var errRotationFailed = errors.New("Password rotation failed, see previous log output")
-
 // Rotator is the AWS Lambda function and handler. Create a new Rotator by
 // calling NewRotator, then use it in your main.go by calling lambda.Start(r.Handler)
 // where "r" is the new Rotator. See the documentation and examples for more details.
@@ -94,16 +313,44 @@ var errRotationFailed = errors.New("Password rotation failed, see previous log o
 // Currently, only secret string, not secret binary, is used and it must be
 // a JSON string with key-value pairs. See SecretSetter for details.
 type Rotator struct {
-	sm     secretsmanageriface.SecretsManagerAPI
+	sm     SecretsManager
 	ss     SecretSetter
 	db     db.PasswordSetter
-	event  EventReceiver
+	event  redactReceiver
 	skipDb bool
 	// --
 	clientRequestToken string
 	secretId           string
 	startTime          time.Time
 	replicationWait    time.Duration
+	replicationPoll    time.Duration // Config.ReplicationPollInterval
+	maxReplicationPoll time.Duration // Config.MaxReplicationPollInterval
+	tagPrefix          string
+	tags               secretTags
+	fleet              *FleetVerifier
+	policy             PasswordValidator
+	adminSecretId      string
+	admin              *db.Credentials
+	strategy           RotationStrategy
+	deadlineReserve    time.Duration
+	stepHooks          StepHooks
+	stateStore         StateStore
+	state              RotationState
+	stateMux           *sync.Mutex
+	locker             Locker
+	userCommands       bool
+	cleanupOnFailure   bool
+	canary             Canary
+	notifiers          []Notifier
+	mirrors            []Mirror
+	discardOld         bool
+	discardGrace       time.Duration
+	killSessions       bool
+	lingeringSessions  bool
+	hostAction         string // guarded by stateMux
+	middleware         []Middleware
+	stepResult         *stepResult
+	cfgErr             error
 }
 
 // NewRotator creates a new Rotator.
@@ -119,29 +366,127 @@ func NewRotator(cfg Config) *Rotator {
 	if ss == nil {
 		ss = RandomPassword{}
 	}
-	return &Rotator{
-		sm:              cfg.SecretsManager,
-		db:              cfg.PasswordSetter,
-		ss:              ss,
-		event:           event,
-		skipDb:          cfg.SkipDatabase,
-		replicationWait: cfg.ReplicationWait,
+	strategy := cfg.RotationStrategy
+	if strategy == nil {
+		if _, ok := ss.(MultiCredentials); ok {
+			strategy = MultiUser{}
+		} else {
+			strategy = SingleUser{}
+		}
+	}
+	deadlineReserve := cfg.DeadlineReserve
+	if deadlineReserve == 0 {
+		deadlineReserve = DEFAULT_DEADLINE_RESERVE
+	}
+	replicationPoll := cfg.ReplicationPollInterval
+	if replicationPoll == 0 {
+		replicationPoll = DEFAULT_REPLICATION_POLL_INTERVAL
+	}
+	maxReplicationPoll := cfg.MaxReplicationPollInterval
+	if maxReplicationPoll == 0 {
+		maxReplicationPoll = DEFAULT_MAX_REPLICATION_POLL_INTERVAL
+	}
+	r := &Rotator{
+		sm:                 cfg.SecretsManager,
+		db:                 cfg.PasswordSetter,
+		ss:                 ss,
+		event:              redactReceiver{r: event},
+		skipDb:             cfg.SkipDatabase,
+		replicationWait:    cfg.ReplicationWait,
+		replicationPoll:    replicationPoll,
+		maxReplicationPoll: maxReplicationPoll,
+		tagPrefix:          cfg.SecretTagPrefix,
+		fleet:              cfg.FleetVerifier,
+		policy:             cfg.PasswordPolicy,
+		adminSecretId:      cfg.AdminSecretId,
+		strategy:           strategy,
+		deadlineReserve:    deadlineReserve,
+		stepHooks:          cfg.StepHooks,
+		stateStore:         cfg.StateStore,
+		stateMux:           &sync.Mutex{},
+		stepResult:         newStepResult(),
+		locker:             cfg.Locker,
+		userCommands:       cfg.UserCommands,
+		cleanupOnFailure:   cfg.CleanupOnFailure,
+		canary:             cfg.Canary,
+		notifiers:          cfg.Notifiers,
+		mirrors:            cfg.Mirrors,
+		discardOld:         cfg.DiscardOldPassword,
+		discardGrace:       cfg.DiscardGracePeriod,
+		killSessions:       cfg.KillSessions,
+		lingeringSessions:  cfg.ReportLingeringSessions,
+		cfgErr:             cfg.Validate(),
 	}
+	if cfg.SecretCache != nil && cfg.SecretsManager != nil {
+		r.sm = cachedSecretsManager{
+			SecretsManager: cfg.SecretsManager,
+			cache:          cfg.SecretCache,
+			token:          func() string { return r.clientRequestToken },
+		}
+	}
+	return r
 }
 
 // Handler is the entry point for every invocation. This function is hooked into
 // the Lambda framework by calling lambda.Start(r.Handler) where "r" is the Rotator
 // returned by NewRotator.
 //
-// Use only this function. The other Rotator functions are exported only for testing.
+// Use only this function or RawHandler, which decodes any JSON object. The
+// other Rotator functions are exported only for testing.
+//
+// When a Secrets Manager step succeeds, Handler returns what the step did:
+// the step, secret ID, secret version IDs, run time, and database hosts.
+// See RESULT_STEP and the other RESULT_ constants. On error, it returns nil.
 func (r *Rotator) Handler(ctx context.Context, event map[string]string) (map[string]string, error) {
+	// Deliver all events before returning, in case Lambda freezes the function
+	defer r.flushEvents(ctx)
+
 	if !InvokedBySecretsManager(event) {
 		debug("user event: %+v", event)
+		if r.fleet != nil && event["action"] == FLEET_VERIFY_ACTION {
+			return r.fleet.Handler(ctx, event)
+		}
+		if r.userCommands && event["command"] != "" {
+			res, err := r.command(ctx, event)
+			return res, redactError(err)
+		}
 		return r.ss.Handler(ctx, event)
 	}
 
 	debug("Secrets Manager event: %+v", event)
 
+	if r.cfgErr != nil {
+		log.Printf("ERROR: invalid Config: %s", r.cfgErr)
+		return nil, r.cfgErr
+	}
+
+	// The start time is only valid for the same rotation (secret version).
+	// A warm Lambda function can run steps of different rotations.
+	if event["ClientRequestToken"] != r.clientRequestToken {
+		r.startTime = time.Time{}
+	}
+	r.clientRequestToken = event["ClientRequestToken"]
+	r.secretId = event["SecretId"]
+	r.event.secretId = r.secretId
+	r.event.versionId = r.clientRequestToken
+
+	// Read per-secret config from secret tags, if enabled. This must be done
+	// before Init because the tags can change how the PasswordSetter inits.
+	r.tags = secretTags{}
+	if r.tagPrefix != "" {
+		tags, err := r.readSecretTags()
+		if err != nil {
+			return nil, err
+		}
+		debug("secret tags: %v", tags.settings)
+		r.tags = tags
+		if t, ok := r.db.(db.Tunable); ok {
+			if err := t.Tune(tags.settings); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	// Initialize user-provided SecretSetter and PasswordSetter. On first call
 	// (invocation), these should set up any internal data, e.g. find and connect
 	// to all the db instances. These must be idempotent because we don't know
@@ -153,23 +498,43 @@ func (r *Rotator) Handler(ctx context.Context, event map[string]string) (map[str
 		return nil, err
 	}
 
-	r.clientRequestToken = event["ClientRequestToken"]
-	r.secretId = event["SecretId"]
 	step := event["Step"]
-	var err error
+
+	// Get admin credentials, if configured, for the steps that set the password.
+	// testSecret sets the password if it has to roll back.
+	r.admin = nil
+	if step == "setSecret" || step == "testSecret" {
+		if err := r.loadAdmin(); err != nil {
+			return nil, err
+		}
+	}
+
 	switch step {
-	case "createSecret":
-		err = r.CreateSecret(ctx, event)
-	case "setSecret":
-		err = r.SetSecret(ctx, event)
-	case "testSecret":
-		err = r.TestSecret(ctx, event)
-	case "finishSecret":
-		err = r.FinishSecret(ctx, event)
+	case "createSecret", "setSecret", "testSecret", "finishSecret":
 	default:
 		return nil, ErrInvalidStep
 	}
 
+	// Load rotation state from previous steps, if enabled
+	if err := r.loadState(ctx); err != nil {
+		return nil, err
+	}
+	r.stepResult = newStepResult()
+	if o, ok := r.db.(db.HostObservable); ok {
+		o.SetHostObserver(r)
+	}
+
+	// Run the step and all middleware, including StepHooks and StateStore
+	t0 := time.Now()
+	err := r.chain()(ctx, step, event)
+	r.event.Receive(Event{
+		Name:     EVENT_END_STEP,
+		Step:     step,
+		Time:     time.Now(),
+		Error:    err,
+		Duration: time.Now().Sub(t0),
+	})
+
 	if err != nil {
 		r.event.Receive(Event{
 			Name:  EVENT_ERROR,
@@ -177,14 +542,15 @@ func (r *Rotator) Handler(ctx context.Context, event map[string]string) (map[str
 			Step:  step,
 			Error: err,
 		})
+		return nil, redactError(err)
 	}
-	return nil, err
+	return r.result(step, time.Now().Sub(t0)), nil
 }
 
 // CreateSecret is the first step in the Secrets Manager rotation process.
 //
 // Do not call this function directly. It is exported only for testing.
-func (r *Rotator) CreateSecret(ctx context.Context, event map[string]string) error {
+func (r *Rotator) CreateSecret(ctx context.Context, event map[string]string) (err error) {
 	t0 := time.Now()
 	log.Println("CreateSecret call")
 	defer func() {
@@ -224,11 +590,34 @@ func (r *Rotator) CreateSecret(ctx context.Context, event map[string]string) err
 		https://docs.aws.amazon.com/secretsmanager/latest/userguide/rotating-secrets-lambda-function-overview.html
 	*/
 
-	r.event.Receive(Event{
+	if r.tags.window != nil && !r.tags.window.contains(time.Now()) {
+		log.Printf("not rotating: %s", ErrOutsideMaintenanceWindow)
+		return ErrOutsideMaintenanceWindow
+	}
+
+	// Lock the secret for this rotation, if enabled. This is idempotent, so
+	// it's ok on retry. If this step fails, there is no rollback, so unlock
+	// the secret else every retry (new token) is locked out until the lock
+	// expires.
+	if err := r.lock(ctx); err != nil {
+		log.Printf("not rotating: %s", err)
+		return err
+	}
+	defer func() {
+		if err != nil {
+			r.unlock(ctx)
+		}
+	}()
+
+	err = r.event.ReceiveVeto(Event{
 		Name: EVENT_BEGIN_ROTATION,
 		Step: "createSecret",
 		Time: time.Now(),
 	})
+	if err != nil {
+		log.Printf("not rotating: vetoed: %s", err)
+		return &RotationError{Step: "createSecret", Kind: ErrRotationVetoed, Err: err}
+	}
 
 	// Get current secret
 	curSec, curVals, err := r.getSecret(AWSCURRENT)
@@ -286,8 +675,12 @@ func (r *Rotator) CreateSecret(ctx context.Context, event map[string]string) err
 				// There's a pending secret and it's not ours. Something (or someone)
 				// else is rotating this secret at the same time.
 				debug("pending secret has different version id = %s", *penSec.VersionId)
-				return fmt.Errorf("another pending secret exists (version ID %s); "+
-					" another process might be rotating this secret, or a previous rotation failed without cleaning up", *penSec.VersionId)
+				return &RotationError{
+					Step: "createSecret",
+					Kind: ErrPendingConflict,
+					Err: fmt.Errorf("version ID %s; another process might be rotating this secret,"+
+						" or a previous rotation failed without cleaning up", *penSec.VersionId),
+				}
 			}
 		}
 	}
@@ -305,14 +698,49 @@ func (r *Rotator) CreateSecret(ctx context.Context, event map[string]string) err
 		newVals[k] = v
 	}
 
+	hooks, haveHooks := r.ss.(RotateHooks)
+	if haveHooks {
+		if err := hooks.BeforeRotate(curVals, newVals); err != nil {
+			return fmt.Errorf("BeforeRotate: %s", err)
+		}
+	}
+
 	// Have user-provided SecretSetter rotate the secret. Normally, it should
 	// just change the password, but it's free to change any secret values.
 	if err := r.ss.Rotate(newVals); err != nil {
 		return err
 	}
+
+	// Keep other secret values (host, port, etc.) that the SecretSetter does
+	// not own. See SecretKeyOwner.
+	preserveFields(r.ss, curVals, newVals)
+	redactSecret(r.ss, newVals)
+
+	// Have the RotationStrategy make its changes, like switching users
+	if err := r.strategy.Rotate(curVals, newVals); err != nil {
+		return err
+	}
+
+	// Let the SecretSetter set values derived from the new values
+	if haveHooks {
+		if err := hooks.AfterRotate(curVals, newVals); err != nil {
+			return fmt.Errorf("AfterRotate: %s", err)
+		}
+	}
 	debugSecret("new secret values: %v", newVals)
 
-	// Convert secret JSON to string
+	// Validate new password before it's put in Secrets Manager. Once put, it
+	// becomes AWSPENDING and the next steps try to set it on the databases.
+	if r.policy != nil {
+		for _, creds := range r.dbCreds(newVals, newVals).All() {
+			if err := r.policy.Validate(creds.New.Password); err != nil {
+				return fmt.Errorf("new password for %s is not valid: %s", creds.New.Username, err)
+			}
+		}
+	}
+
+	// Convert secret JSON to string. json.Marshal sorts map keys, so the key
+	// order is stable.
 	bytes, err := json.Marshal(newVals)
 	if err != nil {
 		return err
@@ -345,49 +773,45 @@ func (r *Rotator) SetSecret(ctx context.Context, event map[string]string) error
 		log.Printf("SetSecret return: %dms", d.Milliseconds())
 	}()
 
-	if r.skipDb {
+	if r.skipDatabase() {
 		log.Println("SkipDatabase is enabled, not rotating password on database")
 		return nil
 	}
 
 	// Get new, pending secret values from previous (first) step. Then have
 	// user-provided SecretSetter return the new user and pass from the secret.
-	_, newVals, err := r.getSecret(AWSPENDING)
+	newSecret, newVals, err := r.getSecret(AWSPENDING)
 	if err != nil {
 		return err
 	}
-	newUsername, newPassword := r.ss.Credentials(newVals)
+	if err := r.checkPending("setSecret", newSecret); err != nil {
+		return err
+	}
 
 	// And get current secret values in case setting new fails and we to roll back
 	_, curVals, err := r.getSecret(AWSCURRENT)
 	if err != nil {
 		return err
 	}
-	curUsername, curPassword := r.ss.Credentials(curVals)
-
-	curCred := db.Credentials{
-		Username: curUsername,
-		Password: curPassword,
-	}
-
-	newCred := db.Credentials{
-		Username: newUsername,
-		Password: newPassword,
-	}
 
 	// Combine the current and new credentials. This is plumbed all the way down
 	// into the db.PassswordSetter implementation.
-	creds := db.NewPassword{
-		Current: curCred,
-		New:     newCred,
-	}
+	creds := r.dbCreds(curVals, newVals)
 	debugSecret("db credentials: %+v", creds)
+
+	// Database calls must finish with enough time left to roll back, so they
+	// use the budget context. Rollback uses the original context.
+	bctx, cancel := r.budget(ctx)
+	defer cancel()
+
 	// Check to see if DB is already set to Pending password.
 	// This can happen if there's a previous run of the lambda crashed
 	// in TestSecret or FinishSecret steps.
-	// Treat this as if SetPassword has completed successfully.
+	// Treat this as if SetPassword has completed successfully. It usually
+	// fails, so it's a probe: the PasswordSetter does not wait for it to work.
 	log.Println("Verifying if DB is already set to AWSPENDING version of secret")
-	if err := r.db.VerifyPassword(ctx, creds); err == nil {
+	if err := r.db.VerifyPassword(db.WithProbe(bctx), creds); err == nil {
+		r.setInterrupted(false)
 		r.event.Receive(Event{
 			Name: EVENT_END_PASSWORD_ROTATION,
 			Step: "setSecret",
@@ -397,66 +821,103 @@ func (r *Rotator) SetSecret(ctx context.Context, event map[string]string) error
 		return nil
 	}
 
-	// Verify that credentials are valid before attempting to update secrets
-	// this is to guard against scenarios that DB is in mismatch state with secret managers
-	// AWSCURRENT version of the secret.  A couple of example of this is
-	// 1. Manual update of password in DB
-	// 2. Secret Manager secret is changed manually
-	log.Println("Verifying if AWSCURRENT version of secret is valid")
-	if err := r.db.VerifyPassword(ctx, db.NewPassword{Current: curCred, New: curCred}); err != nil {
-		log.Printf("ERROR: DB is not set to AWSCURRENT version of secret, attempting to verify AWSPREVIOUS version: %v", err)
-		// the current version of secret is out of sync with db.  check if db is in sync with
-		// the previous version of the secret
-		_, prevVals, err := r.getSecret(AWSPREVIOUS)
-		if err != nil {
-			r.event.Receive(Event{
-				Name: EVENT_BEGIN_PASSWORD_ROLLBACK,
-				Step: "setSecret",
-				Time: time.Now(),
-			})
-			log.Printf("ERROR: unable to retreive previous version of the credential. %v  "+
-				" starting rollback", err)
-
-			// calling rollback to remove AWSPENDING Label.
-			return r.rollback(ctx, creds, "SetSecret")
-		}
-		prevUsername, prevPassword := r.ss.Credentials(prevVals)
-		prevCred := db.Credentials{
-			Username: prevUsername,
-			Password: prevPassword,
-		}
-
-		if err := r.db.VerifyPassword(ctx, db.NewPassword{Current: prevCred, New: prevCred}); err != nil {
-			r.event.Receive(Event{
-				Name: EVENT_BEGIN_PASSWORD_ROLLBACK,
-				Step: "setSecret",
-				Time: time.Now(),
-			})
-			log.Printf("ERROR: all versions of credentials in secret manager is out of sync with db; %v starting rollback", err)
-
-			// calling rollback to remove AWSPENDING Label.
-			return r.rollback(ctx, creds, "SetSecret")
-		}
-		// update creds used for setting password since we've confirmed that DB is set to previousVersion of secrets
-		creds = db.NewPassword{
-			Current: prevCred,
-			New:     newCred,
+	// Resume if the previous invocation was interrupted while setting the new
+	// password. The databases have mixed passwords: the new password on the
+	// changed hosts and the current password on the others. So do not verify
+	// the current password, which fails on the changed hosts.
+	changed, resume := r.interrupted()
+	if resume {
+		log.Printf("Resuming interrupted SetPassword, new password already set on %d hosts: %v", len(changed), changed)
+	} else {
+		// Verify that credentials are valid before attempting to update secrets
+		// this is to guard against scenarios that DB is in mismatch state with secret managers
+		// AWSCURRENT version of the secret.  A couple of example of this is
+		// 1. Manual update of password in DB
+		// 2. Secret Manager secret is changed manually
+		log.Println("Verifying if AWSCURRENT version of secret is valid")
+		if curErr := r.db.VerifyPassword(bctx, r.dbCreds(curVals, curVals)); curErr != nil {
+			log.Printf("ERROR: DB is not set to AWSCURRENT version of secret, attempting to verify AWSPREVIOUS version: %v", curErr)
+			// the current version of secret is out of sync with db.  check if db is in sync with
+			// the previous version of the secret
+			_, prevVals, err := r.getSecret(AWSPREVIOUS)
+			if err != nil {
+				r.event.Receive(Event{
+					Name: EVENT_BEGIN_PASSWORD_ROLLBACK,
+					Step: "setSecret",
+					Time: time.Now(),
+				})
+				log.Printf("ERROR: unable to retreive previous version of the credential. %v  "+
+					" starting rollback", err)
+
+				// calling rollback to remove AWSPENDING Label.
+				return r.rollback(ctx, creds, "setSecret", ErrVerifyFailed, curErr)
+			}
+			if err := r.db.VerifyPassword(bctx, r.dbCreds(prevVals, prevVals)); err != nil {
+				r.event.Receive(Event{
+					Name: EVENT_BEGIN_PASSWORD_ROLLBACK,
+					Step: "setSecret",
+					Time: time.Now(),
+				})
+				log.Printf("ERROR: all versions of credentials in secret manager is out of sync with db; %v starting rollback", err)
+
+				// calling rollback to remove AWSPENDING Label.
+				return r.rollback(ctx, creds, "setSecret", ErrVerifyFailed, err)
+			}
+			// update creds used for setting password since we've confirmed that DB is set to previousVersion of secrets
+			creds = r.dbCreds(prevVals, newVals)
+			log.Println("DB is set to AWSPREVIOUS version of secret")
 		}
-		log.Println("DB is set to AWSPREVIOUS version of secret")
 	}
 
 	// Have user-provided PasswordSetter set database password to new value.
 	// Normally, this is when the database password actually changes.
 	// The PasswordSetter is responsible for knowing which db instances to change.
 	// mysql.PasswordSetter, for example, sets every RDS instance in parallel.
-	r.startTime = time.Now()
-	r.event.Receive(Event{
+	begin := Event{
 		Name: EVENT_BEGIN_PASSWORD_ROTATION,
 		Step: "setSecret",
-		Time: r.startTime,
-	})
+		Time: time.Now(),
+	}
+	if resume {
+		r.event.Receive(begin) // too late to veto: the password is partly changed
+	} else if err := r.event.ReceiveVeto(begin); err != nil {
+		log.Printf("not setting new password: vetoed: %s", err)
+		if rerr := r.removePending(ctx, r.clientRequestToken); rerr != nil {
+			log.Printf("ERROR: failed to remove pending secret: %s", rerr)
+		}
+		return &RotationError{Step: "setSecret", Kind: ErrRotationVetoed, Err: err}
+	}
+	if !resume || r.startTime.IsZero() {
+		r.startTime = begin.Time
+	}
+
+	if resume {
+		if rs, ok := r.db.(db.Resumable); ok {
+			rs.Resume(changed)
+		} else {
+			log.Printf("WARNING: PasswordSetter does not implement db.Resumable, setting new password on all hosts")
+		}
+	}
+	r.setHostAction(host_set)
+	setStart := time.Now()
+	err = r.db.SetPassword(bctx, creds)
+	r.setHostAction("")
+	if err != nil {
+		// If the Lambda context is cancelled, there is no time to roll back.
+		// Save the changed hosts (the state is saved after the step) so the
+		// retry can resume.
+		if ctx.Err() != nil {
+			log.Printf("ERROR: SetPassword interrupted: %s", err)
+			r.setInterrupted(true)
+			r.event.Receive(Event{
+				Name:  EVENT_PASSWORD_ROTATION_INTERRUPTED,
+				Step:  "setSecret",
+				Time:  time.Now(),
+				Error: err,
+			})
+			return &RotationError{Step: "setSecret", Kind: ErrInterrupted, Err: err}
+		}
 
-	if err := r.db.SetPassword(ctx, creds); err != nil {
 		// Roll back to original password since setting the new password failed.
 		// Depending on how the PasswordSetter is configured, this might be a no-op.
 		// Normally, we want to roll back so all dbs instances have the same
@@ -467,12 +928,15 @@ func (r *Rotator) SetSecret(ctx context.Context, event map[string]string) error
 			Step: "setSecret",
 			Time: time.Now(),
 		})
-		return r.rollback(ctx, creds, "SetSecret")
+		return r.rollback(ctx, creds, "setSecret", ErrSetPasswordFailed, err)
 	}
+	r.setInterrupted(false)
+	r.hostsFailed("setSecret", setStart)
 	r.event.Receive(Event{
-		Name: EVENT_END_PASSWORD_ROTATION,
-		Step: "setSecret",
-		Time: r.startTime,
+		Name:     EVENT_END_PASSWORD_ROTATION,
+		Step:     "setSecret",
+		Time:     r.startTime,
+		Duration: time.Now().Sub(r.startTime),
 	})
 
 	// At this point, the db password has been changed, but AWS Secrets Manager
@@ -492,47 +956,42 @@ func (r *Rotator) TestSecret(ctx context.Context, event map[string]string) error
 		log.Printf("TestSecret return: %dms", d.Milliseconds())
 	}()
 
-	if r.skipDb {
+	if r.skipDatabase() {
 		log.Println("SkipDatabase is enabled, not verifying password on database")
 		return nil
 	}
 
 	// Get new, pending secret values from previous (first) step. Then have
 	// user-provided SecretSetter return the new user and pass from the secret.
-	_, newVals, err := r.getSecret(AWSPENDING)
+	newSecret, newVals, err := r.getSecret(AWSPENDING)
 	if err != nil {
 		return err
 	}
-	newUsername, newPassword := r.ss.Credentials(newVals)
+	if err := r.checkPending("testSecret", newSecret); err != nil {
+		return err // not ours, so do not roll back
+	}
 
 	// And get current secret values in case setting new fails and we to roll back
 	_, curVals, err := r.getSecret(AWSCURRENT)
 	if err != nil {
 		return err
 	}
-	curUsername, curPassword := r.ss.Credentials(curVals)
 
 	// Combine the current and new credentials. This is plumbed all the way down
 	// into the db.PassswordSetter implementation.
-	creds := db.NewPassword{
-		Current: db.Credentials{
-			Username: curUsername,
-			Password: curPassword,
-		},
-		New: db.Credentials{
-			Username: newUsername,
-			Password: newPassword,
-		},
-	}
+	creds := r.dbCreds(curVals, newVals)
 	debugSecret("db credentials: %+v", creds)
+	bctx, cancel := r.budget(ctx)
+	defer cancel()
 
 	// Have user-provided PasswordSetter verify that new database password works
+	verifyStart := time.Now()
 	r.event.Receive(Event{
 		Name: EVENT_BEGIN_PASSWORD_VERIFICATION,
 		Step: "testSecret",
-		Time: time.Now(),
+		Time: verifyStart,
 	})
-	if err := r.db.VerifyPassword(ctx, creds); err != nil {
+	if err := r.db.VerifyPassword(bctx, creds); err != nil {
 		// Roll back to original password since new password doesn't work
 		log.Printf("ERROR: VerifyPassword failed, rollback: %s", err)
 		r.event.Receive(Event{
@@ -540,12 +999,28 @@ func (r *Rotator) TestSecret(ctx context.Context, event map[string]string) error
 			Step: "testSecret",
 			Time: time.Now(),
 		})
-		return r.rollback(ctx, creds, "TestSecret")
+		return r.rollback(ctx, creds, "testSecret", ErrVerifyFailed, err)
+	}
+	r.hostsFailed("testSecret", verifyStart)
+
+	// Have the application-level canary check the new password, if enabled
+	if r.canary != nil {
+		log.Println("Running canary")
+		if err := r.canary.Check(bctx, event, creds); err != nil {
+			log.Printf("ERROR: canary failed, rollback: %s", err)
+			r.event.Receive(Event{
+				Name: EVENT_BEGIN_PASSWORD_ROLLBACK,
+				Step: "testSecret",
+				Time: time.Now(),
+			})
+			return r.rollback(ctx, creds, "testSecret", ErrCanaryFailed, err)
+		}
 	}
 	r.event.Receive(Event{
-		Name: EVENT_END_PASSWORD_VERIFICATION,
-		Step: "testSecret",
-		Time: time.Now(),
+		Name:     EVENT_END_PASSWORD_VERIFICATION,
+		Step:     "testSecret",
+		Time:     time.Now(),
+		Duration: time.Now().Sub(verifyStart),
 	})
 
 	// At this point, AWS Secrets Manager still returns the old password.
@@ -564,77 +1039,275 @@ func (r *Rotator) FinishSecret(ctx context.Context, event map[string]string) err
 		log.Printf("FinishSecret return: %dms", d.Milliseconds())
 	}()
 
-	// Get current and new secrets so we can move the AWSPENDING/CURRENT label
-	// by secret ID
-	curSecret, _, err := r.getSecret(AWSCURRENT)
+	// Is the new secret already current? This happens on retry if a previous
+	// finishSecret moved AWSCURRENT but failed after, like waiting for replication.
+	// Then AWSPENDING might be gone, so the old secret is AWSPREVIOUS and the
+	// new secret is AWSCURRENT. Skip to cleanup.
+	stages, err := r.versionStages(r.clientRequestToken)
 	if err != nil {
 		return err
 	}
-	newSecret, _, err := r.getSecret(AWSPENDING)
-	if err != nil {
+	finished := stages[AWSCURRENT]
+
+	var curSecret, newSecret *secretsmanager.GetSecretValueOutput
+	var curVals, newVals map[string]string
+	if finished {
+		log.Printf("new secret (version id = %s) is already current, finishing", r.clientRequestToken)
+		if curSecret, curVals, err = r.getSecret(AWSPREVIOUS); err != nil {
+			return err
+		}
+		if newSecret, newVals, err = r.getSecret(AWSCURRENT); err != nil {
+			return err
+		}
+	} else {
+		// Get current and new secrets so we can move the AWSPENDING/CURRENT label
+		// by secret ID
+		if curSecret, curVals, err = r.getSecret(AWSCURRENT); err != nil {
+			return err
+		}
+		if newSecret, newVals, err = r.getSecret(AWSPENDING); err != nil {
+			return err
+		}
+		if err := r.checkPending("finishSecret", newSecret); err != nil {
+			return err
+		}
+	}
+
+	hooks, haveHooks := r.ss.(FinishHooks)
+	if haveHooks && !finished {
+		if err := hooks.BeforeFinish(curVals, newVals); err != nil {
+			return fmt.Errorf("BeforeFinish: %s", err)
+		}
+	}
+
+	var currentTime time.Time // when the new secret was made current, if known
+	if !finished {
+		// Move AWSCURRENT label from the current secret to the new. This makes the
+		// new secret current and automatically labels the old secret "previous".
+		debug("moving AWSCURRENT from version id = %v to version id = %v", *curSecret.VersionId, *newSecret.VersionId)
+		_, err = r.sm.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
+			SecretId:            aws.String(r.secretId),
+			RemoveFromVersionId: curSecret.VersionId,
+			MoveToVersionId:     newSecret.VersionId,
+			VersionStage:        aws.String(AWSCURRENT),
+		})
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		currentTime = now
+		downtime := r.passwordDowntime(now, newSecret)
+		r.event.Receive(Event{
+			Name:     EVENT_NEW_PASSWORD_IS_CURRENT,
+			Step:     "finishSecret",
+			Time:     now,
+			Duration: downtime,
+		})
+	}
+
+	// Copy the new secret to other stores, if any. On error, fail so Secrets
+	// Manager retries this step, which mirrors again, because the databases
+	// use the new password.
+	if err := r.mirror(ctx, curVals, newVals); err != nil {
 		return err
 	}
 
-	// Move AWSCURRENT label from the current secret to the new. This makes the
-	// new secret current and automatically labels the old secret "previous".
-	debug("moving AWSCURRENT from version id = %v to version id = %v", *curSecret.VersionId, *newSecret.VersionId)
-	_, err = r.sm.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
-		SecretId:            aws.String(r.secretId),
-		RemoveFromVersionId: curSecret.VersionId,
-		MoveToVersionId:     newSecret.VersionId,
-		VersionStage:        aws.String(AWSCURRENT),
-	})
+	// Wait for secret replication to complete to all replica regions
+	replStart := time.Now()
+	err = r.checkSecretReplicationStatus(ctx)
 	if err != nil {
 		return err
 	}
-	now := time.Now()
 	r.event.Receive(Event{
-		Name: EVENT_NEW_PASSWORD_IS_CURRENT,
-		Step: "finishSecret",
-		Time: now,
+		Name:     EVENT_SECRET_REPLICATED,
+		Step:     "finishSecret",
+		Time:     time.Now(),
+		Duration: time.Now().Sub(replStart),
 	})
 
-	downtime := now.Sub(r.startTime)
-	log.Printf("password downtime: %dms", downtime.Milliseconds())
+	// Remove AWSPENDING label, unless a previous try already removed it
+	if !finished || stages[AWSPENDING] {
+		debug("removing AWSPENDING from version id = %v", *newSecret.VersionId)
+		_, err = r.sm.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
+			SecretId:            aws.String(r.secretId),
+			RemoveFromVersionId: newSecret.VersionId,
+			VersionStage:        aws.String(AWSPENDING),
+		})
+		if err != nil {
+			log.Println(err)
+		}
+	}
 
-	// Wait for secret replication to complete to all replica regions
-	err = r.checkSecretReplicationStatus()
-	if err != nil {
-		return err
+	if haveHooks {
+		if err := hooks.AfterFinish(curVals, newVals); err != nil {
+			log.Printf("ERROR: AfterFinish: %s (ignored, new secret is current)", err)
+		}
 	}
 
-	// Remove AWSPENDING label
-	debug("removing AWSPENDING from version id = %v", *newSecret.VersionId)
-	_, err = r.sm.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
-		SecretId:            aws.String(r.secretId),
-		RemoveFromVersionId: newSecret.VersionId,
-		VersionStage:        aws.String(AWSPENDING),
-	})
-	if err != nil {
-		log.Println(err)
+	// Notify services that use the secret, if any
+	r.notify(ctx, *newSecret.VersionId)
+
+	// Discard the old password now, unless there's a grace period
+	if r.discardOld && r.discardGrace == 0 {
+		if err := r.discardOldPassword(ctx, r.dbCreds(curVals, newVals), "finishSecret", currentTime); err != nil {
+			log.Printf("ERROR: discard old password: %s (ignored, new secret is current)", err)
+		}
 	}
 
-	r.event.Receive(Event{
+	// Kill sessions that might use the old password, so clients reconnect
+	if r.killSessions {
+		if err := r.killOldSessions(ctx, r.dbCreds(curVals, newVals)); err != nil {
+			log.Printf("ERROR: kill sessions: %s (ignored, new secret is current)", err)
+		}
+	}
+	if r.lingeringSessions {
+		if err := r.reportLingeringSessions(ctx, r.dbCreds(curVals, newVals)); err != nil {
+			log.Printf("ERROR: count lingering sessions: %s (ignored, new secret is current)", err)
+		}
+	}
+
+	// Rotation time is from when createSecret put the new secret, if known
+	end := Event{
 		Name: EVENT_END_ROTATION,
 		Step: "finishSecret",
 		Time: time.Now(),
-	})
+	}
+	if newSecret.CreatedDate != nil {
+		end.Duration = end.Time.Sub(*newSecret.CreatedDate)
+	}
+	r.event.Receive(end)
+
+	r.unlock(ctx)
 
 	return nil
 }
 
 // --------------------------------------------------------------------------
 
+// checkPending returns a RotationError with Kind ErrPendingConflict if the
+// pending secret is not the version of this rotation (the ClientRequestToken).
+// This happens when two rotations of the secret race: both put a pending
+// secret in createSecret, and the second took the AWSPENDING label from the
+// first. The first (the loser) must stop without changing or rolling back the
+// databases, which the second (the winner) is rotating to its pending secret.
+// Set Config.Locker to keep the rotations from interleaving at all.
+func (r *Rotator) checkPending(step string, pending *secretsmanager.GetSecretValueOutput) error {
+	if v := aws.StringValue(pending.VersionId); v != r.clientRequestToken {
+		return &RotationError{
+			Step: step,
+			Kind: ErrPendingConflict,
+			Err:  fmt.Errorf("version ID %s, not this rotation's version ID %s; another rotation of this secret took over", v, r.clientRequestToken),
+		}
+	}
+	return nil
+}
+
+// versionStages returns the stages of the secret version. The map is empty
+// if the version does not exist or has no stages.
+func (r *Rotator) versionStages(versionId string) (map[string]bool, error) {
+	stages := map[string]bool{}
+	out, err := r.sm.DescribeSecret(&secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(r.secretId),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if out == nil {
+		return stages, nil
+	}
+	for _, stage := range out.VersionIdsToStages[versionId] {
+		stages[aws.StringValue(stage)] = true
+	}
+	return stages, nil
+}
+
+// loadAdmin gets the admin credentials if Config.AdminSecretId is set.
+func (r *Rotator) loadAdmin() error {
+	if r.adminSecretId == "" {
+		return nil
+	}
+	_, adminVals, err := getSecret(r.sm, r.adminSecretId, AWSCURRENT)
+	if err != nil {
+		return fmt.Errorf("cannot get admin secret %s: %s", r.adminSecretId, err)
+	}
+	r.admin = &db.Credentials{
+		Username: adminVals["username"],
+		Password: adminVals["password"],
+	}
+	debug("using admin %s", r.admin.Username)
+	return nil
+}
+
+// skipDatabase returns true if Config.SkipDatabase or the skip-database secret
+// tag is true.
+func (r *Rotator) skipDatabase() bool {
+	return r.skipDb || r.tags.skipDatabase
+}
+
+// dbCreds returns the database credentials from the current and new secret
+// values using the RotationStrategy. Admin is set if Config.AdminSecretId is set.
+// Changed is set to the secret keys that differ between the values.
+func (r *Rotator) dbCreds(curVals, newVals map[string]string) db.NewPassword {
+	creds := r.strategy.Credentials(r.ss, curVals, newVals)
+	creds.Admin = r.admin
+	creds.Changed = changedFields(curVals, newVals)
+	return creds
+}
+
+// passwordDowntime returns the time from when SetSecret set the new password
+// to now. SetSecret runs in a different invocation, so the set time is known
+// only if the state store is enabled or the Lambda function is warm. Else,
+// the pending version created date is used, which is an upper bound because
+// CreateSecret creates it before SetSecret. Zero is returned if unknown.
+func (r *Rotator) passwordDowntime(now time.Time, newSecret *secretsmanager.GetSecretValueOutput) time.Duration {
+	switch {
+	case !r.startTime.IsZero():
+		d := now.Sub(r.startTime)
+		log.Printf("password downtime: %dms", d.Milliseconds())
+		return d
+	case newSecret.CreatedDate != nil:
+		d := now.Sub(*newSecret.CreatedDate)
+		log.Printf("password downtime: %dms (upper bound: since pending secret created)", d.Milliseconds())
+		return d
+	}
+	log.Println("password downtime: unknown")
+	return 0
+}
+
+// budget returns a context that is cancelled DeadlineReserve before the
+// Lambda invocation deadline, so there is time to roll back. If ctx has no
+// deadline, it returns ctx.
+func (r *Rotator) budget(ctx context.Context) (context.Context, context.CancelFunc) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return ctx, func() {}
+	}
+	budget := deadline.Add(-r.deadlineReserve)
+	log.Printf("time budget: %dms (%dms reserved for rollback)", time.Until(budget).Milliseconds(), r.deadlineReserve.Milliseconds())
+	return context.WithDeadline(ctx, budget)
+}
+
 func (r *Rotator) getSecret(stage string) (*secretsmanager.GetSecretValueOutput, map[string]string, error) {
+	s, v, err := getSecret(r.sm, r.secretId, stage)
+	if err == nil {
+		redactSecret(r.ss, v)
+		r.stateMux.Lock()
+		r.stepResult.versions[stage] = aws.StringValue(s.VersionId)
+		r.stateMux.Unlock()
+	}
+	return s, v, err
+}
+
+func getSecret(sm SecretsManager, secretId, stage string) (*secretsmanager.GetSecretValueOutput, map[string]string, error) {
 	// Fetch secret from Secrets Manager
-	s, err := r.sm.GetSecretValue(&secretsmanager.GetSecretValueInput{
-		SecretId:     aws.String(r.secretId),
+	s, err := sm.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(secretId),
 		VersionStage: aws.String(stage),
 	})
 	if err != nil {
 		return nil, nil, err
 	}
-	debug("%s stage %s version %v", r.secretId, stage, *s.VersionId)
+	debug("%s stage %s version %v", secretId, stage, *s.VersionId)
 
 	if s.SecretString == nil || *s.SecretString == "" {
 		return s, nil, fmt.Errorf("secret string is nil or empty string; " +
@@ -649,22 +1322,26 @@ func (r *Rotator) getSecret(stage string) (*secretsmanager.GetSecretValueOutput,
 		return s, nil, fmt.Errorf("secret string is 'null' literal; " +
 			"it must be valid JSON like '{\"username\":\"foo\",\"password\":\"bar\"}'")
 	}
+	redactSecret(nil, v)
 	debugSecret("%s secret values: %v", stage, *s.SecretString)
 
 	return s, v, nil
 }
 
-func (r *Rotator) rollback(ctx context.Context, creds db.NewPassword, rotationStep string) error {
-	if err := r.db.Rollback(ctx, creds); err != nil {
+func (r *Rotator) rollback(ctx context.Context, creds db.NewPassword, rotationStep string, kind, cause error) error {
+	r.setHostAction(host_rollback)
+	err := r.db.Rollback(ctx, creds)
+	r.setHostAction("")
+	if err != nil {
 		log.Printf("ERROR: Rollback failed: %s", err)
-		return errRotationFailed
+		return &RotationError{Step: rotationStep, Kind: ErrRollbackFailed, Err: err}
 	}
 
 	// Remove pending secret and clear the cache, i.e. roll back Secrets Manager
 	// to point before this rotation
 	newSecret, _, err := r.getSecret(AWSPENDING)
 	if err != nil {
-		return err
+		return &RotationError{Step: rotationStep, Kind: ErrRollbackFailed, Err: err}
 	}
 	debug("removing AWSPENDING from version id = %v", *newSecret.VersionId)
 	_, err = r.sm.UpdateSecretVersionStage(&secretsmanager.UpdateSecretVersionStageInput{
@@ -674,26 +1351,34 @@ func (r *Rotator) rollback(ctx context.Context, creds db.NewPassword, rotationSt
 	})
 	if err != nil {
 		log.Printf("ERROR: failed to remove pending secret: %s", err)
-		return errRotationFailed
+		return &RotationError{Step: rotationStep, Kind: ErrRollbackFailed, Err: err}
 	}
 
 	log.Printf("%s failed but rollback was successful", rotationStep)
+	r.unlock(ctx)
 
-	return errRotationFailed // always return this error
+	return &RotationError{Step: rotationStep, Kind: kind, Err: cause}
 }
 
 // checks that secret have been replicated to all replica regions
 // this is necessary between multiple calls of UpdateSecretVersionStage
 // to guard against arace condition in AWS that leaves secret replication
 // stuck indefinitely.
-func (r *Rotator) checkSecretReplicationStatus() error {
+func (r *Rotator) checkSecretReplicationStatus(ctx context.Context) error {
 	log.Println("checking secret replication status")
 	waitDuration := DEFAULT_REPLICATION_WAIT
 	if r.replicationWait > 0 {
 		waitDuration = r.replicationWait
 	}
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline) - r.deadlineReserve; left < waitDuration {
+			log.Printf("replication wait reduced from %s to %s by Lambda deadline", waitDuration, left)
+			waitDuration = left
+		}
+	}
 
 	startTime := time.Now()
+	poll := r.replicationPoll
 	for time.Now().Sub(startTime) < waitDuration {
 		secret, err := r.sm.DescribeSecret(&secretsmanager.DescribeSecretInput{
 			SecretId: aws.String(r.secretId),
@@ -723,9 +1408,33 @@ func (r *Rotator) checkSecretReplicationStatus() error {
 			log.Println("secret replication sync completed successfully")
 			return nil // success
 		}
-		time.Sleep(500 * time.Millisecond)
+		time.Sleep(r.replicationPollWait(poll, waitDuration-time.Now().Sub(startTime)))
+		if poll *= 2; poll > r.maxReplicationPoll {
+			poll = r.maxReplicationPoll
+		}
+	}
+	return &RotationError{
+		Step: "finishSecret",
+		Kind: ErrReplicationTimeout,
+		Err:  fmt.Errorf("not all replicas have status %s after %s", secretsmanager.StatusTypeInSync, waitDuration),
+	}
+}
+
+// replicationPollWait returns the wait before the next DescribeSecret call in
+// checkSecretReplicationStatus: between half and all of poll (random jitter),
+// but no more than left, the time left to wait for replication.
+func (r *Rotator) replicationPollWait(poll, left time.Duration) time.Duration {
+	if poll > r.maxReplicationPoll {
+		poll = r.maxReplicationPoll
+	}
+	wait := poll/2 + time.Duration(rand.Int63n(int64(poll/2)+1))
+	if wait > left {
+		wait = left
 	}
-	return fmt.Errorf("timeout waiting for secret replication StatusTypeInSync = true")
+	if wait < 0 {
+		return 0
+	}
+	return wait
 }
 
 // --------------------------------------------------------------------------
@@ -736,16 +1445,34 @@ var (
 	Debug = false
 
 	// DebugSecret IS DANGEROUS: it prints secret values to STDERR when Debug is enabled.
-	// If Debug is false (disabled), this value is ignored.
+	// Its output is not redacted (see RedactValue). If Debug is false (disabled),
+	// this value is ignored.
 	//
 	// Be very careful enabling this!
 	DebugSecret = false
 
-	debugLog = log.New(os.Stderr, "DEBUG ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile|log.LUTC)
+	debugLog = log.New(RedactWriter{W: os.Stderr}, "DEBUG ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile|log.LUTC)
+
+	// debugSecretLog is not redacted because DebugSecret is meant to print secret values
+	debugSecretLog = log.New(os.Stderr, "DEBUG ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile|log.LUTC)
 
 	// DEFAULT_REPLICATION_WAIT is the default duration that password rotation lambda will
 	// wait for secret replication to secondary regions to complete
 	DEFAULT_REPLICATION_WAIT = 30 * time.Second
+
+	// DEFAULT_REPLICATION_POLL_INTERVAL is the default first wait between
+	// DescribeSecret calls that check secret replication. See
+	// Config.ReplicationPollInterval.
+	DEFAULT_REPLICATION_POLL_INTERVAL = 500 * time.Millisecond
+
+	// DEFAULT_MAX_REPLICATION_POLL_INTERVAL is the default maximum wait between
+	// DescribeSecret calls that check secret replication. See
+	// Config.MaxReplicationPollInterval.
+	DEFAULT_MAX_REPLICATION_POLL_INTERVAL = 5 * time.Second
+
+	// DEFAULT_DEADLINE_RESERVE is the default time reserved at the end of the
+	// Lambda invocation to roll back. See Config.DeadlineReserve.
+	DEFAULT_DEADLINE_RESERVE = 10 * time.Second
 )
 
 func debugSecret(msg string, v ...interface{}) {
@@ -754,7 +1481,7 @@ func debugSecret(msg string, v ...interface{}) {
 	}
 	_, file, line, _ := runtime.Caller(1)
 	msg = fmt.Sprintf("%s:%d %s", path.Base(file), line, msg)
-	debugLog.Printf(msg, v...)
+	debugSecretLog.Printf(msg, v...)
 }
 
 func debug(msg string, v ...interface{}) {
@@ -211,6 +211,65 @@ func TestStepCreateSecretRetry(t *testing.T) {
 	}
 }
 
+func TestStepCreateSecretPasswordPolicy(t *testing.T) {
+	// Test that the "createSecret" step validates the new password with
+	// Config.PasswordPolicy and does not put an invalid password as pending
+	var gotPutInput *secretsmanager.PutSecretValueInput
+
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					ARN:           aws.String("arn"),
+					Name:          aws.String("sercetName"),
+					SecretString:  &secretString1,
+					VersionId:     aws.String("v1"),
+					VersionStages: []*string{aws.String(rotate.AWSCURRENT)},
+					CreatedDate:   &now,
+				}, nil
+			default:
+				return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+			}
+		},
+		PutSecretValueFunc: func(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+			gotPutInput = input
+			return &secretsmanager.PutSecretValueOutput{}, nil
+		},
+	}
+
+	// Generates a password with a single quote which the policy excludes
+	ss := test.MockSecretSetter{
+		RotateFunc: func(secret map[string]string) error {
+			secret["password"] = "it's"
+			return nil
+		},
+		CredentialsFunc: func(secret map[string]string) (string, string) {
+			return secret["username"], secret["password"]
+		},
+	}
+
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter:   ss,
+		PasswordSetter: test.MockPasswordSetter{},
+		PasswordPolicy: rotate.PasswordPolicy{Exclude: "'"},
+	})
+
+	event := map[string]string{
+		"ClientRequestToken": "abc",
+		"SecretId":           "def",
+		"Step":               "createSecret",
+	}
+	_, err := r.Handler(context.TODO(), event)
+	if err == nil {
+		t.Error("no error, expected error for invalid password")
+	}
+	if gotPutInput != nil {
+		t.Errorf("new pending secret put, expected nil: %+v", *gotPutInput)
+	}
+}
+
 func TestStepSetSecret(t *testing.T) {
 	// Test that the "setSecret" step gets both secrets (current and pending),
 	// gets the db creds from pending, and sets them via PasswordSetter. This is
@@ -279,6 +338,7 @@ func TestStepSetSecret(t *testing.T) {
 	}
 
 	var gotUsername, gotPassword string
+	var gotProbes []bool
 	ps := test.MockPasswordSetter{
 		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
 			gotUsername = creds.New.Username
@@ -286,6 +346,7 @@ func TestStepSetSecret(t *testing.T) {
 			return nil
 		},
 		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotProbes = append(gotProbes, db.IsProbe(ctx))
 			if creds.New.Password == expectedSecret {
 				return fmt.Errorf("fail to get to set password")
 			}
@@ -302,7 +363,7 @@ func TestStepSetSecret(t *testing.T) {
 
 	// Simulate setSecret event from Secrets Manager
 	event := map[string]string{
-		"ClientRequestToken": "abc",
+		"ClientRequestToken": "v2",
 		"SecretId":           "def",
 		"Step":               "setSecret",
 	}
@@ -318,6 +379,12 @@ func TestStepSetSecret(t *testing.T) {
 		t.Errorf("got password %s, expected \"p2\"", gotPassword)
 	}
 
+	// Checking if the pending password is already set is a probe (expected to
+	// fail), verifying the current password is not
+	if diff := deep.Equal(gotProbes, []bool{true, false}); diff != nil {
+		t.Error(diff)
+	}
+
 	// The code should not call UpdateSecretVersionStage. That doesn't happen
 	// until the last step.
 	if updateSecretVersionCalled {
@@ -431,7 +498,7 @@ func TestStepSetSecret_DBAlreadySetToPending(t *testing.T) {
 
 	// Simulate setSecret event from Secrets Manager
 	event := map[string]string{
-		"ClientRequestToken": "abc",
+		"ClientRequestToken": "v2",
 		"SecretId":           "def",
 		"Step":               "setSecret",
 	}
@@ -568,7 +635,7 @@ func TestStepSetSecret_DBSetToPrevoius(t *testing.T) {
 
 	// Simulate setSecret event from Secrets Manager
 	event := map[string]string{
-		"ClientRequestToken": "abc",
+		"ClientRequestToken": "v2",
 		"SecretId":           "def",
 		"Step":               "setSecret",
 	}
@@ -705,7 +772,7 @@ func TestStepSetSecret_DBMismatch(t *testing.T) {
 
 	// Simulate setSecret event from Secrets Manager
 	event := map[string]string{
-		"ClientRequestToken": "abc",
+		"ClientRequestToken": "v2",
 		"SecretId":           "def",
 		"Step":               "setSecret",
 	}
@@ -815,7 +882,7 @@ func TestStepTestSecret(t *testing.T) {
 
 	// Simulate testSecret event from Secrets Manager
 	event := map[string]string{
-		"ClientRequestToken": "abc",
+		"ClientRequestToken": "v2",
 		"SecretId":           "def",
 		"Step":               "testSecret",
 	}
@@ -896,7 +963,7 @@ func TestStepFinishSecret(t *testing.T) {
 
 	// Simulate testSecret event from Secrets Manager
 	event := map[string]string{
-		"ClientRequestToken": "abc",
+		"ClientRequestToken": "v2",
 		"SecretId":           "def",
 		"Step":               "finishSecret",
 	}
@@ -980,7 +1047,7 @@ func TestStepFinishSecretFailure(t *testing.T) {
 
 	// Simulate testSecret event from Secrets Manager
 	event := map[string]string{
-		"ClientRequestToken": "abc",
+		"ClientRequestToken": "v2",
 		"SecretId":           "def",
 		"Step":               "finishSecret",
 	}
@@ -998,6 +1065,61 @@ func TestStepFinishSecretFailure(t *testing.T) {
 	}
 }
 
+func TestStepFinishSecretReplicationBackoff(t *testing.T) {
+	// Test that the wait between DescribeSecret calls that check replication
+	// starts at ReplicationPollInterval and doubles up to MaxReplicationPollInterval
+	fake := test.NewFakeSecretsManager()
+	fake.AddSecret("def", map[string]string{"username": "foo", "password": "p1"})
+	var calls []time.Time
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc:           fake.GetSecretValue,
+		UpdateSecretVersionStageFunc: fake.UpdateSecretVersionStage,
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			calls = append(calls, time.Now())
+			out, err := fake.DescribeSecret(input)
+			if err != nil {
+				return nil, err
+			}
+			out.ReplicationStatus = []*secretsmanager.ReplicationStatusType{
+				{Region: aws.String("us-west-2"), Status: aws.String(secretsmanager.StatusTypeInProgress)},
+			}
+			return out, nil
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager:             sm,
+		PasswordSetter:             test.MockPasswordSetter{},
+		ReplicationWait:            500 * time.Millisecond,
+		ReplicationPollInterval:    10 * time.Millisecond,
+		MaxReplicationPollInterval: 80 * time.Millisecond,
+	})
+	fake.PutSecretValue(&secretsmanager.PutSecretValueInput{
+		SecretId:           aws.String("def"),
+		ClientRequestToken: aws.String("v2"),
+		SecretString:       aws.String(`{"username":"foo","password":"p2"}`),
+		VersionStages:      []*string{aws.String(rotate.AWSPENDING)},
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err == nil {
+		t.Fatal("no error, expected ErrReplicationTimeout")
+	}
+
+	// A constant 10ms poll makes about 50 calls in 500ms; backoff about 10
+	if len(calls) < 4 || len(calls) > 20 {
+		t.Fatalf("got %d DescribeSecret calls, expected 4 to 20", len(calls))
+	}
+	replication := calls[len(calls)-4:] // the first calls are not replication checks
+	for i := 1; i < len(replication)-1; i++ {
+		if gap := replication[i].Sub(replication[i-1]); gap < 40*time.Millisecond || gap > 150*time.Millisecond {
+			t.Errorf("wait %d is %s, expected 40ms to 80ms (half to all of MaxReplicationPollInterval)", i, gap)
+		}
+	}
+}
+
 func TestUserInvoke(t *testing.T) {
 	// Test that when the user invokes the lambda, not Secrets Manager, the
 	// SecretSetter.Handler method is called and its return value is returned
@@ -1029,3 +1151,384 @@ func TestUserInvoke(t *testing.T) {
 		t.Log(diff)
 	}
 }
+
+func TestStepSetSecretAdmin(t *testing.T) {
+	// Test that the "setSecret" step gets the admin secret when Config.AdminSecretId
+	// is set and passes the admin credentials to the PasswordSetter
+	gotSecretIds := map[string]bool{}
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			gotSecretIds[*input.SecretId] = true
+			if *input.SecretId == "admin" {
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: aws.String(`{"username":"root","password":"secret"}`),
+					VersionId:    aws.String("a1"),
+				}, nil
+			}
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+	}
+
+	var gotCreds db.NewPassword
+	ps := test.MockPasswordSetter{
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.New.Password == "p2" {
+				return fmt.Errorf("not set yet")
+			}
+			return nil
+		},
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			gotCreds = creds
+			return nil
+		},
+	}
+
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter:   rotate.RandomPassword{},
+		PasswordSetter: ps,
+		AdminSecretId:  "admin",
+	})
+
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Error(err)
+	}
+
+	expectCreds := db.NewPassword{
+		Current: db.Credentials{Username: "foo", Password: "p1", Extra: map[string]string{"v": "1"}},
+		New:     db.Credentials{Username: "foo", Password: "p2", Extra: map[string]string{"v": "2"}},
+		Admin:   &db.Credentials{Username: "root", Password: "secret"},
+		Changed: []string{"password", "v"},
+	}
+	if diff := deep.Equal(gotCreds, expectCreds); diff != nil {
+		t.Error(diff)
+	}
+	if !gotSecretIds["admin"] || !gotSecretIds["def"] {
+		t.Errorf("got secret IDs %v, expected admin and def", gotSecretIds)
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := rotate.Config{
+		SecretsManager: test.MockSecretsManager{},
+		PasswordSetter: test.MockPasswordSetter{},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("got error for valid config: %s", err)
+	}
+
+	invalid := map[string]rotate.Config{
+		"no SecretsManager": {
+			PasswordSetter: test.MockPasswordSetter{},
+		},
+		"no PasswordSetter": {
+			SecretsManager: test.MockSecretsManager{},
+			SkipDatabase:   true,
+		},
+		"negative ReplicationWait": {
+			SecretsManager:  test.MockSecretsManager{},
+			PasswordSetter:  test.MockPasswordSetter{},
+			ReplicationWait: -1 * time.Second,
+		},
+		"negative ReplicationPollInterval": {
+			SecretsManager:          test.MockSecretsManager{},
+			PasswordSetter:          test.MockPasswordSetter{},
+			ReplicationPollInterval: -1 * time.Second,
+		},
+		"negative MaxReplicationPollInterval": {
+			SecretsManager:             test.MockSecretsManager{},
+			PasswordSetter:             test.MockPasswordSetter{},
+			MaxReplicationPollInterval: -1 * time.Second,
+		},
+		"AlternatingUsers without admin": {
+			SecretsManager:   test.MockSecretsManager{},
+			PasswordSetter:   test.MockPasswordSetter{},
+			RotationStrategy: rotate.AlternatingUsers{},
+		},
+	}
+	for name, cfg := range invalid {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: no error, expected an error", name)
+		}
+	}
+
+	// Handler returns the error instead of panicking on nil PasswordSetter
+	r := rotate.NewRotator(invalid["no PasswordSetter"])
+	event := map[string]string{
+		"ClientRequestToken": "abc",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err == nil {
+		t.Error("no error from Handler, expected config error")
+	}
+}
+
+func TestStepSetSecretDeadline(t *testing.T) {
+	// Test that SetPassword is cancelled DeadlineReserve before the Lambda
+	// deadline, and Rollback gets the original context with time left
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+	}
+
+	var setErr, rollbackErr error
+	rolledBack := false
+	ps := test.MockPasswordSetter{
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.New.Password == "p2" {
+				return fmt.Errorf("not set yet")
+			}
+			return nil
+		},
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			setErr = ctx.Err()
+			return setErr
+		},
+		RollbackFunc: func(ctx context.Context, creds db.NewPassword) error {
+			rolledBack = true
+			rollbackErr = ctx.Err()
+			return nil
+		},
+	}
+
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager:  sm,
+		PasswordSetter:  ps,
+		DeadlineReserve: 5 * time.Second,
+	})
+
+	// 1s left in invocation, less than the 5s reserve, so the budget is
+	// already used up
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+	if _, err := r.Handler(ctx, event); err == nil {
+		t.Error("no error, expected rotation failed error")
+	}
+	if setErr != context.DeadlineExceeded {
+		t.Errorf("SetPassword got context error %v, expected %v", setErr, context.DeadlineExceeded)
+	}
+	if !rolledBack {
+		t.Error("Rollback not called")
+	}
+	if rollbackErr != nil {
+		t.Errorf("Rollback got context error %v, expected nil", rollbackErr)
+	}
+}
+
+func TestStepFinishSecretDowntime(t *testing.T) {
+	// Test that password downtime is from the pending secret created date when
+	// SetSecret ran in a different invocation
+	created := time.Now().Add(-1 * time.Minute)
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+					CreatedDate:  &created,
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{}, nil
+		},
+	}
+	var downtime time.Duration
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{},
+		EventReceiver: eventRecorder(func(e rotate.Event) {
+			if e.Name == rotate.EVENT_NEW_PASSWORD_IS_CURRENT {
+				downtime = e.Duration
+			}
+		}),
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if downtime < time.Minute || downtime > 2*time.Minute {
+		t.Errorf("got downtime %s, expected about 1m", downtime)
+	}
+}
+
+func TestStepFinishSecretRetry(t *testing.T) {
+	// Test that finishSecret is idempotent: on retry after the new secret (v2)
+	// was made current and AWSPENDING was removed, it finishes without moving
+	// AWSCURRENT again
+	var gotUpdates []*secretsmanager.UpdateSecretVersionStageInput
+	var pending bool
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSPREVIOUS:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+			if pending {
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+			return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "not found", nil)
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			gotUpdates = append(gotUpdates, input)
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			stages := []*string{aws.String(rotate.AWSCURRENT)}
+			if pending {
+				stages = append(stages, aws.String(rotate.AWSPENDING))
+			}
+			return &secretsmanager.DescribeSecretOutput{
+				VersionIdsToStages: map[string][]*string{
+					"v1": {aws.String(rotate.AWSPREVIOUS)},
+					"v2": stages,
+				},
+			}, nil
+		},
+	}
+	var gotEvents []string
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: test.MockPasswordSetter{},
+		EventReceiver: eventRecorder(func(e rotate.Event) {
+			gotEvents = append(gotEvents, e.Name)
+		}),
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(gotUpdates) != 0 {
+		t.Errorf("got %d stage updates, expected none", len(gotUpdates))
+	}
+	expect := []string{rotate.EVENT_SECRET_REPLICATED, rotate.EVENT_END_ROTATION, rotate.EVENT_END_STEP}
+	if diff := deep.Equal(gotEvents, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// If AWSPENDING was not removed, only it is removed
+	pending = true
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(gotUpdates) != 1 || *gotUpdates[0].VersionStage != rotate.AWSPENDING || *gotUpdates[0].RemoveFromVersionId != "v2" {
+		t.Errorf("got stage updates %v, expected only AWSPENDING removed from v2", gotUpdates)
+	}
+}
+
+// minimalSecretsManager implements only rotate.SecretsManager, not the full
+// secretsmanageriface.SecretsManagerAPI, like a mock in a downstream test.
+type minimalSecretsManager struct {
+	fake *test.FakeSecretsManager
+}
+
+var _ rotate.SecretsManager = minimalSecretsManager{}
+
+func (m minimalSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	return m.fake.GetSecretValue(input)
+}
+
+func (m minimalSecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	return m.fake.PutSecretValue(input)
+}
+
+func (m minimalSecretsManager) UpdateSecretVersionStage(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	return m.fake.UpdateSecretVersionStage(input)
+}
+
+func (m minimalSecretsManager) DescribeSecret(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+	return m.fake.DescribeSecret(input)
+}
+
+func (m minimalSecretsManager) GetRandomPassword(input *secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error) {
+	return m.fake.GetRandomPassword(input)
+}
+
+func TestMinimalSecretsManager(t *testing.T) {
+	// Test a full rotation with a client that has only the methods of
+	// rotate.SecretsManager
+	fake := test.NewFakeSecretsManager()
+	fake.AddSecret("def", map[string]string{"username": "foo", "password": "p1"})
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: minimalSecretsManager{fake: fake},
+		PasswordSetter: test.MockPasswordSetter{},
+	})
+	for _, step := range []string{"createSecret", "setSecret", "testSecret", "finishSecret"} {
+		event := map[string]string{
+			"ClientRequestToken": "v2",
+			"SecretId":           "def",
+			"Step":               step,
+		}
+		if _, err := r.Handler(context.TODO(), event); err != nil {
+			t.Fatalf("%s: %s", step, err)
+		}
+	}
+	if got := fake.Stages("def")[rotate.AWSCURRENT]; got != "v2" {
+		t.Errorf("AWSCURRENT is version %q, expected v2", got)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2026, Square, Inc.
+
+// Package rotatetest provides test doubles for unit testing code that uses the
+// rotate package, like a Lambda main.go that wires a Rotator, or a custom
+// SecretSetter or PasswordSetter. Each mock has a Func field per method; the
+// method calls the Func if set, else it returns zero values (no error), except
+// MockSecretsManager, which returns values that the Rotator can use.
+//
+// Unlike package test, which has the mocks and fakes used by the tests in this
+// module, this package is supported: its API follows the module version.
+package rotatetest
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+)
+
+// MockSecretsManager implements rotate.SecretsManager. If a Func is not set,
+// GetSecretValue returns a ResourceNotFoundException error, like a secret
+// without the requested version, and the other methods return an empty output
+// and no error, so the zero value is a Secrets Manager without secrets.
+type MockSecretsManager struct {
+	GetSecretValueFunc           func(*secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValueFunc           func(*secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error)
+	UpdateSecretVersionStageFunc func(*secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error)
+	DescribeSecretFunc           func(*secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error)
+	GetRandomPasswordFunc        func(*secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error)
+}
+
+var _ rotate.SecretsManager = MockSecretsManager{}
+
+func (m MockSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	if m.GetSecretValueFunc != nil {
+		return m.GetSecretValueFunc(input)
+	}
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "Secrets Manager can't find the specified secret value (no GetSecretValueFunc)", nil)
+}
+
+func (m MockSecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	if m.PutSecretValueFunc != nil {
+		return m.PutSecretValueFunc(input)
+	}
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (m MockSecretsManager) UpdateSecretVersionStage(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	if m.UpdateSecretVersionStageFunc != nil {
+		return m.UpdateSecretVersionStageFunc(input)
+	}
+	return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+}
+
+func (m MockSecretsManager) DescribeSecret(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+	if m.DescribeSecretFunc != nil {
+		return m.DescribeSecretFunc(input)
+	}
+	return &secretsmanager.DescribeSecretOutput{}, nil
+}
+
+func (m MockSecretsManager) GetRandomPassword(input *secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error) {
+	if m.GetRandomPasswordFunc != nil {
+		return m.GetRandomPasswordFunc(input)
+	}
+	return &secretsmanager.GetRandomPasswordOutput{}, nil
+}
+
+// --------------------------------------------------------------------------
+
+// MockSecretSetter implements rotate.SecretSetter.
+type MockSecretSetter struct {
+	InitFunc        func(context.Context, map[string]string) error
+	HandlerFunc     func(context.Context, map[string]string) (map[string]string, error)
+	RotateFunc      func(secret map[string]string) error
+	CredentialsFunc func(secret map[string]string) (username, password string)
+}
+
+var _ rotate.SecretSetter = MockSecretSetter{}
+
+func (m MockSecretSetter) Init(ctx context.Context, event map[string]string) error {
+	if m.InitFunc != nil {
+		return m.InitFunc(ctx, event)
+	}
+	return nil
+}
+
+func (m MockSecretSetter) Handler(ctx context.Context, event map[string]string) (map[string]string, error) {
+	if m.HandlerFunc != nil {
+		return m.HandlerFunc(ctx, event)
+	}
+	return nil, nil
+}
+
+func (m MockSecretSetter) Rotate(secret map[string]string) error {
+	if m.RotateFunc != nil {
+		return m.RotateFunc(secret)
+	}
+	return nil
+}
+
+func (m MockSecretSetter) Credentials(secret map[string]string) (username, password string) {
+	if m.CredentialsFunc != nil {
+		return m.CredentialsFunc(secret)
+	}
+	return "", ""
+}
+
+// --------------------------------------------------------------------------
+
+// MockPasswordSetter implements db.PasswordSetter.
+type MockPasswordSetter struct {
+	InitFunc           func(context.Context, map[string]string) error
+	SetPasswordFunc    func(ctx context.Context, creds db.NewPassword) error
+	VerifyPasswordFunc func(ctx context.Context, creds db.NewPassword) error
+	RollbackFunc       func(ctx context.Context, creds db.NewPassword) error
+}
+
+var _ db.PasswordSetter = MockPasswordSetter{}
+
+func (m MockPasswordSetter) Init(ctx context.Context, s map[string]string) error {
+	if m.InitFunc != nil {
+		return m.InitFunc(ctx, s)
+	}
+	return nil
+}
+
+func (m MockPasswordSetter) SetPassword(ctx context.Context, creds db.NewPassword) error {
+	if m.SetPasswordFunc != nil {
+		return m.SetPasswordFunc(ctx, creds)
+	}
+	return nil
+}
+
+func (m MockPasswordSetter) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
+	if m.VerifyPasswordFunc != nil {
+		return m.VerifyPasswordFunc(ctx, creds)
+	}
+	return nil
+}
+
+func (m MockPasswordSetter) Rollback(ctx context.Context, creds db.NewPassword) error {
+	if m.RollbackFunc != nil {
+		return m.RollbackFunc(ctx, creds)
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+
+// MockPasswordClient implements mysql.PasswordClient, which is set as
+// mysql.Config.DbClient to test a mysql.PasswordSetter without a database.
+type MockPasswordClient struct {
+	SetPasswordFunc    func(ctx context.Context, creds db.NewPassword) error
+	VerifyPasswordFunc func(ctx context.Context, creds db.NewPassword) error
+}
+
+var _ mysql.PasswordClient = MockPasswordClient{}
+
+func (m MockPasswordClient) SetPassword(ctx context.Context, creds db.NewPassword) error {
+	if m.SetPasswordFunc != nil {
+		return m.SetPasswordFunc(ctx, creds)
+	}
+	return nil
+}
+
+func (m MockPasswordClient) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
+	if m.VerifyPasswordFunc != nil {
+		return m.VerifyPasswordFunc(ctx, creds)
+	}
+	return nil
+}
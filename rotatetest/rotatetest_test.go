@@ -0,0 +1,108 @@
+// Copyright 2026, Square, Inc.
+
+package rotatetest_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/rotatetest"
+)
+
+func TestWiring(t *testing.T) {
+	// Test that the mocks wire a Rotator, like a consumer's unit test: the
+	// setSecret step sets the password from the pending secret
+	secrets := map[string]string{
+		rotate.AWSCURRENT: `{"username":"foo","password":"p1"}`,
+		rotate.AWSPENDING: `{"username":"foo","password":"p2"}`,
+	}
+	sm := rotatetest.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			version := "v1"
+			if *input.VersionStage == rotate.AWSPENDING {
+				version = "v2"
+			}
+			return &secretsmanager.GetSecretValueOutput{
+				SecretString: aws.String(secrets[*input.VersionStage]),
+				VersionId:    aws.String(version),
+			}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{
+				VersionIdsToStages: map[string][]*string{
+					"v1": {aws.String(rotate.AWSCURRENT)},
+					"v2": {aws.String(rotate.AWSPENDING)},
+				},
+			}, nil
+		},
+	}
+	var got db.NewPassword
+	dbPassword := "p1"
+	ps := rotatetest.MockPasswordSetter{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			got = creds
+			dbPassword = creds.New.Password
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if creds.New.Password != dbPassword {
+				return errors.New("access denied")
+			}
+			return nil
+		},
+	}
+	ss := rotatetest.MockSecretSetter{
+		CredentialsFunc: func(secret map[string]string) (string, string) {
+			return secret["username"], secret["password"]
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		SecretSetter:   ss,
+		PasswordSetter: ps,
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if got.Current.Password != "p1" || got.New.Password != "p2" {
+		t.Errorf("set password %q -> %q, expected p1 -> p2", got.Current.Password, got.New.Password)
+	}
+}
+
+func TestMockSecretsManagerZero(t *testing.T) {
+	// Test that the zero value is a Secrets Manager without secrets: a step
+	// returns ResourceNotFoundException instead of panicking on a nil output
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: rotatetest.MockSecretsManager{},
+		PasswordSetter: rotatetest.MockPasswordSetter{},
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "createSecret",
+	}
+	_, err := r.Handler(context.TODO(), event)
+	if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != secretsmanager.ErrCodeResourceNotFoundException {
+		t.Errorf("got error %v, expected ResourceNotFoundException", err)
+	}
+
+	sm := rotatetest.MockSecretsManager{}
+	if out, err := sm.DescribeSecret(&secretsmanager.DescribeSecretInput{}); out == nil || err != nil {
+		t.Errorf("DescribeSecret returned %v, %v; expected empty output and no error", out, err)
+	}
+	if out, err := sm.PutSecretValue(&secretsmanager.PutSecretValueInput{}); out == nil || err != nil {
+		t.Errorf("PutSecretValue returned %v, %v; expected empty output and no error", out, err)
+	}
+}
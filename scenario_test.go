@@ -0,0 +1,205 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata/scenarios/")
+
+// TestScenarios replays each testdata/scenarios/*.txt script against a Rotator
+// with the fake Secrets Manager and compares the log of events, step results,
+// and secret staging labels to the .golden file of the same name. A script has
+// one command per line ("#" starts a comment):
+//
+//	<step> <token>         send the Secrets Manager event, like "setSecret t1"
+//	fail <op> <n>          fail the next n set, verify, or rollback calls
+//	password <p>           set the database password, like a manual change
+//
+// Run "go test -run TestScenarios -update" to write the golden files, then
+// review the diff.
+func TestScenarios(t *testing.T) {
+	scripts, err := filepath.Glob("testdata/scenarios/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scripts) == 0 {
+		t.Fatal("no scenarios in testdata/scenarios/")
+	}
+	for _, script := range scripts {
+		name := strings.TrimSuffix(filepath.Base(script), ".txt")
+		t.Run(name, func(t *testing.T) {
+			got := runScenario(t, script)
+			golden := strings.TrimSuffix(script, ".txt") + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			expect, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%s (run with -update to create it)", err)
+			}
+			// Other tests add values to the package redactor, like "secret",
+			// which can redact words in errors, so redact both the same
+			got, want := rotate.Redact(got), rotate.Redact(string(expect))
+			if diff := deep.Equal(strings.Split(got, "\n"), strings.Split(want, "\n")); diff != nil {
+				t.Errorf("event log differs from %s (run with -update if the change is intended):\n%s", golden, strings.Join(diff, "\n"))
+			}
+		})
+	}
+}
+
+// scenarioDb is a database with one password and injectable failures.
+type scenarioDb struct {
+	password string
+	fail     map[string]int // set, verify, rollback => calls to fail
+}
+
+func (d *scenarioDb) failNext(op string) error {
+	if d.fail[op] > 0 {
+		d.fail[op]--
+		return fmt.Errorf("injected %s error", op)
+	}
+	return nil
+}
+
+func runScenario(t *testing.T, script string) string {
+	f, err := os.Open(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	sm := test.NewFakeSecretsManager()
+	sm.AddSecret("s1", map[string]string{"username": "foo", "password": "p1"})
+	d := &scenarioDb{password: "p1", fail: map[string]int{}}
+
+	// Passwords are random, so the log names them by the first secret version
+	// (or "p1") that has it
+	passwords := map[string]string{"p1": "p1"}
+	pw := func(p string) string {
+		if n, ok := passwords[p]; ok {
+			return n
+		}
+		return "?"
+	}
+
+	log := &strings.Builder{}
+	ps := test.MockPasswordSetter{
+		SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if err := d.failNext("set"); err != nil {
+				return err
+			}
+			if creds.Current.Password != d.password && creds.New.Password != d.password {
+				return fmt.Errorf("access denied")
+			}
+			d.password = creds.New.Password
+			return nil
+		},
+		VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if err := d.failNext("verify"); err != nil {
+				return err
+			}
+			if creds.New.Password != d.password {
+				return fmt.Errorf("access denied")
+			}
+			return nil
+		},
+		RollbackFunc: func(ctx context.Context, creds db.NewPassword) error {
+			if err := d.failNext("rollback"); err != nil {
+				return err
+			}
+			d.password = creds.Current.Password
+			return nil
+		},
+	}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: ps,
+		SecretSetter:   rotate.RandomPassword{},
+		EventReceiver: eventRecorder(func(e rotate.Event) {
+			line := "  " + e.Name
+			if e.Step != "" {
+				line += " " + e.Step
+			}
+			if e.Error != nil {
+				line += ": " + e.Error.Error()
+			}
+			fmt.Fprintln(log, line)
+		}),
+	})
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		switch {
+		case fields[0] == "fail" && len(fields) == 3:
+			calls, err := strconv.Atoi(fields[2])
+			if err != nil {
+				t.Fatalf("%s:%d: %s", script, n, err)
+			}
+			d.fail[fields[1]] = calls
+			fmt.Fprintf(log, "# %s\n", line)
+		case fields[0] == "password" && len(fields) == 2:
+			d.password = fields[1]
+			passwords[fields[1]] = fields[1]
+			fmt.Fprintf(log, "# %s\n", line)
+		case len(fields) == 2:
+			fmt.Fprintf(log, "> %s\n", line)
+			event := map[string]string{"Step": fields[0], "SecretId": "s1", "ClientRequestToken": fields[1]}
+			_, err := r.Handler(context.TODO(), event)
+			if err != nil {
+				fmt.Fprintf(log, "< error: %s\n", err)
+			} else {
+				fmt.Fprintln(log, "< ok")
+			}
+
+			stages := sm.Stages("s1")
+			labels := make([]string, 0, len(stages))
+			for label := range stages {
+				labels = append(labels, label)
+			}
+			sort.Strings(labels)
+			for i, label := range labels {
+				p := sm.Values("s1", label)["password"]
+				if _, ok := passwords[p]; !ok {
+					passwords[p] = stages[label]
+				}
+				labels[i] = label + "=" + stages[label]
+			}
+			fmt.Fprintf(log, "  secret: %s\n", strings.Join(labels, " "))
+			fmt.Fprintf(log, "  db: %s\n", pw(d.password))
+		default:
+			t.Fatalf("%s:%d: invalid command: %s", script, n, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return log.String()
+}
@@ -4,9 +4,9 @@ package rotate
 
 import (
 	"context"
+	crand "crypto/rand"
 	"errors"
-	"math/rand"
-	"time"
+	"io"
 )
 
 // SecretSetter manages the user-specific secret value. Rotator has only one
@@ -58,8 +58,8 @@ const (
 //
 // RandomPassword does not support Handler (user-invoked password rotation),
 // it only supports rotation by Secrets Manager. The password generated by
-// RandomPassword may be configured by setting either `PasswordLength` or
-// `ValidCharset` on initialization
+// RandomPassword may be configured by setting `PasswordLength`, `ValidCharset`,
+// `Policy`, or `MySQLPolicy` on initialization
 type RandomPassword struct {
 	// Options to configure the random password generated.
 
@@ -73,6 +73,33 @@ type RandomPassword struct {
 	//   ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!@#$%^&*()-
 	// will be used.
 	ValidCharset []rune
+
+	// Policy defines additional rules for random passwords generated by
+	// RandomPassword, like the minimum number of digits. If not provided,
+	// there are no additional rules. See PasswordPolicy for details.
+	Policy *PasswordPolicy
+
+	// MySQLPolicy guarantees that random passwords generated by RandomPassword
+	// comply with the MySQL validate_password component. If PasswordLength is
+	// less than the policy length, the policy length is used. It can be used
+	// with Policy, in which case the greater minimums are used.
+	MySQLPolicy *MySQLPasswordPolicy
+
+	// Entropy is the source of random bytes, like KMSRandom for HSM-backed
+	// randomness. If nil, crypto/rand.Reader is used.
+	Entropy io.Reader
+
+	// FIPS enables FIPS mode for FedRAMP and similar workloads: the password
+	// entropy must be at least MinEntropyBits. If the password length, charset,
+	// and policy cannot reach it, Rotate returns an error. The entropy source must
+	// be approved, too: crypto/rand is an approved DRBG when built with a FIPS
+	// 140-validated Go cryptographic module, and KMSRandom uses FIPS 140-validated
+	// HSMs.
+	FIPS bool
+
+	// MinEntropyBits is the minimum password entropy in bits. In FIPS mode, if
+	// zero, DEFAULT_FIPS_ENTROPY_BITS is used. Else, it is not enforced if zero.
+	MinEntropyBits int
 }
 
 var _ RandomPassword = RandomPassword{}
@@ -88,8 +115,6 @@ func (s RandomPassword) Handler(context.Context, map[string]string) (map[string]
 var chars = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!@#$%^&*()-")
 
 func (s RandomPassword) Rotate(secret map[string]string) error {
-	rand.Seed(time.Now().UnixNano())
-
 	// Use custom options for RandomPassword (if provided)
 	passwordLength := s.PasswordLength
 	if passwordLength == 0 {
@@ -101,13 +126,41 @@ func (s RandomPassword) Rotate(secret map[string]string) error {
 		charset = s.ValidCharset
 	}
 
+	entropy := s.Entropy
+	if entropy == nil {
+		entropy = crand.Reader
+	}
+	minBits := s.MinEntropyBits
+	if s.FIPS && minBits == 0 {
+		minBits = DEFAULT_FIPS_ENTROPY_BITS
+	}
+
 	// Make a `passwordLength` char random password containing characters from
-	// `charset`
-	newPassword := make([]rune, passwordLength)
-	for i := 0; i < passwordLength; i++ {
-		newPassword[i] = charset[rand.Intn(len(charset))]
+	// `charset` and satisfying the policies (if any)
+	var policy PasswordPolicy
+	if s.Policy != nil {
+		policy = *s.Policy
+	}
+	var check func(string) error
+	if s.MySQLPolicy != nil {
+		mysqlPolicy, minLength, err := s.MySQLPolicy.passwordPolicy()
+		if err != nil {
+			return err
+		}
+		policy = policy.merge(mysqlPolicy)
+		if passwordLength < minLength {
+			passwordLength = minLength
+		}
+		username := secret["username"]
+		check = func(password string) error {
+			return s.MySQLPolicy.check(password, username)
+		}
+	}
+	newPassword, err := policy.generate(charset, passwordLength, readerIntn(entropy), minBits, check)
+	if err != nil {
+		return err
 	}
-	secret["password"] = string(newPassword)
+	secret["password"] = newPassword
 	return nil
 }
 
@@ -1,10 +1,18 @@
 package rotate_test
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
 	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/test"
 )
 
 func TestRandomPassword_Default(t *testing.T) {
@@ -59,3 +67,234 @@ func TestRandomPassword_Custom(t *testing.T) {
 		t.Fatalf("expected to generate password '%s' from single character charset, got '%s'", strings.Repeat("X", rotate.DEFAULT_PASSWORD_LENGTH), secret["password"])
 	}
 }
+
+func TestRandomPassword_Policy(t *testing.T) {
+	rp := rotate.RandomPassword{
+		PasswordLength: 12,
+		Policy: &rotate.PasswordPolicy{
+			MinUpper:   2,
+			MinLower:   2,
+			MinDigits:  2,
+			MinSpecial: 2,
+			Exclude:    `'"\@`,
+			NoRepeat:   true,
+			Prefix:     "pw-",
+			Suffix:     "-x",
+		},
+	}
+
+	// Generate many passwords because they're random
+	for i := 0; i < 100; i++ {
+		secret := map[string]string{
+			"username": "test-user",
+			"password": "original-password",
+		}
+		if err := rp.Rotate(secret); err != nil {
+			t.Fatal(err)
+		}
+		password := secret["password"]
+		if len(password) != 12+len("pw-")+len("-x") {
+			t.Fatalf("expected password with %d characters, got %d characters: %s", 17, len(password), password)
+		}
+		if err := rp.Policy.Validate(password); err != nil {
+			t.Fatalf("generated password %s does not satisfy policy: %s", password, err)
+		}
+	}
+
+	// Policy that cannot be satisfied is an error, not an invalid password
+	rp = rotate.RandomPassword{
+		ValidCharset: []rune("abc123"),
+		Policy:       &rotate.PasswordPolicy{MinUpper: 1},
+	}
+	secret := map[string]string{"password": "original-password"}
+	if err := rp.Rotate(secret); err == nil {
+		t.Errorf("no error, expected error because charset has no upper case letters")
+	}
+	if secret["password"] != "original-password" {
+		t.Errorf("password changed on error: %s", secret["password"])
+	}
+}
+
+func TestPasswordPolicy_Validate(t *testing.T) {
+	p := rotate.PasswordPolicy{MinDigits: 1, Exclude: "'", NoRepeat: true}
+	invalid := []string{
+		"abcdef", // no digits
+		"abc'1",  // excluded char
+		"abbc1",  // repeated char
+	}
+	for _, password := range invalid {
+		if err := p.Validate(password); err == nil {
+			t.Errorf("no error for %s, expected error", password)
+		}
+	}
+	if err := p.Validate("abc1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRandomPassword_MySQLPolicy(t *testing.T) {
+	policy := &rotate.MySQLPasswordPolicy{
+		Policy:           rotate.VALIDATE_PASSWORD_STRONG,
+		Length:           24, // greater than PasswordLength
+		MixedCaseCount:   2,
+		SpecialCharCount: 3,
+		Dictionary:       []string{"abcd", "pass"},
+		CheckUserName:    true,
+	}
+	rp := rotate.RandomPassword{
+		PasswordLength: 10,
+		MySQLPolicy:    policy,
+	}
+
+	for i := 0; i < 100; i++ {
+		secret := map[string]string{
+			"username": "test-user",
+			"password": "original-password",
+		}
+		if err := rp.Rotate(secret); err != nil {
+			t.Fatal(err)
+		}
+		if len(secret["password"]) != 24 {
+			t.Fatalf("expected password with 24 characters, got %d characters", len(secret["password"]))
+		}
+		if err := policy.Validate(secret["password"], "test-user"); err != nil {
+			t.Fatalf("generated password %s does not satisfy MySQL policy: %s", secret["password"], err)
+		}
+	}
+
+	if err := policy.Validate("Aa1!Aa1!Aa1!Aa1!Aa1!Aa1!", "test-user"); err != nil {
+		t.Error(err)
+	}
+	invalid := []string{
+		"xxPASSxxAa1!bB2@cC3#dD4$", // dictionary word, case-insensitive
+		"Short1!a",                 // too short
+		"resu-tset",                // username reversed
+	}
+	for _, password := range invalid {
+		if err := policy.Validate(password, "test-user"); err == nil {
+			t.Errorf("no error for %s, expected error", password)
+		}
+	}
+}
+
+func TestRandomPassword_FIPS(t *testing.T) {
+	// Default length and charset are about 124 bits of entropy, more than the
+	// FIPS minimum
+	rp := rotate.RandomPassword{FIPS: true}
+	secret := map[string]string{
+		"username": "test-user",
+		"password": "original-password",
+	}
+	if err := rp.Rotate(secret); err != nil {
+		t.Fatal(err)
+	}
+	if len(secret["password"]) != rotate.DEFAULT_PASSWORD_LENGTH {
+		t.Errorf("got %d characters, expected %d", len(secret["password"]), rotate.DEFAULT_PASSWORD_LENGTH)
+	}
+
+	// 10 characters is only about 62 bits
+	rp = rotate.RandomPassword{FIPS: true, PasswordLength: 10}
+	if err := rp.Rotate(secret); err == nil {
+		t.Error("no error, expected entropy error")
+	}
+
+	// MinEntropyBits is enforced without FIPS, too
+	rp = rotate.RandomPassword{PasswordLength: 10, MinEntropyBits: 80}
+	if err := rp.Rotate(secret); err == nil {
+		t.Error("no error, expected entropy error")
+	}
+	rp = rotate.RandomPassword{FIPS: true, PasswordLength: 10, MinEntropyBits: 60}
+	if err := rp.Rotate(secret); err != nil {
+		t.Error(err)
+	}
+}
+
+type mockKMS struct {
+	kmsiface.KMSAPI
+	calls int
+}
+
+func (m *mockKMS) GenerateRandom(input *kms.GenerateRandomInput) (*kms.GenerateRandomOutput, error) {
+	m.calls++
+	return &kms.GenerateRandomOutput{Plaintext: make([]byte, *input.NumberOfBytes)}, nil
+}
+
+func TestRandomPassword_Entropy(t *testing.T) {
+	// All zero bytes always pick the first character
+	rp := rotate.RandomPassword{
+		ValidCharset: []rune("ab"),
+		Entropy:      bytes.NewReader(make([]byte, 1000)),
+	}
+	secret := map[string]string{
+		"username": "test-user",
+		"password": "original-password",
+	}
+	if err := rp.Rotate(secret); err != nil {
+		t.Fatal(err)
+	}
+	expect := strings.Repeat("a", rotate.DEFAULT_PASSWORD_LENGTH)
+	if secret["password"] != expect {
+		t.Errorf("got password %s, expected %s", secret["password"], expect)
+	}
+
+	// Error reading entropy source is returned
+	rp.Entropy = bytes.NewReader(nil)
+	if err := rp.Rotate(secret); err == nil {
+		t.Error("no error, expected an error reading entropy source")
+	}
+
+	// KMSRandom buffers bytes, so one password is one call
+	m := &mockKMS{}
+	rp.Entropy = rotate.NewKMSRandom(m)
+	if err := rp.Rotate(secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret["password"] != expect {
+		t.Errorf("got password %s, expected %s", secret["password"], expect)
+	}
+	if m.calls != 1 {
+		t.Errorf("got %d calls to GenerateRandom, expected 1", m.calls)
+	}
+}
+
+func TestAWSRandomPassword(t *testing.T) {
+	var gotInput *secretsmanager.GetRandomPasswordInput
+	sm := test.MockSecretsManager{
+		GetRandomPasswordFunc: func(input *secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error) {
+			gotInput = input
+			return &secretsmanager.GetRandomPasswordOutput{
+				RandomPassword: aws.String("aws-random"),
+			}, nil
+		},
+	}
+	ss := rotate.AWSRandomPassword{
+		SecretsManager:          sm,
+		ExcludeCharacters:       `'"\`,
+		RequireEachIncludedType: true,
+	}
+
+	secret := map[string]string{
+		"username": "test-user",
+		"password": "original-password",
+	}
+	if err := ss.Rotate(secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret["password"] != "aws-random" {
+		t.Errorf("got password %s, expected aws-random", secret["password"])
+	}
+
+	expectInput := &secretsmanager.GetRandomPasswordInput{
+		PasswordLength:          aws.Int64(rotate.DEFAULT_PASSWORD_LENGTH),
+		ExcludeCharacters:       aws.String(`'"\`),
+		ExcludeNumbers:          aws.Bool(false),
+		ExcludeLowercase:        aws.Bool(false),
+		ExcludeUppercase:        aws.Bool(false),
+		ExcludePunctuation:      aws.Bool(false),
+		IncludeSpace:            aws.Bool(false),
+		RequireEachIncludedType: aws.Bool(true),
+	}
+	if diff := deep.Equal(gotInput, expectInput); diff != nil {
+		t.Error(diff)
+	}
+}
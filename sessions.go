@@ -0,0 +1,68 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// killOldSessions kills the database sessions of the creds.New users and sends
+// EVENT_SESSIONS_KILLED. It's called by FinishSecret if Config.KillSessions is true.
+func (r *Rotator) killOldSessions(ctx context.Context, creds db.NewPassword) error {
+	if r.skipDatabase() {
+		log.Println("database skipped, not killing sessions")
+		return nil
+	}
+	k, ok := r.db.(db.SessionKiller)
+	if !ok {
+		return fmt.Errorf("PasswordSetter %T does not implement db.SessionKiller", r.db)
+	}
+	t0 := time.Now()
+	if err := k.KillSessions(ctx, creds); err != nil {
+		return err
+	}
+	r.event.Receive(Event{
+		Name:     EVENT_SESSIONS_KILLED,
+		Step:     "finishSecret",
+		Time:     time.Now(),
+		Duration: time.Now().Sub(t0),
+	})
+	return nil
+}
+
+// reportLingeringSessions counts the database sessions of the creds.New users
+// still connected with the old password and sends EVENT_LINGERING_SESSIONS.
+// It's called by FinishSecret if Config.ReportLingeringSessions is true.
+func (r *Rotator) reportLingeringSessions(ctx context.Context, creds db.NewPassword) error {
+	if r.skipDatabase() {
+		log.Println("database skipped, not counting lingering sessions")
+		return nil
+	}
+	c, ok := r.db.(db.SessionCounter)
+	if !ok {
+		return fmt.Errorf("PasswordSetter %T does not implement db.SessionCounter", r.db)
+	}
+	t0 := time.Now()
+	sessions, err := c.CountSessions(ctx, creds)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, n := range sessions {
+		total += n
+	}
+	log.Printf("%d sessions on %d databases still connected with the old password", total, len(sessions))
+	r.event.Receive(Event{
+		Name:     EVENT_LINGERING_SESSIONS,
+		Step:     "finishSecret",
+		Time:     time.Now(),
+		Duration: time.Now().Sub(t0),
+		Sessions: sessions,
+	})
+	return nil
+}
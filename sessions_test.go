@@ -0,0 +1,156 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type killPasswordSetter struct {
+	test.MockPasswordSetter
+	killed *[]db.NewPassword
+	err    error
+}
+
+func (m killPasswordSetter) KillSessions(ctx context.Context, creds db.NewPassword) error {
+	*m.killed = append(*m.killed, creds)
+	return m.err
+}
+
+func TestFinishSecretKillSessions(t *testing.T) {
+	var killed []db.NewPassword
+	var events []string
+	sm := discardSecretsManager(time.Now(), map[string][]*string{
+		"v1": {aws.String(rotate.AWSCURRENT)},
+		"v2": {aws.String(rotate.AWSPENDING)},
+	})
+	sm.GetSecretValueFunc = func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+		switch *input.VersionStage {
+		case rotate.AWSCURRENT:
+			return &secretsmanager.GetSecretValueOutput{SecretString: &secretString1, VersionId: aws.String("v1")}, nil
+		default:
+			return &secretsmanager.GetSecretValueOutput{SecretString: &secretString2, VersionId: aws.String("v2")}, nil
+		}
+	}
+	ps := killPasswordSetter{killed: &killed}
+	newRotator := func() *rotate.Rotator {
+		cfg := rotate.Config{
+			SecretsManager: sm,
+			PasswordSetter: ps,
+			EventReceiver:  eventRecorder(func(e rotate.Event) { events = append(events, e.Name) }),
+			KillSessions:   true,
+		}
+		if err := cfg.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		return rotate.NewRotator(cfg)
+	}
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := newRotator().Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if len(killed) != 1 || killed[0].New.Password != "p2" {
+		t.Fatalf("killed %+v, expected once with new password p2", killed)
+	}
+	if !hasEvent(events, rotate.EVENT_SESSIONS_KILLED) {
+		t.Errorf("no %s event", rotate.EVENT_SESSIONS_KILLED)
+	}
+
+	// Error does not fail finishSecret because the new secret is current
+	events = nil
+	ps.err = fmt.Errorf("forced error")
+	if _, err := newRotator().Handler(context.TODO(), event); err != nil {
+		t.Errorf("got error %s, expected nil (kill error ignored)", err)
+	}
+	if hasEvent(events, rotate.EVENT_SESSIONS_KILLED) {
+		t.Errorf("got %s event, expected none on error", rotate.EVENT_SESSIONS_KILLED)
+	}
+}
+
+type countPasswordSetter struct {
+	test.MockPasswordSetter
+}
+
+func (m countPasswordSetter) CountSessions(ctx context.Context, creds db.NewPassword) (map[string]int, error) {
+	return map[string]int{"db1": 2, "db2": 0}, nil
+}
+
+func TestFinishSecretLingeringSessions(t *testing.T) {
+	var events []rotate.Event
+	sm := discardSecretsManager(time.Now(), map[string][]*string{
+		"v1": {aws.String(rotate.AWSCURRENT)},
+		"v2": {aws.String(rotate.AWSPENDING)},
+	})
+	sm.GetSecretValueFunc = func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+		switch *input.VersionStage {
+		case rotate.AWSCURRENT:
+			return &secretsmanager.GetSecretValueOutput{SecretString: &secretString1, VersionId: aws.String("v1")}, nil
+		default:
+			return &secretsmanager.GetSecretValueOutput{SecretString: &secretString2, VersionId: aws.String("v2")}, nil
+		}
+	}
+	cfg := rotate.Config{
+		SecretsManager:          sm,
+		PasswordSetter:          countPasswordSetter{},
+		EventReceiver:           eventRecorder(func(e rotate.Event) { events = append(events, e) }),
+		ReportLingeringSessions: true,
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "finishSecret",
+	}
+	if _, err := rotate.NewRotator(cfg).Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	var got *rotate.Event
+	for i := range events {
+		if events[i].Name == rotate.EVENT_LINGERING_SESSIONS {
+			got = &events[i]
+		}
+	}
+	if got == nil {
+		t.Fatalf("no %s event", rotate.EVENT_LINGERING_SESSIONS)
+	}
+	if diff := deep.Equal(got.Sessions, map[string]int{"db1": 2, "db2": 0}); diff != nil {
+		t.Error(diff)
+	}
+
+	cfg.PasswordSetter = test.MockPasswordSetter{}
+	if err := cfg.Validate(); err == nil {
+		t.Error("no error, expected error for PasswordSetter without CountSessions")
+	}
+}
+
+func TestKillSessionsValidate(t *testing.T) {
+	cfg := rotate.Config{
+		SecretsManager: test.MockSecretsManager{},
+		PasswordSetter: test.MockPasswordSetter{},
+		KillSessions:   true,
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("no error, expected error for PasswordSetter without KillSessions")
+	}
+	cfg.PasswordSetter = killPasswordSetter{killed: &[]db.NewPassword{}}
+	if err := cfg.Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// MultiPasswordSetter is a PasswordSetter that sets the secret on multiple
+// backends, because Config takes only one PasswordSetter. It is used when the
+// SecretSetter rotates more than one secret key, like a database password and
+// an HMAC signing key, and each key is used by a different backend.
+//
+// A PasswordSetter that implements db.FieldSetter is called only if one of its
+// fields changed (see db.NewPassword.Changed), so each backend acts only on its
+// fields. A PasswordSetter that does not implement db.FieldSetter is always
+// called. Values other than the password are in Credentials.Extra only if the
+// SecretSetter implements FullCredentials, like RandomPassword.
+//
+// SetPassword calls the PasswordSetters in order and stops on the first error.
+// Rollback calls the PasswordSetters that SetPassword called, in reverse order;
+// if SetPassword was not called, like when TestSecret fails in a later
+// invocation, it calls all PasswordSetters whose fields changed. Optional
+// interfaces, like db.OldPasswordDiscarder, are not called.
+//
+// Create a MultiPasswordSetter by calling NewMultiPasswordSetter.
+type MultiPasswordSetter struct {
+	setters []db.PasswordSetter
+	// --
+	set []int // indexes of setters called by last SetPassword
+}
+
+var _ db.PasswordSetter = &MultiPasswordSetter{}
+
+// NewMultiPasswordSetter creates a new MultiPasswordSetter that sets the secret
+// on the PasswordSetters. Nil PasswordSetters are ignored.
+func NewMultiPasswordSetter(setters ...db.PasswordSetter) *MultiPasswordSetter {
+	m := &MultiPasswordSetter{
+		setters: make([]db.PasswordSetter, 0, len(setters)),
+	}
+	for _, ps := range setters {
+		if ps == nil {
+			continue
+		}
+		m.setters = append(m.setters, ps)
+	}
+	return m
+}
+
+// Init calls Init on all PasswordSetters.
+func (m *MultiPasswordSetter) Init(ctx context.Context, secret map[string]string) error {
+	for i, ps := range m.setters {
+		if err := ps.Init(ctx, secret); err != nil {
+			return fmt.Errorf("PasswordSetter %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// SetPassword calls SetPassword on the PasswordSetters whose fields changed.
+func (m *MultiPasswordSetter) SetPassword(ctx context.Context, creds db.NewPassword) error {
+	m.set = []int{}
+	for _, i := range m.affected(creds) {
+		m.set = append(m.set, i)
+		if err := m.setters[i].SetPassword(ctx, creds); err != nil {
+			return fmt.Errorf("PasswordSetter %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// VerifyPassword calls VerifyPassword on the PasswordSetters whose fields changed.
+func (m *MultiPasswordSetter) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
+	for _, i := range m.affected(creds) {
+		if err := m.setters[i].VerifyPassword(ctx, creds); err != nil {
+			return fmt.Errorf("PasswordSetter %d: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// Rollback calls Rollback on the PasswordSetters that SetPassword called, or
+// all PasswordSetters whose fields changed, in reverse order. It rolls back
+// every PasswordSetter even if some fail, and returns all errors.
+func (m *MultiPasswordSetter) Rollback(ctx context.Context, creds db.NewPassword) error {
+	set := m.set
+	if set == nil {
+		set = m.affected(creds)
+	}
+	var errs []error
+	for n := len(set) - 1; n >= 0; n-- {
+		i := set[n]
+		if err := m.setters[i].Rollback(ctx, creds); err != nil {
+			log.Printf("ERROR: PasswordSetter %d: Rollback failed: %s", i, err)
+			errs = append(errs, fmt.Errorf("PasswordSetter %d: %s", i, err))
+		}
+	}
+	m.set = nil
+	return errors.Join(errs...)
+}
+
+// affected returns the indexes of the PasswordSetters whose fields changed,
+// or that do not implement db.FieldSetter.
+func (m *MultiPasswordSetter) affected(creds db.NewPassword) []int {
+	idx := make([]int, 0, len(m.setters))
+	for i, ps := range m.setters {
+		if fs, ok := ps.(db.FieldSetter); ok && !creds.Affects(fs.Fields()) {
+			log.Printf("PasswordSetter %d: fields %v not changed, skipping", i, fs.Fields())
+			continue
+		}
+		idx = append(idx, i)
+	}
+	return idx
+}
@@ -0,0 +1,145 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+// fieldSetter is a MockPasswordSetter that acts on the given secret keys.
+type fieldSetter struct {
+	test.MockPasswordSetter
+	fields []string
+}
+
+func (s fieldSetter) Fields() []string {
+	return s.fields
+}
+
+func TestMultiPasswordSetter(t *testing.T) {
+	// Test that a SecretSetter that rotates the password and an HMAC key sets
+	// each on its own backend, and that a rotation of only the HMAC key does
+	// not touch the database
+	var calls []string
+	dbPassword, hmacKey := "p1", "k1"
+	dbSetter := fieldSetter{
+		MockPasswordSetter: test.MockPasswordSetter{
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				calls = append(calls, "db set")
+				dbPassword = creds.New.Password
+				return nil
+			},
+			VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				if creds.New.Password != dbPassword {
+					return errors.New("access denied")
+				}
+				return nil
+			},
+		},
+		fields: []string{"password"},
+	}
+	hmacSetter := fieldSetter{
+		MockPasswordSetter: test.MockPasswordSetter{
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				calls = append(calls, "hmac set")
+				hmacKey = creds.New.Extra["hmac_key"]
+				return nil
+			},
+			VerifyPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				if creds.New.Extra["hmac_key"] != hmacKey {
+					return errors.New("invalid signature")
+				}
+				return nil
+			},
+		},
+		fields: []string{"hmac_key"},
+	}
+
+	setSecret := func(curVals, newVals string) {
+		t.Helper()
+		calls = nil
+		sm := test.MockSecretsManager{
+			GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+				if *input.VersionStage == rotate.AWSCURRENT {
+					return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(curVals), VersionId: aws.String("v1")}, nil
+				}
+				return &secretsmanager.GetSecretValueOutput{SecretString: aws.String(newVals), VersionId: aws.String("v2")}, nil
+			},
+		}
+		r := rotate.NewRotator(rotate.Config{
+			SecretsManager: sm,
+			SecretSetter:   fullCredentials{},
+			PasswordSetter: rotate.NewMultiPasswordSetter(dbSetter, hmacSetter),
+		})
+		event := map[string]string{"ClientRequestToken": "v2", "SecretId": "def", "Step": "setSecret"}
+		if _, err := r.Handler(context.TODO(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setSecret(`{"username":"foo","password":"p1","hmac_key":"k1"}`, `{"username":"foo","password":"p2","hmac_key":"k2"}`)
+	if diff := deep.Equal(calls, []string{"db set", "hmac set"}); diff != nil {
+		t.Error(diff)
+	}
+	if dbPassword != "p2" || hmacKey != "k2" {
+		t.Errorf("got password %q and hmac_key %q, expected p2 and k2", dbPassword, hmacKey)
+	}
+
+	setSecret(`{"username":"foo","password":"p2","hmac_key":"k2"}`, `{"username":"foo","password":"p2","hmac_key":"k3"}`)
+	if diff := deep.Equal(calls, []string{"hmac set"}); diff != nil {
+		t.Error(diff)
+	}
+	if dbPassword != "p2" || hmacKey != "k3" {
+		t.Errorf("got password %q and hmac_key %q, expected p2 and k3", dbPassword, hmacKey)
+	}
+}
+
+func TestMultiPasswordSetterRollback(t *testing.T) {
+	// Test that Rollback rolls back only the PasswordSetters that SetPassword
+	// called, in reverse order
+	var calls []string
+	setter := func(name string, setErr error) test.MockPasswordSetter {
+		return test.MockPasswordSetter{
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				calls = append(calls, name+" set")
+				return setErr
+			},
+			RollbackFunc: func(ctx context.Context, creds db.NewPassword) error {
+				calls = append(calls, name+" rollback")
+				return nil
+			},
+		}
+	}
+	m := rotate.NewMultiPasswordSetter(setter("a", nil), setter("b", errors.New("access denied")), setter("c", nil))
+	creds := db.NewPassword{Changed: []string{"password"}}
+	if err := m.SetPassword(context.TODO(), creds); err == nil {
+		t.Error("SetPassword returned nil, expected an error")
+	}
+	if err := m.Rollback(context.TODO(), creds); err != nil {
+		t.Error(err)
+	}
+	expect := []string{"a set", "b set", "b rollback", "a rollback"}
+	if diff := deep.Equal(calls, expect); diff != nil {
+		t.Error(diff)
+	}
+}
+
+// fullCredentials makes a SecretSetter return all credentials, so values like
+// hmac_key are in Extra.
+type fullCredentials struct {
+	test.MockSecretSetter
+}
+
+func (s fullCredentials) DBCredentials(secret map[string]string) db.Credentials {
+	return rotate.ParseCredentials("", secret)
+}
@@ -0,0 +1,283 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// RotationState is the state of one rotation persisted between steps by a
+// StateStore. Each step runs in a separate Lambda invocation, so without a
+// StateStore all in-memory state is lost between steps.
+type RotationState struct {
+	SecretId  string `dynamodbav:"SecretId"`
+	VersionId string `dynamodbav:"VersionId"` // ClientRequestToken, the new secret version
+
+	// Step is the last step that completed successfully, and StepTime is when.
+	Step     string    `dynamodbav:"Step"`
+	StepTime time.Time `dynamodbav:"StepTime"`
+
+	// SetPasswordTime is when SetSecret began setting the new password on the
+	// databases. It is zero if the password has not been set.
+	SetPasswordTime time.Time `dynamodbav:"SetPasswordTime"`
+
+	// Hosts are the results of the last action on each database host, keyed
+	// on hostname. The PasswordSetter must implement db.HostObservable.
+	Hosts map[string]HostResult `dynamodbav:"Hosts,omitempty"`
+
+	// ChangedHosts are the database hosts on which SetSecret set the new
+	// password and did not roll it back. The PasswordSetter must implement
+	// db.HostObservable.
+	ChangedHosts []string `dynamodbav:"ChangedHosts,omitempty"`
+
+	// Interrupted is true if the Lambda context was cancelled while SetSecret
+	// was setting the new password, so the new password is set only on
+	// ChangedHosts. On retry, SetSecret resumes setting the new password. See
+	// db.Resumable.
+	Interrupted bool `dynamodbav:"Interrupted,omitempty"`
+}
+
+// HostResult is the result of the last password action on one database host.
+type HostResult struct {
+	Action   string    `dynamodbav:"Action"` // PasswordSetter-specific, like "verify"
+	Error    string    `dynamodbav:"Error,omitempty"`
+	Duration int64     `dynamodbav:"DurationMs"`
+	Time     time.Time `dynamodbav:"Time"`
+}
+
+// StateStore persists RotationState between steps. Rotator loads the state
+// at the start of every step and saves it at the end of every step. Step is
+// changed only if the step succeeds, but Hosts are saved even if it fails.
+// Set Config.StateStore to enable. DynamoDBStateStore is provided.
+type StateStore interface {
+	// Load returns the state for the secret version. If there is no state,
+	// it returns a new RotationState with only SecretId and VersionId set.
+	Load(ctx context.Context, secretId, versionId string) (RotationState, error)
+
+	// Save saves the state, replacing any previous state for the secret version.
+	Save(ctx context.Context, state RotationState) error
+}
+
+// STATE_SAVE_TIMEOUT is how long saving the rotation state can take if the
+// Lambda context is cancelled, so the state is saved when it's needed most.
+const STATE_SAVE_TIMEOUT = 3 * time.Second
+
+// DynamoDBStateStore is a StateStore backed by a DynamoDB table. The table
+// must have partition key "SecretId" (string) and sort key "VersionId" (string).
+// If TTL is set, enable DynamoDB TTL on attribute "ExpiresAt" so old rotation
+// states are deleted automatically.
+type DynamoDBStateStore struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	Table    string
+	TTL      time.Duration
+}
+
+var _ StateStore = DynamoDBStateStore{}
+
+// dynamoState is a RotationState with the TTL attribute.
+type dynamoState struct {
+	RotationState
+	ExpiresAt int64 `dynamodbav:"ExpiresAt,omitempty"`
+}
+
+func (s DynamoDBStateStore) Load(ctx context.Context, secretId, versionId string) (RotationState, error) {
+	state := RotationState{SecretId: secretId, VersionId: versionId}
+	out, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      aws.String(s.Table),
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"SecretId":  {S: aws.String(secretId)},
+			"VersionId": {S: aws.String(versionId)},
+		},
+	})
+	if err != nil {
+		return state, err
+	}
+	if len(out.Item) == 0 {
+		return state, nil
+	}
+	var ds dynamoState
+	if err := dynamodbattribute.UnmarshalMap(out.Item, &ds); err != nil {
+		return state, fmt.Errorf("cannot unmarshal rotation state: %s", err)
+	}
+	return ds.RotationState, nil
+}
+
+func (s DynamoDBStateStore) Save(ctx context.Context, state RotationState) error {
+	ds := dynamoState{RotationState: state}
+	if s.TTL > 0 {
+		ds.ExpiresAt = time.Now().Add(s.TTL).Unix()
+	}
+	item, err := dynamodbattribute.MarshalMap(ds)
+	if err != nil {
+		return fmt.Errorf("cannot marshal rotation state: %s", err)
+	}
+	_, err = s.DynamoDB.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.Table),
+		Item:      item,
+	})
+	return err
+}
+
+// --------------------------------------------------------------------------
+
+var _ db.HostObserver = &Rotator{}
+
+// ObserveHost records the host result in the rotation state, which is saved if
+// Config.StateStore is set, and in the Handler result. Rotator sets itself as the observer of the PasswordSetter if it implements
+// db.HostObservable, so this method does not need to be called directly.
+//
+// While SetSecret is setting or rolling back the password, a successful result
+// also adds or removes the host from RotationState.ChangedHosts.
+func (r *Rotator) ObserveHost(hostname, action string, d time.Duration, err error) {
+	r.stateMux.Lock()
+	defer r.stateMux.Unlock()
+	if r.state.Hosts == nil {
+		r.state.Hosts = map[string]HostResult{}
+	}
+	res := HostResult{
+		Action:   action,
+		Duration: d.Milliseconds(),
+		Time:     time.Now(),
+	}
+	if err != nil {
+		res.Error = Redact(err.Error())
+	}
+	r.state.Hosts[hostname] = res
+	r.stepResult.hosts[hostname] = true
+
+	if err != nil {
+		return
+	}
+	switch r.hostAction {
+	case host_set:
+		for _, h := range r.state.ChangedHosts {
+			if h == hostname {
+				return
+			}
+		}
+		r.state.ChangedHosts = append(r.state.ChangedHosts, hostname)
+	case host_rollback:
+		changed := r.state.ChangedHosts[:0]
+		for _, h := range r.state.ChangedHosts {
+			if h != hostname {
+				changed = append(changed, h)
+			}
+		}
+		r.state.ChangedHosts = changed
+	}
+}
+
+// hostsFailed sends EVENT_HOSTS_FAILED if hosts failed since the time, but the
+// PasswordSetter call succeeded because it tolerates some failed hosts, like
+// mysql.Config.FailureThreshold.
+func (r *Rotator) hostsFailed(step string, since time.Time) {
+	r.stateMux.Lock()
+	failed := []string{}
+	for hostname, res := range r.state.Hosts {
+		if res.Error != "" && !res.Time.Before(since) {
+			failed = append(failed, hostname)
+		}
+	}
+	r.stateMux.Unlock()
+	if len(failed) == 0 {
+		return
+	}
+	sort.Strings(failed)
+	log.Printf("WARNING: %d database hosts failed but PasswordSetter succeeded: %s", len(failed), strings.Join(failed, ", "))
+	r.event.Receive(Event{
+		Name:  EVENT_HOSTS_FAILED,
+		Step:  step,
+		Time:  time.Now(),
+		Error: fmt.Errorf("%d database hosts failed: %s", len(failed), strings.Join(failed, ", ")),
+	})
+}
+
+// Values of Rotator.hostAction: the PasswordSetter call that is running, so
+// ObserveHost can track RotationState.ChangedHosts.
+const (
+	host_set      = "set"
+	host_rollback = "rollback"
+)
+
+// setHostAction sets the PasswordSetter call that is running, or "" if none.
+func (r *Rotator) setHostAction(action string) {
+	r.stateMux.Lock()
+	r.hostAction = action
+	r.stateMux.Unlock()
+}
+
+// interrupted returns the hosts on which the new password was set if a previous
+// SetSecret was interrupted, else it returns false.
+func (r *Rotator) interrupted() ([]string, bool) {
+	r.stateMux.Lock()
+	defer r.stateMux.Unlock()
+	if !r.state.Interrupted {
+		return nil, false
+	}
+	return append([]string{}, r.state.ChangedHosts...), true
+}
+
+// setInterrupted sets RotationState.Interrupted, which is saved after the step.
+func (r *Rotator) setInterrupted(interrupted bool) {
+	r.stateMux.Lock()
+	r.state.Interrupted = interrupted
+	r.stateMux.Unlock()
+}
+
+// loadState loads the rotation state, if enabled. It is called by Handler
+// before the step.
+func (r *Rotator) loadState(ctx context.Context) error {
+	r.state = RotationState{SecretId: r.secretId, VersionId: r.clientRequestToken}
+	if r.stateStore == nil {
+		return nil
+	}
+	state, err := r.stateStore.Load(ctx, r.secretId, r.clientRequestToken)
+	if err != nil {
+		return fmt.Errorf("cannot load rotation state: %s", err)
+	}
+	debug("rotation state: step %s at %s", state.Step, state.StepTime)
+	r.state = state
+	r.startTime = state.SetPasswordTime
+	return nil
+}
+
+// saveState saves the rotation state after the step, if enabled. It is called
+// by Handler after the step with the step error. Step is changed only if stepErr
+// is nil.
+func (r *Rotator) saveState(ctx context.Context, step string, stepErr error) error {
+	if r.stateStore == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		// The context is cancelled, like Lambda timeout, but the state (which
+		// hosts were changed) is needed to resume or roll back on retry
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), STATE_SAVE_TIMEOUT)
+		defer cancel()
+	}
+	r.stateMux.Lock()
+	defer r.stateMux.Unlock()
+	if stepErr == nil {
+		r.state.Step = step
+		r.state.StepTime = time.Now()
+	}
+	r.state.SetPasswordTime = r.startTime
+	if err := r.stateStore.Save(ctx, r.state); err != nil {
+		log.Printf("ERROR: cannot save rotation state: %s", err)
+		return fmt.Errorf("cannot save rotation state: %s", err)
+	}
+	return nil
+}
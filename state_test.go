@@ -0,0 +1,334 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (m *mockDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	key := *input.Key["SecretId"].S + "/" + *input.Key["VersionId"].S
+	return &dynamodb.GetItemOutput{Item: m.items[key]}, nil
+}
+
+func (m *mockDynamoDB) PutItemWithContext(ctx aws.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	key := *input.Item["SecretId"].S + "/" + *input.Item["VersionId"].S
+	m.items[key] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+type observablePasswordSetter struct {
+	test.MockPasswordSetter
+	o db.HostObserver
+}
+
+func (ps *observablePasswordSetter) SetHostObserver(o db.HostObserver) {
+	ps.o = o
+}
+
+func TestStateStore(t *testing.T) {
+	// Test that state from setSecret is saved and loaded in finishSecret by
+	// a different Rotator, like a different Lambda invocation
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{}, nil
+		},
+	}
+	ddb := &mockDynamoDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	store := rotate.DynamoDBStateStore{DynamoDB: ddb, Table: "rotation", TTL: time.Hour}
+
+	ps := &observablePasswordSetter{}
+	ps.VerifyPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		if creds.New.Password == "p2" {
+			return fmt.Errorf("not set yet")
+		}
+		return nil
+	}
+	ps.SetPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		ps.o.ObserveHost("db1", "setting", 5*time.Millisecond, nil)
+		return nil
+	}
+
+	newRotator := func() *rotate.Rotator {
+		return rotate.NewRotator(rotate.Config{
+			SecretsManager: sm,
+			PasswordSetter: ps,
+			StateStore:     store,
+		})
+	}
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+	if _, err := newRotator().Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err := store.Load(context.TODO(), "def", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Step != "setSecret" {
+		t.Errorf("got step %s, expected setSecret", state.Step)
+	}
+	if state.SetPasswordTime.IsZero() {
+		t.Error("SetPasswordTime is zero, expected it to be set")
+	}
+	if h, ok := state.Hosts["db1"]; !ok || h.Action != "setting" || h.Error != "" {
+		t.Errorf("got host results %+v, expected db1 setting ok", state.Hosts)
+	}
+	setTime := state.SetPasswordTime
+
+	event["Step"] = "finishSecret"
+	if _, err := newRotator().Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	state, err = store.Load(context.TODO(), "def", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Step != "finishSecret" {
+		t.Errorf("got step %s, expected finishSecret", state.Step)
+	}
+	if !state.SetPasswordTime.Equal(setTime) {
+		t.Errorf("got SetPasswordTime %s, expected %s from setSecret", state.SetPasswordTime, setTime)
+	}
+	if _, ok := state.Hosts["db1"]; !ok {
+		t.Errorf("host results from setSecret lost: %+v", state.Hosts)
+	}
+}
+
+func TestHostsFailed(t *testing.T) {
+	// Test that EVENT_HOSTS_FAILED is sent if hosts failed but the PasswordSetter
+	// succeeded, like mysql.Config.FailureThreshold
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+	}
+	ps := &observablePasswordSetter{}
+	set := false
+	ps.SetPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		ps.o.ObserveHost("db1", "setting", time.Millisecond, nil)
+		ps.o.ObserveHost("db2", "setting", time.Millisecond, fmt.Errorf("rebooting"))
+		set = true
+		return nil
+	}
+	ps.VerifyPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		if creds.New.Password == "p2" && !set {
+			return fmt.Errorf("not set yet")
+		}
+		ps.o.ObserveHost("db1", "verify", time.Millisecond, nil)
+		return nil
+	}
+	gotEvents := []rotate.Event{}
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager: sm,
+		PasswordSetter: ps,
+		EventReceiver:  eventRecorder(func(e rotate.Event) { gotEvents = append(gotEvents, e) }),
+	})
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	var failed *rotate.Event
+	for i := range gotEvents {
+		if gotEvents[i].Name == rotate.EVENT_HOSTS_FAILED {
+			failed = &gotEvents[i]
+		}
+	}
+	if failed == nil {
+		t.Fatalf("no %s event, expected one after setSecret", rotate.EVENT_HOSTS_FAILED)
+	}
+	if failed.Step != "setSecret" || failed.Error == nil || failed.Error.Error() != "1 database hosts failed: db2" {
+		t.Errorf("got event %+v, expected setSecret with error for db2", *failed)
+	}
+
+	// db2 failed in setSecret, not testSecret, so no event
+	gotEvents = []rotate.Event{}
+	event["Step"] = "testSecret"
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range gotEvents {
+		if e.Name == rotate.EVENT_HOSTS_FAILED {
+			t.Errorf("got %s event in testSecret, expected none", e.Name)
+		}
+	}
+}
+
+type resumablePasswordSetter struct {
+	observablePasswordSetter
+	resumed []string
+}
+
+func (ps *resumablePasswordSetter) Resume(hostnames []string) {
+	ps.resumed = hostnames
+}
+
+func TestStateStoreInterrupted(t *testing.T) {
+	// Test that when the context is cancelled during SetPassword, the changed
+	// hosts are saved, and the retry resumes with those hosts instead of
+	// verifying the current password, which fails on the changed hosts
+	sm := test.MockSecretsManager{
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			switch *input.VersionStage {
+			case rotate.AWSCURRENT:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString1,
+					VersionId:    aws.String("v1"),
+				}, nil
+			default:
+				return &secretsmanager.GetSecretValueOutput{
+					SecretString: &secretString2,
+					VersionId:    aws.String("v2"),
+				}, nil
+			}
+		},
+		UpdateSecretVersionStageFunc: func(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+			t.Errorf("UpdateSecretVersionStage called, expected pending secret to be kept")
+			return &secretsmanager.UpdateSecretVersionStageOutput{}, nil
+		},
+	}
+	ddb := &mockDynamoDB{items: map[string]map[string]*dynamodb.AttributeValue{}}
+	store := rotate.DynamoDBStateStore{DynamoDB: ddb, Table: "rotation"}
+
+	ps := &resumablePasswordSetter{}
+	var events []string
+	recv := eventRecorder(func(e rotate.Event) { events = append(events, e.Name) })
+	newRotator := func() *rotate.Rotator {
+		return rotate.NewRotator(rotate.Config{
+			SecretsManager:   sm,
+			PasswordSetter:   ps,
+			StateStore:       store,
+			EventReceiver:    recv,
+			CleanupOnFailure: true,
+		})
+	}
+	event := map[string]string{
+		"ClientRequestToken": "v2",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+
+	// First invocation: the new password is set on db1, then the context is
+	// cancelled, like a Lambda timeout, before db2
+	ctx, cancel := context.WithCancel(context.Background())
+	ps.VerifyPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		if creds.New.Password == "p2" {
+			return fmt.Errorf("not set yet")
+		}
+		return nil
+	}
+	ps.SetPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		ps.o.ObserveHost("db1", "setting", time.Millisecond, nil)
+		cancel()
+		ps.o.ObserveHost("db2", "setting", time.Millisecond, ctx.Err())
+		return ctx.Err()
+	}
+	_, err := newRotator().Handler(ctx, event)
+	if !errors.Is(err, rotate.ErrInterrupted) {
+		t.Fatalf("got error %v, expected ErrInterrupted", err)
+	}
+	state, err := store.Load(context.TODO(), "def", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.Interrupted {
+		t.Error("Interrupted is false, expected true")
+	}
+	if diff := deep.Equal(state.ChangedHosts, []string{"db1"}); diff != nil {
+		t.Error(diff)
+	}
+	if !hasEvent(events, rotate.EVENT_PASSWORD_ROTATION_INTERRUPTED) {
+		t.Errorf("no %s event: %v", rotate.EVENT_PASSWORD_ROTATION_INTERRUPTED, events)
+	}
+
+	// Retry: mixed passwords, so verifying the current password fails, but
+	// SetSecret resumes with the changed hosts
+	ps.VerifyPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		return fmt.Errorf("mixed passwords")
+	}
+	ps.SetPasswordFunc = func(ctx context.Context, creds db.NewPassword) error {
+		ps.o.ObserveHost("db2", "setting", time.Millisecond, nil)
+		return nil
+	}
+	if _, err := newRotator().Handler(context.TODO(), event); err != nil {
+		t.Fatal(err)
+	}
+	if diff := deep.Equal(ps.resumed, []string{"db1"}); diff != nil {
+		t.Error(diff)
+	}
+	state, err = store.Load(context.TODO(), "def", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Interrupted {
+		t.Error("Interrupted is true after resume, expected false")
+	}
+	if diff := deep.Equal(state.ChangedHosts, []string{"db1", "db2"}); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func hasEvent(events []string, name string) bool {
+	for _, e := range events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
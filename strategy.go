@@ -0,0 +1,132 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// RotationStrategy determines which database users a rotation changes.
+// Rotator calls Rotate in CreateSecret, after SecretSetter.Rotate, and calls
+// Credentials in SetSecret and TestSecret to get the database credentials for
+// the PasswordSetter.
+//
+// SingleUser, AlternatingUsers, and MultiUser are provided. If Config.RotationStrategy
+// is nil, MultiUser is used if the SecretSetter implements MultiCredentials,
+// else SingleUser is used.
+type RotationStrategy interface {
+	// Rotate changes the new secret values after SecretSetter.Rotate. newVals
+	// is a copy of curVals with the new password. Rotate must not change curVals.
+	Rotate(curVals, newVals map[string]string) error
+
+	// Credentials returns the database credentials to change from curVals
+	// to newVals. Rotator sets Admin in the returned value.
+	Credentials(ss SecretSetter, curVals, newVals map[string]string) db.NewPassword
+}
+
+var (
+	_ RotationStrategy = SingleUser{}
+	_ RotationStrategy = AlternatingUsers{}
+	_ RotationStrategy = MultiUser{}
+)
+
+// SingleUser is the default RotationStrategy: one user, and the password is
+// changed in place. There is a short time between setSecret and finishSecret
+// when the new password is set on the databases but the secret returns the old
+// password.
+type SingleUser struct{}
+
+func (s SingleUser) Rotate(curVals, newVals map[string]string) error {
+	return nil
+}
+
+func (s SingleUser) Credentials(ss SecretSetter, curVals, newVals map[string]string) db.NewPassword {
+	return db.NewPassword{
+		Current: secretCredentials(ss, curVals),
+		New:     secretCredentials(ss, newVals),
+	}
+}
+
+// MultiUser is the RotationStrategy for a SecretSetter that implements
+// MultiCredentials: all accounts are changed as one unit. The first account
+// is Current and New, and the other accounts are Accounts. Current and new
+// accounts are paired by index. If the SecretSetter does not implement
+// MultiCredentials, it works like SingleUser.
+type MultiUser struct{}
+
+func (s MultiUser) Rotate(curVals, newVals map[string]string) error {
+	return nil
+}
+
+func (s MultiUser) Credentials(ss SecretSetter, curVals, newVals map[string]string) db.NewPassword {
+	mc, ok := ss.(MultiCredentials)
+	if !ok {
+		return SingleUser{}.Credentials(ss, curVals, newVals)
+	}
+	cur := mc.AllCredentials(curVals)
+	new := mc.AllCredentials(newVals)
+	creds := db.NewPassword{}
+	for i := 0; i < len(cur) && i < len(new); i++ {
+		if i == 0 {
+			creds.Current, creds.New = cur[0], new[0]
+			continue
+		}
+		creds.Accounts = append(creds.Accounts, db.NewPassword{Current: cur[i], New: new[i]})
+	}
+	return creds
+}
+
+const (
+	DEFAULT_CLONE_SUFFIX = "_clone" // username suffix for AlternatingUsers
+)
+
+// AlternatingUsers is the RotationStrategy that alternates between two users
+// with the same privileges, like app and app_clone. Each rotation changes the
+// password of the user that is not current, then switches the secret to that
+// user, so the current user and password keep working during the rotation.
+// This is the same as the AWS alternating users rotation strategy.
+//
+// The password of the other user is not in the current secret, so the PasswordSetter
+// must connect as an admin to set it: set Config.AdminSecretId. Both users must
+// already exist. The secret must have a "username" value; other values are
+// changed by the SecretSetter as usual.
+type AlternatingUsers struct {
+	// Suffix is the username suffix of the other user. If empty, DEFAULT_CLONE_SUFFIX
+	// is used.
+	Suffix string
+}
+
+// Rotate switches the username to the other user: it adds Suffix to the username
+// if it does not have it, else it removes Suffix.
+func (s AlternatingUsers) Rotate(curVals, newVals map[string]string) error {
+	username := curVals[SECRET_KEY_USERNAME]
+	if username == "" {
+		return fmt.Errorf("secret has no %s value; it is required for AlternatingUsers", SECRET_KEY_USERNAME)
+	}
+	suffix := s.suffix()
+	if strings.HasSuffix(username, suffix) && username != suffix {
+		newVals[SECRET_KEY_USERNAME] = strings.TrimSuffix(username, suffix)
+	} else {
+		newVals[SECRET_KEY_USERNAME] = username + suffix
+	}
+	return nil
+}
+
+// Credentials returns the new user as both Current and New. Current.Password
+// is the password from curVals, which belongs to the current user, because
+// the password of the other user is not known.
+func (s AlternatingUsers) Credentials(ss SecretSetter, curVals, newVals map[string]string) db.NewPassword {
+	creds := SingleUser{}.Credentials(ss, curVals, newVals)
+	creds.Current.Username = creds.New.Username
+	return creds
+}
+
+func (s AlternatingUsers) suffix() string {
+	if s.Suffix == "" {
+		return DEFAULT_CLONE_SUFFIX
+	}
+	return s.Suffix
+}
@@ -0,0 +1,66 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"testing"
+
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+func TestAlternatingUsers(t *testing.T) {
+	s := rotate.AlternatingUsers{}
+
+	// app -> app_clone
+	curVals := map[string]string{"username": "app", "password": "p1"}
+	newVals := map[string]string{"username": "app", "password": "p2"}
+	if err := s.Rotate(curVals, newVals); err != nil {
+		t.Fatal(err)
+	}
+	if newVals["username"] != "app_clone" {
+		t.Errorf("got username %s, expected app_clone", newVals["username"])
+	}
+	if curVals["username"] != "app" {
+		t.Errorf("current username changed to %s", curVals["username"])
+	}
+
+	// Both Current and New are the new user because that's the user being changed
+	got := s.Credentials(rotate.RandomPassword{}, curVals, newVals)
+	expect := db.NewPassword{
+		Current: db.Credentials{Username: "app_clone", Password: "p1"},
+		New:     db.Credentials{Username: "app_clone", Password: "p2"},
+	}
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	// app_clone -> app
+	curVals = newVals
+	newVals = map[string]string{"username": "app_clone", "password": "p3"}
+	if err := s.Rotate(curVals, newVals); err != nil {
+		t.Fatal(err)
+	}
+	if newVals["username"] != "app" {
+		t.Errorf("got username %s, expected app", newVals["username"])
+	}
+
+	// Username is required
+	if err := s.Rotate(map[string]string{}, map[string]string{}); err == nil {
+		t.Error("no error without username, expected an error")
+	}
+}
+
+func TestMultiUserWithoutMultiCredentials(t *testing.T) {
+	// MultiUser works like SingleUser if the SecretSetter doesn't implement
+	// MultiCredentials
+	curVals := map[string]string{"username": "app", "password": "p1"}
+	newVals := map[string]string{"username": "app", "password": "p2"}
+	got := rotate.MultiUser{}.Credentials(rotate.RandomPassword{}, curVals, newVals)
+	expect := rotate.SingleUser{}.Credentials(rotate.RandomPassword{}, curVals, newVals)
+	if diff := deep.Equal(got, expect); diff != nil {
+		t.Error(diff)
+	}
+}
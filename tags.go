@@ -0,0 +1,118 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+)
+
+const (
+	TAG_SKIP_DATABASE      = "skip-database"      // "true" or "false"
+	TAG_MAINTENANCE_WINDOW = "maintenance-window" // "HH:MM-HH:MM" UTC
+)
+
+// ErrOutsideMaintenanceWindow is returned by CreateSecret if the secret has
+// a maintenance window tag and the current time is outside the window.
+// Secrets Manager retries the rotation later.
+var ErrOutsideMaintenanceWindow = errors.New("current time is outside the maintenance window")
+
+// secretTags is the per-secret configuration read from tags on the secret.
+// Only tags starting with Config.SecretTagPrefix are used, and the prefix is
+// removed from the tag keys.
+type secretTags struct {
+	settings     map[string]string // all tags with prefix removed
+	skipDatabase bool
+	window       *maintenanceWindow
+}
+
+// readSecretTags calls DescribeSecret and returns the tags with the given prefix.
+func (r *Rotator) readSecretTags() (secretTags, error) {
+	t0 := time.Now()
+	out, err := r.sm.DescribeSecret(&secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(r.secretId),
+	})
+	debug("DescribeSecret response time: %dms", time.Now().Sub(t0).Milliseconds())
+	if err != nil {
+		return secretTags{}, err
+	}
+	if out == nil {
+		return secretTags{settings: map[string]string{}}, nil
+	}
+	return parseSecretTags(r.tagPrefix, out.Tags)
+}
+
+func parseSecretTags(prefix string, tags []*secretsmanager.Tag) (secretTags, error) {
+	st := secretTags{settings: map[string]string{}}
+	for _, tag := range tags {
+		if tag == nil || tag.Key == nil || !strings.HasPrefix(*tag.Key, prefix) {
+			continue
+		}
+		key := strings.TrimPrefix(*tag.Key, prefix)
+		val := aws.StringValue(tag.Value)
+		st.settings[key] = val
+
+		switch key {
+		case TAG_SKIP_DATABASE:
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return st, fmt.Errorf("invalid %s%s tag value: %s: %s", prefix, key, val, err)
+			}
+			st.skipDatabase = b
+		case TAG_MAINTENANCE_WINDOW:
+			w, err := parseMaintenanceWindow(val)
+			if err != nil {
+				return st, fmt.Errorf("invalid %s%s tag value: %s: %s", prefix, key, val, err)
+			}
+			st.window = w
+		}
+	}
+	return st, nil
+}
+
+// maintenanceWindow is a daily UTC time range, like "22:00-02:00". The end can
+// be before the start, in which case the window spans midnight.
+type maintenanceWindow struct {
+	start time.Duration // since midnight
+	end   time.Duration // since midnight
+}
+
+func parseMaintenanceWindow(s string) (*maintenanceWindow, error) {
+	f := strings.Split(s, "-")
+	if len(f) != 2 {
+		return nil, fmt.Errorf("expected HH:MM-HH:MM")
+	}
+	start, err := parseClock(f[0])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseClock(f[1])
+	if err != nil {
+		return nil, err
+	}
+	return &maintenanceWindow{start: start, end: end}, nil
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// contains returns true if t is inside the window.
+func (w maintenanceWindow) contains(t time.Time) bool {
+	t = t.UTC()
+	now := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.start <= w.end {
+		return now >= w.start && now < w.end
+	}
+	return now >= w.start || now < w.end // spans midnight
+}
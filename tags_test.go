@@ -0,0 +1,117 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+type tunablePasswordSetter struct {
+	test.MockPasswordSetter
+	settings map[string]string
+}
+
+func (ps *tunablePasswordSetter) Tune(settings map[string]string) error {
+	ps.settings = settings
+	return nil
+}
+
+func TestSecretTags(t *testing.T) {
+	// Test that secret tags with the prefix are read, the skip-database tag
+	// skips the database, and all tags are passed to a db.Tunable PasswordSetter
+	sm := test.MockSecretsManager{
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{
+				Tags: []*secretsmanager.Tag{
+					{Key: aws.String("rotation:skip-database"), Value: aws.String("true")},
+					{Key: aws.String("rotation:parallel"), Value: aws.String("5")},
+					{Key: aws.String("owner"), Value: aws.String("dba")},
+				},
+			}, nil
+		},
+	}
+
+	setPasswordCalled := false
+	ps := &tunablePasswordSetter{
+		MockPasswordSetter: test.MockPasswordSetter{
+			SetPasswordFunc: func(ctx context.Context, creds db.NewPassword) error {
+				setPasswordCalled = true
+				return nil
+			},
+		},
+	}
+
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager:  sm,
+		SecretSetter:    test.MockSecretSetter{},
+		PasswordSetter:  ps,
+		SecretTagPrefix: "rotation:",
+	})
+
+	event := map[string]string{
+		"ClientRequestToken": "abc",
+		"SecretId":           "def",
+		"Step":               "setSecret",
+	}
+	if _, err := r.Handler(context.TODO(), event); err != nil {
+		t.Error(err)
+	}
+	if setPasswordCalled {
+		t.Errorf("SetPassword called, expected skip-database tag to skip it")
+	}
+	expectSettings := map[string]string{"skip-database": "true", "parallel": "5"}
+	if fmt.Sprint(ps.settings) != fmt.Sprint(expectSettings) {
+		t.Errorf("got settings %v, expected %v", ps.settings, expectSettings)
+	}
+}
+
+func TestSecretTagsMaintenanceWindow(t *testing.T) {
+	// Test that createSecret does not start a rotation outside the maintenance window
+	now := time.Now().UTC()
+	window := fmt.Sprintf("%s-%s", now.Add(1*time.Hour).Format("15:04"), now.Add(2*time.Hour).Format("15:04"))
+
+	getSecretValueCalled := false
+	sm := test.MockSecretsManager{
+		DescribeSecretFunc: func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+			return &secretsmanager.DescribeSecretOutput{
+				Tags: []*secretsmanager.Tag{
+					{Key: aws.String("rotation:maintenance-window"), Value: aws.String(window)},
+				},
+			}, nil
+		},
+		GetSecretValueFunc: func(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+			getSecretValueCalled = true
+			return nil, fmt.Errorf("GetSecretValue should not be called")
+		},
+	}
+
+	r := rotate.NewRotator(rotate.Config{
+		SecretsManager:  sm,
+		SecretSetter:    test.MockSecretSetter{},
+		PasswordSetter:  test.MockPasswordSetter{},
+		SecretTagPrefix: "rotation:",
+	})
+
+	event := map[string]string{
+		"ClientRequestToken": "abc",
+		"SecretId":           "def",
+		"Step":               "createSecret",
+	}
+	_, err := r.Handler(context.TODO(), event)
+	if err != rotate.ErrOutsideMaintenanceWindow {
+		t.Errorf("got error %v, expected ErrOutsideMaintenanceWindow", err)
+	}
+	if getSecretValueCalled {
+		t.Errorf("GetSecretValue called, expected no rotation outside maintenance window")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TEAMS_TIMEOUT is how long one post to a Microsoft Teams webhook can take.
+const TEAMS_TIMEOUT = 5 * time.Second
+
+// TeamsReceiver is an EventReceiver that posts rotation notifications to a
+// Microsoft Teams webhook (an Incoming Webhook or a Workflows "post to a channel
+// when a webhook request is received" webhook) as an Adaptive Card. By default,
+// it posts when a rotation succeeds (EVENT_END_ROTATION), fails (EVENT_ERROR),
+// or is interrupted (EVENT_PASSWORD_ROTATION_INTERRUPTED). The card shows the
+// secret name, step, duration, and error (redacted), not the secret value.
+//
+// Posts are synchronous; wrap it in an AsyncReceiver to post without blocking
+// the rotation. Errors are logged and ignored.
+type TeamsReceiver struct {
+	URL    string       // webhook URL
+	Client *http.Client // http.DefaultClient if nil
+
+	// Events are the event names to post, like EVENT_END_ROTATION. If nil,
+	// EVENT_END_ROTATION, EVENT_ERROR, and EVENT_PASSWORD_ROTATION_INTERRUPTED
+	// are posted.
+	Events []string
+}
+
+var _ EventReceiver = TeamsReceiver{}
+
+func (r TeamsReceiver) Receive(e Event) {
+	if !r.post(e.Name) {
+		return
+	}
+	body, err := json.Marshal(teamsMessage(e))
+	if err != nil {
+		log.Printf("ERROR: Teams webhook: %s", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TEAMS_TIMEOUT)
+	defer cancel()
+	if err := postJSON(ctx, httpClient(r.Client), r.URL, body); err != nil {
+		// Do not log the URL: the webhook URL is the credential
+		log.Printf("ERROR: Teams webhook: event %s: %s", e.Name, teamsRedactURL(err.Error(), r.URL))
+	}
+}
+
+// post returns true if the event should be posted.
+func (r TeamsReceiver) post(name string) bool {
+	if r.Events == nil {
+		return name == EVENT_END_ROTATION || name == EVENT_ERROR || name == EVENT_PASSWORD_ROTATION_INTERRUPTED
+	}
+	for _, n := range r.Events {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// teamsMessage returns the webhook message with an Adaptive Card for the event.
+func teamsMessage(e Event) map[string]interface{} {
+	title, color := "Password rotation: "+e.Name, "default"
+	switch e.Name {
+	case EVENT_END_ROTATION:
+		title, color = "Password rotation completed", "good"
+	case EVENT_ERROR:
+		title, color = "Password rotation failed", "attention"
+	case EVENT_PASSWORD_ROTATION_INTERRUPTED:
+		title, color = "Password rotation interrupted", "warning"
+	}
+	facts := []map[string]string{
+		{"title": "Secret", "value": secretName(e.SecretId)},
+	}
+	if e.Step != "" {
+		facts = append(facts, map[string]string{"title": "Step", "value": e.Step})
+	}
+	if e.Duration > 0 {
+		facts = append(facts, map[string]string{"title": "Duration", "value": e.Duration.Round(time.Millisecond).String()})
+	}
+	if e.Error != nil {
+		facts = append(facts, map[string]string{"title": "Error", "value": e.Error.Error()})
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	facts = append(facts, map[string]string{"title": "Time", "value": t.UTC().Format(time.RFC3339)})
+
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{
+			{
+				"contentType": "application/vnd.microsoft.card.adaptive",
+				"content": map[string]interface{}{
+					"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+					"type":    "AdaptiveCard",
+					"version": "1.4",
+					"body": []map[string]interface{}{
+						{"type": "TextBlock", "text": title, "weight": "bolder", "size": "medium", "color": color, "wrap": true},
+						{"type": "FactSet", "facts": facts},
+					},
+				},
+			},
+		},
+	}
+}
+
+// teamsRedactURL replaces the webhook URL in msg, because it contains the
+// webhook signature.
+func teamsRedactURL(msg, url string) string {
+	if url == "" {
+		return msg
+	}
+	return strings.ReplaceAll(msg, url, "<webhook URL>")
+}
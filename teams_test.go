@@ -0,0 +1,69 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+func TestTeamsReceiver(t *testing.T) {
+	var posts []map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+			t.Error(err)
+		}
+		posts = append(posts, msg)
+	}))
+	defer ts.Close()
+
+	r := rotate.TeamsReceiver{URL: ts.URL}
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	r.Receive(rotate.Event{Name: rotate.EVENT_BEGIN_ROTATION, SecretId: "prod/db", Step: "createSecret", Time: now})
+	r.Receive(rotate.Event{Name: rotate.EVENT_ERROR, SecretId: "prod/db", Step: "setSecret", Time: now, Error: errors.New("access denied")})
+	r.Receive(rotate.Event{Name: rotate.EVENT_END_ROTATION, SecretId: "prod/db", Step: "finishSecret", Time: now, Duration: 90 * time.Second})
+
+	if len(posts) != 2 {
+		t.Fatalf("got %d posts, expected 2 (begin-rotation not posted by default)", len(posts))
+	}
+
+	card := func(msg map[string]interface{}) (string, string, []interface{}) {
+		content := msg["attachments"].([]interface{})[0].(map[string]interface{})["content"].(map[string]interface{})
+		body := content["body"].([]interface{})
+		title := body[0].(map[string]interface{})
+		return title["text"].(string), title["color"].(string), body[1].(map[string]interface{})["facts"].([]interface{})
+	}
+	title, color, facts := card(posts[0])
+	if title != "Password rotation failed" || color != "attention" {
+		t.Errorf("got title %q color %q, expected failure", title, color)
+	}
+	expect := []interface{}{
+		map[string]interface{}{"title": "Secret", "value": "prod/db"},
+		map[string]interface{}{"title": "Step", "value": "setSecret"},
+		map[string]interface{}{"title": "Error", "value": "access denied"},
+		map[string]interface{}{"title": "Time", "value": "2026-01-02T03:04:05Z"},
+	}
+	if diff := deep.Equal(facts, expect); diff != nil {
+		t.Error(diff)
+	}
+
+	title, color, facts = card(posts[1])
+	if title != "Password rotation completed" || color != "good" {
+		t.Errorf("got title %q color %q, expected success", title, color)
+	}
+	if diff := deep.Equal(facts[2], map[string]interface{}{"title": "Duration", "value": "1m30s"}); diff != nil {
+		t.Error(diff)
+	}
+	if posts[1]["type"] != "message" {
+		t.Errorf("got type %v, expected message", posts[1]["type"])
+	}
+}
@@ -0,0 +1,16 @@
+// Copyright 2026, Square, Inc.
+
+// Package e2e has end-to-end tests that run the four rotation steps against
+// LocalStack Secrets Manager and MySQL in Docker (see docker-compose.yml). The
+// tests require the e2e build tag, so "go test ./..." does not run them:
+//
+//	make e2e
+//
+// Or start the services and run the tests:
+//
+//	docker compose -f test/e2e/docker-compose.yml up -d --wait
+//	go test -tags e2e ./test/e2e/
+//
+// The services are configured by env vars: E2E_AWS_ENDPOINT (default
+// http://localhost:4566) and E2E_MYSQL_DSN (default root:test@tcp(127.0.0.1:13306)/).
+package e2e
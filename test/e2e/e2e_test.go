@@ -0,0 +1,272 @@
+// Copyright 2026, Square, Inc.
+
+//go:build e2e
+
+package e2e_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	driver "github.com/go-sql-driver/mysql"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+	"github.com/square/password-rotation-lambda/v2/test"
+)
+
+// fixture is one secret in LocalStack and its MySQL user.
+type fixture struct {
+	sm       *secretsmanager.SecretsManager
+	admin    *sql.DB
+	cfg      *driver.Config // admin DSN
+	secretId string
+	user     string
+}
+
+func env(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// setup creates a MySQL user with password "p1" and a secret with the same
+// username and password. It fails the test if LocalStack or MySQL is not
+// running (see package doc).
+func setup(t *testing.T) fixture {
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(env("E2E_AWS_ENDPOINT", "http://localhost:4566")),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("test", "test", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sm := secretsmanager.New(sess)
+
+	cfg, err := driver.ParseDSN(env("E2E_MYSQL_DSN", "root:test@tcp(127.0.0.1:13306)/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	if err := admin.Ping(); err != nil {
+		t.Fatalf("MySQL not running (make e2e): %s", err)
+	}
+
+	user := fmt.Sprintf("e2e_%d", time.Now().UnixNano()%1e9)
+	if _, err := admin.Exec(fmt.Sprintf("CREATE USER '%s'@'%%' IDENTIFIED BY 'p1'", user)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec(fmt.Sprintf("DROP USER IF EXISTS '%s'@'%%'", user)) })
+
+	bytes, _ := json.Marshal(map[string]string{"username": user, "password": "p1"})
+	_, err = sm.CreateSecret(&secretsmanager.CreateSecretInput{
+		Name:         aws.String(user),
+		SecretString: aws.String(string(bytes)),
+	})
+	if err != nil {
+		t.Fatalf("LocalStack not running (make e2e): %s", err)
+	}
+	t.Cleanup(func() {
+		sm.DeleteSecret(&secretsmanager.DeleteSecretInput{SecretId: aws.String(user), ForceDeleteWithoutRecovery: aws.Bool(true)})
+	})
+
+	return fixture{sm: sm, admin: admin, cfg: cfg, secretId: user, user: user}
+}
+
+// rdsClient returns a mock RDS client with one instance: the MySQL server.
+func (f fixture) rdsClient(t *testing.T) test.MockRDSClient {
+	host, port, err := net.SplitHostPort(f.cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, _ := strconv.ParseInt(port, 10, 64)
+	return test.MockRDSClient{
+		DescribeDBInstancesFunc: func(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
+			return &rds.DescribeDBInstancesOutput{
+				DBInstances: []*rds.DBInstance{
+					{
+						DBInstanceIdentifier: aws.String("e2e"),
+						Endpoint:             &rds.Endpoint{Address: aws.String(host), Port: aws.Int64(p)},
+					},
+				},
+			}, nil
+		},
+	}
+}
+
+// rotator returns a Rotator for the fixture secret that sets the password with
+// the client, which is usually mysql.NewRDSClient.
+func (f fixture) rotator(t *testing.T, client mysql.PasswordClient, retry uint) *rotate.Rotator {
+	ps := mysql.NewPasswordSetter(mysql.Config{
+		RDSClient: f.rdsClient(t),
+		DbClient:  client,
+		Retry:     retry,
+		RetryWait: 100 * time.Millisecond,
+	})
+	return rotate.NewRotator(rotate.Config{
+		SecretsManager: f.sm,
+		SecretSetter:   rotate.RandomPassword{},
+		PasswordSetter: ps,
+	})
+}
+
+// stages returns the version ID of each staging label.
+func (f fixture) stages(t *testing.T) map[string]string {
+	out, err := f.sm.DescribeSecret(&secretsmanager.DescribeSecretInput{SecretId: aws.String(f.secretId)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := map[string]string{}
+	for id, labels := range out.VersionIdsToStages {
+		for _, l := range labels {
+			stages[aws.StringValue(l)] = id
+		}
+	}
+	return stages
+}
+
+// password returns the password of the secret version with the staging label.
+func (f fixture) password(t *testing.T, stage string) string {
+	out, err := f.sm.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId:     aws.String(f.secretId),
+		VersionStage: aws.String(stage),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v map[string]string
+	if err := json.Unmarshal([]byte(aws.StringValue(out.SecretString)), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v["password"]
+}
+
+// login returns an error if the user cannot connect with the password.
+func (f fixture) login(password string) error {
+	cfg := f.cfg.Clone()
+	cfg.User = f.user
+	cfg.Passwd = password
+	conn, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Ping()
+}
+
+// flakyClient fails the first failSet SetPassword calls and every
+// VerifyPassword call if failVerify is true.
+type flakyClient struct {
+	mysql.PasswordClient
+	failSet    *int
+	failVerify bool
+}
+
+func (c flakyClient) SetPassword(ctx context.Context, creds db.NewPassword) error {
+	if *c.failSet > 0 {
+		*c.failSet--
+		return errors.New("injected SetPassword error")
+	}
+	return c.PasswordClient.SetPassword(ctx, creds)
+}
+
+func (c flakyClient) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
+	if c.failVerify {
+		return errors.New("injected VerifyPassword error")
+	}
+	return c.PasswordClient.VerifyPassword(ctx, creds)
+}
+
+func TestRotate(t *testing.T) {
+	f := setup(t)
+	r := f.rotator(t, mysql.NewRDSClient(false, false), 0)
+
+	version, err := r.Rotate(context.TODO(), f.secretId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stages := f.stages(t)
+	if stages[rotate.AWSCURRENT] != version {
+		t.Errorf("AWSCURRENT is version %s, expected %s", stages[rotate.AWSCURRENT], version)
+	}
+	if _, ok := stages[rotate.AWSPENDING]; ok {
+		t.Errorf("AWSPENDING not removed: %v", stages)
+	}
+	newPass := f.password(t, rotate.AWSCURRENT)
+	if err := f.login(newPass); err != nil {
+		t.Errorf("cannot log in with new password: %s", err)
+	}
+	if err := f.login("p1"); err == nil {
+		t.Error("can log in with old password")
+	}
+}
+
+func TestRotateRollback(t *testing.T) {
+	// Verify fails, so testSecret rolls back the database to the old password
+	// and removes the pending secret
+	f := setup(t)
+	noFail := 0
+	client := flakyClient{PasswordClient: mysql.NewRDSClient(false, false), failSet: &noFail, failVerify: true}
+	r := f.rotator(t, client, 0)
+
+	if _, err := r.Rotate(context.TODO(), f.secretId); err == nil {
+		t.Fatal("no error when VerifyPassword fails")
+	}
+	stages := f.stages(t)
+	if _, ok := stages[rotate.AWSPENDING]; ok {
+		t.Errorf("AWSPENDING not removed after rollback: %v", stages)
+	}
+	if p := f.password(t, rotate.AWSCURRENT); p != "p1" {
+		t.Errorf("current password changed to %q after rollback", p)
+	}
+	if err := f.login("p1"); err != nil {
+		t.Errorf("cannot log in with old password after rollback: %s", err)
+	}
+}
+
+func TestRotateRetry(t *testing.T) {
+	// SetPassword fails once and is retried (Config.Retry), and Secrets Manager
+	// retries a step (the same event again), which must be idempotent
+	f := setup(t)
+	failSet := 1
+	client := flakyClient{PasswordClient: mysql.NewRDSClient(false, false), failSet: &failSet}
+	r := f.rotator(t, client, 1)
+
+	token := fmt.Sprintf("00000000-0000-4000-8000-%012d", time.Now().UnixNano()%1e12)
+	steps := []string{"createSecret", "setSecret", "setSecret", "testSecret", "finishSecret", "finishSecret"}
+	for _, step := range steps {
+		event := map[string]string{"Step": step, "SecretId": f.secretId, "ClientRequestToken": token}
+		if _, err := r.Handler(context.TODO(), event); err != nil {
+			t.Fatalf("%s: %s", step, err)
+		}
+	}
+	if failSet != 0 {
+		t.Errorf("SetPassword not retried")
+	}
+	if stages := f.stages(t); stages[rotate.AWSCURRENT] != token {
+		t.Errorf("AWSCURRENT is version %s, expected %s", stages[rotate.AWSCURRENT], token)
+	}
+	if err := f.login(f.password(t, rotate.AWSCURRENT)); err != nil {
+		t.Errorf("cannot log in with new password: %s", err)
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright 2026, Square, Inc.
+
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// FakeSecretsManager is a stateful fake of Secrets Manager for end-to-end tests
+// of rotation, like running rotate.Rotator.Rotate with a SecretSetter and
+// PasswordSetter. Unlike MockSecretsManager, it keeps secrets, versions, staging
+// labels, tags, and replication status across calls, like Secrets Manager:
+//
+//   - GetSecretValue gets a version by ID or staging label (default AWSCURRENT)
+//   - PutSecretValue is idempotent for the same ClientRequestToken and value
+//   - A staging label is on only one version; moving AWSCURRENT moves
+//     AWSPREVIOUS to the version that was current
+//   - DescribeSecret lists only versions with staging labels
+//
+// Errors are awserr.Error with Secrets Manager error codes. Calls that are not
+// implemented panic (nil secretsmanageriface.SecretsManagerAPI). It is safe for
+// concurrent use. Create it with NewFakeSecretsManager.
+type FakeSecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+
+	// ReplicationLag is the number of DescribeSecret calls after a staging label
+	// changes for which the replicas (see AddReplica) are InProgress, before they
+	// are InSync again. If zero, replicas are always InSync.
+	ReplicationLag int
+
+	mux     *sync.Mutex
+	secrets map[string]*fakeSecret // keyed on name
+}
+
+type fakeSecret struct {
+	name     string
+	arn      string
+	versions map[string]*fakeVersion // keyed on version ID
+	tags     []*secretsmanager.Tag
+	regions  []string // replica regions
+	lag      int      // DescribeSecret calls left until replicas are InSync
+}
+
+type fakeVersion struct {
+	value   string
+	stages  map[string]bool
+	created time.Time
+}
+
+var _ secretsmanageriface.SecretsManagerAPI = &FakeSecretsManager{}
+
+// NewFakeSecretsManager creates a FakeSecretsManager with no secrets.
+// Create secrets with AddSecret or CreateSecret.
+func NewFakeSecretsManager() *FakeSecretsManager {
+	return &FakeSecretsManager{
+		mux:     &sync.Mutex{},
+		secrets: map[string]*fakeSecret{},
+	}
+}
+
+// AddSecret creates a secret with the values as its AWSCURRENT version, which
+// has version ID "v1". The values are marshaled as the JSON secret string.
+func (f *FakeSecretsManager) AddSecret(secretId string, values map[string]string) {
+	bytes, err := json.Marshal(values)
+	if err != nil {
+		panic(err)
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.secrets[secretId] = &fakeSecret{
+		name: secretId,
+		arn:  "arn:aws:secretsmanager:us-east-1:123456789012:secret:" + secretId,
+		versions: map[string]*fakeVersion{
+			"v1": {value: string(bytes), stages: map[string]bool{"AWSCURRENT": true}, created: time.Now()},
+		},
+	}
+}
+
+// AddReplica adds a replica region to the secret, which DescribeSecret reports
+// in ReplicationStatus (see ReplicationLag).
+func (f *FakeSecretsManager) AddReplica(secretId, region string) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if s, ok := f.secrets[secretId]; ok {
+		s.regions = append(s.regions, region)
+	}
+}
+
+// Stages returns the version ID of each staging label of the secret, like
+// {"AWSCURRENT": "v2", "AWSPREVIOUS": "v1"}. It returns nil if the secret
+// does not exist.
+func (f *FakeSecretsManager) Stages(secretId string) map[string]string {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	s, err := f.secret(aws.String(secretId))
+	if err != nil {
+		return nil
+	}
+	stages := map[string]string{}
+	for id, v := range s.versions {
+		for stage := range v.stages {
+			stages[stage] = id
+		}
+	}
+	return stages
+}
+
+// Values returns the values of the secret version with the staging label,
+// unmarshaled from the JSON secret string. It returns nil if there is no such
+// version or the secret string is not a JSON object of strings.
+func (f *FakeSecretsManager) Values(secretId, stage string) map[string]string {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	s, err := f.secret(aws.String(secretId))
+	if err != nil {
+		return nil
+	}
+	for _, v := range s.versions {
+		if v.stages[stage] {
+			var values map[string]string
+			json.Unmarshal([]byte(v.value), &values)
+			return values
+		}
+	}
+	return nil
+}
+
+// CreateSecret creates a secret. If SecretString is set, it is the AWSCURRENT
+// version, with ClientRequestToken as the version ID (or "v1" if not set).
+func (f *FakeSecretsManager) CreateSecret(input *secretsmanager.CreateSecretInput) (*secretsmanager.CreateSecretOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	name := aws.StringValue(input.Name)
+	if _, ok := f.secrets[name]; ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceExistsException, "secret "+name+" already exists", nil)
+	}
+	s := &fakeSecret{
+		name:     name,
+		arn:      "arn:aws:secretsmanager:us-east-1:123456789012:secret:" + name,
+		versions: map[string]*fakeVersion{},
+		tags:     input.Tags,
+	}
+	out := &secretsmanager.CreateSecretOutput{ARN: aws.String(s.arn), Name: aws.String(name)}
+	if input.SecretString != nil {
+		id := aws.StringValue(input.ClientRequestToken)
+		if id == "" {
+			id = "v1"
+		}
+		s.versions[id] = &fakeVersion{value: *input.SecretString, stages: map[string]bool{"AWSCURRENT": true}, created: time.Now()}
+		out.VersionId = aws.String(id)
+	}
+	f.secrets[name] = s
+	return out, nil
+}
+
+// GetSecretValue returns the version by VersionId or VersionStage, or the
+// AWSCURRENT version if neither is set.
+func (f *FakeSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	s, err := f.secret(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	stage := aws.StringValue(input.VersionStage)
+	if stage == "" && input.VersionId == nil {
+		stage = "AWSCURRENT"
+	}
+	for id, v := range s.versions {
+		if input.VersionId != nil && id != *input.VersionId {
+			continue
+		}
+		if stage != "" && !v.stages[stage] {
+			continue
+		}
+		created := v.created
+		return &secretsmanager.GetSecretValueOutput{
+			ARN:           aws.String(s.arn),
+			Name:          aws.String(s.name),
+			SecretString:  aws.String(v.value),
+			VersionId:     aws.String(id),
+			VersionStages: stageList(v.stages),
+			CreatedDate:   &created,
+		}, nil
+	}
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException,
+		fmt.Sprintf("secret %s has no version %s%s", s.name, aws.StringValue(input.VersionId), stage), nil)
+}
+
+// PutSecretValue puts a new version with ID ClientRequestToken and the staging
+// labels VersionStages, or AWSCURRENT if none. Putting the same version again
+// with the same value is not an error; with a different value, it is.
+func (f *FakeSecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	s, err := f.secret(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	id := aws.StringValue(input.ClientRequestToken)
+	if id == "" {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "ClientRequestToken is required", nil)
+	}
+	value := aws.StringValue(input.SecretString)
+	stages := aws.StringValueSlice(input.VersionStages)
+	if len(stages) == 0 {
+		stages = []string{"AWSCURRENT"}
+	}
+	if v, ok := s.versions[id]; ok {
+		if v.value != value {
+			return nil, awserr.New(secretsmanager.ErrCodeResourceExistsException,
+				fmt.Sprintf("version %s of secret %s already exists with a different value", id, s.name), nil)
+		}
+	} else {
+		s.versions[id] = &fakeVersion{value: value, stages: map[string]bool{}, created: time.Now()}
+	}
+	for _, stage := range stages {
+		s.move(stage, id)
+	}
+	return &secretsmanager.PutSecretValueOutput{
+		ARN:           aws.String(s.arn),
+		Name:          aws.String(s.name),
+		VersionId:     aws.String(id),
+		VersionStages: stageList(s.versions[id].stages),
+	}, nil
+}
+
+// UpdateSecretVersionStage moves or removes a staging label. Like Secrets
+// Manager, moving a label that is on another version requires RemoveFromVersionId
+// to be that version, and removing a label requires it to be on RemoveFromVersionId.
+func (f *FakeSecretsManager) UpdateSecretVersionStage(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	s, err := f.secret(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	stage := aws.StringValue(input.VersionStage)
+	from := aws.StringValue(input.RemoveFromVersionId)
+	to := aws.StringValue(input.MoveToVersionId)
+	cur := ""
+	for id, v := range s.versions {
+		if v.stages[stage] {
+			cur = id
+		}
+	}
+	if from != "" && from != cur {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException,
+			fmt.Sprintf("staging label %s is not on version %s of secret %s", stage, from, s.name), nil)
+	}
+	if to == "" {
+		if from == "" {
+			return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "MoveToVersionId or RemoveFromVersionId is required", nil)
+		}
+		delete(s.versions[from].stages, stage)
+		s.lag = f.ReplicationLag
+		return &secretsmanager.UpdateSecretVersionStageOutput{ARN: aws.String(s.arn), Name: aws.String(s.name)}, nil
+	}
+	if _, ok := s.versions[to]; !ok {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException,
+			fmt.Sprintf("secret %s has no version %s", s.name, to), nil)
+	}
+	if cur != "" && cur != to && from == "" {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException,
+			fmt.Sprintf("staging label %s is on version %s of secret %s; RemoveFromVersionId is required", stage, cur, s.name), nil)
+	}
+	s.move(stage, to)
+	s.lag = f.ReplicationLag
+	return &secretsmanager.UpdateSecretVersionStageOutput{ARN: aws.String(s.arn), Name: aws.String(s.name)}, nil
+}
+
+// DescribeSecret returns the secret with its versions that have staging
+// labels, tags, and replication status.
+func (f *FakeSecretsManager) DescribeSecret(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	s, err := f.secret(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	out := &secretsmanager.DescribeSecretOutput{
+		ARN:                aws.String(s.arn),
+		Name:               aws.String(s.name),
+		Tags:               s.tags,
+		VersionIdsToStages: map[string][]*string{},
+	}
+	for id, v := range s.versions {
+		if len(v.stages) > 0 {
+			out.VersionIdsToStages[id] = stageList(v.stages)
+		}
+	}
+	status := secretsmanager.StatusTypeInSync
+	if s.lag > 0 {
+		status = secretsmanager.StatusTypeInProgress
+		s.lag--
+	}
+	for _, region := range s.regions {
+		out.ReplicationStatus = append(out.ReplicationStatus, &secretsmanager.ReplicationStatusType{
+			Region: aws.String(region),
+			Status: aws.String(status),
+		})
+	}
+	return out, nil
+}
+
+// DescribeSecretWithContext is DescribeSecret; the context and options are ignored.
+func (f *FakeSecretsManager) DescribeSecretWithContext(ctx context.Context, input *secretsmanager.DescribeSecretInput, opts ...request.Option) (*secretsmanager.DescribeSecretOutput, error) {
+	return f.DescribeSecret(input)
+}
+
+// TagResource adds or replaces tags of the secret.
+func (f *FakeSecretsManager) TagResource(input *secretsmanager.TagResourceInput) (*secretsmanager.TagResourceOutput, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	s, err := f.secret(input.SecretId)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range input.Tags {
+		replaced := false
+		for _, t := range s.tags {
+			if aws.StringValue(t.Key) == aws.StringValue(tag.Key) {
+				t.Value = tag.Value
+				replaced = true
+			}
+		}
+		if !replaced {
+			s.tags = append(s.tags, &secretsmanager.Tag{Key: tag.Key, Value: tag.Value})
+		}
+	}
+	return &secretsmanager.TagResourceOutput{}, nil
+}
+
+// GetRandomPassword returns a random password of PasswordLength (default 32)
+// characters. It supports the Exclude options and IncludeSpace, but not
+// RequireEachIncludedType.
+func (f *FakeSecretsManager) GetRandomPassword(input *secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error) {
+	chars := ""
+	if !aws.BoolValue(input.ExcludeLowercase) {
+		chars += "abcdefghijklmnopqrstuvwxyz"
+	}
+	if !aws.BoolValue(input.ExcludeUppercase) {
+		chars += "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	}
+	if !aws.BoolValue(input.ExcludeNumbers) {
+		chars += "0123456789"
+	}
+	if !aws.BoolValue(input.ExcludePunctuation) {
+		chars += "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+	}
+	if aws.BoolValue(input.IncludeSpace) {
+		chars += " "
+	}
+	for _, c := range aws.StringValue(input.ExcludeCharacters) {
+		chars = strings.ReplaceAll(chars, string(c), "")
+	}
+	if chars == "" {
+		return nil, awserr.New(secretsmanager.ErrCodeInvalidParameterException, "all characters are excluded", nil)
+	}
+	n := aws.Int64Value(input.PasswordLength)
+	if n == 0 {
+		n = 32
+	}
+	password := make([]byte, n)
+	for i := range password {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return nil, err
+		}
+		password[i] = chars[j.Int64()]
+	}
+	return &secretsmanager.GetRandomPasswordOutput{RandomPassword: aws.String(string(password))}, nil
+}
+
+// secret returns the secret by name or ARN. The caller must lock f.mux.
+func (f *FakeSecretsManager) secret(secretId *string) (*fakeSecret, error) {
+	id := aws.StringValue(secretId)
+	for _, s := range f.secrets {
+		if s.name == id || s.arn == id {
+			return s, nil
+		}
+	}
+	return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "secret "+id+" not found", nil)
+}
+
+// move moves the staging label to the version. Moving AWSCURRENT moves
+// AWSPREVIOUS to the version that was current.
+func (s *fakeSecret) move(stage, versionId string) {
+	for id, v := range s.versions {
+		if v.stages[stage] && id != versionId {
+			delete(v.stages, stage)
+			if stage == "AWSCURRENT" {
+				s.move("AWSPREVIOUS", id)
+			}
+		}
+	}
+	s.versions[versionId].stages[stage] = true
+}
+
+// stageList returns the staging labels, sorted.
+func stageList(stages map[string]bool) []*string {
+	list := make([]string, 0, len(stages))
+	for stage := range stages {
+		list = append(list, stage)
+	}
+	sort.Strings(list)
+	return aws.StringSlice(list)
+}
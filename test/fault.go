@@ -0,0 +1,226 @@
+// Copyright 2026, Square, Inc.
+
+package test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/db/mysql"
+)
+
+// Fault is an error or latency to inject into a call. See Faults.
+type Fault struct {
+	// Err is returned by the call, if set.
+	Err error
+
+	// Delay is slept before the call. If the call has a context, the sleep ends
+	// early when the context is done, and the call returns the context error,
+	// like a timeout.
+	Delay time.Duration
+
+	// After makes the call, then returns Err, like a timeout after the change
+	// was made: the caller sees an error but the change was made.
+	After bool
+
+	// Skip is the number of calls to let through before injecting the fault.
+	Skip int
+
+	// Times is the number of calls to inject the fault into, after Skip.
+	// Zero injects it into every call.
+	Times int
+}
+
+// Faults injects faults into calls by key. Secrets Manager calls are keyed on
+// "secretsmanager.<Operation>", like "secretsmanager.PutSecretValue". Database
+// calls are keyed on "mysql.<Method>@<hostname>" for one host, or
+// "mysql.<Method>" for all hosts, like "mysql.SetPassword@db1". A host key
+// takes precedence. Use FaultySecretsManager and FaultyPasswordClient to inject
+// the faults. Faults is safe for concurrent use by multiple goroutines.
+type Faults struct {
+	mux    *sync.Mutex
+	faults map[string]*Fault
+	calls  map[string]int
+}
+
+// NewFaults returns a Faults with no faults.
+func NewFaults() *Faults {
+	return &Faults{
+		mux:    &sync.Mutex{},
+		faults: map[string]*Fault{},
+		calls:  map[string]int{},
+	}
+}
+
+// Set sets the fault for the key, replacing any previous fault.
+func (f *Faults) Set(key string, fault Fault) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.faults[key] = &fault
+}
+
+// Clear removes all faults and resets the call counts.
+func (f *Faults) Clear() {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.faults = map[string]*Fault{}
+	f.calls = map[string]int{}
+}
+
+// Calls returns the number of calls with the key, with or without a fault.
+func (f *Faults) Calls(key string) int {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	return f.calls[key]
+}
+
+// do calls call with the fault of the first key that has one, if any.
+func (f *Faults) do(ctx context.Context, call func() error, keys ...string) error {
+	fault := f.next(keys)
+	if fault == nil {
+		return call()
+	}
+	if fault.Delay > 0 {
+		select {
+		case <-time.After(fault.Delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if fault.Err == nil {
+		return call()
+	}
+	if fault.After {
+		call() // make the change, but return the fault error
+	}
+	return fault.Err
+}
+
+// next counts a call with the keys and returns a copy of the fault to inject,
+// or nil if none.
+func (f *Faults) next(keys []string) *Fault {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	for _, key := range keys {
+		f.calls[key]++
+	}
+	for _, key := range keys {
+		fault, ok := f.faults[key]
+		if !ok {
+			continue
+		}
+		if fault.Skip > 0 {
+			fault.Skip--
+			return nil
+		}
+		if fault.Times < 0 {
+			continue // used up
+		}
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				fault.Times = -1
+			}
+		}
+		injected := *fault
+		return &injected
+	}
+	return nil
+}
+
+// --------------------------------------------------------------------------
+
+// FaultySecretsManager injects Faults into the Secrets Manager calls that the
+// rotate package makes, then calls the embedded SecretsManagerAPI, usually
+// a FakeSecretsManager.
+type FaultySecretsManager struct {
+	secretsmanageriface.SecretsManagerAPI
+	Faults *Faults
+}
+
+var _ secretsmanageriface.SecretsManagerAPI = FaultySecretsManager{}
+
+func (m FaultySecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	var out *secretsmanager.GetSecretValueOutput
+	err := m.Faults.do(context.Background(), func() (err error) {
+		out, err = m.SecretsManagerAPI.GetSecretValue(input)
+		return err
+	}, "secretsmanager.GetSecretValue")
+	return out, err
+}
+
+func (m FaultySecretsManager) PutSecretValue(input *secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error) {
+	var out *secretsmanager.PutSecretValueOutput
+	err := m.Faults.do(context.Background(), func() (err error) {
+		out, err = m.SecretsManagerAPI.PutSecretValue(input)
+		return err
+	}, "secretsmanager.PutSecretValue")
+	return out, err
+}
+
+func (m FaultySecretsManager) UpdateSecretVersionStage(input *secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error) {
+	var out *secretsmanager.UpdateSecretVersionStageOutput
+	err := m.Faults.do(context.Background(), func() (err error) {
+		out, err = m.SecretsManagerAPI.UpdateSecretVersionStage(input)
+		return err
+	}, "secretsmanager.UpdateSecretVersionStage")
+	return out, err
+}
+
+func (m FaultySecretsManager) DescribeSecret(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error) {
+	var out *secretsmanager.DescribeSecretOutput
+	err := m.Faults.do(context.Background(), func() (err error) {
+		out, err = m.SecretsManagerAPI.DescribeSecret(input)
+		return err
+	}, "secretsmanager.DescribeSecret")
+	return out, err
+}
+
+func (m FaultySecretsManager) DescribeSecretWithContext(ctx context.Context, input *secretsmanager.DescribeSecretInput, opts ...request.Option) (*secretsmanager.DescribeSecretOutput, error) {
+	var out *secretsmanager.DescribeSecretOutput
+	err := m.Faults.do(ctx, func() (err error) {
+		out, err = m.SecretsManagerAPI.DescribeSecretWithContext(ctx, input, opts...)
+		return err
+	}, "secretsmanager.DescribeSecret")
+	return out, err
+}
+
+func (m FaultySecretsManager) GetRandomPassword(input *secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error) {
+	var out *secretsmanager.GetRandomPasswordOutput
+	err := m.Faults.do(context.Background(), func() (err error) {
+		out, err = m.SecretsManagerAPI.GetRandomPassword(input)
+		return err
+	}, "secretsmanager.GetRandomPassword")
+	return out, err
+}
+
+// --------------------------------------------------------------------------
+
+// FaultyPasswordClient injects Faults into the calls to the embedded
+// mysql.PasswordClient, keyed on the method and the hostname of the new
+// credentials. It implements only PasswordClient, not the optional interfaces
+// like mysql.OldPasswordClient.
+type FaultyPasswordClient struct {
+	mysql.PasswordClient
+	Faults *Faults
+}
+
+var _ mysql.PasswordClient = FaultyPasswordClient{}
+
+func (c FaultyPasswordClient) SetPassword(ctx context.Context, creds db.NewPassword) error {
+	return c.Faults.do(ctx, func() error {
+		return c.PasswordClient.SetPassword(ctx, creds)
+	}, "mysql.SetPassword@"+creds.New.Hostname, "mysql.SetPassword")
+}
+
+func (c FaultyPasswordClient) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
+	return c.Faults.do(ctx, func() error {
+		return c.PasswordClient.VerifyPassword(ctx, creds)
+	}, "mysql.VerifyPassword@"+creds.New.Hostname, "mysql.VerifyPassword")
+}
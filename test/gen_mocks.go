@@ -0,0 +1,212 @@
+// Copyright 2026, Square, Inc.
+
+//go:build ignore
+
+// gen_mocks.go generates mock_aws.go: a mock for each AWS API that the rotate
+// and db packages call. Each mock embeds the service interface (so it satisfies
+// it) and has a <Method>Func field for each method, which the method calls if
+// set, else it returns zero values. To mock another method, add it to mocks
+// below and run "go generate ./test/".
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+type mock struct {
+	name    string
+	iface   reflect.Type
+	methods []string
+}
+
+func iface(p interface{}) reflect.Type {
+	return reflect.TypeOf(p).Elem()
+}
+
+var mocks = []mock{
+	{"MockSecretsManager", iface((*secretsmanageriface.SecretsManagerAPI)(nil)), []string{
+		"GetSecretValue",
+		"PutSecretValue",
+		"UpdateSecretVersionStage",
+		"DescribeSecret",
+		"DescribeSecretWithContext",
+		"GetRandomPassword",
+	}},
+	{"MockRDSClient", iface((*rdsiface.RDSAPI)(nil)), []string{
+		"DescribeDBInstances",
+		"DescribeDBClusters",
+	}},
+	{"MockDynamoDB", iface((*dynamodbiface.DynamoDBAPI)(nil)), []string{
+		"GetItemWithContext",
+		"PutItemWithContext",
+		"DeleteItemWithContext",
+		"UpdateItem",
+	}},
+	{"MockKMS", iface((*kmsiface.KMSAPI)(nil)), []string{
+		"GenerateRandom",
+		"GenerateMac",
+	}},
+	{"MockCloudWatch", iface((*cloudwatchiface.CloudWatchAPI)(nil)), []string{
+		"PutMetricData",
+	}},
+	{"MockEventBridge", iface((*eventbridgeiface.EventBridgeAPI)(nil)), []string{
+		"PutEvents",
+	}},
+	{"MockSSM", iface((*ssmiface.SSMAPI)(nil)), []string{
+		"PutParameterWithContext",
+	}},
+	{"MockECS", iface((*ecsiface.ECSAPI)(nil)), []string{
+		"UpdateServiceWithContext",
+	}},
+	{"MockLambda", iface((*lambdaiface.LambdaAPI)(nil)), []string{
+		"InvokeWithContext",
+	}},
+	{"MockS3", iface((*s3iface.S3API)(nil)), []string{
+		"PutObject",
+	}},
+	{"MockSTS", iface((*stsiface.STSAPI)(nil)), []string{
+		"GetCallerIdentityRequest",
+	}},
+}
+
+var ctxType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+func main() {
+	imports := map[string]bool{}
+	addImport := func(t reflect.Type) {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+			t = t.Elem()
+		}
+		if t.PkgPath() != "" {
+			imports[t.PkgPath()] = true
+		}
+	}
+
+	body := &bytes.Buffer{}
+	for _, mk := range mocks {
+		addImport(mk.iface)
+		fmt.Fprintf(body, "\n// --------------------------------------------------------------------------\n\n")
+		fmt.Fprintf(body, "type %s struct {\n\t%s\n", mk.name, mk.iface.String())
+		for _, name := range mk.methods {
+			m, ok := mk.iface.MethodByName(name)
+			if !ok {
+				log.Fatalf("%s has no method %s", mk.iface, name)
+			}
+			params, _, _ := signature(m.Type, addImport)
+			fmt.Fprintf(body, "\t%sFunc func(%s) (%s)\n", name, strings.Join(params, ", "), results(m.Type))
+		}
+		fmt.Fprintf(body, "}\n\nvar _ %s = %s{}\n", mk.iface.String(), mk.name)
+
+		for _, name := range mk.methods {
+			m, _ := mk.iface.MethodByName(name)
+			params, args, names := signature(m.Type, addImport)
+			for i := range params {
+				params[i] = names[i] + " " + params[i]
+			}
+			fmt.Fprintf(body, "\nfunc (m %s) %s(%s) (%s) {\n", mk.name, name, strings.Join(params, ", "), results(m.Type))
+			fmt.Fprintf(body, "\tif m.%sFunc != nil {\n\t\treturn m.%sFunc(%s)\n\t}\n", name, name, strings.Join(args, ", "))
+			// A WithContext method without its Func calls the plain method, so
+			// tests can set either Func
+			if base := strings.TrimSuffix(name, "WithContext"); base != name && contains(mk.methods, base) {
+				fmt.Fprintf(body, "\tif m.%sFunc != nil {\n\t\treturn m.%sFunc(input)\n\t}\n", base, base)
+			}
+			fmt.Fprintf(body, "\treturn %s\n}\n", zeros(m.Type))
+		}
+	}
+
+	paths := []string{}
+	for p := range imports {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	out := &bytes.Buffer{}
+	fmt.Fprintf(out, "// Copyright 2020, Square, Inc.\n\n")
+	fmt.Fprintf(out, "// Code generated by gen_mocks.go; DO NOT EDIT.\n\n")
+	fmt.Fprintf(out, "package test\n\n//go:generate go run gen_mocks.go\n\nimport (\n")
+	for i, p := range paths {
+		if i > 0 && !strings.Contains(paths[i-1], ".") && strings.Contains(p, ".") {
+			fmt.Fprintf(out, "\n") // stdlib, then third-party
+		}
+		fmt.Fprintf(out, "\t%q\n", p)
+	}
+	fmt.Fprintf(out, ")\n")
+	out.Write(body.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatalf("%s\n%s", err, out.Bytes())
+	}
+	if err := os.WriteFile("mock_aws.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// signature returns the parameter types, call args, and parameter names of
+// method type t: ctx for a context, opts for variadic options, else input.
+func signature(t reflect.Type, addImport func(reflect.Type)) (params, args, names []string) {
+	for i := 0; i < t.NumIn(); i++ {
+		in := t.In(i)
+		addImport(in)
+		switch {
+		case t.IsVariadic() && i == t.NumIn()-1:
+			params = append(params, "..."+in.Elem().String())
+			names = append(names, "opts")
+			args = append(args, "opts...")
+		case in == ctxType:
+			params = append(params, in.String())
+			names = append(names, "ctx")
+			args = append(args, "ctx")
+		default:
+			params = append(params, in.String())
+			names = append(names, "input")
+			args = append(args, "input")
+		}
+	}
+	return params, args, names
+}
+
+func results(t reflect.Type) string {
+	out := []string{}
+	for i := 0; i < t.NumOut(); i++ {
+		out = append(out, t.Out(i).String())
+	}
+	return strings.Join(out, ", ")
+}
+
+func zeros(t reflect.Type) string {
+	out := []string{}
+	for i := 0; i < t.NumOut(); i++ {
+		out = append(out, "nil")
+	}
+	return strings.Join(out, ", ")
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -1,22 +1,53 @@
 // Copyright 2020, Square, Inc.
 
+// Code generated by gen_mocks.go; DO NOT EDIT.
+
 package test
 
+//go:generate go run gen_mocks.go
+
 import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/aws/aws-sdk-go/service/lambda/lambdaiface"
 	"github.com/aws/aws-sdk-go/service/rds"
 	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
 )
 
+// --------------------------------------------------------------------------
+
 type MockSecretsManager struct {
 	secretsmanageriface.SecretsManagerAPI
-	GetSecretValueFunc           func(*secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
-	PutSecretValueFunc           func(*secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error)
-	UpdateSecretVersionStageFunc func(*secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error)
-	DescribeSecretFunc           func(input *secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error)
+	GetSecretValueFunc            func(*secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error)
+	PutSecretValueFunc            func(*secretsmanager.PutSecretValueInput) (*secretsmanager.PutSecretValueOutput, error)
+	UpdateSecretVersionStageFunc  func(*secretsmanager.UpdateSecretVersionStageInput) (*secretsmanager.UpdateSecretVersionStageOutput, error)
+	DescribeSecretFunc            func(*secretsmanager.DescribeSecretInput) (*secretsmanager.DescribeSecretOutput, error)
+	DescribeSecretWithContextFunc func(context.Context, *secretsmanager.DescribeSecretInput, ...request.Option) (*secretsmanager.DescribeSecretOutput, error)
+	GetRandomPasswordFunc         func(*secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error)
 }
 
+var _ secretsmanageriface.SecretsManagerAPI = MockSecretsManager{}
+
 func (m MockSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
 	if m.GetSecretValueFunc != nil {
 		return m.GetSecretValueFunc(input)
@@ -45,16 +76,219 @@ func (m MockSecretsManager) DescribeSecret(input *secretsmanager.DescribeSecretI
 	return nil, nil
 }
 
+func (m MockSecretsManager) DescribeSecretWithContext(ctx context.Context, input *secretsmanager.DescribeSecretInput, opts ...request.Option) (*secretsmanager.DescribeSecretOutput, error) {
+	if m.DescribeSecretWithContextFunc != nil {
+		return m.DescribeSecretWithContextFunc(ctx, input, opts...)
+	}
+	if m.DescribeSecretFunc != nil {
+		return m.DescribeSecretFunc(input)
+	}
+	return nil, nil
+}
+
+func (m MockSecretsManager) GetRandomPassword(input *secretsmanager.GetRandomPasswordInput) (*secretsmanager.GetRandomPasswordOutput, error) {
+	if m.GetRandomPasswordFunc != nil {
+		return m.GetRandomPasswordFunc(input)
+	}
+	return nil, nil
+}
+
 // --------------------------------------------------------------------------
 
 type MockRDSClient struct {
 	rdsiface.RDSAPI
 	DescribeDBInstancesFunc func(*rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error)
+	DescribeDBClustersFunc  func(*rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error)
 }
 
+var _ rdsiface.RDSAPI = MockRDSClient{}
+
 func (m MockRDSClient) DescribeDBInstances(input *rds.DescribeDBInstancesInput) (*rds.DescribeDBInstancesOutput, error) {
 	if m.DescribeDBInstancesFunc != nil {
 		return m.DescribeDBInstancesFunc(input)
 	}
 	return nil, nil
 }
+
+func (m MockRDSClient) DescribeDBClusters(input *rds.DescribeDBClustersInput) (*rds.DescribeDBClustersOutput, error) {
+	if m.DescribeDBClustersFunc != nil {
+		return m.DescribeDBClustersFunc(input)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	GetItemWithContextFunc    func(context.Context, *dynamodb.GetItemInput, ...request.Option) (*dynamodb.GetItemOutput, error)
+	PutItemWithContextFunc    func(context.Context, *dynamodb.PutItemInput, ...request.Option) (*dynamodb.PutItemOutput, error)
+	DeleteItemWithContextFunc func(context.Context, *dynamodb.DeleteItemInput, ...request.Option) (*dynamodb.DeleteItemOutput, error)
+	UpdateItemFunc            func(*dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error)
+}
+
+var _ dynamodbiface.DynamoDBAPI = MockDynamoDB{}
+
+func (m MockDynamoDB) GetItemWithContext(ctx context.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if m.GetItemWithContextFunc != nil {
+		return m.GetItemWithContextFunc(ctx, input, opts...)
+	}
+	return nil, nil
+}
+
+func (m MockDynamoDB) PutItemWithContext(ctx context.Context, input *dynamodb.PutItemInput, opts ...request.Option) (*dynamodb.PutItemOutput, error) {
+	if m.PutItemWithContextFunc != nil {
+		return m.PutItemWithContextFunc(ctx, input, opts...)
+	}
+	return nil, nil
+}
+
+func (m MockDynamoDB) DeleteItemWithContext(ctx context.Context, input *dynamodb.DeleteItemInput, opts ...request.Option) (*dynamodb.DeleteItemOutput, error) {
+	if m.DeleteItemWithContextFunc != nil {
+		return m.DeleteItemWithContextFunc(ctx, input, opts...)
+	}
+	return nil, nil
+}
+
+func (m MockDynamoDB) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	if m.UpdateItemFunc != nil {
+		return m.UpdateItemFunc(input)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockKMS struct {
+	kmsiface.KMSAPI
+	GenerateRandomFunc func(*kms.GenerateRandomInput) (*kms.GenerateRandomOutput, error)
+	GenerateMacFunc    func(*kms.GenerateMacInput) (*kms.GenerateMacOutput, error)
+}
+
+var _ kmsiface.KMSAPI = MockKMS{}
+
+func (m MockKMS) GenerateRandom(input *kms.GenerateRandomInput) (*kms.GenerateRandomOutput, error) {
+	if m.GenerateRandomFunc != nil {
+		return m.GenerateRandomFunc(input)
+	}
+	return nil, nil
+}
+
+func (m MockKMS) GenerateMac(input *kms.GenerateMacInput) (*kms.GenerateMacOutput, error) {
+	if m.GenerateMacFunc != nil {
+		return m.GenerateMacFunc(input)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockCloudWatch struct {
+	cloudwatchiface.CloudWatchAPI
+	PutMetricDataFunc func(*cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+var _ cloudwatchiface.CloudWatchAPI = MockCloudWatch{}
+
+func (m MockCloudWatch) PutMetricData(input *cloudwatch.PutMetricDataInput) (*cloudwatch.PutMetricDataOutput, error) {
+	if m.PutMetricDataFunc != nil {
+		return m.PutMetricDataFunc(input)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockEventBridge struct {
+	eventbridgeiface.EventBridgeAPI
+	PutEventsFunc func(*eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error)
+}
+
+var _ eventbridgeiface.EventBridgeAPI = MockEventBridge{}
+
+func (m MockEventBridge) PutEvents(input *eventbridge.PutEventsInput) (*eventbridge.PutEventsOutput, error) {
+	if m.PutEventsFunc != nil {
+		return m.PutEventsFunc(input)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockSSM struct {
+	ssmiface.SSMAPI
+	PutParameterWithContextFunc func(context.Context, *ssm.PutParameterInput, ...request.Option) (*ssm.PutParameterOutput, error)
+}
+
+var _ ssmiface.SSMAPI = MockSSM{}
+
+func (m MockSSM) PutParameterWithContext(ctx context.Context, input *ssm.PutParameterInput, opts ...request.Option) (*ssm.PutParameterOutput, error) {
+	if m.PutParameterWithContextFunc != nil {
+		return m.PutParameterWithContextFunc(ctx, input, opts...)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockECS struct {
+	ecsiface.ECSAPI
+	UpdateServiceWithContextFunc func(context.Context, *ecs.UpdateServiceInput, ...request.Option) (*ecs.UpdateServiceOutput, error)
+}
+
+var _ ecsiface.ECSAPI = MockECS{}
+
+func (m MockECS) UpdateServiceWithContext(ctx context.Context, input *ecs.UpdateServiceInput, opts ...request.Option) (*ecs.UpdateServiceOutput, error) {
+	if m.UpdateServiceWithContextFunc != nil {
+		return m.UpdateServiceWithContextFunc(ctx, input, opts...)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockLambda struct {
+	lambdaiface.LambdaAPI
+	InvokeWithContextFunc func(context.Context, *lambda.InvokeInput, ...request.Option) (*lambda.InvokeOutput, error)
+}
+
+var _ lambdaiface.LambdaAPI = MockLambda{}
+
+func (m MockLambda) InvokeWithContext(ctx context.Context, input *lambda.InvokeInput, opts ...request.Option) (*lambda.InvokeOutput, error) {
+	if m.InvokeWithContextFunc != nil {
+		return m.InvokeWithContextFunc(ctx, input, opts...)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockS3 struct {
+	s3iface.S3API
+	PutObjectFunc func(*s3.PutObjectInput) (*s3.PutObjectOutput, error)
+}
+
+var _ s3iface.S3API = MockS3{}
+
+func (m MockS3) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if m.PutObjectFunc != nil {
+		return m.PutObjectFunc(input)
+	}
+	return nil, nil
+}
+
+// --------------------------------------------------------------------------
+
+type MockSTS struct {
+	stsiface.STSAPI
+	GetCallerIdentityRequestFunc func(*sts.GetCallerIdentityInput) (*request.Request, *sts.GetCallerIdentityOutput)
+}
+
+var _ stsiface.STSAPI = MockSTS{}
+
+func (m MockSTS) GetCallerIdentityRequest(input *sts.GetCallerIdentityInput) (*request.Request, *sts.GetCallerIdentityOutput) {
+	if m.GetCallerIdentityRequestFunc != nil {
+		return m.GetCallerIdentityRequestFunc(input)
+	}
+	return nil, nil
+}
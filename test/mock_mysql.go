@@ -3,26 +3,8 @@
 package test
 
 import (
-	"context"
-
-	"github.com/square/password-rotation-lambda/v2/db"
+	"github.com/square/password-rotation-lambda/v2/rotatetest"
 )
 
-type MockMySQLPasswordClient struct {
-	SetPasswordFunc    func(ctx context.Context, creds db.NewPassword) error
-	VerifyPasswordFunc func(ctx context.Context, creds db.NewPassword) error
-}
-
-func (m MockMySQLPasswordClient) SetPassword(ctx context.Context, creds db.NewPassword) error {
-	if m.SetPasswordFunc != nil {
-		return m.SetPasswordFunc(ctx, creds)
-	}
-	return nil
-}
-
-func (m MockMySQLPasswordClient) VerifyPassword(ctx context.Context, creds db.NewPassword) error {
-	if m.VerifyPasswordFunc != nil {
-		return m.VerifyPasswordFunc(ctx, creds)
-	}
-	return nil
-}
+// MockMySQLPasswordClient is rotatetest.MockPasswordClient.
+type MockMySQLPasswordClient = rotatetest.MockPasswordClient
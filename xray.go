@@ -0,0 +1,245 @@
+// Copyright 2026, Square, Inc.
+
+package rotate
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+
+	"github.com/square/password-rotation-lambda/v2/db"
+)
+
+// DEFAULT_XRAY_DAEMON_ADDR is the X-Ray daemon address if none is set and
+// env var AWS_XRAY_DAEMON_ADDRESS is not set.
+const DEFAULT_XRAY_DAEMON_ADDR = "127.0.0.1:2000"
+
+// XRay sends AWS X-Ray subsegments to the X-Ray daemon (which Lambda runs when
+// active tracing is enabled) for rotation steps, AWS API calls, and per-host
+// database actions, so a trace shows where the rotation spends its time.
+// Subsegments are children of the Lambda function segment from the Lambda
+// trace header, and are sent only if the trace is sampled. Wire it up in three
+// places:
+//
+//	x := rotate.NewXRay("")
+//	sess.Handlers.Complete.PushBackNamed(x.AWSHandler()) // AWS API calls
+//	ps := mysql.NewPasswordSetter(mysql.Config{..., Observer: x}) // per-host actions
+//	r := rotate.NewRotator(cfg)
+//	r.Use(x.Middleware()) // steps
+//
+// AWS API calls and host actions during a step are children of the step
+// subsegment. XRay traces one rotation step at a time, which is how Lambda
+// invokes the function. Send errors are logged and ignored.
+type XRay struct {
+	addr string
+	// --
+	mux   *sync.Mutex
+	conn  net.Conn
+	trace xrayTrace
+	step  string // current step subsegment ID, or "" if none
+}
+
+var _ db.HostObserver = &XRay{}
+
+// xrayTrace is the trace from the Lambda trace header, like
+// "Root=1-5759e988-bd862e3fe1be46a994272793;Parent=53995c3f42cd8ad8;Sampled=1".
+type xrayTrace struct {
+	root    string
+	parent  string
+	sampled bool
+}
+
+// xraySegment is an X-Ray subsegment document.
+type xraySegment struct {
+	Name        string                 `json:"name"`
+	Id          string                 `json:"id"`
+	TraceId     string                 `json:"trace_id"`
+	ParentId    string                 `json:"parent_id"`
+	Type        string                 `json:"type"`
+	StartTime   float64                `json:"start_time"`
+	EndTime     float64                `json:"end_time"`
+	Namespace   string                 `json:"namespace,omitempty"`
+	Error       bool                   `json:"error,omitempty"`
+	Annotations map[string]string      `json:"annotations,omitempty"`
+	AWS         map[string]interface{} `json:"aws,omitempty"`
+}
+
+// NewXRay creates a new XRay that sends to the X-Ray daemon at addr. If addr
+// is empty, env var AWS_XRAY_DAEMON_ADDRESS is used, which Lambda sets, else
+// DEFAULT_XRAY_DAEMON_ADDR.
+func NewXRay(addr string) *XRay {
+	if addr == "" {
+		addr = os.Getenv("AWS_XRAY_DAEMON_ADDRESS")
+	}
+	if addr == "" {
+		addr = DEFAULT_XRAY_DAEMON_ADDR
+	}
+	return &XRay{
+		addr: addr,
+		mux:  &sync.Mutex{},
+	}
+}
+
+// Middleware returns a Middleware that traces each step as a subsegment named
+// for the step, with annotation SecretId. Add it with Rotator.Use.
+func (x *XRay) Middleware() Middleware {
+	return func(next StepFunc) StepFunc {
+		return func(ctx context.Context, step string, event map[string]string) error {
+			trace := traceFromContext(ctx)
+			if !trace.sampled {
+				return next(ctx, step, event)
+			}
+			seg := xraySegment{
+				Name:        step,
+				Id:          xrayId(),
+				StartTime:   epoch(time.Now()),
+				Annotations: map[string]string{"SecretId": event["SecretId"]},
+			}
+			x.mux.Lock()
+			x.trace = trace
+			x.step = seg.Id
+			x.mux.Unlock()
+
+			err := next(ctx, step, event)
+
+			x.mux.Lock()
+			x.step = ""
+			x.mux.Unlock()
+			seg.EndTime = epoch(time.Now())
+			seg.Error = err != nil
+			x.send(seg, trace.parent)
+			return err
+		}
+	}
+}
+
+// AWSHandler returns an AWS SDK request handler that traces each AWS API call,
+// including retries, as a subsegment named for the service. Add it to the
+// session Complete handlers. Calls outside a traced step are not traced.
+func (x *XRay) AWSHandler() request.NamedHandler {
+	return request.NamedHandler{
+		Name: "rotate.XRay",
+		Fn: func(req *request.Request) {
+			seg := xraySegment{
+				Name:      req.ClientInfo.ServiceName,
+				Id:        xrayId(),
+				StartTime: epoch(req.Time),
+				EndTime:   epoch(time.Now()),
+				Namespace: "aws",
+				Error:     req.Error != nil,
+				AWS: map[string]interface{}{
+					"operation":  req.Operation.Name,
+					"region":     aws.StringValue(req.Config.Region),
+					"request_id": req.RequestID,
+					"retries":    req.RetryCount,
+				},
+			}
+			x.sendChild(seg)
+		},
+	}
+}
+
+// ObserveHost traces one password action on one database host as a subsegment
+// named for the host, with annotation Action.
+func (x *XRay) ObserveHost(hostname, action string, d time.Duration, err error) {
+	now := time.Now()
+	x.sendChild(xraySegment{
+		Name:        hostname,
+		Id:          xrayId(),
+		StartTime:   epoch(now.Add(-d)),
+		EndTime:     epoch(now),
+		Namespace:   "remote",
+		Error:       err != nil,
+		Annotations: map[string]string{"Action": action},
+	})
+}
+
+// sendChild sends the subsegment as a child of the current step subsegment,
+// if any.
+func (x *XRay) sendChild(seg xraySegment) {
+	x.mux.Lock()
+	parent, trace := x.step, x.trace
+	x.mux.Unlock()
+	if parent == "" {
+		return
+	}
+	seg.TraceId = trace.root
+	x.send(seg, parent)
+}
+
+func (x *XRay) send(seg xraySegment, parentId string) {
+	x.mux.Lock()
+	defer x.mux.Unlock()
+	if seg.TraceId == "" {
+		seg.TraceId = x.trace.root
+	}
+	seg.ParentId = parentId
+	seg.Type = "subsegment"
+	doc, err := json.Marshal(seg)
+	if err != nil {
+		log.Printf("ERROR: X-Ray: %s", err)
+		return
+	}
+	if x.conn == nil {
+		conn, err := net.Dial("udp", x.addr)
+		if err != nil {
+			log.Printf("ERROR: X-Ray daemon %s: %s", x.addr, err)
+			return
+		}
+		x.conn = conn
+	}
+	if _, err := x.conn.Write(append([]byte("{\"format\":\"json\",\"version\":1}\n"), doc...)); err != nil {
+		log.Printf("ERROR: X-Ray daemon %s: %s", x.addr, err)
+	}
+}
+
+// traceFromContext returns the trace from the Lambda trace header, which the
+// Lambda runtime sets in the context and env var _X_AMZN_TRACE_ID.
+func traceFromContext(ctx context.Context) xrayTrace {
+	header, _ := ctx.Value("x-amzn-trace-id").(string)
+	if header == "" {
+		header = os.Getenv("_X_AMZN_TRACE_ID")
+	}
+	var trace xrayTrace
+	for _, kv := range strings.Split(header, ";") {
+		f := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(f) != 2 {
+			continue
+		}
+		switch f[0] {
+		case "Root":
+			trace.root = f[1]
+		case "Parent":
+			trace.parent = f[1]
+		case "Sampled":
+			trace.sampled = f[1] == "1"
+		}
+	}
+	if trace.root == "" || trace.parent == "" {
+		trace.sampled = false
+	}
+	return trace
+}
+
+// xrayId returns a random 64-bit segment ID in hex.
+func xrayId() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// epoch returns t in seconds since the Unix epoch with microsecond precision,
+// which is the X-Ray time format.
+func epoch(t time.Time) float64 {
+	return float64(t.UnixNano()/1e3) / 1e6
+}
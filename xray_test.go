@@ -0,0 +1,90 @@
+// Copyright 2026, Square, Inc.
+
+package rotate_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/go-test/deep"
+
+	rotate "github.com/square/password-rotation-lambda/v2"
+)
+
+func TestXRay(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	x := rotate.NewXRay(pc.LocalAddr().String())
+
+	step := x.Middleware()(func(ctx context.Context, step string, event map[string]string) error {
+		req := request.New(aws.Config{Region: aws.String("us-east-1")}, metadata.ClientInfo{ServiceName: "secretsmanager"},
+			request.Handlers{}, nil, &request.Operation{Name: "GetSecretValue"}, nil, nil)
+		x.AWSHandler().Fn(req)
+		x.ObserveHost("db1", "setting", time.Millisecond, errors.New("fail"))
+		return nil
+	})
+
+	// Not sampled: nothing sent
+	ctx := context.WithValue(context.Background(), "x-amzn-trace-id", "Root=1-abc-def;Parent=p1;Sampled=0")
+	if err := step(ctx, "setSecret", map[string]string{"SecretId": "db"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sampled
+	ctx = context.WithValue(context.Background(), "x-amzn-trace-id", "Root=1-abc-def;Parent=p1;Sampled=1")
+	if err := step(ctx, "setSecret", map[string]string{"SecretId": "db"}); err != nil {
+		t.Fatal(err)
+	}
+
+	type segment struct {
+		Name        string
+		Id          string
+		TraceId     string `json:"trace_id"`
+		ParentId    string `json:"parent_id"`
+		Type        string
+		Namespace   string
+		Error       bool
+		Annotations map[string]string
+	}
+	segs := []segment{}
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 3; i++ {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := strings.SplitN(string(buf[:n]), "\n", 2)
+		if f[0] != `{"format":"json","version":1}` {
+			t.Errorf("got header %s", f[0])
+		}
+		var s segment
+		if err := json.Unmarshal([]byte(f[1]), &s); err != nil {
+			t.Fatal(err)
+		}
+		segs = append(segs, s)
+	}
+	stepId := segs[2].Id
+	for i := range segs {
+		segs[i].Id = ""
+	}
+	expect := []segment{
+		{Name: "secretsmanager", TraceId: "1-abc-def", ParentId: stepId, Type: "subsegment", Namespace: "aws"},
+		{Name: "db1", TraceId: "1-abc-def", ParentId: stepId, Type: "subsegment", Namespace: "remote", Error: true, Annotations: map[string]string{"Action": "setting"}},
+		{Name: "setSecret", TraceId: "1-abc-def", ParentId: "p1", Type: "subsegment", Annotations: map[string]string{"SecretId": "db"}},
+	}
+	if diff := deep.Equal(segs, expect); diff != nil {
+		t.Error(diff)
+	}
+}